@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/client"
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	apperrors "github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+)
+
+// wakeOnLANClient is implemented by router clients that can wake devices.
+// The demo data client cannot.
+type wakeOnLANClient interface {
+	WakeOnLAN(ctx context.Context, mac string) error
+}
+
+// wanAccessClient is implemented by router clients that can block devices
+type wanAccessClient interface {
+	SetWANAccess(ctx context.Context, mac string, allowed bool) error
+}
+
+// registerActions adds the enabled action endpoints to mux. Disabled actions
+// are not registered and answer 404.
+func registerActions(mux *http.ServeMux, cfg config.ActionsConfig, routerClient client.RouterClient) {
+	if cfg.WakeOnLAN {
+		mux.Handle("/api/v1/wol", guardAction(cfg.Token, wakeOnLANHandler(routerClient)))
+		logger.Default.Warnf("Wake-on-LAN action enabled on /api/v1/wol")
+	}
+	if cfg.BlockDevices {
+		mux.Handle("/api/v1/devices/block", guardAction(cfg.Token, wanAccessHandler(routerClient, false)))
+		mux.Handle("/api/v1/devices/unblock", guardAction(cfg.Token, wanAccessHandler(routerClient, true)))
+		logger.Default.Warnf("Device block actions enabled on /api/v1/devices/block and /api/v1/devices/unblock")
+	}
+}
+
+// guardAction only passes POST requests carrying token as bearer token to
+// next. Actions change the network, so unlike the read-only endpoints they
+// are not left to the listener's optional authentication alone.
+func guardAction(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="miwifi-exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wakeOnLANHandler wakes the device given by the mac query parameter through
+// the router
+func wakeOnLANHandler(routerClient client.RouterClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		waker, ok := routerClient.(wakeOnLANClient)
+		if !ok {
+			http.Error(w, "Wake-on-LAN is not supported by this router client", http.StatusNotImplemented)
+			return
+		}
+
+		address, ok := macParam(w, r)
+		if !ok {
+			return
+		}
+
+		if err := waker.WakeOnLAN(r.Context(), address); err != nil {
+			err = apperrors.RedactError(err)
+			logger.Default.Errorf("Wake-on-LAN of %s requested by %s failed: %v", address, r.RemoteAddr, err)
+			http.Error(w, "Wake-on-LAN failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		logger.Default.Infof("Sent Wake-on-LAN packet to %s as requested by %s", address, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"mac": address, "status": "sent"})
+	})
+}
+
+// wanAccessHandler allows or blocks internet access of the device given by
+// the mac query parameter
+func wanAccessHandler(routerClient client.RouterClient, allowed bool) http.Handler {
+	action := "block"
+	if allowed {
+		action = "unblock"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		blocker, ok := routerClient.(wanAccessClient)
+		if !ok {
+			http.Error(w, "Blocking devices is not supported by this router client", http.StatusNotImplemented)
+			return
+		}
+
+		address, ok := macParam(w, r)
+		if !ok {
+			return
+		}
+
+		if err := blocker.SetWANAccess(r.Context(), address, allowed); err != nil {
+			err = apperrors.RedactError(err)
+			logger.Default.Errorf("Failed to %s %s as requested by %s: %v", action, address, r.RemoteAddr, err)
+			http.Error(w, "Failed to "+action+" device: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		logger.Default.Infof("Device %s %sed as requested by %s", address, action, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"mac": address, "status": action + "ed"})
+	})
+}
+
+// macParam returns the mac query parameter in the upper case form the router
+// uses. It answers 400 and returns false when the parameter is not a MAC
+// address.
+func macParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	mac, err := net.ParseMAC(r.URL.Query().Get("mac"))
+	if err != nil || len(mac) != 6 {
+		http.Error(w, "Parameter mac must be a MAC address such as AA:BB:CC:DD:EE:FF", http.StatusBadRequest)
+		return "", false
+	}
+	return strings.ToUpper(mac.String()), true
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/client"
+	"github.com/helloworlde/miwifi-exporter/internal/collector"
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// routerInfoClient is implemented by router clients that know the router
+// model and firmware from init_info
+type routerInfoClient interface {
+	InitInfo() *models.InitInfo
+}
+
+// logStartupBanner logs the effective configuration with secrets masked and
+// the enabled collectors, the first things to look at when nothing is
+// exported
+func logStartupBanner(cfg *config.Config, metricsCollector *collector.MetricsCollector) {
+	if effective, err := json.Marshal(cfg.Effective()); err == nil {
+		logger.Default.Infof("Effective configuration: %s", effective)
+	}
+
+	enabled := metricsCollector.EnabledCollectors()
+	if len(enabled) == 0 {
+		logger.Default.Warn("No collectors enabled, only exporter metrics will be exported; check COLLECTORS_ENABLED and COLLECTORS_DISABLED")
+	} else {
+		logger.Default.Infof("Enabled collectors: %s", strings.Join(enabled, ", "))
+	}
+}
+
+// logRouterInfo logs the router model and firmware once they are known from
+// the login
+func logRouterInfo(routerClient client.RouterClient) {
+	infoClient, ok := routerClient.(routerInfoClient)
+	if !ok {
+		return
+	}
+	info := infoClient.InitInfo()
+	if info == nil {
+		return
+	}
+	logger.Default.Infof("Router %q: model %s, firmware %s", info.RouterName, info.Hardware, info.RomVersion)
+}
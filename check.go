@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/client"
+	"github.com/helloworlde/miwifi-exporter/internal/collector"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// endpointCheck describes a single router API probed by the check command
+type endpointCheck struct {
+	name     string
+	optional bool
+	fetch    func(ctx context.Context) error
+}
+
+// runCheck validates configuration, authenticates against the router and
+// calls every endpoint once. It returns the process exit code.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfiguration(*configFile)
+	if err != nil {
+		fmt.Printf("[FAIL] configuration: %v\n", err)
+		return 1
+	}
+	fmt.Printf("[ OK ] configuration: router %s, namespace %s\n", cfg.Router.IP, cfg.Server.Namespace)
+
+	logger.Init(cfg.Logging.Level, cfg.Logging.Format)
+
+	timeout := cfg.Router.TimeoutOr(cfg.Router.RequestTimeout)
+	routerClient := client.NewMiWiFiClient(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Router.TimeoutOr(cfg.Router.AuthTimeout))
+	start := time.Now()
+	err = routerClient.Authenticate(ctx)
+	cancel()
+	if err != nil {
+		fmt.Printf("[FAIL] authentication: %v\n", err)
+		return 1
+	}
+	fmt.Printf("[ OK ] authentication (%v)\n", time.Since(start).Round(time.Millisecond))
+
+	recorded := &recordedClient{}
+	checks := []endpointCheck{
+		{name: "misystem/status", fetch: func(ctx context.Context) (err error) {
+			recorded.status, err = routerClient.GetSystemStatus(ctx)
+			return err
+		}},
+		{name: "misystem/devicelist", fetch: func(ctx context.Context) (err error) {
+			recorded.devices, err = routerClient.GetDeviceList(ctx)
+			return err
+		}},
+		{name: "xqnetwork/wan_info", fetch: func(ctx context.Context) (err error) {
+			recorded.wan, err = routerClient.GetWanInfo(ctx)
+			return err
+		}},
+		{name: "xqnetwork/wifi_detail_all", fetch: func(ctx context.Context) (err error) {
+			recorded.wifi, err = routerClient.GetWifiDetails(ctx)
+			return err
+		}},
+		{name: "xqdisk/disk_info", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.disk, err = routerClient.GetDiskStatus(ctx)
+			return err
+		}},
+		{name: "xqsystem/samba_status", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.samba, err = routerClient.GetSambaStatus(ctx)
+			return err
+		}},
+		{name: "misystem/sys_info", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.sysInfo, err = routerClient.GetSysInfo(ctx)
+			return err
+		}},
+		{name: "xqnetwork/port_status", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.ports, err = routerClient.GetPortStatus(ctx)
+			return err
+		}},
+		{name: "xqnetwork/wps_status", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.wpsStatus, err = routerClient.GetWPSStatus(ctx)
+			return err
+		}},
+		{name: "misystem/topo_graph", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.topoGraph, err = routerClient.GetTopoGraph(ctx)
+			return err
+		}},
+		{name: "xqnetwork/wifi_statistics", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.wifiStatistics, err = routerClient.GetWifiStatistics(ctx)
+			return err
+		}},
+		{name: "xqnetwork/iptv", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.iptvStatus, err = routerClient.GetIPTVStatus(ctx)
+			return err
+		}},
+		{name: "xqsystem/upnp", optional: true, fetch: func(ctx context.Context) (err error) {
+			recorded.upnpStatus, err = routerClient.GetUPnPStatus(ctx)
+			return err
+		}},
+	}
+
+	failed := false
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		err := check.fetch(ctx)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		cancel()
+
+		switch {
+		case err == nil:
+			fmt.Printf("[ OK ] %s (%v)\n", check.name, elapsed)
+		case check.optional:
+			fmt.Printf("[WARN] %s: %v (optional, ignored)\n", check.name, err)
+		default:
+			fmt.Printf("[FAIL] %s: %v\n", check.name, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return 1
+	}
+
+	// Replay the recorded responses through the collector to list the metrics
+	checkCfg := *cfg
+	checkCfg.Cache.Enabled = false
+	metricsCollector := collector.NewMetricsCollector(&checkCfg)
+	metricsCollector.SetClient(recorded)
+	defer metricsCollector.Close()
+
+	families, err := metricsCollector.Gatherer(context.Background()).Gather()
+	if err != nil {
+		fmt.Printf("[FAIL] metrics: %v\n", err)
+		return 1
+	}
+
+	counts := make(map[string]int, len(families))
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		counts[family.GetName()] = len(family.GetMetric())
+		names = append(names, family.GetName())
+	}
+	sort.Strings(names)
+
+	fmt.Printf("[ OK ] metrics: %d families would be exported\n", len(names))
+	for _, name := range names {
+		fmt.Printf("       %-50s %d series\n", name, counts[name])
+	}
+
+	return 0
+}
+
+// recordedClient serves responses captured during the endpoint checks so the
+// router is not queried a second time
+type recordedClient struct {
+	status         *models.SystemStatus
+	devices        *models.DeviceList
+	wan            *models.WanInfo
+	wifi           *models.WifiDetailAll
+	disk           *models.DiskStatus
+	samba          *models.SambaStatus
+	sysInfo        *models.SysInfo
+	ports          *models.PortStatus
+	wpsStatus      *models.WPSStatus
+	topoGraph      *models.TopoGraph
+	wifiStatistics *models.WifiStatistics
+	iptvStatus     *models.IPTVStatus
+	upnpStatus     *models.UPnPStatus
+}
+
+func (r *recordedClient) GetSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
+	return r.status, nil
+}
+
+func (r *recordedClient) GetDeviceList(ctx context.Context) (*models.DeviceList, error) {
+	return r.devices, nil
+}
+
+func (r *recordedClient) GetWanInfo(ctx context.Context) (*models.WanInfo, error) {
+	return r.wan, nil
+}
+
+func (r *recordedClient) GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error) {
+	return r.wifi, nil
+}
+
+func (r *recordedClient) GetDiskStatus(ctx context.Context) (*models.DiskStatus, error) {
+	if r.disk == nil {
+		return nil, fmt.Errorf("disk status not available")
+	}
+	return r.disk, nil
+}
+
+func (r *recordedClient) GetSambaStatus(ctx context.Context) (*models.SambaStatus, error) {
+	if r.samba == nil {
+		return nil, fmt.Errorf("samba status not available")
+	}
+	return r.samba, nil
+}
+
+func (r *recordedClient) GetSysInfo(ctx context.Context) (*models.SysInfo, error) {
+	if r.sysInfo == nil {
+		return nil, fmt.Errorf("system info not available")
+	}
+	return r.sysInfo, nil
+}
+
+func (r *recordedClient) GetPortStatus(ctx context.Context) (*models.PortStatus, error) {
+	if r.ports == nil {
+		return nil, fmt.Errorf("port status not available")
+	}
+	return r.ports, nil
+}
+
+func (r *recordedClient) GetWPSStatus(ctx context.Context) (*models.WPSStatus, error) {
+	if r.wpsStatus == nil {
+		return nil, fmt.Errorf("WPS status not available")
+	}
+	return r.wpsStatus, nil
+}
+
+func (r *recordedClient) GetTopoGraph(ctx context.Context) (*models.TopoGraph, error) {
+	if r.topoGraph == nil {
+		return nil, fmt.Errorf("mesh topology not available")
+	}
+	return r.topoGraph, nil
+}
+
+func (r *recordedClient) GetWifiStatistics(ctx context.Context) (*models.WifiStatistics, error) {
+	if r.wifiStatistics == nil {
+		return nil, fmt.Errorf("WiFi radio statistics not available")
+	}
+	return r.wifiStatistics, nil
+}
+
+func (r *recordedClient) GetIPTVStatus(ctx context.Context) (*models.IPTVStatus, error) {
+	if r.iptvStatus == nil {
+		return nil, fmt.Errorf("IPTV status not available")
+	}
+	return r.iptvStatus, nil
+}
+
+func (r *recordedClient) GetUPnPStatus(ctx context.Context) (*models.UPnPStatus, error) {
+	if r.upnpStatus == nil {
+		return nil, fmt.Errorf("UPnP mappings not available")
+	}
+	return r.upnpStatus, nil
+}
+
+func (r *recordedClient) Authenticate(ctx context.Context) error {
+	return nil
+}
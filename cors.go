@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+)
+
+// apiPrefix is the path of the JSON endpoints browsers may call cross-origin
+const apiPrefix = "/api/"
+
+// corsMaxAge is how long browsers may cache a preflight answer, in seconds
+const corsMaxAge = "600"
+
+// withSecurityHeaders adds standard security headers to every response and
+// answers cross-origin requests to the JSON endpoints under /api/ from the
+// configured origins. Headers set in the web configuration file take
+// precedence.
+func withSecurityHeaders(cfg config.ServerConfig, next http.Handler) http.Handler {
+	if !cfg.SecurityHeaders && len(cfg.CORSOrigins) == 0 {
+		return next
+	}
+
+	anyOrigin := slices.Contains(cfg.CORSOrigins, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api := strings.HasPrefix(r.URL.Path, apiPrefix)
+		if cfg.SecurityHeaders {
+			setDefaultHeader(w, "X-Content-Type-Options", "nosniff")
+			setDefaultHeader(w, "X-Frame-Options", "DENY")
+			setDefaultHeader(w, "Referrer-Policy", "no-referrer")
+			if api {
+				// JSON is never rendered or cached by the browser
+				setDefaultHeader(w, "Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+				setDefaultHeader(w, "Cache-Control", "no-store")
+			}
+		}
+
+		origin := r.Header.Get("Origin")
+		if !api || origin == "" || len(cfg.CORSOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := anyOrigin || slices.Contains(cfg.CORSOrigins, origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		w.Header().Add("Vary", "Origin")
+		if !allowed {
+			if preflight {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			// Without the CORS headers the browser hides the answer
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if !preflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST")
+		// Actions take their token as bearer token
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// setDefaultHeader sets a response header unless it is already set
+func setDefaultHeader(w http.ResponseWriter, key, value string) {
+	if w.Header().Get(key) == "" {
+		w.Header().Set(key, value)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+)
+
+// newDebugMux returns the pprof and expvar handlers. They are kept off the
+// metrics listener, which may be reachable from the whole network.
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// startDebugServer serves the debug endpoints on address until ctx is
+// cancelled
+func startDebugServer(ctx context.Context, address string) {
+	server := &http.Server{
+		Addr:    address,
+		Handler: newDebugMux(),
+		// Profiles and traces stream for their requested duration
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logger.Default.Warnf("Debug endpoints enabled on http://%s/debug/pprof/ and /debug/vars", address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Default.Errorf("Debug server failed: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+}
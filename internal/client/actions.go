@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+)
+
+// actionResponse is the answer of router APIs that change something rather
+// than report it
+type actionResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// WakeOnLAN asks the router to send a Wake-on-LAN magic packet to the device
+// with mac on the LAN
+func (c *MiWiFiClient) WakeOnLAN(ctx context.Context, mac string) error {
+	return c.withSession(ctx, func() error {
+		return c.postAction(ctx, "xqnetwork/wol", url.Values{"mac": {mac}})
+	})
+}
+
+// SetWANAccess allows or blocks internet access of the device with mac. The
+// device stays connected to the LAN either way.
+func (c *MiWiFiClient) SetWANAccess(ctx context.Context, mac string, allowed bool) error {
+	wan := "0"
+	if allowed {
+		wan = "1"
+	}
+	return c.withSession(ctx, func() error {
+		return c.postAction(ctx, "xqsystem/set_mac_filter", url.Values{"mac": {mac}, "wan": {wan}})
+	})
+}
+
+// postAction posts form to the router API endpoint and checks the answer.
+// Unlike fetches, actions are only repeated after an expired session.
+func (c *MiWiFiClient) postAction(ctx context.Context, endpoint string, form url.Values) error {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/%s",
+		c.config.Router.IP, token, endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.NewInternalError("failed to create request", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.NewNetworkError("failed to call "+endpoint, err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, endpoint, token); err != nil {
+		return err
+	}
+
+	var result actionResponse
+	if err := c.decodeResponse(resp, &result); err != nil {
+		return decodeError(endpoint+" response", err)
+	}
+	return c.checkCode(endpoint, result.Code, result.Msg, token)
+}
@@ -0,0 +1,309 @@
+package client
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AuthState describes the router session of a client
+type AuthState int
+
+const (
+	// AuthStateUnauthenticated means no login was attempted yet or the
+	// router rejected the token
+	AuthStateUnauthenticated AuthState = iota
+	// AuthStateAuthenticated means the client holds a token
+	AuthStateAuthenticated
+	// AuthStateFailed means the last login failed and the next one waits
+	// for the backoff to pass
+	AuthStateFailed
+)
+
+// AuthStates lists every state, in the order used for metrics
+var AuthStates = []AuthState{AuthStateUnauthenticated, AuthStateAuthenticated, AuthStateFailed}
+
+func (s AuthState) String() string {
+	switch s {
+	case AuthStateAuthenticated:
+		return "authenticated"
+	case AuthStateFailed:
+		return "failed"
+	default:
+		return "unauthenticated"
+	}
+}
+
+const (
+	// minAuthBackoff is the delay after the first failed login, doubled on
+	// every further failure up to maxAuthBackoff
+	minAuthBackoff = 5 * time.Second
+	maxAuthBackoff = 5 * time.Minute
+	// sessionCheckInterval is how often MaintainSession looks at a healthy
+	// session
+	sessionCheckInterval = 10 * time.Second
+	// tokenExpiredCode is the code the router answers for an unknown stok
+	tokenExpiredCode = 401
+)
+
+var (
+	// errLoginRejected marks a login the router answered without a token,
+	// usually because of a wrong password
+	errLoginRejected = stderrors.New("login rejected")
+	// errLoginLocked marks a login refused because of too many attempts
+	errLoginLocked = stderrors.New("login locked")
+	// errLoginThrottled marks a login not sent because of LoginsPerMinute
+	errLoginThrottled = stderrors.New("login throttled")
+)
+
+// Reasons of failed logins counted by AuthFailures
+const (
+	AuthFailureRejected  = "rejected"
+	AuthFailureLocked    = "locked"
+	AuthFailureThrottled = "throttled"
+	AuthFailureError     = "error"
+)
+
+// AuthFailureReasons lists every reason, in the order used for metrics
+var AuthFailureReasons = []string{AuthFailureRejected, AuthFailureLocked, AuthFailureThrottled, AuthFailureError}
+
+// lockoutMessages are parts of the messages routers answer once they stop
+// accepting logins
+var lockoutMessages = []string{"too many", "frequent", "locked"}
+
+// isLockoutMessage reports whether the msg of a login response says that
+// the router stopped accepting logins
+func isLockoutMessage(msg interface{}) bool {
+	text, ok := msg.(string)
+	if !ok {
+		return false
+	}
+	text = strings.ToLower(text)
+	for _, part := range lockoutMessages {
+		if strings.Contains(text, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// authFailureReason classifies a failed login
+func authFailureReason(err error) string {
+	switch {
+	case stderrors.Is(err, errLoginThrottled):
+		return AuthFailureThrottled
+	case stderrors.Is(err, errLoginLocked):
+		return AuthFailureLocked
+	case stderrors.Is(err, errLoginRejected):
+		return AuthFailureRejected
+	default:
+		return AuthFailureError
+	}
+}
+
+// AuthFailures returns the number of failed logins by reason since start
+func (c *MiWiFiClient) AuthFailures() map[string]uint64 {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	failures := make(map[string]uint64, len(AuthFailureReasons))
+	for _, reason := range AuthFailureReasons {
+		failures[reason] = c.authFailureCounts[reason]
+	}
+	return failures
+}
+
+// takeLoginAttempt records a login request about to be sent. It fails once
+// LoginsPerMinute requests were sent within the last minute, so a wrong
+// password cannot turn into a login storm. c.authMu must be held.
+func (c *MiWiFiClient) takeLoginAttempt(now time.Time) error {
+	cutoff := now.Add(-time.Minute)
+	recent := c.loginAttempts[:0]
+	for _, attempt := range c.loginAttempts {
+		if attempt.After(cutoff) {
+			recent = append(recent, attempt)
+		}
+	}
+	c.loginAttempts = recent
+
+	if limit := c.config.Router.LoginsPerMinute; limit > 0 && len(recent) >= limit {
+		return errors.NewAuthenticationError(fmt.Sprintf("login limit of %d per minute reached", limit), errLoginThrottled)
+	}
+	c.loginAttempts = append(c.loginAttempts, now)
+	return nil
+}
+
+// AuthState returns the current state of the router session
+func (c *MiWiFiClient) AuthState() AuthState {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	switch {
+	case c.auth != nil:
+		return AuthStateAuthenticated
+	case c.authFailures > 0:
+		return AuthStateFailed
+	default:
+		return AuthStateUnauthenticated
+	}
+}
+
+// ensureAuthenticated logs in unless the client already holds a token. While
+// the login backoff runs it fails without contacting the router.
+func (c *MiWiFiClient) ensureAuthenticated(ctx context.Context) error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.auth != nil {
+		return nil
+	}
+	if wait := time.Until(c.nextAuthAttempt); wait > 0 {
+		return errors.NewAuthenticationError(
+			fmt.Sprintf("login suspended for %v after %d failed attempts", wait.Round(time.Second), c.authFailures),
+			c.lastAuthError,
+		)
+	}
+	return c.authenticateLocked(ctx)
+}
+
+// authenticateLocked logs in and updates the backoff. c.authMu must be held.
+func (c *MiWiFiClient) authenticateLocked(ctx context.Context) error {
+	// A login gets its own deadline, so a hanging login does not use up the
+	// whole collection that needed it
+	ctx, cancel := context.WithTimeout(ctx, c.config.Router.TimeoutOr(c.config.Router.AuthTimeout))
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "router.login")
+	defer span.End()
+
+	attempts := 0
+	err := c.retry.WithRetry(ctx, func() error {
+		attempts++
+		return c.doAuthenticate(ctx)
+	})
+	span.SetAttributes(attribute.Int("router.retries", attempts-1))
+	tracing.RecordError(span, err)
+	if err != nil {
+		// A cancelled scrape says nothing about the router
+		if ctx.Err() != nil {
+			return err
+		}
+
+		reason := authFailureReason(err)
+		if c.authFailureCounts == nil {
+			c.authFailureCounts = make(map[string]uint64)
+		}
+		c.authFailureCounts[reason]++
+
+		c.authFailures++
+		c.lastAuthError = err
+		backoff := maxAuthBackoff
+		if c.authFailures <= 10 {
+			backoff = min(minAuthBackoff<<(c.authFailures-1), maxAuthBackoff)
+		}
+		switch reason {
+		case AuthFailureLocked:
+			// Every further attempt may extend the lockout
+			backoff = max(backoff, c.config.Router.LockoutBackoff)
+		case AuthFailureThrottled:
+			// Wait until the oldest attempt leaves the one-minute window
+			if len(c.loginAttempts) > 0 {
+				backoff = max(backoff, time.Until(c.loginAttempts[0].Add(time.Minute)).Round(time.Second))
+			}
+		}
+		c.nextAuthAttempt = time.Now().Add(backoff)
+		logger.Default.Warnf("Router login failed %d time(s), next attempt in %v", c.authFailures, backoff)
+		return err
+	}
+
+	if c.authFailures > 0 {
+		logger.Default.Infof("Router login recovered after %d failed attempt(s)", c.authFailures)
+	}
+	c.authFailures = 0
+	c.lastAuthError = nil
+	c.nextAuthAttempt = time.Time{}
+	return nil
+}
+
+// withSession runs fn once the client is logged in. When the router rejects
+// the token, fn runs again after a fresh login. Other failures are returned
+// as they are, retrying is up to the caller, see concurrent.DataFetcher.
+func (c *MiWiFiClient) withSession(ctx context.Context, fn func() error) error {
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	err := fn()
+	if !errors.IsAuthenticationError(err) {
+		return err
+	}
+
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// token returns the current session token, empty when not authenticated
+func (c *MiWiFiClient) token() string {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.auth == nil {
+		return ""
+	}
+	return c.auth.Token
+}
+
+// invalidateToken drops the session if it still uses token, so the next
+// request logs in again. A newer token obtained meanwhile is kept.
+func (c *MiWiFiClient) invalidateToken(token string) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.auth != nil && c.auth.Token == token {
+		c.auth = nil
+	}
+}
+
+// MaintainSession logs in again in the background whenever the session is
+// lost, honouring the login backoff, until ctx is cancelled. Scrapes then
+// find a valid session once the router is reachable again.
+func (c *MiWiFiClient) MaintainSession(ctx context.Context) {
+	timer := time.NewTimer(c.untilNextSessionCheck())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if c.AuthState() != AuthStateAuthenticated {
+			if err := c.ensureAuthenticated(ctx); err != nil {
+				logger.Default.Debugf("Background router login failed: %v", err)
+			}
+		}
+
+		timer.Reset(c.untilNextSessionCheck())
+	}
+}
+
+// untilNextSessionCheck returns when MaintainSession should look at the
+// session again
+func (c *MiWiFiClient) untilNextSessionCheck() time.Duration {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.auth == nil {
+		return max(time.Until(c.nextAuthAttempt), 0)
+	}
+	return sessionCheckInterval
+}
@@ -5,7 +5,7 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +14,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/helloworlde/miwifi-exporter/internal/config"
@@ -28,6 +30,15 @@ type RouterClient interface {
 	GetDeviceList(ctx context.Context) (*models.DeviceList, error)
 	GetWanInfo(ctx context.Context) (*models.WanInfo, error)
 	GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error)
+	GetDiskStatus(ctx context.Context) (*models.DiskStatus, error)
+	GetSambaStatus(ctx context.Context) (*models.SambaStatus, error)
+	GetSysInfo(ctx context.Context) (*models.SysInfo, error)
+	GetPortStatus(ctx context.Context) (*models.PortStatus, error)
+	GetWPSStatus(ctx context.Context) (*models.WPSStatus, error)
+	GetTopoGraph(ctx context.Context) (*models.TopoGraph, error)
+	GetWifiStatistics(ctx context.Context) (*models.WifiStatistics, error)
+	GetIPTVStatus(ctx context.Context) (*models.IPTVStatus, error)
+	GetUPnPStatus(ctx context.Context) (*models.UPnPStatus, error)
 	Authenticate(ctx context.Context) error
 }
 
@@ -36,6 +47,20 @@ type MiWiFiClient struct {
 	httpClient *http.Client
 	auth       *models.Auth
 	retry      *errors.RetryHandler
+	// authMu serializes logins and guards auth and the login backoff
+	authMu          sync.Mutex
+	authFailures    int
+	lastAuthError   error
+	nextAuthAttempt time.Time
+	// initInfo is the init_info answered during the last login
+	initInfo atomic.Pointer[models.InitInfo]
+	// loginVariant is the nonce and hash combination the router accepted
+	// last, guarded by authMu
+	loginVariant *loginVariant
+	// loginAttempts holds the login requests sent within the last minute
+	// and authFailureCounts the failed logins by reason, guarded by authMu
+	loginAttempts     []time.Time
+	authFailureCounts map[string]uint64
 }
 
 func NewMiWiFiClient(cfg *config.Config) *MiWiFiClient {
@@ -46,32 +71,95 @@ func NewMiWiFiClient(cfg *config.Config) *MiWiFiClient {
 		MaxIdleConns:        50,
 		MaxIdleConnsPerHost: 10,
 		IdleConnTimeout:     90 * time.Second,
-		Timeout:             time.Duration(cfg.Router.Timeout) * time.Second,
+		Timeout:             cfg.Router.TimeoutOr(cfg.Router.RequestTimeout),
 		TLSHandshakeTimeout: 10 * time.Second,
 		DisableKeepAlives:   false,
 		MaxConnsPerHost:     30,
 		DisableCompression:  false,
 	}
+	if cfg.Router.ProxyURL != "" {
+		// The URL is checked by the config validation
+		if proxyURL, err := url.Parse(cfg.Router.ProxyURL); err == nil {
+			httpCfg.ProxyURL = proxyURL
+		}
+	}
 	
 	optimizedClient := httputil.NewOptimizedClient(httpCfg)
 	optimizedClient.Jar = jar
+	optimizedClient.Transport = httputil.NewTracingTransport(optimizedClient.Transport)
 	
-	return &MiWiFiClient{
+	c := &MiWiFiClient{
 		config:     cfg,
 		httpClient: optimizedClient,
 		retry:      errors.NewRetryHandler(3, 30*time.Second, logger.Default),
 	}
+	
+	// Resume the session of the previous run instead of logging in again
+	if cfg.Router.SessionFile != "" {
+		if err := c.restoreSession(); err != nil {
+			logger.Default.Warnf("Failed to restore router session: %v", err)
+		}
+	}
+	
+	return c
 }
 
-func (c *MiWiFiClient) Authenticate(ctx context.Context) error {
-	return c.retry.WithRetry(func() error {
-		return c.doAuthenticate(ctx)
+// InitInfo returns the model and firmware reported by the router during the
+// last login, nil before the first login reached the router
+func (c *MiWiFiClient) InitInfo() *models.InitInfo {
+	return c.initInfo.Load()
+}
+
+// NewReplayClient creates a client answering every API call from responses
+// recorded with RecordResponses. No authentication is performed, and the
+// session of a live exporter sharing the session file is neither resumed nor
+// overwritten.
+func NewReplayClient(cfg *config.Config, dir string) *MiWiFiClient {
+	replayCfg := *cfg
+	replayCfg.Router.SessionFile = ""
+	c := NewMiWiFiClient(&replayCfg)
+	c.httpClient.Transport = httputil.NewReplayTransport(dir)
+	c.auth = &models.Auth{Token: "replay", Code: 200}
+	return c
+}
+
+// RecordResponses saves every raw router API response to dir with passwords
+// and tokens scrubbed
+func (c *MiWiFiClient) RecordResponses(dir string) error {
+	transport, err := httputil.NewRecordingTransport(c.httpClient.Transport, dir)
+	if err != nil {
+		return err
+	}
+	c.httpClient.Transport = transport
+	return nil
+}
+
+// LogCurlCommands logs every router API request as an equivalent curl
+// command, with the stok token, passwords and the login nonce masked
+func (c *MiWiFiClient) LogCurlCommands() {
+	c.httpClient.Transport = httputil.NewCurlTransport(c.httpClient.Transport, func(command string) {
+		logger.Default.Infof("%s", errors.Redact(command))
 	})
 }
 
+// InstrumentTransport records latency and size of every router API request
+// into collector, labelled by endpoint
+func (c *MiWiFiClient) InstrumentTransport(collector httputil.MetricsCollector) {
+	c.httpClient.Transport = httputil.NewMetricsTransport(c.httpClient.Transport, collector)
+}
+
+// Authenticate logs in to the router, ignoring any login backoff
+func (c *MiWiFiClient) Authenticate(ctx context.Context) error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	return c.authenticateLocked(ctx)
+}
+
 func (c *MiWiFiClient) doAuthenticate(ctx context.Context) error {
 	router := &models.Router{
 		IP:       c.config.Router.IP,
+		Username: c.config.Router.Username,
 		Password: c.config.Router.Password,
 		Headers: map[string]string{
 			"Connection": "keep-alive",
@@ -90,6 +178,12 @@ func (c *MiWiFiClient) doAuthenticate(ctx context.Context) error {
 	}
 
 	logger.Default.Info("Router authentication successful")
+	
+	if c.config.Router.SessionFile != "" {
+		if err := c.saveSession(); err != nil {
+			logger.Default.Warnf("Failed to persist router session: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -125,6 +219,9 @@ func (c *MiWiFiClient) getInitialPage(ctx context.Context, router *models.Router
 		return errors.NewNetworkError("failed to get initial page", err)
 	}
 	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "web", ""); err != nil {
+		return err
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -186,12 +283,17 @@ func (c *MiWiFiClient) getInitInfo(ctx context.Context, router *models.Router) e
 		return errors.NewNetworkError("failed to get init info", err)
 	}
 	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqsystem/init_info", ""); err != nil {
+		return err
+	}
 
 	var initInfo models.InitInfo
-	if err := json.NewDecoder(resp.Body).Decode(&initInfo); err != nil {
-		return errors.NewInternalError("failed to decode init info", err)
+	if err := c.decodeResponse(resp, &initInfo); err != nil {
+		return decodeError("init info", err)
 	}
 
+	c.initInfo.Store(&initInfo)
+
 	// Store init info
 	router.Data["hardware"] = initInfo.Hardware
 	router.Data["rom_version"] = initInfo.RomVersion
@@ -203,13 +305,53 @@ func (c *MiWiFiClient) getInitInfo(ctx context.Context, router *models.Router) e
 }
 
 func (c *MiWiFiClient) doLogin(ctx context.Context, router *models.Router) error {
+	variants := loginVariants(c.config.Router.NonceType, router.Data["new_encrypt_mode"] == "1", c.config.Router.LoginFallback)
+	// Start with the variant the router accepted last time
+	if c.loginVariant != nil {
+		for i, variant := range variants {
+			if variant == *c.loginVariant {
+				variants[0], variants[i] = variants[i], variants[0]
+				break
+			}
+		}
+	}
+
+	var err error
+	for i, variant := range variants {
+		if throttled := c.takeLoginAttempt(time.Now()); throttled != nil {
+			// Report why the earlier variants failed rather than the limit
+			if err != nil {
+				return err
+			}
+			return throttled
+		}
+		err = c.postLogin(ctx, router, variant)
+		if err == nil {
+			if i > 0 {
+				logger.Default.Infof("Router accepted the login with %s", variant)
+			}
+			c.loginVariant = &variant
+			return nil
+		}
+		// Only a rejected login is worth another variant, a locked one
+		// would only extend the lockout
+		if !stderrors.Is(err, errLoginRejected) {
+			return err
+		}
+		logger.Default.Debugf("Router rejected the login with %s: %v", variant, err)
+	}
+	return err
+}
+
+// postLogin sends one login request built with variant
+func (c *MiWiFiClient) postLogin(ctx context.Context, router *models.Router, variant loginVariant) error {
 	pwd := router.Password
 	key := router.Data["key"]
 	deviceID := router.Data["device_id"]
-	nonce := fmt.Sprintf("0_%s_%d_962", deviceID, time.Now().Unix())
+	nonce := buildNonce(variant.nonceType, deviceID, time.Now())
 
 	var password string
-	if router.Data["new_encrypt_mode"] == "1" {
+	if variant.sha256 {
 		a := c.hashSHA256(pwd + key)
 		password = c.hashSHA256(nonce + a)
 	} else {
@@ -219,7 +361,7 @@ func (c *MiWiFiClient) doLogin(ctx context.Context, router *models.Router) error
 
 	loginURL := fmt.Sprintf("http://%s/cgi-bin/luci/api/xqsystem/login", router.IP)
 	data := url.Values{}
-	data.Set("username", "admin")
+	data.Set("username", router.Username)
 	data.Set("password", password)
 	data.Set("logtype", "2")
 	data.Set("nonce", nonce)
@@ -240,14 +382,28 @@ func (c *MiWiFiClient) doLogin(ctx context.Context, router *models.Router) error
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return errors.NewAuthenticationError("router refuses logins after too many attempts", errLoginLocked)
+	}
+	// Some firmwares reject a login with 401 or 403 and a JSON body, which
+	// is handled below
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		if err := c.checkStatus(resp, "xqsystem/login", ""); err != nil {
+			return err
+		}
+	}
+
 	var loginData map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&loginData); err != nil {
-		return errors.NewInternalError("failed to decode login response", err)
+	if err := c.decodeResponse(resp, &loginData); err != nil {
+		return decodeError("login response", err)
 	}
 
 	token, ok := loginData["token"].(string)
 	if !ok {
-		return errors.NewAuthenticationError("token not found in login response", nil)
+		if isLockoutMessage(loginData["msg"]) {
+			return errors.NewAuthenticationError(fmt.Sprintf("router refuses logins after too many attempts: %v", loginData["msg"]), errLoginLocked)
+		}
+		return errors.NewAuthenticationError("token not found in login response", errLoginRejected)
 	}
 
 	path, ok := loginData["url"].(string)
@@ -270,14 +426,8 @@ func (c *MiWiFiClient) doLogin(ctx context.Context, router *models.Router) error
 }
 
 func (c *MiWiFiClient) GetSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
-	if c.auth == nil {
-		if err := c.Authenticate(ctx); err != nil {
-			return nil, err
-		}
-	}
-
 	var result *models.SystemStatus
-	err := c.retry.WithRetry(func() error {
+	err := c.withSession(ctx, func() error {
 		status, err := c.getSystemStatus(ctx)
 		if err != nil {
 			return err
@@ -290,8 +440,9 @@ func (c *MiWiFiClient) GetSystemStatus(ctx context.Context) (*models.SystemStatu
 }
 
 func (c *MiWiFiClient) getSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
+	token := c.token()
 	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/misystem/status", 
-		c.config.Router.IP, c.auth.Token)
+		c.config.Router.IP, token)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -303,29 +454,29 @@ func (c *MiWiFiClient) getSystemStatus(ctx context.Context) (*models.SystemStatu
 		return nil, errors.NewNetworkError("failed to get system status", err)
 	}
 	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "misystem/status", token); err != nil {
+		return nil, err
+	}
 
 	var status models.SystemStatus
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+	if err := c.decodeResponse(resp, &status); err != nil {
 		// If token is invalid, re-authenticate and retry
 		if strings.Contains(err.Error(), "token") || status.Code != 0 {
-			c.auth = nil
+			c.invalidateToken(token)
 			return nil, errors.NewAuthenticationError("invalid token", err)
 		}
-		return nil, errors.NewInternalError("failed to decode system status", err)
+		return nil, decodeError("system status", err)
+	}
+	if err := c.checkCode("misystem/status", status.Code, status.Msg, token); err != nil {
+		return nil, err
 	}
 
 	return &status, nil
 }
 
 func (c *MiWiFiClient) GetDeviceList(ctx context.Context) (*models.DeviceList, error) {
-	if c.auth == nil {
-		if err := c.Authenticate(ctx); err != nil {
-			return nil, err
-		}
-	}
-
 	var result *models.DeviceList
-	err := c.retry.WithRetry(func() error {
+	err := c.withSession(ctx, func() error {
 		devices, err := c.getDeviceList(ctx)
 		if err != nil {
 			return err
@@ -338,8 +489,9 @@ func (c *MiWiFiClient) GetDeviceList(ctx context.Context) (*models.DeviceList, e
 }
 
 func (c *MiWiFiClient) getDeviceList(ctx context.Context) (*models.DeviceList, error) {
+	token := c.token()
 	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/misystem/devicelist", 
-		c.config.Router.IP, c.auth.Token)
+		c.config.Router.IP, token)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -351,29 +503,32 @@ func (c *MiWiFiClient) getDeviceList(ctx context.Context) (*models.DeviceList, e
 		return nil, errors.NewNetworkError("failed to get device list", err)
 	}
 	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "misystem/devicelist", token); err != nil {
+		return nil, err
+	}
 
-	var deviceList models.DeviceList
-	if err := json.NewDecoder(resp.Body).Decode(&deviceList); err != nil {
+	deviceList := acquireDeviceList()
+	if err := c.decodeDeviceListResponse(resp, deviceList); err != nil {
+		code := deviceList.Code
+		c.ReleaseDeviceList(deviceList)
 		// If token is invalid, re-authenticate and retry
-		if strings.Contains(err.Error(), "token") || deviceList.Code != 0 {
-			c.auth = nil
+		if strings.Contains(err.Error(), "token") || code != 0 {
+			c.invalidateToken(token)
 			return nil, errors.NewAuthenticationError("invalid token", err)
 		}
-		return nil, errors.NewInternalError("failed to decode device list", err)
+		return nil, decodeError("device list", err)
+	}
+	if err := c.checkCode("misystem/devicelist", deviceList.Code, deviceList.Msg, token); err != nil {
+		c.ReleaseDeviceList(deviceList)
+		return nil, err
 	}
 
-	return &deviceList, nil
+	return deviceList, nil
 }
 
 func (c *MiWiFiClient) GetWanInfo(ctx context.Context) (*models.WanInfo, error) {
-	if c.auth == nil {
-		if err := c.Authenticate(ctx); err != nil {
-			return nil, err
-		}
-	}
-
 	var result *models.WanInfo
-	err := c.retry.WithRetry(func() error {
+	err := c.withSession(ctx, func() error {
 		wan, err := c.getWanInfo(ctx)
 		if err != nil {
 			return err
@@ -386,8 +541,9 @@ func (c *MiWiFiClient) GetWanInfo(ctx context.Context) (*models.WanInfo, error)
 }
 
 func (c *MiWiFiClient) getWanInfo(ctx context.Context) (*models.WanInfo, error) {
+	token := c.token()
 	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqnetwork/wan_info", 
-		c.config.Router.IP, c.auth.Token)
+		c.config.Router.IP, token)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -399,29 +555,30 @@ func (c *MiWiFiClient) getWanInfo(ctx context.Context) (*models.WanInfo, error)
 		return nil, errors.NewNetworkError("failed to get WAN info", err)
 	}
 	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqnetwork/wan_info", token); err != nil {
+		return nil, err
+	}
 
 	var wanInfo models.WanInfo
-	if err := json.NewDecoder(resp.Body).Decode(&wanInfo); err != nil {
+	if err := c.decodeResponse(resp, &wanInfo); err != nil {
 		// If token is invalid, re-authenticate and retry
 		if strings.Contains(err.Error(), "token") || wanInfo.Code != 0 {
-			c.auth = nil
+			c.invalidateToken(token)
 			return nil, errors.NewAuthenticationError("invalid token", err)
 		}
-		return nil, errors.NewInternalError("failed to decode WAN info", err)
+		return nil, decodeError("WAN info", err)
+	}
+	if err := c.checkCode("xqnetwork/wan_info", wanInfo.Code, wanInfo.Msg, token); err != nil {
+		return nil, err
 	}
 
+	scrubWanInfo(&wanInfo)
 	return &wanInfo, nil
 }
 
 func (c *MiWiFiClient) GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error) {
-	if c.auth == nil {
-		if err := c.Authenticate(ctx); err != nil {
-			return nil, err
-		}
-	}
-
 	var result *models.WifiDetailAll
-	err := c.retry.WithRetry(func() error {
+	err := c.withSession(ctx, func() error {
 		wifi, err := c.getWifiDetails(ctx)
 		if err != nil {
 			return err
@@ -434,8 +591,9 @@ func (c *MiWiFiClient) GetWifiDetails(ctx context.Context) (*models.WifiDetailAl
 }
 
 func (c *MiWiFiClient) getWifiDetails(ctx context.Context) (*models.WifiDetailAll, error) {
+	token := c.token()
 	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqnetwork/wifi_detail_all", 
-		c.config.Router.IP, c.auth.Token)
+		c.config.Router.IP, token)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -447,20 +605,468 @@ func (c *MiWiFiClient) getWifiDetails(ctx context.Context) (*models.WifiDetailAl
 		return nil, errors.NewNetworkError("failed to get WiFi details", err)
 	}
 	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqnetwork/wifi_detail_all", token); err != nil {
+		return nil, err
+	}
 
 	var wifiDetails models.WifiDetailAll
-	if err := json.NewDecoder(resp.Body).Decode(&wifiDetails); err != nil {
+	if err := c.decodeResponse(resp, &wifiDetails); err != nil {
 		// If token is invalid, re-authenticate and retry
 		if strings.Contains(err.Error(), "token") || wifiDetails.Code != 0 {
-			c.auth = nil
+			c.invalidateToken(token)
 			return nil, errors.NewAuthenticationError("invalid token", err)
 		}
-		return nil, errors.NewInternalError("failed to decode WiFi details", err)
+		return nil, decodeError("WiFi details", err)
+	}
+	if err := c.checkCode("xqnetwork/wifi_detail_all", wifiDetails.Code, wifiDetails.Msg, token); err != nil {
+		return nil, err
 	}
 
+	scrubWifiDetails(&wifiDetails)
 	return &wifiDetails, nil
 }
 
+func (c *MiWiFiClient) GetDiskStatus(ctx context.Context) (*models.DiskStatus, error) {
+	var result *models.DiskStatus
+	err := c.withSession(ctx, func() error {
+		disk, err := c.getDiskStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result = disk
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getDiskStatus(ctx context.Context) (*models.DiskStatus, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqdisk/disk_info", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get disk status", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqdisk/disk_info", token); err != nil {
+		return nil, err
+	}
+
+	var diskStatus models.DiskStatus
+	if err := c.decodeResponse(resp, &diskStatus); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || diskStatus.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("disk status", err)
+	}
+	if err := c.checkCode("xqdisk/disk_info", diskStatus.Code, diskStatus.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &diskStatus, nil
+}
+
+func (c *MiWiFiClient) GetSambaStatus(ctx context.Context) (*models.SambaStatus, error) {
+	var result *models.SambaStatus
+	err := c.withSession(ctx, func() error {
+		samba, err := c.getSambaStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result = samba
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getSambaStatus(ctx context.Context) (*models.SambaStatus, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqsystem/samba_status", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get samba status", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqsystem/samba_status", token); err != nil {
+		return nil, err
+	}
+
+	var sambaStatus models.SambaStatus
+	if err := c.decodeResponse(resp, &sambaStatus); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || sambaStatus.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("samba status", err)
+	}
+	if err := c.checkCode("xqsystem/samba_status", sambaStatus.Code, sambaStatus.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &sambaStatus, nil
+}
+
+func (c *MiWiFiClient) GetSysInfo(ctx context.Context) (*models.SysInfo, error) {
+	var result *models.SysInfo
+	err := c.withSession(ctx, func() error {
+		sysInfo, err := c.getSysInfo(ctx)
+		if err != nil {
+			return err
+		}
+		result = sysInfo
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getSysInfo(ctx context.Context) (*models.SysInfo, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/misystem/sys_info", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get system info", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "misystem/sys_info", token); err != nil {
+		return nil, err
+	}
+
+	var sysInfo models.SysInfo
+	if err := c.decodeResponse(resp, &sysInfo); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || sysInfo.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("system info", err)
+	}
+	if err := c.checkCode("misystem/sys_info", sysInfo.Code, sysInfo.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &sysInfo, nil
+}
+
+func (c *MiWiFiClient) GetPortStatus(ctx context.Context) (*models.PortStatus, error) {
+	var result *models.PortStatus
+	err := c.withSession(ctx, func() error {
+		ports, err := c.getPortStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result = ports
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getPortStatus(ctx context.Context) (*models.PortStatus, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqnetwork/port_status", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get port status", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqnetwork/port_status", token); err != nil {
+		return nil, err
+	}
+
+	var portStatus models.PortStatus
+	if err := c.decodeResponse(resp, &portStatus); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || portStatus.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("port status", err)
+	}
+	if err := c.checkCode("xqnetwork/port_status", portStatus.Code, portStatus.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &portStatus, nil
+}
+
+func (c *MiWiFiClient) GetWPSStatus(ctx context.Context) (*models.WPSStatus, error) {
+	var result *models.WPSStatus
+	err := c.withSession(ctx, func() error {
+		wpsStatus, err := c.getWPSStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result = wpsStatus
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getWPSStatus(ctx context.Context) (*models.WPSStatus, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqnetwork/wps_status", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get WPS status", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqnetwork/wps_status", token); err != nil {
+		return nil, err
+	}
+
+	var wpsStatus models.WPSStatus
+	if err := c.decodeResponse(resp, &wpsStatus); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || wpsStatus.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("WPS status", err)
+	}
+	if err := c.checkCode("xqnetwork/wps_status", wpsStatus.Code, wpsStatus.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &wpsStatus, nil
+}
+
+func (c *MiWiFiClient) GetTopoGraph(ctx context.Context) (*models.TopoGraph, error) {
+	var result *models.TopoGraph
+	err := c.withSession(ctx, func() error {
+		topoGraph, err := c.getTopoGraph(ctx)
+		if err != nil {
+			return err
+		}
+		result = topoGraph
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getTopoGraph(ctx context.Context) (*models.TopoGraph, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/misystem/topo_graph", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get mesh topology", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "misystem/topo_graph", token); err != nil {
+		return nil, err
+	}
+
+	var topoGraph models.TopoGraph
+	if err := c.decodeResponse(resp, &topoGraph); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || topoGraph.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("mesh topology", err)
+	}
+	if err := c.checkCode("misystem/topo_graph", topoGraph.Code, topoGraph.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &topoGraph, nil
+}
+
+func (c *MiWiFiClient) GetWifiStatistics(ctx context.Context) (*models.WifiStatistics, error) {
+	var result *models.WifiStatistics
+	err := c.withSession(ctx, func() error {
+		wifiStatistics, err := c.getWifiStatistics(ctx)
+		if err != nil {
+			return err
+		}
+		result = wifiStatistics
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getWifiStatistics(ctx context.Context) (*models.WifiStatistics, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqnetwork/wifi_statistics", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get WiFi radio statistics", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqnetwork/wifi_statistics", token); err != nil {
+		return nil, err
+	}
+
+	var wifiStatistics models.WifiStatistics
+	if err := c.decodeResponse(resp, &wifiStatistics); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || wifiStatistics.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("WiFi radio statistics", err)
+	}
+	if err := c.checkCode("xqnetwork/wifi_statistics", wifiStatistics.Code, wifiStatistics.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &wifiStatistics, nil
+}
+
+func (c *MiWiFiClient) GetIPTVStatus(ctx context.Context) (*models.IPTVStatus, error) {
+	var result *models.IPTVStatus
+	err := c.withSession(ctx, func() error {
+		iptvStatus, err := c.getIPTVStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result = iptvStatus
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getIPTVStatus(ctx context.Context) (*models.IPTVStatus, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqnetwork/iptv", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get IPTV status", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqnetwork/iptv", token); err != nil {
+		return nil, err
+	}
+
+	var iptvStatus models.IPTVStatus
+	if err := c.decodeResponse(resp, &iptvStatus); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || iptvStatus.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("IPTV status", err)
+	}
+	if err := c.checkCode("xqnetwork/iptv", iptvStatus.Code, iptvStatus.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &iptvStatus, nil
+}
+
+func (c *MiWiFiClient) GetUPnPStatus(ctx context.Context) (*models.UPnPStatus, error) {
+	var result *models.UPnPStatus
+	err := c.withSession(ctx, func() error {
+		upnpStatus, err := c.getUPnPStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result = upnpStatus
+		return nil
+	})
+	
+	return result, err
+}
+
+func (c *MiWiFiClient) getUPnPStatus(ctx context.Context) (*models.UPnPStatus, error) {
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/xqsystem/upnp", 
+		c.config.Router.IP, token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to get UPnP mappings", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, "xqsystem/upnp", token); err != nil {
+		return nil, err
+	}
+
+	var upnpStatus models.UPnPStatus
+	if err := c.decodeResponse(resp, &upnpStatus); err != nil {
+		// If token is invalid, re-authenticate and retry
+		if strings.Contains(err.Error(), "token") || upnpStatus.Code != 0 {
+			c.invalidateToken(token)
+			return nil, errors.NewAuthenticationError("invalid token", err)
+		}
+		return nil, decodeError("UPnP mappings", err)
+	}
+	if err := c.checkCode("xqsystem/upnp", upnpStatus.Code, upnpStatus.Msg, token); err != nil {
+		return nil, err
+	}
+
+	return &upnpStatus, nil
+}
+
 func (c *MiWiFiClient) hashSHA1(data string) string {
 	h := sha1.New()
 	h.Write([]byte(data))
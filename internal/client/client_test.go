@@ -0,0 +1,400 @@
+package client
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/pkg/concurrent"
+)
+
+const (
+	fakeRouterPassword = "router-password"
+	fakeRouterKey      = "a2ffa5c9be07488bbb04a3a47d3c5f6a"
+	fakeRouterDeviceID = "test-device-id"
+	stokPrefix         = "/cgi-bin/luci/;stok="
+)
+
+// fakeResponse overrides the answer of one API endpoint
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// fakeRouter speaks the login protocol of the router web interface and
+// answers the API endpoints with the demo fixtures unless overridden
+type fakeRouter struct {
+	t      testing.TB
+	server *httptest.Server
+	// newEncryptMode is what init_info reports, sha256 what the login
+	// actually checks
+	newEncryptMode int
+	sha256         bool
+	// expiredStatus is the HTTP status answered for an unknown token, the
+	// router default is 200 with code 401
+	expiredStatus int
+
+	mu        sync.Mutex
+	token     string
+	logins    int
+	rejected  int
+	requests  map[string]int
+	responses map[string]fakeResponse
+}
+
+func newFakeRouter(t testing.TB, newEncryptMode int, sha256 bool) *fakeRouter {
+	t.Helper()
+
+	fr := &fakeRouter{
+		t:              t,
+		newEncryptMode: newEncryptMode,
+		sha256:         sha256,
+		requests:       make(map[string]int),
+		responses:      make(map[string]fakeResponse),
+	}
+	fr.server = httptest.NewServer(http.HandlerFunc(fr.serveHTTP))
+	t.Cleanup(fr.server.Close)
+	return fr
+}
+
+// config returns the configuration of a client of the fake router
+func (fr *fakeRouter) config(fallback bool) *config.Config {
+	return &config.Config{Router: config.RouterConfig{
+		IP:            strings.TrimPrefix(fr.server.URL, "http://"),
+		Username:      "admin",
+		Password:      fakeRouterPassword,
+		Timeout:       5,
+		LoginFallback: fallback,
+		MaxResponseMB: 1,
+	}}
+}
+
+// client returns a client of the fake router that has not logged in yet
+func (fr *fakeRouter) client(fallback bool) *MiWiFiClient {
+	return NewMiWiFiClient(fr.config(fallback))
+}
+
+// respond overrides the answer of an API path such as misystem/status
+func (fr *fakeRouter) respond(api string, status int, body string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.responses[api] = fakeResponse{status: status, body: body}
+}
+
+// expireToken makes the router forget the current session
+func (fr *fakeRouter) expireToken() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.token = ""
+}
+
+// counts returns the accepted and rejected logins and the requests of api
+func (fr *fakeRouter) counts(api string) (logins, rejected, requests int) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.logins, fr.rejected, fr.requests[api]
+}
+
+func (fr *fakeRouter) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/cgi-bin/luci/web":
+		fmt.Fprintf(w, "<script>\n\tvar deviceId = '%s';\n\tvar Encrypt = {\n\t\tkey: '%s',\n\t\tiv: '0'\n\t};\n</script>",
+			fakeRouterDeviceID, fakeRouterKey)
+	case r.URL.Path == "/cgi-bin/luci/api/xqsystem/init_info":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"code": 0, "newEncryptMode": fr.newEncryptMode, "hardware": "RB03", "romversion": "1.0.55",
+		})
+	case r.URL.Path == "/cgi-bin/luci/api/xqsystem/login":
+		fr.serveLogin(w, r)
+	case strings.HasPrefix(r.URL.Path, stokPrefix):
+		token, api, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, stokPrefix), "/api/")
+		fr.requests[api]++
+		if token == "" || token != fr.token {
+			status := http.StatusOK
+			if fr.expiredStatus != 0 {
+				status = fr.expiredStatus
+			}
+			writeJSON(w, status, map[string]interface{}{"code": 401, "msg": "Invalid token"})
+			return
+		}
+		fr.serveAPI(w, api)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (fr *fakeRouter) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		fr.t.Errorf("login form: %v", err)
+	}
+	nonce := r.PostForm.Get("nonce")
+	if !strings.Contains(nonce, "_"+fakeRouterDeviceID+"_") {
+		fr.t.Errorf("nonce %q does not contain the device id", nonce)
+	}
+
+	newHash := sha1.New
+	if fr.sha256 {
+		newHash = sha256.New
+	}
+	if r.PostForm.Get("username") != "admin" || r.PostForm.Get("password") != hashHex(newHash, nonce+hashHex(newHash, fakeRouterPassword+fakeRouterKey)) {
+		fr.rejected++
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": 401, "msg": "not auth"})
+		return
+	}
+
+	fr.logins++
+	fr.token = fmt.Sprintf("token-%d", fr.logins)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code": 0, "token": fr.token, "url": stokPrefix + fr.token + "/web/home",
+	})
+}
+
+func (fr *fakeRouter) serveAPI(w http.ResponseWriter, api string) {
+	if response, ok := fr.responses[api]; ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(response.status)
+		fmt.Fprint(w, response.body)
+		return
+	}
+
+	body, err := os.ReadFile("../../fixtures/demo/" + strings.ReplaceAll(api, "/", "_") + ".json")
+	if err != nil {
+		http.NotFound(w, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func hashHex(newHash func() hash.Hash, s string) string {
+	h := newHash()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestMain(m *testing.M) {
+	// The client logs logins and retries
+	logger.Default = logger.NewWithOutput("error", "text", io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		newEncryptMode int
+		sha256         bool
+		fallback       bool
+		wantErr        bool
+		wantRejected   int
+	}{
+		{name: "sha1", newEncryptMode: 0, sha256: false},
+		{name: "sha256", newEncryptMode: 1, sha256: true},
+		// Some ROM builds report the wrong mode in init_info
+		{name: "wrong mode with fallback", newEncryptMode: 0, sha256: true, fallback: true, wantRejected: 1},
+		{name: "wrong mode without fallback", newEncryptMode: 1, sha256: false, wantErr: true, wantRejected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newFakeRouter(t, tt.newEncryptMode, tt.sha256)
+			c := router.client(tt.fallback)
+
+			err := c.Authenticate(context.Background())
+			logins, rejected, _ := router.counts("")
+			if rejected != tt.wantRejected {
+				t.Errorf("router rejected %d logins, want %d", rejected, tt.wantRejected)
+			}
+
+			if tt.wantErr {
+				if !errors.IsAuthenticationError(err) {
+					t.Fatalf("Authenticate returned %v, want an authentication error", err)
+				}
+				if state := c.AuthState(); state != AuthStateFailed {
+					t.Errorf("state after a rejected login is %v, want failed", state)
+				}
+				if failures := c.AuthFailures()[AuthFailureRejected]; failures != 1 {
+					t.Errorf("counted %d rejected logins, want 1", failures)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if logins != 1 {
+				t.Errorf("router accepted %d logins, want 1", logins)
+			}
+			if state := c.AuthState(); state != AuthStateAuthenticated {
+				t.Errorf("state after login is %v, want authenticated", state)
+			}
+			if info := c.InitInfo(); info == nil || info.Hardware != "RB03" {
+				t.Errorf("init_info not kept after login: %+v", info)
+			}
+			if _, err := c.GetSystemStatus(context.Background()); err != nil {
+				t.Errorf("GetSystemStatus after login: %v", err)
+			}
+		})
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	router := newFakeRouter(t, 0, false)
+	c := router.client(true)
+	c.config.Router.Password = "wrong"
+
+	err := c.Authenticate(context.Background())
+	if !errors.IsAuthenticationError(err) {
+		t.Fatalf("Authenticate returned %v, want an authentication error", err)
+	}
+	// The fallback tries every hash and nonce combination once
+	if _, rejected, _ := router.counts(""); rejected != 4 {
+		t.Errorf("router saw %d login attempts, want 4", rejected)
+	}
+
+	// Data requests wait for the backoff instead of logging in again
+	if _, err := c.GetSystemStatus(context.Background()); !errors.IsAuthenticationError(err) {
+		t.Errorf("GetSystemStatus during the backoff returned %v, want an authentication error", err)
+	}
+	if _, rejected, requests := router.counts("misystem/status"); rejected != 4 || requests != 0 {
+		t.Errorf("request during the backoff reached the router: %d logins, %d requests", rejected, requests)
+	}
+}
+
+func TestTokenExpiresMidSession(t *testing.T) {
+	for _, expiredStatus := range []int{http.StatusOK, http.StatusUnauthorized} {
+		t.Run(http.StatusText(expiredStatus), func(t *testing.T) {
+			router := newFakeRouter(t, 1, true)
+			router.expiredStatus = expiredStatus
+			c := router.client(false)
+			ctx := context.Background()
+
+			// The first request logs in on its own
+			if _, err := c.GetSystemStatus(ctx); err != nil {
+				t.Fatalf("GetSystemStatus: %v", err)
+			}
+
+			router.expireToken()
+			status, err := c.GetSystemStatus(ctx)
+			if err != nil {
+				t.Fatalf("GetSystemStatus after the token expired: %v", err)
+			}
+			if status == nil || status.Hardware.Platform == "" {
+				t.Errorf("GetSystemStatus returned no data after logging in again: %+v", status)
+			}
+
+			logins, _, requests := router.counts("misystem/status")
+			if logins != 2 {
+				t.Errorf("router saw %d logins, want 2", logins)
+			}
+			// The rejected request is repeated once with the new token
+			if requests != 3 {
+				t.Errorf("router saw %d status requests, want 3", requests)
+			}
+		})
+	}
+}
+
+func TestMalformedJSON(t *testing.T) {
+	router := newFakeRouter(t, 0, false)
+	c := router.client(false)
+	ctx := context.Background()
+
+	router.respond("misystem/status", http.StatusOK, `{"code":0,"cpu":{"load":`)
+	_, err := c.GetSystemStatus(ctx)
+	if err == nil {
+		t.Fatal("GetSystemStatus decoded a truncated response")
+	}
+	if errors.IsAuthenticationError(err) {
+		t.Errorf("truncated response treated as an expired session: %v", err)
+	}
+	if !strings.Contains(err.Error(), "system status") {
+		t.Errorf("error does not name the endpoint: %v", err)
+	}
+
+	router.respond("misystem/devicelist", http.StatusOK, `{"code":0,"list":[{"mac":"02:00:00:00:00:01"},{"mac":`)
+	if _, err := c.GetDeviceList(ctx); err == nil {
+		t.Error("GetDeviceList decoded a truncated response")
+	}
+
+	// A response larger than MaxResponseMB is not read to the end
+	router.respond("xqnetwork/wan_info", http.StatusOK, `{"code":0,"padding":"`+strings.Repeat("x", 2<<20)+`"}`)
+	if _, err := c.GetWanInfo(ctx); !stderrors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("GetWanInfo of an oversized response returned %v, want ErrResponseTooLarge", err)
+	}
+
+	// Broken responses say nothing about the session
+	if logins, _, _ := router.counts(""); logins != 1 {
+		t.Errorf("router saw %d logins, want 1", logins)
+	}
+	if _, err := c.GetSysInfo(ctx); err != nil {
+		t.Errorf("GetSysInfo after broken responses: %v", err)
+	}
+}
+
+func TestPartialEndpointFailures(t *testing.T) {
+	router := newFakeRouter(t, 0, false)
+	router.respond("xqdisk/disk_info", http.StatusNotFound, "Not Found")
+	router.respond("xqsystem/samba_status", http.StatusOK, `{"code":403,"msg":"Permission denied"}`)
+	router.respond("xqnetwork/wan_info", http.StatusBadGateway, "Bad Gateway")
+	c := router.client(false)
+
+	fetcher := concurrent.NewDataFetcher(5*time.Second, 1, time.Millisecond)
+	data, err := fetcher.FetchData(context.Background(), c)
+
+	// wan_info is required, the storage endpoints are best-effort
+	if err == nil || !strings.Contains(err.Error(), "wan_info") {
+		t.Errorf("FetchData returned %v, want the wan_info failure", err)
+	}
+	if data == nil || data.SystemStatus == nil || data.DeviceList == nil || data.WifiDetails == nil || data.SysInfo == nil {
+		t.Fatalf("data of the working endpoints is missing: %+v", data)
+	}
+	if data.WanInfo != nil || data.DiskStatus != nil || data.SambaStatus != nil {
+		t.Errorf("data of failed endpoints is set: %+v", data)
+	}
+
+	// The 502 is retried once, the answers of the router are not
+	for api, want := range map[string]int{"xqnetwork/wan_info": 2, "xqdisk/disk_info": 1, "xqsystem/samba_status": 1} {
+		if _, _, requests := router.counts(api); requests != want {
+			t.Errorf("router saw %d requests of %s, want %d", requests, api, want)
+		}
+	}
+
+	// Every failure keeps its type for the metrics
+	_, err = c.GetDiskStatus(context.Background())
+	var statusErr *HTTPStatusError
+	if !stderrors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetDiskStatus returned %v, want HTTP 404", err)
+	}
+	_, err = c.GetSambaStatus(context.Background())
+	if !stderrors.Is(err, ErrPermissionDenied) {
+		t.Errorf("GetSambaStatus returned %v, want ErrPermissionDenied", err)
+	}
+	if logins, _, _ := router.counts(""); logins != 1 {
+		t.Errorf("router saw %d logins, want 1", logins)
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+)
+
+// Kinds of RouterCodeError
+const (
+	// CodeKindTokenExpired means the session is gone, logging in again helps
+	CodeKindTokenExpired = "token_expired"
+	// CodeKindPermissionDenied means the account may not use the endpoint
+	CodeKindPermissionDenied = "permission_denied"
+	// CodeKindUnsupported means the firmware does not provide the endpoint
+	CodeKindUnsupported = "unsupported"
+	// CodeKindOther is any other error code
+	CodeKindOther = "other"
+)
+
+// Codes the router answers besides tokenExpiredCode
+const (
+	permissionDeniedCode = 403
+	unsupportedCode      = 404
+)
+
+// Sentinels matched by errors.Is against a RouterCodeError of that kind
+var (
+	ErrTokenExpired     = stderrors.New("router session expired")
+	ErrPermissionDenied = stderrors.New("router denied access")
+	ErrUnsupportedAPI   = stderrors.New("router does not support the API")
+)
+
+// RouterCodeError is a router response with a non-zero code. Such responses
+// carry no data; decoded anyway they would be exported as zeros.
+type RouterCodeError struct {
+	Endpoint string
+	Code     int
+	Msg      string
+}
+
+// Kind classifies the code as one of the CodeKind constants
+func (e *RouterCodeError) Kind() string {
+	switch e.Code {
+	case tokenExpiredCode:
+		return CodeKindTokenExpired
+	case permissionDeniedCode:
+		return CodeKindPermissionDenied
+	case unsupportedCode:
+		return CodeKindUnsupported
+	}
+	return CodeKindOther
+}
+
+func (e *RouterCodeError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("%s answered code %d (%s): %s", e.Endpoint, e.Code, e.Kind(), e.Msg)
+	}
+	return fmt.Sprintf("%s answered code %d (%s)", e.Endpoint, e.Code, e.Kind())
+}
+
+func (e *RouterCodeError) Is(target error) bool {
+	switch target {
+	case ErrTokenExpired:
+		return e.Kind() == CodeKindTokenExpired
+	case ErrPermissionDenied:
+		return e.Kind() == CodeKindPermissionDenied
+	case ErrUnsupportedAPI:
+		return e.Kind() == CodeKindUnsupported
+	}
+	return false
+}
+
+// checkCode returns an error for a response with a non-zero code. An expired
+// token drops the session and is an authentication error, so the request is
+// repeated after logging in again; other codes are not retried.
+func (c *MiWiFiClient) checkCode(endpoint string, code int, msg, token string) error {
+	if code == 0 {
+		return nil
+	}
+	codeErr := &RouterCodeError{Endpoint: endpoint, Code: code, Msg: msg}
+	if codeErr.Kind() == CodeKindTokenExpired {
+		c.invalidateToken(token)
+		return errors.NewAuthenticationError("invalid token", codeErr)
+	}
+	return errors.NewRouterError("request rejected", codeErr)
+}
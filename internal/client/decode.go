@@ -0,0 +1,249 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// maxPooledBufferSize keeps the buffer of an unusually large response from
+// being held by the pool for the lifetime of the process
+const maxPooledBufferSize = 1 << 20
+
+// defaultMaxResponseSize applies when no limit is configured
+const defaultMaxResponseSize = 16 << 20
+
+// ErrResponseTooLarge is returned for a response body above the size limit
+var ErrResponseTooLarge = stderrors.New("response exceeds the size limit")
+
+// bodyBufferPool holds the buffers router responses are read into. A device
+// list of a large network is hundreds of kilobytes; reusing the buffer avoids
+// growing a new one for every request.
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// bodyReaderPool holds the read buffers of streamed responses
+var bodyReaderPool = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewReaderSize(nil, 32<<10)
+	},
+}
+
+// maxPooledDevices keeps the entries of an unusually large device list from
+// being held by the pool for the lifetime of the process
+const maxPooledDevices = 4096
+
+// deviceListPool holds device lists handed back with ReleaseDeviceList. The
+// device list is the largest response, decoding into the entries of a
+// released list saves growing a new slice of them for every request.
+var deviceListPool = sync.Pool{
+	New: func() interface{} {
+		return new(models.DeviceList)
+	},
+}
+
+// acquireDeviceList returns an empty device list, reusing the entries of a
+// released one
+func acquireDeviceList() *models.DeviceList {
+	list := deviceListPool.Get().(*models.DeviceList)
+	*list = models.DeviceList{List: list.List[:0]}
+	return list
+}
+
+// ReleaseDeviceList hands a device list returned by GetDeviceList back for
+// decoding a later response into. The caller passes on its ownership: the
+// list must not be cached, and nobody may read it after the call.
+func (c *MiWiFiClient) ReleaseDeviceList(list *models.DeviceList) {
+	if list == nil || cap(list.List) > maxPooledDevices {
+		return
+	}
+	// Drop the strings of the old entries, the next response decodes into
+	// zeroed entries anyway
+	clear(list.List[:cap(list.List)])
+	deviceListPool.Put(list)
+}
+
+// limitedReader fails with ErrResponseTooLarge once more than limit bytes
+// were read, unlike io.LimitReader which ends the body silently
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.limit {
+		return 0, fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, l.limit)
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes
+	// from a larger one
+	if remaining := l.limit - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n, fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, l.limit)
+	}
+	return n, err
+}
+
+// decodeJSON reads at most limit bytes of r into a pooled buffer and decodes
+// them into v. The decoded value does not reference the buffer, so it can be
+// returned to the pool right away.
+func decodeJSON(r io.Reader, v interface{}, limit int64) error {
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			buf.Reset()
+			bodyBufferPool.Put(buf)
+		}
+	}()
+
+	if _, err := buf.ReadFrom(&limitedReader{r: r, limit: limit}); err != nil {
+		return err
+	}
+	return json.Unmarshal(buf.Bytes(), v)
+}
+
+// decodeDeviceList decodes a device list response one device at a time, so
+// the raw body of a large network is never held in memory at once; only the
+// pooled read buffer and the entry being decoded are. The entries are
+// appended to list.List, whose capacity is reused. At most limit bytes are
+// read.
+func decodeDeviceList(r io.Reader, list *models.DeviceList, limit int64) error {
+	lr := &limitedReader{r: r, limit: limit}
+	br := bodyReaderPool.Get().(*bufio.Reader)
+	br.Reset(lr)
+	defer func() {
+		br.Reset(nil)
+		bodyReaderPool.Put(br)
+	}()
+
+	dec := json.NewDecoder(br)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	// Fields other than the list are small, they are collected and decoded
+	// into list at the end so new model fields need no change here
+	rest := make(map[string]json.RawMessage)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if key != "list" {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			rest[key] = raw
+			continue
+		}
+
+		token, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			continue
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return fmt.Errorf("device list: expected an array, got %v", token)
+		}
+		for dec.More() {
+			list.List = append(list.List, models.DeviceEntry{})
+			if err := dec.Decode(&list.List[len(list.List)-1]); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	// The read that crossed the limit may have completed the object, the
+	// buffered reader then holds back its error
+	if lr.read > lr.limit {
+		return fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, limit)
+	}
+
+	if len(rest) == 0 {
+		return nil
+	}
+	fields, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(fields, list)
+}
+
+// expectDelim reads the next token and checks that it is delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if got, ok := token.(json.Delim); !ok || got != delim {
+		return fmt.Errorf("expected %q, got %v", delim, token)
+	}
+	return nil
+}
+
+// maxResponseSize returns the configured response size limit in bytes
+func (c *MiWiFiClient) maxResponseSize() int64 {
+	if c.config.Router.MaxResponseMB <= 0 {
+		return defaultMaxResponseSize
+	}
+	return int64(c.config.Router.MaxResponseMB) << 20
+}
+
+// checkContentLength rejects a response announcing a body above the size
+// limit before any of it is read
+func (c *MiWiFiClient) checkContentLength(resp *http.Response) error {
+	if limit := c.maxResponseSize(); resp.ContentLength > limit {
+		return fmt.Errorf("%w of %d bytes: announced %d bytes", ErrResponseTooLarge, limit, resp.ContentLength)
+	}
+	return nil
+}
+
+// decodeResponse decodes the body of a router response into v
+func (c *MiWiFiClient) decodeResponse(resp *http.Response, v interface{}) error {
+	if err := c.checkContentLength(resp); err != nil {
+		return err
+	}
+	return decodeJSON(resp.Body, v, c.maxResponseSize())
+}
+
+// decodeDeviceListResponse streams the body of a device list response into
+// list
+func (c *MiWiFiClient) decodeDeviceListResponse(resp *http.Response, list *models.DeviceList) error {
+	if err := c.checkContentLength(resp); err != nil {
+		return err
+	}
+	return decodeDeviceList(resp.Body, list, c.maxResponseSize())
+}
+
+// decodeError wraps a failure to decode a response. An oversized response is
+// not retried, it would only be read again.
+func decodeError(what string, err error) error {
+	if stderrors.Is(err, ErrResponseTooLarge) {
+		return errors.NewRouterError("failed to decode "+what, err)
+	}
+	return errors.NewInternalError("failed to decode "+what, err)
+}
@@ -0,0 +1,151 @@
+//go:build !race
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// TestDecodeJSONReusesBuffers guards against reading every response into a
+// freshly grown buffer again. Decoding a large body whose content is skipped
+// must only allocate a small fraction of the body size. The race detector
+// allocates for its own bookkeeping, so the check only runs without it.
+func TestDecodeJSONReusesBuffers(t *testing.T) {
+	body := []byte(`{"code":0,"padding":"` + strings.Repeat("x", 256<<10) + `"}`)
+	var v struct {
+		Code int `json:"code"`
+	}
+
+	decode := func() {
+		if err := decodeJSON(bytes.NewReader(body), &v, defaultMaxResponseSize); err != nil {
+			t.Fatalf("decodeJSON: %v", err)
+		}
+	}
+	// Warm the pool
+	decode()
+
+	const runs = 20
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < runs; i++ {
+		decode()
+	}
+	runtime.ReadMemStats(&after)
+
+	perDecode := (after.TotalAlloc - before.TotalAlloc) / runs
+	if limit := uint64(len(body) / 10); perDecode > limit {
+		t.Errorf("decodeJSON allocated %d bytes per call for a %d byte body, want at most %d", perDecode, len(body), limit)
+	}
+}
+
+// scrapeDevices is the size of the network of the scrape benchmarks
+const scrapeDevices = 500
+
+// newScrapeClient returns a client logged in to a fake router listing
+// scrapeDevices devices
+func newScrapeClient(tb testing.TB) *MiWiFiClient {
+	tb.Helper()
+
+	router := newFakeRouter(tb, 1, true)
+	router.respond("misystem/devicelist", http.StatusOK, string(deviceListBody(scrapeDevices)))
+	c := router.client(false)
+	if err := c.Authenticate(context.Background()); err != nil {
+		tb.Fatalf("Authenticate: %v", err)
+	}
+	return c
+}
+
+// benchmarkScrapeDecode requests and decodes the endpoints every scrape
+// needs. With release set the device list is handed back like the collector
+// does once a collection is superseded.
+func benchmarkScrapeDecode(b *testing.B, c *MiWiFiClient, release bool) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.GetSystemStatus(ctx); err != nil {
+			b.Fatal(err)
+		}
+		list, err := c.GetDeviceList(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(list.List) != scrapeDevices {
+			b.Fatalf("decoded %d devices, want %d", len(list.List), scrapeDevices)
+		}
+		if release {
+			c.ReleaseDeviceList(list)
+		}
+		if _, err := c.GetWanInfo(ctx); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := c.GetWifiDetails(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScrapeDecode(b *testing.B) {
+	benchmarkScrapeDecode(b, newScrapeClient(b), true)
+}
+
+// scrapeDecodeBudget bounds the bytes one scrape allocates decoding the
+// responses of a network of scrapeDevices, about twice what it takes today
+const scrapeDecodeBudget = 800 << 10
+
+// TestScrapeDecodeAllocations fails when decoding the responses of a scrape
+// allocates more than its budget, or when releasing the device list no
+// longer saves allocating its entries. It runs for a few seconds and is
+// skipped with -short.
+func TestScrapeDecodeAllocations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("benchmarks skipped in short mode")
+	}
+
+	c := newScrapeClient(t)
+	released := testing.Benchmark(func(b *testing.B) { benchmarkScrapeDecode(b, c, true) })
+	kept := testing.Benchmark(func(b *testing.B) { benchmarkScrapeDecode(b, c, false) })
+	if released.N == 0 || kept.N == 0 {
+		t.Fatal("benchmark did not run")
+	}
+
+	if bytes := released.AllocedBytesPerOp(); bytes > scrapeDecodeBudget {
+		t.Errorf("a scrape allocates %d bytes decoding, budget is %d", bytes, scrapeDecodeBudget)
+	}
+	entries := int64(scrapeDevices) * int64(unsafe.Sizeof(models.DeviceEntry{}))
+	if saved := kept.AllocedBytesPerOp() - released.AllocedBytesPerOp(); saved < entries {
+		t.Errorf("releasing the device list saves %d bytes per scrape, want at least the %d bytes of its entries", saved, entries)
+	}
+}
+
+func TestReleasedDeviceListReused(t *testing.T) {
+	c := newScrapeClient(t)
+	ctx := context.Background()
+
+	first, err := c.GetDeviceList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Mac = "stale"
+	entries := &first.List[0]
+	c.ReleaseDeviceList(first)
+
+	second, err := c.GetDeviceList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first || &second.List[0] != entries {
+		t.Fatal("the released device list was not reused")
+	}
+	if second.Mac != "" || len(second.List) != scrapeDevices || second.List[1].Name != "device-0001" {
+		t.Errorf("the reused list holds %q and %d devices, want only the new response", second.Mac, len(second.List))
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// deviceListBody returns a device list response with n devices
+func deviceListBody(n int) []byte {
+	var b strings.Builder
+	b.WriteString(`{"code":0,"list":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"mac":"02:00:00:00:%02x:%02x","name":"device-%04d","online":1,"type":1,`+
+			`"authority":{"wan":1,"lan":1},"ip":[{"ip":"192.168.31.%d","upspeed":"1024","downspeed":"4096"}],`+
+			`"statistics":{"online":"3600","upspeed":"1024","downspeed":"4096"}}`,
+			byte(i>>8), byte(i), i, 2+i%250)
+	}
+	b.WriteString(`]}`)
+	return []byte(b.String())
+}
+
+func TestDecodeJSONMatchesDecoder(t *testing.T) {
+	body := deviceListBody(50)
+
+	var pooled, streamed models.DeviceList
+	if err := decodeJSON(bytes.NewReader(body), &pooled, defaultMaxResponseSize); err != nil {
+		t.Fatalf("decodeJSON: %v", err)
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&streamed); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	got, _ := json.Marshal(pooled)
+	want, _ := json.Marshal(streamed)
+	if !bytes.Equal(got, want) {
+		t.Errorf("decodeJSON result differs from json.Decoder:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestDecodeDeviceListMatchesDecoder(t *testing.T) {
+	for _, body := range [][]byte{
+		deviceListBody(50),
+		[]byte(`{"mac":"02:00:00:00:00:01","list":null,"code":0}`),
+		[]byte(`{"code":401,"msg":"Invalid token"}`),
+	} {
+		var streamed, decoded models.DeviceList
+		if err := decodeDeviceList(bytes.NewReader(body), &streamed, defaultMaxResponseSize); err != nil {
+			t.Fatalf("decodeDeviceList(%.40s): %v", body, err)
+		}
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&decoded); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+
+		got, _ := json.Marshal(streamed)
+		want, _ := json.Marshal(decoded)
+		if !bytes.Equal(got, want) {
+			t.Errorf("decodeDeviceList result differs from json.Decoder:\n got %s\nwant %s", got, want)
+		}
+	}
+}
+
+// TestDecodeRejectsOversizedResponses checks that both decoders stop reading
+// at the limit, and that a body of exactly the limit is accepted
+func TestDecodeRejectsOversizedResponses(t *testing.T) {
+	body := deviceListBody(50)
+	limit := int64(len(body))
+
+	var list models.DeviceList
+	if err := decodeJSON(bytes.NewReader(body), &list, limit); err != nil {
+		t.Errorf("decodeJSON at the limit: %v", err)
+	}
+	if err := decodeDeviceList(bytes.NewReader(body), &models.DeviceList{}, limit); err != nil {
+		t.Errorf("decodeDeviceList at the limit: %v", err)
+	}
+
+	if err := decodeJSON(bytes.NewReader(body), &models.DeviceList{}, limit-1); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("decodeJSON above the limit returned %v, want ErrResponseTooLarge", err)
+	}
+	if err := decodeDeviceList(bytes.NewReader(body), &models.DeviceList{}, limit-1); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("decodeDeviceList above the limit returned %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func BenchmarkDecodeDeviceList(b *testing.B) {
+	body := deviceListBody(500)
+
+	b.Run("decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			var list models.DeviceList
+			if err := json.NewDecoder(bytes.NewReader(body)).Decode(&list); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			var list models.DeviceList
+			if err := decodeJSON(bytes.NewReader(body), &list, defaultMaxResponseSize); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			var list models.DeviceList
+			if err := decodeDeviceList(bytes.NewReader(body), &list, defaultMaxResponseSize); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkGetDeviceList measures a full device list request against a fake
+// router with 500 devices
+func BenchmarkGetDeviceList(b *testing.B) {
+	body := deviceListBody(500)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	c := NewMiWiFiClient(&config.Config{Router: config.RouterConfig{
+		IP:      strings.TrimPrefix(server.URL, "http://"),
+		Timeout: 5,
+	}})
+	c.auth = &models.Auth{Token: "test-token", Code: 200}
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.GetDeviceList(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
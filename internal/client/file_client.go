@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// FileRouterClient serves router data from JSON fixtures in a directory, as
+// written by --record-responses. Files are read on every call so they can be
+// edited while the exporter runs.
+type FileRouterClient struct {
+	dir string
+}
+
+// NewFileRouterClient creates a client reading fixtures from dir
+func NewFileRouterClient(dir string) (*FileRouterClient, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixture directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("fixture path %s is not a directory", dir)
+	}
+
+	return &FileRouterClient{dir: dir}, nil
+}
+
+func (c *FileRouterClient) Authenticate(ctx context.Context) error {
+	return nil
+}
+
+// InitInfo returns the router model and firmware from
+// xqsystem_init_info.json, nil if the fixture is missing
+func (c *FileRouterClient) InitInfo() *models.InitInfo {
+	var initInfo models.InitInfo
+	if err := c.load("xqsystem_init_info.json", &initInfo); err != nil {
+		return nil
+	}
+	return &initInfo
+}
+
+func (c *FileRouterClient) GetSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
+	var status models.SystemStatus
+	if err := c.load("misystem_status.json", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *FileRouterClient) GetDeviceList(ctx context.Context) (*models.DeviceList, error) {
+	var deviceList models.DeviceList
+	if err := c.load("misystem_devicelist.json", &deviceList); err != nil {
+		return nil, err
+	}
+	return &deviceList, nil
+}
+
+func (c *FileRouterClient) GetWanInfo(ctx context.Context) (*models.WanInfo, error) {
+	var wanInfo models.WanInfo
+	if err := c.load("xqnetwork_wan_info.json", &wanInfo); err != nil {
+		return nil, err
+	}
+	scrubWanInfo(&wanInfo)
+	return &wanInfo, nil
+}
+
+func (c *FileRouterClient) GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error) {
+	var wifiDetails models.WifiDetailAll
+	if err := c.load("xqnetwork_wifi_detail_all.json", &wifiDetails); err != nil {
+		return nil, err
+	}
+	scrubWifiDetails(&wifiDetails)
+	return &wifiDetails, nil
+}
+
+func (c *FileRouterClient) GetDiskStatus(ctx context.Context) (*models.DiskStatus, error) {
+	var diskStatus models.DiskStatus
+	if err := c.load("xqdisk_disk_info.json", &diskStatus); err != nil {
+		return nil, err
+	}
+	return &diskStatus, nil
+}
+
+func (c *FileRouterClient) GetSambaStatus(ctx context.Context) (*models.SambaStatus, error) {
+	var sambaStatus models.SambaStatus
+	if err := c.load("xqsystem_samba_status.json", &sambaStatus); err != nil {
+		return nil, err
+	}
+	return &sambaStatus, nil
+}
+
+func (c *FileRouterClient) GetSysInfo(ctx context.Context) (*models.SysInfo, error) {
+	var sysInfo models.SysInfo
+	if err := c.load("misystem_sys_info.json", &sysInfo); err != nil {
+		return nil, err
+	}
+	return &sysInfo, nil
+}
+
+func (c *FileRouterClient) GetPortStatus(ctx context.Context) (*models.PortStatus, error) {
+	var portStatus models.PortStatus
+	if err := c.load("xqnetwork_port_status.json", &portStatus); err != nil {
+		return nil, err
+	}
+	return &portStatus, nil
+}
+
+func (c *FileRouterClient) GetWPSStatus(ctx context.Context) (*models.WPSStatus, error) {
+	var wpsStatus models.WPSStatus
+	if err := c.load("xqnetwork_wps_status.json", &wpsStatus); err != nil {
+		return nil, err
+	}
+	return &wpsStatus, nil
+}
+
+func (c *FileRouterClient) GetTopoGraph(ctx context.Context) (*models.TopoGraph, error) {
+	var topoGraph models.TopoGraph
+	if err := c.load("misystem_topo_graph.json", &topoGraph); err != nil {
+		return nil, err
+	}
+	return &topoGraph, nil
+}
+
+func (c *FileRouterClient) GetWifiStatistics(ctx context.Context) (*models.WifiStatistics, error) {
+	var wifiStatistics models.WifiStatistics
+	if err := c.load("xqnetwork_wifi_statistics.json", &wifiStatistics); err != nil {
+		return nil, err
+	}
+	return &wifiStatistics, nil
+}
+
+func (c *FileRouterClient) GetIPTVStatus(ctx context.Context) (*models.IPTVStatus, error) {
+	var iptvStatus models.IPTVStatus
+	if err := c.load("xqnetwork_iptv.json", &iptvStatus); err != nil {
+		return nil, err
+	}
+	return &iptvStatus, nil
+}
+
+func (c *FileRouterClient) GetUPnPStatus(ctx context.Context) (*models.UPnPStatus, error) {
+	var upnpStatus models.UPnPStatus
+	if err := c.load("xqsystem_upnp.json", &upnpStatus); err != nil {
+		return nil, err
+	}
+	return &upnpStatus, nil
+}
+
+func (c *FileRouterClient) load(name string, v interface{}) error {
+	content, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return errors.NewNetworkError(fmt.Sprintf("fixture %s not available", name), err)
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return errors.NewInternalError(fmt.Sprintf("failed to decode fixture %s", name), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// loginVariant is one way of building the login nonce and password hash.
+// ROM builds disagree on both: some report the wrong newEncryptMode in
+// init_info and some only accept the nonce device type of the mobile app.
+type loginVariant struct {
+	nonceType int
+	sha256    bool
+}
+
+func (v loginVariant) String() string {
+	hash := "sha1"
+	if v.sha256 {
+		hash = "sha256"
+	}
+	return fmt.Sprintf("nonce type %d, %s", v.nonceType, hash)
+}
+
+// loginVariants lists the variants to try in order. The first one follows
+// the configuration and init_info; the fallbacks flip the hash and then the
+// nonce device type.
+func loginVariants(nonceType int, sha256, fallback bool) []loginVariant {
+	primary := loginVariant{nonceType: nonceType, sha256: sha256}
+	if !fallback {
+		return []loginVariant{primary}
+	}
+
+	otherType := 0
+	if nonceType == 0 {
+		otherType = 1
+	}
+	return []loginVariant{
+		primary,
+		{nonceType: nonceType, sha256: !sha256},
+		{nonceType: otherType, sha256: sha256},
+		{nonceType: otherType, sha256: !sha256},
+	}
+}
+
+// buildNonce builds the nonce the web interface sends with a login:
+// <device type>_<device id>_<unix time>_<random below 10000>
+func buildNonce(nonceType int, deviceID string, now time.Time) string {
+	return fmt.Sprintf("%d_%s_%d_%d", nonceType, deviceID, now.Unix(), rand.Intn(10000))
+}
@@ -0,0 +1,24 @@
+package client
+
+import "github.com/helloworlde/miwifi-exporter/internal/models"
+
+// Secret fields are blanked right after decoding so they never reach the
+// cache, snapshots, logs or any debug output.
+
+// scrubWanInfo removes the PPPoE password from WAN info
+func scrubWanInfo(wanInfo *models.WanInfo) {
+	if wanInfo == nil {
+		return
+	}
+	wanInfo.Info.Details.Password = ""
+}
+
+// scrubWifiDetails removes the WiFi passwords from WiFi details
+func scrubWifiDetails(wifiDetails *models.WifiDetailAll) {
+	if wifiDetails == nil {
+		return
+	}
+	for i := range wifiDetails.Info {
+		wifiDetails.Info[i].Password = ""
+	}
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/helloworlde/miwifi-exporter/pkg/cache"
+)
+
+const (
+	testWifiPassword  = "wifi-secret-5f2a"
+	testPPPoEPassword = "pppoe-secret-91c7"
+)
+
+var testResponses = map[string]string{
+	"xqnetwork/wan_info": `{"code":0,"info":{"details":{"username":"user@isp","wanType":"pppoe","password":"` +
+		testPPPoEPassword + `"},"ipv4":[{"ip":"100.64.0.2","mask":"255.255.255.0"}]}}`,
+	"xqnetwork/wifi_detail_all": `{"code":0,"info":[` +
+		`{"ifname":"wl0","ssid":"Home-5G","password":"` + testWifiPassword + `"},` +
+		`{"ifname":"wl1","ssid":"Home-2.4G","password":"` + testWifiPassword + `"}]}`,
+}
+
+// newTestClient returns a logged in client of a fake router that answers
+// testResponses
+func newTestClient(t *testing.T) *MiWiFiClient {
+	t.Helper()
+
+	router := newFakeRouter(t, 1, true)
+	for api, body := range testResponses {
+		router.respond(api, http.StatusOK, body)
+	}
+	c := router.client(false)
+	if err := c.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	return c
+}
+
+// fetchAll fetches WAN info and WiFi details through the client
+func fetchAll(t *testing.T, c RouterClient) (*models.WanInfo, *models.WifiDetailAll) {
+	t.Helper()
+
+	ctx := context.Background()
+	wanInfo, err := c.GetWanInfo(ctx)
+	if err != nil {
+		t.Fatalf("GetWanInfo: %v", err)
+	}
+	wifiDetails, err := c.GetWifiDetails(ctx)
+	if err != nil {
+		t.Fatalf("GetWifiDetails: %v", err)
+	}
+	return wanInfo, wifiDetails
+}
+
+func assertNoSecrets(t *testing.T, where, content string) {
+	t.Helper()
+
+	for _, secret := range []string{testWifiPassword, testPPPoEPassword} {
+		if strings.Contains(content, secret) {
+			t.Errorf("%s contains secret %q", where, secret)
+		}
+	}
+}
+
+func TestClientScrubsPasswordsAfterDecode(t *testing.T) {
+	wanInfo, wifiDetails := fetchAll(t, newTestClient(t))
+
+	if wanInfo.Info.Details.Password != "" {
+		t.Errorf("PPPoE password not scrubbed: %q", wanInfo.Info.Details.Password)
+	}
+	if wanInfo.Info.Details.Username != "user@isp" {
+		t.Errorf("non-secret fields must be kept, got username %q", wanInfo.Info.Details.Username)
+	}
+	if len(wifiDetails.Info) != 2 {
+		t.Fatalf("expected 2 WiFi networks, got %d", len(wifiDetails.Info))
+	}
+	for _, info := range wifiDetails.Info {
+		if info.Password != "" {
+			t.Errorf("WiFi password of %s not scrubbed: %q", info.Ssid, info.Password)
+		}
+	}
+}
+
+// TestPasswordsNeverReachSnapshotsOrLogs checks every place router data is
+// written to: the persisted snapshot, which is also the payload a debug API
+// would serve, and log lines dumping the data.
+func TestPasswordsNeverReachSnapshotsOrLogs(t *testing.T) {
+	wanInfo, wifiDetails := fetchAll(t, newTestClient(t))
+	data := struct {
+		WanInfo     *models.WanInfo
+		WifiDetails *models.WifiDetailAll
+	}{wanInfo, wifiDetails}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := cache.SaveSnapshot(path, data); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	snapshot, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	assertNoSecrets(t, "snapshot", string(snapshot))
+
+	dump, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	assertNoSecrets(t, "JSON dump", string(dump))
+
+	var logs bytes.Buffer
+	log := logger.NewWithOutput("debug", "text", &logs)
+	log.Debugf("wan info: %+v", *wanInfo)
+	log.Debugf("wifi details: %+v", *wifiDetails)
+	assertNoSecrets(t, "logs", logs.String())
+}
+
+func TestFileClientScrubsPasswords(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"xqnetwork_wan_info.json":        testResponses["xqnetwork/wan_info"],
+		"xqnetwork_wifi_detail_all.json": testResponses["xqnetwork/wifi_detail_all"],
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := NewFileRouterClient(dir)
+	if err != nil {
+		t.Fatalf("NewFileRouterClient: %v", err)
+	}
+
+	wanInfo, wifiDetails := fetchAll(t, c)
+	dump, err := json.Marshal([]interface{}{wanInfo, wifiDetails})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	assertNoSecrets(t, "file client data", string(dump))
+}
+
+// TestCurlCommandsMaskSecrets checks the curl commands logged for a login
+// and an API request
+func TestCurlCommandsMaskSecrets(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := logger.Default
+	logger.Default = logger.NewWithOutput("info", "text", &logs)
+	t.Cleanup(func() { logger.Default = defaultLogger })
+
+	router := newFakeRouter(t, 0, false)
+	c := router.client(false)
+	c.LogCurlCommands()
+	if err := c.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := c.GetSystemStatus(context.Background()); err != nil {
+		t.Fatalf("GetSystemStatus: %v", err)
+	}
+
+	output := logs.String()
+	for _, want := range []string{
+		"curl '" + router.server.URL + "/cgi-bin/luci/api/xqsystem/login'",
+		"--data-raw 'logtype=2&nonce=***&password=***&username=admin'",
+		"curl '" + router.server.URL + "/cgi-bin/luci/;stok=***/api/misystem/status'",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("logs do not contain %q:\n%s", want, output)
+		}
+	}
+	for _, secret := range []string{fakeRouterPassword, "token-1", fakeRouterDeviceID} {
+		if strings.Contains(output, secret) {
+			t.Errorf("logs contain secret %q", secret)
+		}
+	}
+}
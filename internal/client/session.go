@@ -0,0 +1,207 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// sessionFileVersion is bumped when the layout of persistedSession changes,
+// older files are then ignored
+const sessionFileVersion = 1
+
+// sessionFile is the on-disk layout of a persisted session. Data holds the
+// JSON encoded persistedSession sealed with AES-GCM.
+type sessionFile struct {
+	Version int    `json:"version"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// persistedSession is what a restarted exporter needs to resume a session
+type persistedSession struct {
+	Router   string           `json:"router"`
+	Username string           `json:"username"`
+	URL      string           `json:"url"`
+	Token    string           `json:"token"`
+	Cookies  []*http.Cookie   `json:"cookies"`
+	InitInfo *models.InitInfo `json:"init_info,omitempty"`
+	SavedAt  time.Time        `json:"saved_at"`
+}
+
+// sessionKeyPath returns the key file protecting the session file
+func (c *MiWiFiClient) sessionKeyPath() string {
+	if c.config.Router.SessionKeyFile != "" {
+		return c.config.Router.SessionKeyFile
+	}
+	return c.config.Router.SessionFile + ".key"
+}
+
+// sessionCipher reads the key file, creating it with a random key on first
+// use. Any content works as key, it is hashed to the AES-256 key size.
+func (c *MiWiFiClient) sessionCipher() (cipher.AEAD, error) {
+	path := c.sessionKeyPath()
+	secret, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+		secret = []byte(hex.EncodeToString(random) + "\n")
+		if err := os.WriteFile(path, secret, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to create session key file: %w", err)
+		}
+		logger.Default.Infof("Created session key file %s", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read session key file: %w", err)
+	}
+
+	trimmed := strings.TrimSpace(string(secret))
+	if trimmed == "" {
+		return nil, fmt.Errorf("session key file %s is empty", path)
+	}
+	key := sha256.Sum256([]byte(trimmed))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// routerURL is the URL the session cookies belong to
+func (c *MiWiFiClient) routerURL() *url.URL {
+	return &url.URL{Scheme: "http", Host: c.config.Router.IP, Path: "/"}
+}
+
+// saveSession persists the current session. c.authMu must be held.
+func (c *MiWiFiClient) saveSession() error {
+	if c.auth == nil {
+		return nil
+	}
+
+	session := persistedSession{
+		Router:   c.config.Router.IP,
+		Username: c.config.Router.Username,
+		URL:      c.auth.URL,
+		Token:    c.auth.Token,
+		InitInfo: c.initInfo.Load(),
+		SavedAt:  time.Now(),
+	}
+	if c.httpClient.Jar != nil {
+		session.Cookies = c.httpClient.Jar.Cookies(c.routerURL())
+	}
+
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	aead, err := c.sessionCipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	content, err := json.Marshal(sessionFile{
+		Version: sessionFileVersion,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// Replace the file atomically, the temporary file is created with mode 0600
+	path := c.config.Router.SessionFile
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create session file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace session file: %w", err)
+	}
+	return nil
+}
+
+// restoreSession resumes the session saved by a previous run. A session of
+// another router or user is ignored. If the router no longer accepts the
+// token, the first request logs in again as usual.
+func (c *MiWiFiClient) restoreSession() error {
+	content, err := os.ReadFile(c.config.Router.SessionFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var file sessionFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("failed to decode session file: %w", err)
+	}
+	if file.Version != sessionFileVersion {
+		return nil
+	}
+
+	aead, err := c.sessionCipher()
+	if err != nil {
+		return err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return fmt.Errorf("session file has an invalid nonce")
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt session file, was the key file replaced? %w", err)
+	}
+
+	var session persistedSession
+	if err := json.Unmarshal(plaintext, &session); err != nil {
+		return fmt.Errorf("failed to decode session: %w", err)
+	}
+	if session.Router != c.config.Router.IP || session.Username != c.config.Router.Username || session.Token == "" {
+		return nil
+	}
+
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	c.auth = &models.Auth{URL: session.URL, Token: session.Token, Code: 200}
+	if c.httpClient.Jar != nil && len(session.Cookies) > 0 {
+		c.httpClient.Jar.SetCookies(c.routerURL(), session.Cookies)
+	}
+	if session.InitInfo != nil {
+		c.initInfo.Store(session.InitInfo)
+	}
+
+	logger.Default.Infof("Resumed router session saved at %s", session.SavedAt.Format(time.RFC3339))
+	return nil
+}
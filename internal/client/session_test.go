@@ -0,0 +1,188 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+)
+
+// sessionConfig returns a configuration of the fake router persisting the
+// session in a temporary directory
+func sessionConfig(t *testing.T, router *fakeRouter) *config.Config {
+	t.Helper()
+
+	cfg := router.config(false)
+	cfg.Router.SessionFile = filepath.Join(t.TempDir(), "session")
+	return cfg
+}
+
+// loginAndSave logs in once so the session file of cfg holds a session
+func loginAndSave(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	if err := NewMiWiFiClient(cfg).Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := os.Stat(cfg.Router.SessionFile); err != nil {
+		t.Fatalf("session not saved: %v", err)
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	router := newFakeRouter(t, 1, true)
+	cfg := sessionConfig(t, router)
+	loginAndSave(t, cfg)
+
+	content, err := os.ReadFile(cfg.Router.SessionFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("token-1")) {
+		t.Errorf("session file contains the token in plain text: %s", content)
+	}
+	for _, path := range []string{cfg.Router.SessionFile, cfg.Router.SessionFile + ".key"} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("%s has mode %v, want 0600", path, perm)
+		}
+	}
+
+	// A restarted exporter resumes the session without logging in again
+	c := NewMiWiFiClient(cfg)
+	if state := c.AuthState(); state != AuthStateAuthenticated {
+		t.Fatalf("state after restoring is %v, want authenticated", state)
+	}
+	if token := c.token(); token != "token-1" {
+		t.Errorf("restored token %q, want token-1", token)
+	}
+	if info := c.InitInfo(); info == nil || info.Hardware != "RB03" {
+		t.Errorf("init_info not restored: %+v", info)
+	}
+	if _, err := c.GetSystemStatus(context.Background()); err != nil {
+		t.Fatalf("GetSystemStatus with the restored session: %v", err)
+	}
+	if logins, _, _ := router.counts(""); logins != 1 {
+		t.Errorf("router saw %d logins, want the restored session to be used", logins)
+	}
+}
+
+func TestSessionRejectsWrongKeyOrTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, cfg *config.Config)
+	}{
+		{
+			name: "replaced key",
+			modify: func(t *testing.T, cfg *config.Config) {
+				if err := os.WriteFile(cfg.Router.SessionFile+".key", []byte("another key\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "tampered data",
+			modify: func(t *testing.T, cfg *config.Config) {
+				content, err := os.ReadFile(cfg.Router.SessionFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var file sessionFile
+				if err := json.Unmarshal(content, &file); err != nil {
+					t.Fatal(err)
+				}
+				file.Data[len(file.Data)/2] ^= 0xff
+				content, _ = json.Marshal(file)
+				if err := os.WriteFile(cfg.Router.SessionFile, content, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newFakeRouter(t, 1, true)
+			cfg := sessionConfig(t, router)
+			loginAndSave(t, cfg)
+			tt.modify(t, cfg)
+
+			c := NewMiWiFiClient(cfg)
+			if state := c.AuthState(); state != AuthStateUnauthenticated {
+				t.Errorf("state after restoring is %v, want unauthenticated", state)
+			}
+			if err := c.restoreSession(); err == nil {
+				t.Errorf("restoreSession accepted the session")
+			}
+		})
+	}
+}
+
+func TestSessionOfAnotherRouterOrUserIgnored(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config.RouterConfig)
+	}{
+		{name: "router", modify: func(cfg *config.RouterConfig) { cfg.IP = "192.0.2.1" }},
+		{name: "user", modify: func(cfg *config.RouterConfig) { cfg.Username = "guest" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newFakeRouter(t, 1, true)
+			cfg := sessionConfig(t, router)
+			loginAndSave(t, cfg)
+
+			other := *cfg
+			tt.modify(&other.Router)
+			c := NewMiWiFiClient(&other)
+			if err := c.restoreSession(); err != nil {
+				t.Fatalf("restoreSession: %v", err)
+			}
+			if state := c.AuthState(); state != AuthStateUnauthenticated {
+				t.Errorf("state after restoring is %v, want unauthenticated", state)
+			}
+			if info := c.InitInfo(); info != nil {
+				t.Errorf("init_info of another session restored: %+v", info)
+			}
+		})
+	}
+}
+
+func TestReplayClientIgnoresSessionFile(t *testing.T) {
+	router := newFakeRouter(t, 1, true)
+	cfg := sessionConfig(t, router)
+	loginAndSave(t, cfg)
+	saved, err := os.ReadFile(cfg.Router.SessionFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewReplayClient(cfg, t.TempDir())
+	if token := c.token(); token != "replay" {
+		t.Errorf("replay client uses token %q, want replay", token)
+	}
+	if info := c.InitInfo(); info != nil {
+		t.Errorf("replay client restored init_info of the live session: %+v", info)
+	}
+	if cookies := c.httpClient.Jar.Cookies(c.routerURL()); len(cookies) > 0 {
+		t.Errorf("replay client restored cookies of the live session: %v", cookies)
+	}
+	if cfg.Router.SessionFile == "" {
+		t.Errorf("NewReplayClient modified the configuration")
+	}
+
+	// Logging in against the recordings, successful or not, never saves
+	// over the session of the live exporter
+	c.Authenticate(context.Background())
+	if content, err := os.ReadFile(cfg.Router.SessionFile); err != nil || !bytes.Equal(content, saved) {
+		t.Errorf("replay client changed the session file")
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+)
+
+// responseSnippetSize bounds the part of an error response kept for
+// diagnostics
+const responseSnippetSize = 256
+
+// HTTPStatusError is a router response with an unexpected HTTP status. Such
+// a response is usually an HTML error page, decoding it as JSON would only
+// report an invalid character.
+type HTTPStatusError struct {
+	Endpoint   string
+	StatusCode int
+	// Snippet is the start of the body with whitespace collapsed
+	Snippet string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("%s answered HTTP %d", e.Endpoint, e.StatusCode)
+	}
+	return fmt.Sprintf("%s answered HTTP %d: %q", e.Endpoint, e.StatusCode, e.Snippet)
+}
+
+// responseSnippet reads the start of an error response body
+func responseSnippet(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, responseSnippetSize))
+	return strings.Join(strings.Fields(strings.ToValidUTF8(string(data), "")), " ")
+}
+
+// checkStatus returns an error for a response without a 2xx status,
+// classified by what helps: a 401 drops the session token and is an
+// authentication error so the request is repeated after logging in again,
+// timeouts and server errors are retried, and any other status, such as the
+// 404 of an API the firmware lacks, is not.
+func (c *MiWiFiClient) checkStatus(resp *http.Response, endpoint, token string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	statusErr := &HTTPStatusError{
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+		Snippet:    responseSnippet(resp.Body),
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		if token != "" {
+			c.invalidateToken(token)
+		}
+		return errors.NewAuthenticationError("session rejected", statusErr)
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		return errors.NewTimeoutError("router timed out", statusErr)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return errors.NewNetworkError("router failed the request", statusErr)
+	}
+	return errors.NewRouterError("request rejected", statusErr)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// CheckROMUpdate asks the router whether a newer firmware is available. It
+// is not part of RouterClient because it is not collected on every scrape.
+func (c *MiWiFiClient) CheckROMUpdate(ctx context.Context) (*models.ROMUpdate, error) {
+	var result *models.ROMUpdate
+	err := c.withSession(ctx, func() error {
+		update, err := c.checkROMUpdate(ctx)
+		if err != nil {
+			return err
+		}
+		result = update
+		return nil
+	})
+
+	return result, err
+}
+
+func (c *MiWiFiClient) checkROMUpdate(ctx context.Context) (*models.ROMUpdate, error) {
+	const endpoint = "xqsystem/check_rom_update"
+	token := c.token()
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/api/%s",
+		c.config.Router.IP, token, endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to create request", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewNetworkError("failed to check for firmware updates", err)
+	}
+	defer resp.Body.Close()
+	if err := c.checkStatus(resp, endpoint, token); err != nil {
+		return nil, err
+	}
+
+	var update models.ROMUpdate
+	if err := c.decodeResponse(resp, &update); err != nil {
+		return nil, decodeError("firmware update check", err)
+	}
+	if err := c.checkCode(endpoint, update.Code, update.Msg, token); err != nil {
+		return nil, err
+	}
+	return &update, nil
+}
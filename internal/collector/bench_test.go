@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/client"
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// networkSizes are the device counts the scrape is measured with: a flat, a
+// busy home network and a large office
+var networkSizes = []int{10, 100, 1000}
+
+// fixtureClient answers from models held in memory, so benchmarks measure
+// the collector rather than reading and decoding fixture files
+type fixtureClient struct {
+	systemStatus   *models.SystemStatus
+	deviceList     *models.DeviceList
+	wanInfo        *models.WanInfo
+	wifiDetails    *models.WifiDetailAll
+	diskStatus     *models.DiskStatus
+	sambaStatus    *models.SambaStatus
+	sysInfo        *models.SysInfo
+	portStatus     *models.PortStatus
+	wpsStatus      *models.WPSStatus
+	topoGraph      *models.TopoGraph
+	wifiStatistics *models.WifiStatistics
+	iptvStatus     *models.IPTVStatus
+	upnpStatus     *models.UPnPStatus
+}
+
+// newFixtureClient loads the demo fixtures and replaces their devices with
+// devices generated from the first demo device
+func newFixtureClient(tb testing.TB, devices int) *fixtureClient {
+	tb.Helper()
+
+	demo, err := client.NewFileRouterClient("../../fixtures/demo")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ctx := context.Background()
+	fc := &fixtureClient{}
+	steps := []func() error{
+		func() (err error) { fc.systemStatus, err = demo.GetSystemStatus(ctx); return },
+		func() (err error) { fc.deviceList, err = demo.GetDeviceList(ctx); return },
+		func() (err error) { fc.wanInfo, err = demo.GetWanInfo(ctx); return },
+		func() (err error) { fc.wifiDetails, err = demo.GetWifiDetails(ctx); return },
+		func() (err error) { fc.diskStatus, err = demo.GetDiskStatus(ctx); return },
+		func() (err error) { fc.sambaStatus, err = demo.GetSambaStatus(ctx); return },
+		func() (err error) { fc.sysInfo, err = demo.GetSysInfo(ctx); return },
+		func() (err error) { fc.portStatus, err = demo.GetPortStatus(ctx); return },
+		func() (err error) { fc.wpsStatus, err = demo.GetWPSStatus(ctx); return },
+		func() (err error) { fc.topoGraph, err = demo.GetTopoGraph(ctx); return },
+		func() (err error) { fc.wifiStatistics, err = demo.GetWifiStatistics(ctx); return },
+		func() (err error) { fc.iptvStatus, err = demo.GetIPTVStatus(ctx); return },
+		func() (err error) { fc.upnpStatus, err = demo.GetUPnPStatus(ctx); return },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if len(fc.deviceList.List) == 0 || len(fc.systemStatus.Dev) == 0 {
+		tb.Fatal("demo fixtures have no devices")
+	}
+
+	entryTemplate := fc.deviceList.List[0]
+	infoTemplate := fc.systemStatus.Dev[0]
+	list := make([]models.DeviceEntry, devices)
+	dev := make([]models.DeviceInfo, devices)
+	for i := range list {
+		mac := fmt.Sprintf("02:00:00:00:%02X:%02X", i>>8, i&0xff)
+
+		entry := entryTemplate
+		entry.Mac = mac
+		entry.Name = fmt.Sprintf("device-%d", i)
+		entry.IP = []models.IPInfo{{IP: fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff)}}
+		list[i] = entry
+
+		info := infoTemplate
+		info.Mac = mac
+		info.DevName = entry.Name
+		dev[i] = info
+	}
+
+	deviceList := *fc.deviceList
+	deviceList.List = list
+	fc.deviceList = &deviceList
+	systemStatus := *fc.systemStatus
+	systemStatus.Dev = dev
+	fc.systemStatus = &systemStatus
+	return fc
+}
+
+func (c *fixtureClient) GetSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
+	return c.systemStatus, nil
+}
+
+func (c *fixtureClient) GetDeviceList(ctx context.Context) (*models.DeviceList, error) {
+	return c.deviceList, nil
+}
+
+func (c *fixtureClient) GetWanInfo(ctx context.Context) (*models.WanInfo, error) {
+	return c.wanInfo, nil
+}
+
+func (c *fixtureClient) GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error) {
+	return c.wifiDetails, nil
+}
+
+func (c *fixtureClient) GetDiskStatus(ctx context.Context) (*models.DiskStatus, error) {
+	return c.diskStatus, nil
+}
+
+func (c *fixtureClient) GetSambaStatus(ctx context.Context) (*models.SambaStatus, error) {
+	return c.sambaStatus, nil
+}
+
+func (c *fixtureClient) GetSysInfo(ctx context.Context) (*models.SysInfo, error) {
+	return c.sysInfo, nil
+}
+
+func (c *fixtureClient) GetPortStatus(ctx context.Context) (*models.PortStatus, error) {
+	return c.portStatus, nil
+}
+
+func (c *fixtureClient) GetWPSStatus(ctx context.Context) (*models.WPSStatus, error) {
+	return c.wpsStatus, nil
+}
+
+func (c *fixtureClient) GetTopoGraph(ctx context.Context) (*models.TopoGraph, error) {
+	return c.topoGraph, nil
+}
+
+func (c *fixtureClient) GetWifiStatistics(ctx context.Context) (*models.WifiStatistics, error) {
+	return c.wifiStatistics, nil
+}
+
+func (c *fixtureClient) GetIPTVStatus(ctx context.Context) (*models.IPTVStatus, error) {
+	return c.iptvStatus, nil
+}
+
+func (c *fixtureClient) GetUPnPStatus(ctx context.Context) (*models.UPnPStatus, error) {
+	return c.upnpStatus, nil
+}
+
+func (c *fixtureClient) Authenticate(ctx context.Context) error {
+	return nil
+}
+
+// benchmarkCollector returns a collector scraping a network of the given
+// size, with or without the cache in front of the router
+func benchmarkCollector(tb testing.TB, devices int, cached bool) *MetricsCollector {
+	tb.Helper()
+
+	mc := NewMetricsCollector(&config.Config{
+		Router:     config.RouterConfig{Host: "miwifi", Timeout: 5},
+		Server:     config.ServerConfig{Namespace: "miwifi", MaxLabelLength: 128},
+		Cache:      config.CacheConfig{Enabled: cached, TTL: time.Hour},
+		Fetch:      config.FetchConfig{Parallelism: 4},
+		Collectors: config.CollectorsConfig{TopDevices: 5, EmptyLabels: emptyLabelsUnknown},
+	})
+	mc.SetClient(newFixtureClient(tb, devices))
+	return mc
+}
+
+// benchmarkCollect measures full scrapes, from fetching the router data to
+// the gathered metric families
+func benchmarkCollect(b *testing.B, devices int, cached bool) {
+	mc := benchmarkCollector(b, devices, cached)
+	defer mc.Close()
+
+	gatherer := mc.Gatherer(context.Background())
+	// Fill the cache so cached runs never fetch
+	if _, err := gatherer.Gather(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := gatherer.Gather(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	for _, devices := range networkSizes {
+		b.Run(fmt.Sprintf("devices=%d", devices), func(b *testing.B) {
+			benchmarkCollect(b, devices, false)
+		})
+	}
+}
+
+func BenchmarkCollectCached(b *testing.B) {
+	for _, devices := range networkSizes {
+		b.Run(fmt.Sprintf("devices=%d", devices), func(b *testing.B) {
+			benchmarkCollect(b, devices, true)
+		})
+	}
+}
+
+// scrapeBudgets bound an uncached scrape per network size. Allocations are
+// about three times and latencies about six times what a scrape takes today,
+// so they only fail on a real regression, not on a busy machine.
+var scrapeBudgets = []struct {
+	devices     int
+	allocsPerOp int64
+	latency     time.Duration
+}{
+	{devices: 10, allocsPerOp: 16000, latency: 5 * time.Millisecond},
+	{devices: 100, allocsPerOp: 80000, latency: 20 * time.Millisecond},
+	{devices: 1000, allocsPerOp: 700000, latency: 250 * time.Millisecond},
+}
+
+// TestScrapeBudgets fails when a scrape allocates or takes more than its
+// budget. It runs for a few seconds and is skipped with -short; latencies are
+// not checked under the race detector.
+func TestScrapeBudgets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("benchmarks skipped in short mode")
+	}
+
+	for _, budget := range scrapeBudgets {
+		budget := budget
+		t.Run(fmt.Sprintf("devices=%d", budget.devices), func(t *testing.T) {
+			result := testing.Benchmark(func(b *testing.B) {
+				benchmarkCollect(b, budget.devices, false)
+			})
+			if result.N == 0 {
+				t.Fatal("benchmark did not run")
+			}
+
+			if allocs := result.AllocsPerOp(); allocs > budget.allocsPerOp {
+				t.Errorf("scrape of %d devices allocates %d times, budget is %d", budget.devices, allocs, budget.allocsPerOp)
+			}
+			if latency := time.Duration(result.NsPerOp()); !raceEnabled && latency > budget.latency {
+				t.Errorf("scrape of %d devices takes %v, budget is %v", budget.devices, latency, budget.latency)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterBuildInfo exports <namespace>_exporter_build_info with the version
+// and commit the exporter was built from. Call it before CheckConstLabels.
+func (mc *MetricsCollector) RegisterBuildInfo(version, commit string) {
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: mc.config.Server.Namespace,
+		Name:      "exporter_build_info",
+		Help:      "导出器的版本信息，值恒为 1",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"commit":    commit,
+			"goversion": runtime.Version(),
+		},
+	})
+	buildInfo.Set(1)
+	mc.register(buildInfo)
+}
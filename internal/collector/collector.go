@@ -2,21 +2,36 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/helloworlde/miwifi-exporter/internal/client"
 	"github.com/helloworlde/miwifi-exporter/internal/config"
+	apperrors "github.com/helloworlde/miwifi-exporter/internal/errors"
 	"github.com/helloworlde/miwifi-exporter/internal/logger"
 	"github.com/helloworlde/miwifi-exporter/internal/metrics"
 	"github.com/helloworlde/miwifi-exporter/internal/models"
 	"github.com/helloworlde/miwifi-exporter/pkg/cache"
 	"github.com/helloworlde/miwifi-exporter/pkg/concurrent"
+	httputil "github.com/helloworlde/miwifi-exporter/pkg/http"
+	"github.com/helloworlde/miwifi-exporter/pkg/loki"
+	"github.com/helloworlde/miwifi-exporter/pkg/notify"
 	"github.com/helloworlde/miwifi-exporter/pkg/memory"
+	"github.com/helloworlde/miwifi-exporter/pkg/probe"
+	"github.com/helloworlde/miwifi-exporter/pkg/syslog"
+	"github.com/helloworlde/miwifi-exporter/pkg/tracing"
 	"github.com/helloworlde/miwifi-exporter/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MetricsCollector struct {
@@ -25,10 +40,75 @@ type MetricsCollector struct {
 	cache          *cache.RouterSmartCache
 	dataFetcher    *concurrent.DataFetcher
 	metrics        *prometheus.Registry
+	// registerErr is the first error registering the exporter's own metrics
+	registerErr    error
 	descriptors    map[string]*prometheus.Desc
+	plugins        []Plugin
 	collectorMetrics *metrics.CollectorMetrics
 	memoryMonitor  *memory.MemoryMonitor
-	mutex          sync.RWMutex
+	// rates is only set when device rates are enabled
+	rates          *rateTracker
+	// prober is only set when probes are enabled
+	prober         *probe.Prober
+	// syslog is only set when router log collection is enabled
+	syslog         *syslog.Receiver
+	// loki is only set when something is pushed to Loki
+	loki           *loki.Pusher
+	lokiErrors     prometheus.Counter
+	// presence is only set when device events are tracked
+	presence       *presenceTracker
+	// inventory is only set when expected devices are declared
+	inventory      *inventoryTracker
+	// notifier is only set when a notification channel is configured
+	notifier       *notify.Notifier
+	// current is the latest collection, exported without locking
+	current        atomic.Pointer[collection]
+	// refreshMu serializes router fetches and guards restored
+	refreshMu      sync.Mutex
+	restored       *models.RouterData
+	startedAt      time.Time
+	// lastSuccess is the UnixNano time of the last successful router fetch
+	lastSuccess    atomic.Int64
+	// cachedAt is the UnixNano time the cached router data was fetched
+	cachedAt       atomic.Int64
+}
+
+// collection is the result of one refresh of the router data, immutable
+// apart from its holders
+type collection struct {
+	data       *models.RouterData
+	err        error
+	// stale is set when data is the persisted snapshot
+	stale      bool
+	finishedAt time.Time
+	// refs counts the holders: mc.current while the collection is current,
+	// and every reader between acquire and unref. The last one calls
+	// release.
+	refs       atomic.Int32
+	// release hands the device list back to the router client for reuse,
+	// nil when the data is shared with the cache or the snapshot
+	release    func()
+}
+
+// acquire adds a reader of c. It fails once c was released.
+func (c *collection) acquire() bool {
+	for {
+		refs := c.refs.Load()
+		if refs == 0 {
+			return false
+		}
+		if c.refs.CompareAndSwap(refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// unref drops a holder of c and releases its data after the last one. A nil
+// c is ignored.
+func (c *collection) unref() {
+	if c != nil && c.refs.Add(-1) == 0 && c.release != nil {
+		c.release()
+	}
 }
 
 type Metrics struct {
@@ -60,28 +140,122 @@ type Metrics struct {
 	WifiDetail      *prometheus.Desc
 }
 
+// authStateClient is implemented by router clients that keep a login session
+type authStateClient interface {
+	AuthState() client.AuthState
+}
+
+// authFailuresClient is implemented by router clients that count failed
+// logins
+type authFailuresClient interface {
+	AuthFailures() map[string]uint64
+}
+
+// routerInfoClient is implemented by router clients that know the router
+// model and firmware from init_info
+type routerInfoClient interface {
+	InitInfo() *models.InitInfo
+}
+
+// deviceListReleaser is implemented by router clients that reuse the device
+// lists handed back to them
+type deviceListReleaser interface {
+	ReleaseDeviceList(list *models.DeviceList)
+}
+
+// instrumentedClient is implemented by router clients that can report
+// per-request HTTP metrics
+type instrumentedClient interface {
+	InstrumentTransport(collector httputil.MetricsCollector)
+}
+
 func NewMetricsCollector(cfg *config.Config) *MetricsCollector {
 	mc := &MetricsCollector{
 		config:      cfg,
-		cache:       cache.NewRouterSmartCache(cfg.Cache.TTL, 1000, true),
+		cache:       cache.NewRouterSmartCache(cfg.Cache.TTL, 1000, cfg.Cache.Enabled && cfg.Cache.Preload),
 		dataFetcher: concurrent.NewDataFetcher(
-			time.Duration(cfg.Router.Timeout)*time.Second,
-			3,
-			5*time.Second,
+			cfg.Router.TimeoutOr(cfg.Router.CollectTimeout),
+			cfg.Fetch.Retries,
+			cfg.Fetch.RetryDelay,
 		),
-		collectorMetrics: metrics.NewCollectorMetrics(cfg.Server.Namespace),
-		memoryMonitor:   memory.NewMemoryMonitor(cfg.Server.Namespace),
+		collectorMetrics: metrics.NewCollectorMetrics(cfg.Server.Namespace, cfg.Histogram),
+		startedAt:       time.Now(),
+	}
+	
+	// The memory monitor only exists when enabled, so a disabled monitor
+	// costs no pools
+	if cfg.Memory.Enabled {
+		mc.memoryMonitor = memory.NewMemoryMonitor(cfg.Server.Namespace)
+	}
+	// Export the duplicate counters from the start, they should stay at 0
+	mc.collectorMetrics.RecordDuplicateDevices(duplicatesDeviceList, 0)
+	mc.collectorMetrics.RecordDuplicateDevices(duplicatesSystemStatus, 0)
+	if cfg.Collectors.DeviceRates {
+		mc.rates = newRateTracker()
+	}
+	if cfg.Probe.Enabled {
+		prober, err := probe.New(cfg.Probe, cfg.Server.Namespace, mc.probeTargets)
+		if err != nil {
+			logger.Default.Errorf("Probes disabled: %v", err)
+		} else {
+			mc.prober = prober
+		}
+	}
+	if cfg.Syslog.Enabled && cfg.Syslog.ForwardLoki || cfg.Loki.DeviceEvents {
+		mc.setupLoki()
+	}
+	mc.notifier = notify.New(cfg.Notify, cfg.Server.Namespace)
+	if cfg.Collectors.DeviceEvents || cfg.Collectors.KnownDevicesFile != "" || cfg.Loki.DeviceEvents || mc.notifier.Enabled(notify.EventNewDevice) {
+		mc.presence = newPresenceTracker(cfg.Server.Namespace, cfg.Collectors.KnownDevicesFile, cfg.Server.MaxLabelLength)
+	}
+	if len(cfg.Inventory.Devices) > 0 {
+		mc.inventory = newInventoryTracker(cfg.Inventory, cfg.Server.Namespace, cfg.Server.MaxLabelLength, mc.startedAt)
+	}
+	if cfg.Syslog.Enabled {
+		receiver, err := syslog.New(cfg.Syslog, cfg.Server.Namespace, cfg.Router.Host, mc.loki)
+		if err != nil {
+			logger.Default.Errorf("Router log collection disabled: %v", err)
+		} else {
+			mc.syslog = receiver
+		}
 	}
 
 	mc.initializeMetrics()
 	mc.initializeDescriptors()
 	
+	mc.dataFetcher.SetParallelism(cfg.Fetch.Parallelism)
+	mc.dataFetcher.SetMaxRetryDelay(cfg.Fetch.RetryMaxDelay)
+	mc.dataFetcher.SetObserver(mc.observeFetchTask)
+	mc.configureFetchTasks()
+	mc.configurePlugins()
+	
+	// Restore the last known data so scrapes have something to serve until
+	// the router answers
+	if cfg.Cache.SnapshotFile != "" {
+		mc.loadSnapshot()
+	}
+	
+	// Serve expired data while refreshing in the background
+	if cfg.Cache.Enabled && cfg.Cache.MaxStale > 0 {
+		mc.cache.SetStaleWhileRevalidate(cfg.Cache.MaxStale, mc.refreshCache)
+	}
+	
+	// Refresh the cache before it expires while scrapes keep coming
+	if cfg.Cache.Enabled && cfg.Cache.Preload {
+		interval := cfg.Cache.PreloadInterval
+		if interval <= 0 {
+			interval = cfg.Cache.TTL / 2
+		}
+		mc.cache.SetPreloader(mc.refreshCache, cache.PreloadOptions{
+			Interval:  interval,
+			Jitter:    cfg.Cache.PreloadJitter,
+			IdleAfter: cfg.Cache.PreloadIdleAfter,
+		})
+	}
+	
 	// Configure memory monitor
 	if mc.memoryMonitor != nil {
 		mc.memoryMonitor.Configure(
-			cfg.Memory.Enabled,
-			cfg.Memory.OptimizeOnCollect,
-			cfg.Memory.ForceGCOnClose,
 			cfg.Memory.TrackAllocations,
 			cfg.Memory.EnablePoolStats,
 		)
@@ -91,14 +265,56 @@ func NewMetricsCollector(cfg *config.Config) *MetricsCollector {
 }
 
 func (mc *MetricsCollector) initializeMetrics() {
+	// The collector itself is registered per scrape, see Gatherer
 	mc.metrics = prometheus.NewRegistry()
-	mc.metrics.MustRegister(mc)
-	mc.metrics.MustRegister(mc.collectorMetrics)
-	mc.metrics.MustRegister(mc.memoryMonitor)
+	selfMetrics := []prometheus.Collector{mc.collectorMetrics}
+	if mc.memoryMonitor != nil {
+		selfMetrics = append(selfMetrics, mc.memoryMonitor)
+	}
+	if mc.prober != nil {
+		selfMetrics = append(selfMetrics, mc.prober)
+	}
+	if mc.syslog != nil {
+		selfMetrics = append(selfMetrics, mc.syslog)
+	}
+	if mc.loki != nil {
+		selfMetrics = append(selfMetrics, mc.lokiErrors)
+	}
+	if mc.presence != nil {
+		selfMetrics = append(selfMetrics, mc.presence)
+	}
+	if mc.inventory != nil {
+		selfMetrics = append(selfMetrics, mc.inventory)
+	}
+	if mc.notifier != nil {
+		selfMetrics = append(selfMetrics, mc.notifier)
+	}
+	if mc.config.Server.RuntimeMetrics {
+		selfMetrics = append(selfMetrics,
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	
+	for _, c := range selfMetrics {
+		mc.register(c)
+	}
+}
+
+// register adds c to the exporter's own metrics. Constant labels clashing
+// with a metric label fail the registration, CheckConstLabels reports the
+// error.
+func (mc *MetricsCollector) register(c prometheus.Collector) {
+	registerer := prometheus.WrapRegistererWith(mc.config.Server.ConstLabels, mc.metrics)
+	if err := registerer.Register(c); err != nil && mc.registerErr == nil {
+		mc.registerErr = err
+	}
 }
 
 func (mc *MetricsCollector) initializeDescriptors() {
-	namespace := mc.config.Server.Namespace
+	// The subsystem only applies to router metrics, the exporter's own
+	// metrics keep their names
+	namespace := mc.config.Server.MetricPrefix()
 
 	mc.descriptors = map[string]*prometheus.Desc{
 		"cpu_cores": prometheus.NewDesc(
@@ -116,6 +332,11 @@ func (mc *MetricsCollector) initializeDescriptors() {
 			"CPU负载百分比",
 			[]string{"host"}, nil,
 		),
+		"cpu_core_load": prometheus.NewDesc(
+			fmt.Sprintf("%s_cpu_core_load", namespace),
+			"单个CPU核心负载百分比",
+			[]string{"host", "core"}, nil,
+		),
 		"memory_total_mb": prometheus.NewDesc(
 			fmt.Sprintf("%s_memory_total_mb", namespace),
 			"总内存(MB)",
@@ -131,6 +352,16 @@ func (mc *MetricsCollector) initializeDescriptors() {
 			"内存使用率",
 			[]string{"host"}, nil,
 		),
+		"conntrack_entries": prometheus.NewDesc(
+			fmt.Sprintf("%s_conntrack_entries", namespace),
+			"连接跟踪(conntrack)表当前条目数",
+			[]string{"host"}, nil,
+		),
+		"conntrack_max": prometheus.NewDesc(
+			fmt.Sprintf("%s_conntrack_max", namespace),
+			"连接跟踪(conntrack)表最大条目数，达到后新连接会被丢弃",
+			[]string{"host"}, nil,
+		),
 		"count_all": prometheus.NewDesc(
 			fmt.Sprintf("%s_count_all", namespace),
 			"设备总数",
@@ -191,6 +422,21 @@ func (mc *MetricsCollector) initializeDescriptors() {
 			"路由器IPv6地址",
 			[]string{"ipv6"}, nil,
 		),
+		"ipv6_prefix_length": prometheus.NewDesc(
+			fmt.Sprintf("%s_ipv6_prefix_length", namespace),
+			"IPv6委派前缀长度",
+			[]string{"host", "prefix"}, nil,
+		),
+		"ipv6_lan_addresses": prometheus.NewDesc(
+			fmt.Sprintf("%s_ipv6_lan_addresses", namespace),
+			"LAN侧IPv6地址数量",
+			[]string{"host"}, nil,
+		),
+		"ipv6_wan_type": prometheus.NewDesc(
+			fmt.Sprintf("%s_ipv6_wan_type", namespace),
+			"IPv6 WAN连接类型",
+			[]string{"host", "wan_type", "ifname"}, nil,
+		),
 		"wan_upload_speed": prometheus.NewDesc(
 			fmt.Sprintf("%s_wan_upload_speed", namespace),
 			"WAN上传速度",
@@ -201,6 +447,21 @@ func (mc *MetricsCollector) initializeDescriptors() {
 			"WAN下载速度",
 			[]string{"host"}, nil,
 		),
+		"wan_max_upload_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_max_upload_speed", namespace),
+			"WAN最大上传速度",
+			[]string{"host"}, nil,
+		),
+		"wan_max_download_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_max_download_speed", namespace),
+			"WAN最大下载速度",
+			[]string{"host"}, nil,
+		),
+		"wan_speed_history": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_speed_history", namespace),
+			"路由器记录的WAN近期速度序列，sum/count为近期平均速度",
+			[]string{"host"}, nil,
+		),
 		"wan_upload_traffic": prometheus.NewDesc(
 			fmt.Sprintf("%s_wan_upload_traffic", namespace),
 			"WAN上传流量",
@@ -211,138 +472,568 @@ func (mc *MetricsCollector) initializeDescriptors() {
 			"WAN下载流量",
 			[]string{"host"}, nil,
 		),
+		"wan_mode_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_mode_info", namespace),
+			"双WAN工作模式，balance为负载均衡，failover为主备切换",
+			[]string{"host", "mode"}, nil,
+		),
+		"wan_interface_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_info", namespace),
+			"双WAN路由器的WAN口信息",
+			[]string{"host", "interface", "ifname", "wan_type", "ip"}, nil,
+		),
+		"wan_interface_link_up": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_link_up", namespace),
+			"WAN口网线是否连接",
+			[]string{"host", "interface"}, nil,
+		),
+		"wan_interface_active": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_active", namespace),
+			"WAN口是否正在承载流量，主备模式下备用口为0",
+			[]string{"host", "interface"}, nil,
+		),
+		"wan_interface_uptime_seconds": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_uptime_seconds", namespace),
+			"WAN口连接时长",
+			[]string{"host", "interface"}, nil,
+		),
+		"wan_interface_upload_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_upload_speed", namespace),
+			"WAN口上传速度",
+			[]string{"host", "interface"}, nil,
+		),
+		"wan_interface_download_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_download_speed", namespace),
+			"WAN口下载速度",
+			[]string{"host", "interface"}, nil,
+		),
+		"wan_interface_upload_traffic": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_upload_traffic", namespace),
+			"WAN口上传流量",
+			[]string{"host", "interface"}, nil,
+		),
+		"wan_interface_download_traffic": prometheus.NewDesc(
+			fmt.Sprintf("%s_wan_interface_download_traffic", namespace),
+			"WAN口下载流量",
+			[]string{"host", "interface"}, nil,
+		),
+		"iptv_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_iptv_info", namespace),
+			"IPTV设置：是否启用、模式(bridge透传或vlan标记)、机顶盒LAN口和各业务的VLAN ID",
+			[]string{"host", "enabled", "mode", "port", "internet_vlan", "iptv_vlan", "voip_vlan"}, nil,
+		),
+		"upnp_enabled": prometheus.NewDesc(
+			fmt.Sprintf("%s_upnp_enabled", namespace),
+			"UPnP是否启用",
+			[]string{"host"}, nil,
+		),
+		"upnp_mappings": prometheus.NewDesc(
+			fmt.Sprintf("%s_upnp_mappings", namespace),
+			"当前UPnP端口映射数量",
+			[]string{"host"}, nil,
+		),
+		"upnp_mapping_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_upnp_mapping_info", namespace),
+			"UPnP端口映射：协议、外部端口、客户端IP和端口及客户端填写的描述",
+			[]string{"host", "protocol", "external_port", "client_ip", "client_port", "description"}, nil,
+		),
 		"device_upload_traffic": prometheus.NewDesc(
 			fmt.Sprintf("%s_device_upload_traffic", namespace),
 			"设备上传流量",
-			[]string{"ip", "mac", "device_name", "is_ap"}, nil,
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
 		),
 		"device_upload_speed": prometheus.NewDesc(
 			fmt.Sprintf("%s_device_upload_speed", namespace),
 			"设备上传速度",
-			[]string{"ip", "mac", "device_name", "is_ap"}, nil,
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
 		),
 		"device_download_traffic": prometheus.NewDesc(
 			fmt.Sprintf("%s_device_download_traffic", namespace),
 			"设备下载流量",
-			[]string{"ip", "mac", "device_name", "is_ap"}, nil,
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
+		),
+		"device_max_upload_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_device_max_upload_speed", namespace),
+			"设备最大上传速度",
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
+		),
+		"device_max_download_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_device_max_download_speed", namespace),
+			"设备最大下载速度",
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
 		),
 		"device_download_speed": prometheus.NewDesc(
 			fmt.Sprintf("%s_device_download_speed", namespace),
 			"设备下载速度",
-			[]string{"ip", "mac", "device_name", "is_ap"}, nil,
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
+		),
+		"device_upload_bytes_per_second": prometheus.NewDesc(
+			fmt.Sprintf("%s_device_upload_bytes_per_second", namespace),
+			"设备在两次抓取路由器数据之间的平均上传速率（字节/秒）",
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
+		),
+		"device_download_bytes_per_second": prometheus.NewDesc(
+			fmt.Sprintf("%s_device_download_bytes_per_second", namespace),
+			"设备在两次抓取路由器数据之间的平均下载速率（字节/秒）",
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
 		),
 		"device_online_time": prometheus.NewDesc(
 			fmt.Sprintf("%s_device_online_time", namespace),
 			"设备在线时间",
-			[]string{"ip", "mac", "device_name", "is_ap"}, nil,
+			[]string{"ip", "mac", "device_name", "is_ap", "connection", "parent_mac", "parent_name"}, nil,
+		),
+		"device_wan_allowed": prometheus.NewDesc(
+			fmt.Sprintf("%s_device_wan_allowed", namespace),
+			"设备是否允许访问外网",
+			[]string{"mac", "device_name"}, nil,
+		),
+		"mesh_node_clients": prometheus.NewDesc(
+			fmt.Sprintf("%s_mesh_node_clients", namespace),
+			"连接到各mesh节点的设备数，parent_mac为空表示直接连接主路由",
+			[]string{"host", "parent_mac", "parent_name"}, nil,
+		),
+		"mesh_backhaul_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_mesh_backhaul_info", namespace),
+			"mesh子节点的回程类型，backhaul为wired或wireless",
+			[]string{"host", "node_mac", "node_name", "backhaul"}, nil,
+		),
+		"mesh_backhaul_rate_mbps": prometheus.NewDesc(
+			fmt.Sprintf("%s_mesh_backhaul_rate_mbps", namespace),
+			"mesh子节点回程链路的协商速率(Mbps)",
+			[]string{"host", "node_mac", "node_name"}, nil,
+		),
+		"mesh_backhaul_upload_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_mesh_backhaul_upload_speed", namespace),
+			"mesh子节点经回程发往上级节点的当前速度（字节/秒）",
+			[]string{"host", "node_mac", "node_name"}, nil,
+		),
+		"mesh_backhaul_download_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_mesh_backhaul_download_speed", namespace),
+			"mesh子节点经回程从上级节点接收的当前速度（字节/秒）",
+			[]string{"host", "node_mac", "node_name"}, nil,
+		),
+		"top_device_download_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_top_device_download_speed", namespace),
+			"当前下载速度最高的设备，rank从1开始",
+			[]string{"host", "rank", "mac", "device_name", "ip"}, nil,
+		),
+		"top_device_upload_speed": prometheus.NewDesc(
+			fmt.Sprintf("%s_top_device_upload_speed", namespace),
+			"当前上传速度最高的设备，rank从1开始",
+			[]string{"host", "rank", "mac", "device_name", "ip"}, nil,
+		),
+		"count_connection": prometheus.NewDesc(
+			fmt.Sprintf("%s_count_connection", namespace),
+			"按连接方式（有线/2.4G/5G/mesh）统计的设备数",
+			[]string{"host", "connection"}, nil,
+		),
+		"count_wan_blocked": prometheus.NewDesc(
+			fmt.Sprintf("%s_count_wan_blocked", namespace),
+			"禁止访问外网的设备数",
+			[]string{"host"}, nil,
 		),
 		"wifi_detail": prometheus.NewDesc(
 			fmt.Sprintf("%s_wifi_detail", namespace),
 			"WiFi网络详细信息",
 			[]string{"ssid", "status", "band_list", "channel"}, nil,
 		),
+		"lan_port_up": prometheus.NewDesc(
+			fmt.Sprintf("%s_lan_port_up", namespace),
+			"网口是否连接",
+			[]string{"host", "port", "name"}, nil,
+		),
+		"lan_port_speed_mbps": prometheus.NewDesc(
+			fmt.Sprintf("%s_lan_port_speed_mbps", namespace),
+			"网口协商速率(Mbps)",
+			[]string{"host", "port", "name"}, nil,
+		),
+		"lan_port_duplex": prometheus.NewDesc(
+			fmt.Sprintf("%s_lan_port_duplex", namespace),
+			"网口双工模式",
+			[]string{"host", "port", "name", "duplex"}, nil,
+		),
+		"wifi_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_info", namespace),
+			"WiFi网络配置信息",
+			[]string{"host", "ifname", "ssid", "encryption", "bandwidth", "txpower", "hidden", "ax", "bsd"}, nil,
+		),
+		"wifi_hidden": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_hidden", namespace),
+			"SSID是否隐藏，1为隐藏",
+			[]string{"host", "ifname", "ssid"}, nil,
+		),
+		"wifi_wps_enabled": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_wps_enabled", namespace),
+			"WPS是否开启，1为开启",
+			[]string{"host"}, nil,
+		),
+		"wifi_bsd_enabled": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_bsd_enabled", namespace),
+			"双频合一(band steering)是否开启，1为开启",
+			[]string{"host"}, nil,
+		),
+		"wifi_txpower": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_txpower", namespace),
+			"WiFi发射功率，固件返回 min/mid/max 时分别为 1/2/3",
+			[]string{"host", "ifname", "ssid"}, nil,
+		),
+		"wifi_bandwidth_mhz": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_bandwidth_mhz", namespace),
+			"WiFi频宽(MHz)，0表示自动",
+			[]string{"host", "ifname", "ssid"}, nil,
+		),
+		"wifi_radio_rx_packets_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_rx_packets_total", namespace),
+			"无线接口自启用以来接收的数据包数",
+			[]string{"host", "ifname"}, nil,
+		),
+		"wifi_radio_tx_packets_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_tx_packets_total", namespace),
+			"无线接口自启用以来发送的数据包数",
+			[]string{"host", "ifname"}, nil,
+		),
+		"wifi_radio_rx_errors_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_rx_errors_total", namespace),
+			"无线接口自启用以来接收出错的数据包数",
+			[]string{"host", "ifname"}, nil,
+		),
+		"wifi_radio_tx_errors_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_tx_errors_total", namespace),
+			"无线接口自启用以来发送出错的数据包数",
+			[]string{"host", "ifname"}, nil,
+		),
+		"wifi_radio_rx_dropped_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_rx_dropped_total", namespace),
+			"无线接口自启用以来接收时丢弃的数据包数",
+			[]string{"host", "ifname"}, nil,
+		),
+		"wifi_radio_tx_dropped_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_tx_dropped_total", namespace),
+			"无线接口自启用以来发送时丢弃的数据包数",
+			[]string{"host", "ifname"}, nil,
+		),
+		"wifi_radio_tx_retries_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_wifi_radio_tx_retries_total", namespace),
+			"无线接口自启用以来因未收到确认而重传的帧数，干扰严重时快速增长",
+			[]string{"host", "ifname"}, nil,
+		),
+		"router_info": prometheus.NewDesc(
+			fmt.Sprintf("%s_router_info", namespace),
+			"路由器型号和固件版本，值恒为1，可通过host与其他指标关联",
+			[]string{"host", "hardware", "firmware_version", "router_name"}, nil,
+		),
+		"auth_state": prometheus.NewDesc(
+			fmt.Sprintf("%s_auth_state", namespace),
+			"路由器登录状态，当前状态为1",
+			[]string{"host", "state"}, nil,
+		),
+		"auth_failures_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_auth_failures_total", namespace),
+			"登录失败次数，reason为rejected(密码错误等)、locked(路由器因尝试过多拒绝登录)、throttled(超过每分钟登录次数限制)或error",
+			[]string{"host", "reason"}, nil,
+		),
+		"parse_errors_total": prometheus.NewDesc(
+			fmt.Sprintf("%s_parse_errors_total", namespace),
+			"路由器返回的字段无法解析的次数，field为字段名，通常说明固件返回了未适配的格式",
+			[]string{"host", "field"}, nil,
+		),
+		"snapshot_stale": prometheus.NewDesc(
+			fmt.Sprintf("%s_snapshot_stale", namespace),
+			"当前指标是否来自持久化的旧快照",
+			[]string{"host"}, nil,
+		),
+		"snapshot_age_seconds": prometheus.NewDesc(
+			fmt.Sprintf("%s_snapshot_age_seconds", namespace),
+			"持久化快照的数据年龄(秒)",
+			[]string{"host"}, nil,
+		),
+		"data_age_seconds": prometheus.NewDesc(
+			fmt.Sprintf("%s_data_age_seconds", namespace),
+			"本次抓取返回的路由器数据的年龄(秒)，即当前时间减去从路由器获取数据的时间，source为live、cache或snapshot",
+			[]string{"host", "source"}, nil,
+		),
+		"usb_disk_present": prometheus.NewDesc(
+			fmt.Sprintf("%s_usb_disk_present", namespace),
+			"是否挂载USB存储",
+			[]string{"host"}, nil,
+		),
+		"usb_disk_total_bytes": prometheus.NewDesc(
+			fmt.Sprintf("%s_usb_disk_total_bytes", namespace),
+			"USB存储总容量(字节)",
+			[]string{"host", "disk", "label"}, nil,
+		),
+		"usb_disk_used_bytes": prometheus.NewDesc(
+			fmt.Sprintf("%s_usb_disk_used_bytes", namespace),
+			"USB存储已用容量(字节)",
+			[]string{"host", "disk", "label"}, nil,
+		),
+		"samba_enabled": prometheus.NewDesc(
+			fmt.Sprintf("%s_samba_enabled", namespace),
+			"Samba共享是否开启",
+			[]string{"host"}, nil,
+		),
 	}
 }
 
 func (mc *MetricsCollector) SetClient(client client.RouterClient) {
 	mc.client = client
+	if instrumented, ok := client.(instrumentedClient); ok {
+		instrumented.InstrumentTransport(mc.collectorMetrics)
+	}
 	// 不启用背景预加载，实现按需缓存策略
 	// 只有访问时才获取数据，缓存10秒后失效
 }
 
 func (mc *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
-	mc.mutex.RLock()
-	defer mc.mutex.RUnlock()
-
+	// Descriptors are never modified after construction
 	for _, desc := range mc.descriptors {
 		ch <- desc
 	}
 }
 
 func (mc *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
-	mc.mutex.Lock()
-	defer mc.mutex.Unlock()
+	mc.CollectWithContext(context.Background(), ch)
+}
+
+// CollectWithContext collects the router metrics, abandoning router requests
+// once ctx is cancelled
+func (mc *MetricsCollector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	mc.collect(ctx, ch)
+}
 
+// collect exports the router metrics and returns the collection they were
+// taken from, nil if ctx was cancelled. Only the fetch time of the returned
+// collection may be read, its device list can be reused by then.
+func (mc *MetricsCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) *collection {
 	start := time.Now()
 	
+	ctx, span := tracing.Start(ctx, "scrape")
+	defer span.End()
+	span.SetAttributes(attribute.String("router.host", mc.config.Router.Host))
+	
 	// Record collection start
 	mc.collectorMetrics.RecordCollectionStart()
+	mc.syncCacheMetrics()
+	
+	current := mc.latest(ctx, start)
+	defer current.unref()
+	mc.exportAuthState(ch)
+	mc.exportParseErrors(ch)
+	if current == nil {
+		tracing.RecordError(span, ctx.Err())
+		return nil
+	}
+	span.SetAttributes(attribute.Bool("collection.stale", current.stale))
+	tracing.RecordError(span, current.err)
+	if current.data == nil {
+		return current
+	}
+
+	// Export metrics
+	for _, plugin := range mc.plugins {
+		plugin.FetchAndExport(mc, ch, current.data)
+	}
+	mc.exportSnapshotMetrics(ch, current)
+	mc.exportDataAge(ch, current.data)
+	
+	if current.stale {
+		return current
+	}
+	
+	// Update memory metrics
+	if mc.memoryMonitor != nil {
+		mc.memoryMonitor.UpdateSystemMetrics()
+	}
+	
+	// Record collection completion
+	duration := time.Since(start)
+	mc.collectorMetrics.RecordCollectionDuration("collect", duration)
+	mc.collectorMetrics.RecordCollectionSuccess("collect")
+	return current
+}
+
+// latest returns the collection a scrape requested at requestedAt exports.
+// The current collection is returned as is when the background refresh
+// published it within ROUTER_REFRESH_INTERVAL or the scrape came sooner than
+// ROUTER_MIN_COLLECT_INTERVAL after it, otherwise the router data is
+// refreshed first.
+// The caller holds the returned collection and unrefs it once done reading.
+func (mc *MetricsCollector) latest(ctx context.Context, requestedAt time.Time) *collection {
+	current := mc.acquireCurrent()
+	if interval := mc.config.Router.RefreshInterval; interval > 0 && current != nil && time.Since(current.finishedAt) < interval {
+		return current
+	}
+	// Scraped more often than the router should be asked, the previous
+	// collection is served again
+	if mc.throttled(current) {
+		mc.collectorMetrics.RecordCollectionThrottled()
+		return current
+	}
+	current.unref()
+	if mc.refresh(ctx, requestedAt) == nil {
+		return nil
+	}
+	return mc.acquireCurrent()
+}
+
+// acquireCurrent returns the current collection with a reader added, nil
+// before the first refresh. The caller unrefs it once done reading.
+func (mc *MetricsCollector) acquireCurrent() *collection {
+	for {
+		current := mc.current.Load()
+		if current == nil || current.acquire() {
+			return current
+		}
+	}
+}
+
+// throttled reports whether current finished less than
+// ROUTER_MIN_COLLECT_INTERVAL ago
+func (mc *MetricsCollector) throttled(current *collection) bool {
+	minInterval := mc.config.Router.MinCollectInterval
+	return current != nil && minInterval > 0 && time.Since(current.finishedAt) < minInterval
+}
+
+// RunRefresh refreshes the router data every ROUTER_REFRESH_INTERVAL until
+// ctx is cancelled, starting at once. It returns immediately when the
+// interval is 0, scrapes then trigger every collection.
+func (mc *MetricsCollector) RunRefresh(ctx context.Context) {
+	interval := mc.config.Router.RefreshInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	for {
+		mc.refresh(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh fetches the router data and publishes it as the current
+// collection. Only one refresh runs at a time; a scrape that waited for
+// another scrape's refresh finishing after requestedAt reuses its result.
+// It returns nil when ctx was cancelled.
+func (mc *MetricsCollector) refresh(ctx context.Context, requestedAt time.Time) *collection {
+	mc.refreshMu.Lock()
+	defer mc.refreshMu.Unlock()
 	
-	// Optimize memory before collection if enabled
-	if mc.config.Memory.OptimizeOnCollect {
-		mc.memoryMonitor.OptimizeMemory()
+	current := mc.current.Load()
+	if current != nil && !current.finishedAt.Before(requestedAt) {
+		return current
 	}
+	// Asked more often than the router should be, the previous collection
+	// stays current
+	if mc.throttled(current) {
+		return current
+	}
+	
+	ctx, span := tracing.Start(ctx, "refresh")
+	defer span.End()
+	span.SetAttributes(attribute.String("router.host", mc.config.Router.Host))
 	
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(mc.config.Router.Timeout)*time.Second)
+	fetchCtx, cancel := context.WithTimeout(ctx, mc.config.Router.TimeoutOr(mc.config.Router.CollectTimeout))
 	defer cancel()
 
 	if mc.client == nil {
 		logger.Default.Error("Router client not initialized")
 		mc.collectorMetrics.RecordCollectionError("collect", "client_not_initialized")
-		return
+		return mc.publish(&collection{err: fmt.Errorf("router client not initialized")})
 	}
 
 	// Collect data from router
-	data, err := mc.collectRouterData(ctx)
+	data, err := mc.collectRouterData(fetchCtx)
+	if err != nil && ctx.Err() != nil {
+		// The scraper went away, nobody reads the metrics anymore
+		logger.Default.Warnf("Scrape cancelled while collecting router data: %v", ctx.Err())
+		mc.collectorMetrics.RecordCollectionError("collect", "scrape_cancelled")
+		return nil
+	}
+	tracing.RecordError(span, err)
 	if err != nil {
 		logger.Default.Errorf("Failed to collect router data: %v", err)
 		mc.collectorMetrics.RecordCollectionError("collect", "data_fetch_failed")
-		return
+		if mc.restored == nil {
+			return mc.publish(&collection{err: err})
+		}
+		
+		logger.Default.Warnf("Serving persisted snapshot from %s", mc.restored.FetchedAt.Format(time.RFC3339))
+		return mc.publish(&collection{data: mc.restored, err: err, stale: true})
 	}
-
-	// Export metrics
-	mc.exportSystemMetrics(ch, data)
-	mc.exportDeviceMetrics(ch, data)
-	mc.exportWANMetrics(ch, data)
-	mc.exportWiFiMetrics(ch, data)
 	
-	// Update memory metrics
-	mc.memoryMonitor.UpdateSystemMetrics()
+	// The snapshot is only used until the first successful collection
+	mc.restored = nil
+	mc.trackPresence(data)
+	if mc.inventory != nil {
+		mc.inventory.observe(data)
+	}
 	
-	// Record collection completion
-	duration := time.Since(start)
-	mc.collectorMetrics.RecordCollectionDuration("collect", duration)
-	mc.collectorMetrics.RecordCollectionSuccess("collect")
+	c := &collection{data: data}
+	// Without the cache nothing but the collection holds the device list,
+	// it is reused once the collection is superseded and no scrape reads it
+	if releaser, ok := mc.client.(deviceListReleaser); ok && !mc.config.Cache.Enabled && data.Source == models.SourceLive {
+		list := data.DeviceList
+		c.release = func() { releaser.ReleaseDeviceList(list) }
+	}
+	return mc.publish(c)
 }
 
-type RouterData struct {
-	SystemStatus *models.SystemStatus
-	DeviceList   *models.DeviceList
-	WanInfo      *models.WanInfo
-	WifiDetails  *models.WifiDetailAll
+// publish stamps c and makes it the current collection, dropping the hold
+// on the previous one
+func (mc *MetricsCollector) publish(c *collection) *collection {
+	c.finishedAt = time.Now()
+	c.refs.Store(1)
+	mc.current.Swap(c).unref()
+	return c
 }
 
-func (mc *MetricsCollector) collectRouterData(ctx context.Context) (*RouterData, error) {
+// collectRouterData returns the router data, from the cache if enabled
+func (mc *MetricsCollector) collectRouterData(ctx context.Context) (*models.RouterData, error) {
 	start := time.Now()
 	
 	// Check cache first if enabled
 	if mc.config.Cache.Enabled {
-		if cachedData := mc.getDataFromCache(); cachedData != nil {
+		cachedData := mc.getDataFromCache()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", cachedData != nil))
+		if cachedData != nil {
 			mc.collectorMetrics.RecordCacheHit("router_data")
-			mc.memoryMonitor.RecordOptimization("cache_hit", 0)
+			if mc.memoryMonitor != nil {
+				mc.memoryMonitor.RecordOptimization("cache_hit", 0)
+			}
 			return cachedData, nil
 		}
 		mc.collectorMetrics.RecordCacheMiss("router_data")
 	}
 	
 	// Use concurrent data fetcher
-	result, err := mc.dataFetcher.FetchData(ctx, mc.client)
+	data, err := mc.dataFetcher.FetchData(ctx, mc.client)
 	if err != nil {
 		mc.collectorMetrics.RecordDataFetchError("router_data", "fetch_failed")
 		return nil, fmt.Errorf("failed to fetch router data: %w", err)
 	}
 	
+	mc.lastSuccess.Store(data.FetchedAt.UnixNano())
+	mc.dropDuplicateDevices(data)
+	recordParseErrors(data)
+	
 	// Update cache if enabled
 	if mc.config.Cache.Enabled {
-		mc.updateCache(result)
+		mc.updateCache(data)
+	}
+	
+	if mc.rates != nil {
+		mc.rates.observe(data, data.FetchedAt)
 	}
 	
-	// Convert to our RouterData type
-	data := &RouterData{
-		SystemStatus: result.SystemStatus,
-		DeviceList:   result.DeviceList,
-		WanInfo:      result.WanInfo,
-		WifiDetails:  result.WifiDetails,
+	if mc.config.Cache.SnapshotFile != "" {
+		if err := cache.SaveSnapshot(mc.config.Cache.SnapshotFile, data); err != nil {
+			logger.Default.Warnf("Failed to persist snapshot: %v", err)
+		}
 	}
 	
 	// Record performance metrics
@@ -353,54 +1044,186 @@ func (mc *MetricsCollector) collectRouterData(ctx context.Context) (*RouterData,
 	return data, nil
 }
 
-// getDataFromCache attempts to get all data from cache
-func (mc *MetricsCollector) getDataFromCache() *RouterData {
-	data := &RouterData{}
+// configureFetchTasks applies the per-endpoint fetch overrides from the configuration
+func (mc *MetricsCollector) configureFetchTasks() {
+	for name, timeout := range mc.config.Fetch.Timeouts {
+		if !mc.dataFetcher.SetTaskTimeout(name, timeout) {
+			logger.Default.Warnf("Ignoring timeout for unknown fetch task %q", name)
+		}
+	}
+	for name, retries := range mc.config.Fetch.EndpointRetries {
+		if !mc.dataFetcher.SetTaskRetries(name, retries) {
+			logger.Default.Warnf("Ignoring retries for unknown fetch task %q", name)
+		}
+	}
+	for _, name := range mc.config.Fetch.BestEffort {
+		if !mc.dataFetcher.SetTaskOptional(name, true) {
+			logger.Default.Warnf("Ignoring unknown best-effort fetch task %q", name)
+		}
+	}
+	for _, name := range mc.config.Fetch.Required {
+		if !mc.dataFetcher.SetTaskOptional(name, false) {
+			logger.Default.Warnf("Ignoring unknown required fetch task %q", name)
+		}
+	}
+}
+
+// observeFetchTask records the duration, retries and outcome of a single
+// endpoint fetch
+func (mc *MetricsCollector) observeFetchTask(task string, duration time.Duration, attempts int, err error) {
+	mc.collectorMetrics.RecordDataFetchDuration(task, "router", duration)
+	if attempts > 1 {
+		mc.collectorMetrics.RecordFetchRetries(task, attempts-1)
+	}
+	if err != nil {
+		if attempts > 1 && !errors.Is(err, context.Canceled) {
+			mc.collectorMetrics.RecordFetchRetriesExhausted(task)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			mc.collectorMetrics.RecordDataFetchTimeout(task)
+		}
+		var codeErr *client.RouterCodeError
+		if errors.As(err, &codeErr) {
+			mc.collectorMetrics.RecordRouterCodeError(task, codeErr.Code, codeErr.Kind())
+		}
+		mc.collectorMetrics.RecordDataFetchError(task, "fetch_failed")
+		return
+	}
+	mc.collectorMetrics.RecordDataFetchSuccess(task)
+}
+
+// syncCacheMetrics publishes the smart cache statistics through the collector metrics
+func (mc *MetricsCollector) syncCacheMetrics() {
+	if !mc.config.Cache.Enabled {
+		return
+	}
 	
-	if status, found := mc.cache.GetSystemStatus(); found {
-		data.SystemStatus = status
-	} else {
-		return nil
+	stats := mc.cache.GetStats()
+	mc.collectorMetrics.SyncCacheStats("router_entries", metrics.CacheCounters{
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Evictions: stats.Evictions,
+		Size:      stats.Size,
+	})
+	for router, stats := range mc.cache.GetRouterStats() {
+		mc.collectorMetrics.SyncRouterCacheStats(router, metrics.CacheCounters{
+			Hits:   stats.Hits,
+			Misses: stats.Misses,
+			Size:   stats.Size,
+		})
+	}
+}
+
+// refreshCache fetches fresh router data into the cache, used for
+// stale-while-revalidate background refreshes
+func (mc *MetricsCollector) refreshCache(ctx context.Context) error {
+	if mc.client == nil {
+		return fmt.Errorf("router client not initialized")
 	}
 	
-	if devices, found := mc.cache.GetDeviceList(); found {
-		data.DeviceList = devices
-	} else {
-		return nil
+	start := time.Now()
+	data, err := mc.dataFetcher.FetchData(ctx, mc.client)
+	if err != nil {
+		logger.Default.Warnf("Background cache refresh failed: %v", err)
+		mc.collectorMetrics.RecordDataFetchError("router_data", "refresh_failed")
+		return err
 	}
 	
-	if wan, found := mc.cache.GetWanInfo(); found {
-		data.WanInfo = wan
-	} else {
-		return nil
+	mc.lastSuccess.Store(data.FetchedAt.UnixNano())
+	mc.updateCache(data)
+	mc.collectorMetrics.RecordDataFetchDuration("router_data", "refresh", time.Since(start))
+	mc.collectorMetrics.RecordDataFetchSuccess("router_data")
+	return nil
+}
+
+// routerEntries returns the cache entries of the router, identified by its
+// address
+func (mc *MetricsCollector) routerEntries() cache.RouterEntries {
+	return mc.cache.Router(mc.config.Router.IP)
+}
+
+// getDataFromCache attempts to get all data from cache
+func (mc *MetricsCollector) getDataFromCache() *models.RouterData {
+	// Loaded before the entries, a concurrent refresh may only make the
+	// data newer than its timestamp
+	data := &models.RouterData{
+		FetchedAt: time.Unix(0, mc.cachedAt.Load()),
+		Source:    models.SourceCache,
 	}
+	found := make(map[string]bool, 8)
+	entries := mc.routerEntries()
 	
-	if wifi, found := mc.cache.GetWifiDetails(); found {
-		data.WifiDetails = wifi
-	} else {
-		return nil
+	data.SystemStatus, found["system_status"] = entries.GetSystemStatus()
+	data.DeviceList, found["device_list"] = entries.GetDeviceList()
+	data.WanInfo, found["wan_info"] = entries.GetWanInfo()
+	data.WifiDetails, found["wifi_details"] = entries.GetWifiDetails()
+	data.DiskStatus, found["disk_status"] = entries.GetDiskStatus()
+	data.SambaStatus, found["samba_status"] = entries.GetSambaStatus()
+	data.SysInfo, found["sys_info"] = entries.GetSysInfo()
+	data.PortStatus, found["port_status"] = entries.GetPortStatus()
+	data.WPSStatus, found["wps_status"] = entries.GetWPSStatus()
+	data.TopoGraph, found["topo_graph"] = entries.GetTopoGraph()
+	data.WifiStatistics, found["wifi_statistics"] = entries.GetWifiStatistics()
+	data.IPTVStatus, found["iptv_status"] = entries.GetIPTVStatus()
+	data.UPnPStatus, found["upnp_status"] = entries.GetUPnPStatus()
+	
+	// Best-effort endpoints may be missing, routers without USB never populate storage
+	for _, task := range mc.dataFetcher.Tasks() {
+		if !task.Optional && !found[task.Name] {
+			return nil
+		}
 	}
 	
 	return data
 }
 
-// updateCache updates the cache with new data
-func (mc *MetricsCollector) updateCache(data *concurrent.RouterData) {
+// updateCache updates the cache with newly fetched data
+func (mc *MetricsCollector) updateCache(data *models.RouterData) {
+	entries := mc.routerEntries()
 	if data.SystemStatus != nil {
-		mc.cache.SetSystemStatus(data.SystemStatus)
+		entries.SetSystemStatus(data.SystemStatus)
 	}
 	if data.DeviceList != nil {
-		mc.cache.SetDeviceList(data.DeviceList)
+		entries.SetDeviceList(data.DeviceList)
 	}
 	if data.WanInfo != nil {
-		mc.cache.SetWanInfo(data.WanInfo)
+		entries.SetWanInfo(data.WanInfo)
 	}
 	if data.WifiDetails != nil {
-		mc.cache.SetWifiDetails(data.WifiDetails)
+		entries.SetWifiDetails(data.WifiDetails)
+	}
+	if data.DiskStatus != nil {
+		entries.SetDiskStatus(data.DiskStatus)
 	}
+	if data.SambaStatus != nil {
+		entries.SetSambaStatus(data.SambaStatus)
+	}
+	if data.SysInfo != nil {
+		entries.SetSysInfo(data.SysInfo)
+	}
+	if data.PortStatus != nil {
+		entries.SetPortStatus(data.PortStatus)
+	}
+	if data.WPSStatus != nil {
+		entries.SetWPSStatus(data.WPSStatus)
+	}
+	if data.TopoGraph != nil {
+		entries.SetTopoGraph(data.TopoGraph)
+	}
+	if data.WifiStatistics != nil {
+		entries.SetWifiStatistics(data.WifiStatistics)
+	}
+	if data.IPTVStatus != nil {
+		entries.SetIPTVStatus(data.IPTVStatus)
+	}
+	if data.UPnPStatus != nil {
+		entries.SetUPnPStatus(data.UPnPStatus)
+	}
+	// Stored after the entries, see collectRouterData
+	mc.cachedAt.Store(data.FetchedAt.UnixNano())
 }
 
-func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, data *RouterData) {
+func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
 	if data.SystemStatus == nil {
 		return
 	}
@@ -408,7 +1231,7 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	host := mc.config.Router.Host
 	
 	// CPU metrics
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["cpu_cores"],
 		prometheus.GaugeValue,
 		float64(data.SystemStatus.CPU.Core),
@@ -416,23 +1239,27 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	)
 	
 	cpuFreq := utils.ParseCPUFrequency(data.SystemStatus.CPU.Hz)
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["cpu_mhz"],
 		prometheus.GaugeValue,
 		cpuFreq,
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["cpu_load"],
 		prometheus.GaugeValue,
 		data.SystemStatus.CPU.Load,
 		host,
 	)
 	
+	mc.exportCPUCoreMetrics(ch, data)
+	
+	mc.exportConntrackMetrics(ch, data)
+	
 	// Memory metrics
 	memTotal := utils.ParseMemorySize(data.SystemStatus.Mem.Total)
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["memory_total_mb"],
 		prometheus.GaugeValue,
 		memTotal,
@@ -440,14 +1267,14 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	)
 	
 	memUsage := data.SystemStatus.Mem.Usage * memTotal
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["memory_usage_mb"],
 		prometheus.GaugeValue,
 		memUsage,
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["memory_usage"],
 		prometheus.GaugeValue,
 		data.SystemStatus.Mem.Usage,
@@ -455,28 +1282,28 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	)
 	
 	// Device count metrics
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["count_all"],
 		prometheus.GaugeValue,
 		float64(data.SystemStatus.Count.All),
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["count_online"],
 		prometheus.GaugeValue,
 		float64(data.SystemStatus.Count.Online),
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["count_all_without_mash"],
 		prometheus.GaugeValue,
 		float64(data.SystemStatus.Count.AllWithoutMash),
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["count_online_without_mash"],
 		prometheus.GaugeValue,
 		float64(data.SystemStatus.Count.OnlineWithoutMash),
@@ -485,7 +1312,7 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	
 	// Uptime
 	if uptime, err := strconv.ParseFloat(data.SystemStatus.UpTime, 64); err == nil {
-		ch <- prometheus.MustNewConstMetric(
+		ch <- mc.constMetric(
 			mc.descriptors["uptime"],
 			prometheus.GaugeValue,
 			uptime,
@@ -494,28 +1321,28 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	}
 	
 	// Hardware info
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["platform"],
 		prometheus.GaugeValue,
 		1,
 		data.SystemStatus.Hardware.Platform,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["version"],
 		prometheus.GaugeValue,
 		1,
 		data.SystemStatus.Hardware.Version,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["sn"],
 		prometheus.GaugeValue,
 		1,
 		data.SystemStatus.Hardware.Sn,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["mac"],
 		prometheus.GaugeValue,
 		1,
@@ -523,81 +1350,210 @@ func (mc *MetricsCollector) exportSystemMetrics(ch chan<- prometheus.Metric, dat
 	)
 }
 
-func (mc *MetricsCollector) exportDeviceMetrics(ch chan<- prometheus.Metric, data *RouterData) {
-	if data.SystemStatus == nil || data.DeviceList == nil {
-		return
+// exportCPUCoreMetrics exports the per-core load, preferring sys_info over
+// the status endpoint when both report it
+func (mc *MetricsCollector) exportCPUCoreMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	loads := data.SystemStatus.CPU.Loads
+	if data.SysInfo != nil && len(data.SysInfo.CPU.Loads) > 0 {
+		loads = data.SysInfo.CPU.Loads
 	}
 	
-	// Process device traffic from system status
-	for _, dev := range data.SystemStatus.Dev {
-		devUpload, _ := utils.InterfaceToFloat64(dev.Upload)
-		devDownload, _ := utils.InterfaceToFloat64(dev.Download)
+	host := mc.config.Router.Host
+	for core, load := range loads {
+		if !load.Valid {
+			continue
+		}
 		
-		var devIP, devName, devIsAP string
-		devMac := dev.Mac
+		ch <- mc.constMetric(
+			mc.descriptors["cpu_core_load"],
+			prometheus.GaugeValue,
+			load.Value,
+			host, strconv.Itoa(core),
+		)
+	}
+}
+
+// exportConntrackMetrics exports the connection tracking table usage. Once
+// the table is full the router drops new connections, which households with
+// many P2P connections notice as random drops.
+func (mc *MetricsCollector) exportConntrackMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	conntrack := data.SystemStatus.Conntrack
+	if conntrack == nil {
+		return
+	}
+	
+	host := mc.config.Router.Host
+	values := []struct {
+		desc  string
+		value models.FlexibleInt
+	}{
+		{"conntrack_entries", conntrack.Count},
+		{"conntrack_max", conntrack.Max},
+	}
+	for _, v := range values {
+		if !v.value.Valid {
+			continue
+		}
+		ch <- mc.constMetric(
+			mc.descriptors[v.desc],
+			prometheus.GaugeValue,
+			float64(v.value.Value),
+			host,
+		)
+	}
+}
+
+func (mc *MetricsCollector) exportDeviceMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.SystemStatus == nil || data.DeviceList == nil {
+		return
+	}
+	
+	parents := meshNodes(data.DeviceList)
+	
+	// Process device traffic from system status
+	for _, dev := range data.SystemStatus.Dev {
+		devUpload, errUpload := dev.Upload.Float64()
+		devDownload, errDownload := dev.Download.Float64()
 		
 		// Find device info from device list
-		for _, device := range data.DeviceList.List {
-			if device.Mac == dev.Mac && len(device.IP) > 0 {
-				devIP = device.IP[0].IP
-				devName = device.Name
-				devIsAP = strconv.Itoa(device.IsAP)
+		var device *models.DeviceEntry
+		for i := range data.DeviceList.List {
+			if data.DeviceList.List[i].Mac == dev.Mac {
+				device = &data.DeviceList.List[i]
 				break
 			}
 		}
+		labels, ok := mc.deviceLabels(dev.Mac, device, parents)
+		if !ok {
+			continue
+		}
 		
-		ch <- prometheus.MustNewConstMetric(
-			mc.descriptors["device_upload_traffic"],
-			prometheus.GaugeValue,
-			devUpload,
-			devIP, devMac, devName, devIsAP,
-		)
+		// Totals of an unknown format are left out rather than exported as 0
+		if errUpload == nil {
+			ch <- mc.constMetric(
+				mc.descriptors["device_upload_traffic"],
+				prometheus.GaugeValue,
+				devUpload,
+				labels...,
+			)
+		}
 		
-		ch <- prometheus.MustNewConstMetric(
-			mc.descriptors["device_download_traffic"],
-			prometheus.GaugeValue,
-			devDownload,
-			devIP, devMac, devName, devIsAP,
-		)
-	}
-	
-	// Process device speed and online time from device list
-	for _, dev := range data.DeviceList.List {
-		if len(dev.IP) > 0 {
-			devIP := dev.IP[0].IP
-			devMac := dev.Mac
-			devName := dev.Name
-			devIsAP := strconv.Itoa(dev.IsAP)
-			
-			devOnlineTime, _ := utils.InterfaceToFloat64(dev.Statistics.Online)
-			devUpSpeed, _ := utils.InterfaceToFloat64(dev.Statistics.UpSpeed)
-			devDownSpeed, _ := utils.InterfaceToFloat64(dev.Statistics.DownSpeed)
-			
-			ch <- prometheus.MustNewConstMetric(
-				mc.descriptors["device_upload_speed"],
+		if errDownload == nil {
+			ch <- mc.constMetric(
+				mc.descriptors["device_download_traffic"],
 				prometheus.GaugeValue,
-				devUpSpeed,
-				devIP, devMac, devName, devIsAP,
+				devDownload,
+				labels...,
 			)
-			
-			ch <- prometheus.MustNewConstMetric(
-				mc.descriptors["device_download_speed"],
+		}
+		
+		// Peak speeds observed by the router, which catch bursts between scrapes
+		if devMaxUpSpeed, err := dev.MaxUploadSpeed.Float64(); err == nil {
+			ch <- mc.constMetric(
+				mc.descriptors["device_max_upload_speed"],
 				prometheus.GaugeValue,
-				devDownSpeed,
-				devIP, devMac, devName, devIsAP,
+				devMaxUpSpeed,
+				labels...,
 			)
-			
-			ch <- prometheus.MustNewConstMetric(
-				mc.descriptors["device_online_time"],
+		}
+		
+		if devMaxDownSpeed, err := dev.MaxDownloadSpeed.Float64(); err == nil {
+			ch <- mc.constMetric(
+				mc.descriptors["device_max_download_speed"],
 				prometheus.GaugeValue,
-				devOnlineTime,
-				devIP, devMac, devName, devIsAP,
+				devMaxDownSpeed,
+				labels...,
 			)
 		}
+		
+		if mc.rates == nil {
+			continue
+		}
+		if rate, ok := mc.rates.rate(dev.Mac); ok {
+			ch <- mc.constMetric(
+				mc.descriptors["device_upload_bytes_per_second"],
+				prometheus.GaugeValue,
+				rate.upload,
+				labels...,
+			)
+			ch <- mc.constMetric(
+				mc.descriptors["device_download_bytes_per_second"],
+				prometheus.GaugeValue,
+				rate.download,
+				labels...,
+			)
+		}
+	}
+	
+	// Process device speed and online time from device list
+	for i := range data.DeviceList.List {
+		dev := &data.DeviceList.List[i]
+		labels, ok := mc.deviceLabels(dev.Mac, dev, parents)
+		if !ok {
+			continue
+		}
+		
+		devOnlineTime, _ := strconv.ParseFloat(dev.Statistics.Online, 64)
+		devUpSpeed, _ := strconv.ParseFloat(dev.Statistics.UpSpeed, 64)
+		devDownSpeed, _ := strconv.ParseFloat(dev.Statistics.DownSpeed, 64)
+		
+		ch <- mc.constMetric(
+			mc.descriptors["device_upload_speed"],
+			prometheus.GaugeValue,
+			devUpSpeed,
+			labels...,
+		)
+		
+		ch <- mc.constMetric(
+			mc.descriptors["device_download_speed"],
+			prometheus.GaugeValue,
+			devDownSpeed,
+			labels...,
+		)
+		
+		ch <- mc.constMetric(
+			mc.descriptors["device_online_time"],
+			prometheus.GaugeValue,
+			devOnlineTime,
+			labels...,
+		)
+	}
+}
+
+func (mc *MetricsCollector) exportDeviceAuthorityMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.DeviceList == nil {
+		return
+	}
+	
+	blocked := 0
+	for i := range data.DeviceList.List {
+		dev := &data.DeviceList.List[i]
+		allowed := float64(dev.Authority.Wan)
+		if dev.Authority.Wan == 0 {
+			blocked++
+		}
+		
+		name, ok := mc.labelValue(deviceName(dev))
+		if !ok {
+			continue
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["device_wan_allowed"],
+			prometheus.GaugeValue,
+			allowed,
+			dev.Mac, name,
+		)
 	}
+	
+	ch <- mc.constMetric(
+		mc.descriptors["count_wan_blocked"],
+		prometheus.GaugeValue,
+		float64(blocked),
+		mc.config.Router.Host,
+	)
 }
 
-func (mc *MetricsCollector) exportWANMetrics(ch chan<- prometheus.Metric, data *RouterData) {
+func (mc *MetricsCollector) exportWANMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
 	if data.SystemStatus == nil || data.WanInfo == nil {
 		return
 	}
@@ -610,28 +1566,55 @@ func (mc *MetricsCollector) exportWANMetrics(ch chan<- prometheus.Metric, data *
 	wanUpload, _ := strconv.ParseFloat(data.SystemStatus.Wan.Upload, 64)
 	wanDownload, _ := strconv.ParseFloat(data.SystemStatus.Wan.Download, 64)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["wan_upload_speed"],
 		prometheus.GaugeValue,
 		wanUpSpeed,
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["wan_download_speed"],
 		prometheus.GaugeValue,
 		wanDownSpeed,
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	if maxUpSpeed, err := strconv.ParseFloat(data.SystemStatus.Wan.MaxUploadSpeed, 64); err == nil {
+		ch <- mc.constMetric(
+			mc.descriptors["wan_max_upload_speed"],
+			prometheus.GaugeValue,
+			maxUpSpeed,
+			host,
+		)
+	}
+	
+	if maxDownSpeed, err := strconv.ParseFloat(data.SystemStatus.Wan.MaxDownloadSpeed, 64); err == nil {
+		ch <- mc.constMetric(
+			mc.descriptors["wan_max_download_speed"],
+			prometheus.GaugeValue,
+			maxDownSpeed,
+			host,
+		)
+	}
+	
+	if history := utils.ParseFloatSeries(data.SystemStatus.Wan.History); len(history) > 0 {
+		count, sum, quantiles := summarize(history)
+		ch <- prometheus.MustNewConstSummary(
+			mc.descriptors["wan_speed_history"],
+			count, sum, quantiles,
+			host,
+		)
+	}
+	
+	ch <- mc.constMetric(
 		mc.descriptors["wan_upload_traffic"],
 		prometheus.GaugeValue,
 		wanUpload,
 		host,
 	)
 	
-	ch <- prometheus.MustNewConstMetric(
+	ch <- mc.constMetric(
 		mc.descriptors["wan_download_traffic"],
 		prometheus.GaugeValue,
 		wanDownload,
@@ -640,7 +1623,7 @@ func (mc *MetricsCollector) exportWANMetrics(ch chan<- prometheus.Metric, data *
 	
 	// IP addresses from WAN info
 	for _, ipv4 := range data.WanInfo.Info.Ipv4 {
-		ch <- prometheus.MustNewConstMetric(
+		ch <- mc.constMetric(
 			mc.descriptors["ipv4"],
 			prometheus.GaugeValue,
 			1,
@@ -648,7 +1631,7 @@ func (mc *MetricsCollector) exportWANMetrics(ch chan<- prometheus.Metric, data *
 		)
 		
 		if mask, err := utils.SubNetMaskToLen(ipv4.Mask); err == nil {
-			ch <- prometheus.MustNewConstMetric(
+			ch <- mc.constMetric(
 				mc.descriptors["ipv4_mask"],
 				prometheus.GaugeValue,
 				float64(mask),
@@ -658,22 +1641,107 @@ func (mc *MetricsCollector) exportWANMetrics(ch chan<- prometheus.Metric, data *
 	}
 	
 	for _, ipv6 := range data.WanInfo.Info.Ipv6Info.IP6Addr {
-		ch <- prometheus.MustNewConstMetric(
+		ch <- mc.constMetric(
 			mc.descriptors["ipv6"],
 			prometheus.GaugeValue,
 			1,
 			ipv6,
 		)
 	}
+	
+	mc.exportIPv6Metrics(ch, data)
+	mc.exportWANPorts(ch, data)
 }
 
-func (mc *MetricsCollector) exportWiFiMetrics(ch chan<- prometheus.Metric, data *RouterData) {
+// summarize computes the count, sum and median, 90th and 99th percentiles of
+// a series for a constant summary
+func summarize(values []float64) (uint64, float64, map[float64]float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+	
+	quantiles := make(map[float64]float64)
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		quantiles[q] = sorted[int(q*float64(len(sorted)-1))]
+	}
+	
+	return uint64(len(sorted)), sum, quantiles
+}
+
+func (mc *MetricsCollector) exportIPv6Metrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	host := mc.config.Router.Host
+	ipv6Info := data.WanInfo.Info.Ipv6Info
+	
+	if ipv6Info.WanType != "" {
+		ch <- mc.constMetric(
+			mc.descriptors["ipv6_wan_type"],
+			prometheus.GaugeValue,
+			1,
+			host, ipv6Info.WanType, ipv6Info.IfName,
+		)
+	}
+	
+	seen := make(map[string]bool)
+	for _, entry := range ipv6Info.LanIP6Prefix {
+		prefix, length, ok := utils.ParseIPv6Prefix(entry)
+		if !ok {
+			continue
+		}
+		
+		label := fmt.Sprintf("%s/%d", prefix, length)
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		
+		ch <- mc.constMetric(
+			mc.descriptors["ipv6_prefix_length"],
+			prometheus.GaugeValue,
+			float64(length),
+			host, label,
+		)
+	}
+	
+	ch <- mc.constMetric(
+		mc.descriptors["ipv6_lan_addresses"],
+		prometheus.GaugeValue,
+		float64(len(ipv6Info.LanIP6Addr)),
+		host,
+	)
+}
+
+func (mc *MetricsCollector) exportWiFiMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	// WPS is reported by its own endpoint, not every firmware has it
+	if data.WPSStatus != nil {
+		enabled := 0.0
+		if data.WPSStatus.Status != 0 {
+			enabled = 1
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["wifi_wps_enabled"],
+			prometheus.GaugeValue,
+			enabled,
+			mc.config.Router.Host,
+		)
+	}
+	
 	if data.WifiDetails == nil {
 		return
 	}
 	
+	ch <- mc.constMetric(
+		mc.descriptors["wifi_bsd_enabled"],
+		prometheus.GaugeValue,
+		float64(data.WifiDetails.Bsd),
+		mc.config.Router.Host,
+	)
+	
 	for _, info := range data.WifiDetails.Info {
-		status, _ := utils.InterfaceToFloat64(info.Status)
+		status, _ := strconv.ParseFloat(info.Status, 64)
 		
 		bandList := ""
 		for i, band := range info.ChannelInfo.BandList {
@@ -687,15 +1755,317 @@ func (mc *MetricsCollector) exportWiFiMetrics(ch chan<- prometheus.Metric, data
 		
 		channel := strconv.Itoa(info.ChannelInfo.Channel)
 		
-		ch <- prometheus.MustNewConstMetric(
+		ch <- mc.constMetric(
 			mc.descriptors["wifi_detail"],
 			prometheus.GaugeValue,
 			status,
 			info.Ssid, info.Status, bandList, channel,
 		)
+		
+		mc.exportWiFiConfigMetrics(ch, info)
+	}
+}
+
+// wifiTxPowerLevels maps the named transmit power levels some firmwares
+// report to numbers
+var wifiTxPowerLevels = map[string]float64{
+	"min": 1,
+	"mid": 2,
+	"max": 3,
+}
+
+// parseTxPower converts a numeric or named transmit power to a number
+func parseTxPower(txpwr string) (float64, bool) {
+	if value, err := strconv.ParseFloat(txpwr, 64); err == nil {
+		return value, true
 	}
+	level, ok := wifiTxPowerLevels[strings.ToLower(txpwr)]
+	return level, ok
 }
 
+func (mc *MetricsCollector) exportWiFiConfigMetrics(ch chan<- prometheus.Metric, info models.WifiDetails) {
+	host := mc.config.Router.Host
+	
+	bandwidth := info.Bandwidth
+	if bandwidth == "" {
+		bandwidth = info.ChannelInfo.Bandwidth
+	}
+	
+	hidden := ""
+	if info.Hidden.Valid {
+		hidden = strconv.FormatInt(info.Hidden.Value, 10)
+		ch <- mc.constMetric(
+			mc.descriptors["wifi_hidden"],
+			prometheus.GaugeValue,
+			float64(info.Hidden.Value),
+			host, info.IfName, info.Ssid,
+		)
+	}
+	
+	ch <- mc.constMetric(
+		mc.descriptors["wifi_info"],
+		prometheus.GaugeValue,
+		1,
+		host, info.IfName, info.Ssid, info.Encryption, bandwidth, info.TxPWR, hidden, info.Ax, info.Bsd,
+	)
+	
+	if txPower, ok := parseTxPower(info.TxPWR); ok {
+		ch <- mc.constMetric(
+			mc.descriptors["wifi_txpower"],
+			prometheus.GaugeValue,
+			txPower,
+			host, info.IfName, info.Ssid,
+		)
+	}
+	
+	if bandwidthMHz, err := strconv.ParseFloat(bandwidth, 64); err == nil {
+		ch <- mc.constMetric(
+			mc.descriptors["wifi_bandwidth_mhz"],
+			prometheus.GaugeValue,
+			bandwidthMHz,
+			host, info.IfName, info.Ssid,
+		)
+	}
+}
+
+func (mc *MetricsCollector) exportStorageMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	host := mc.config.Router.Host
+	
+	if data.DiskStatus != nil {
+		present := 0.0
+		if len(data.DiskStatus.Disks) > 0 {
+			present = 1
+		}
+		
+		ch <- mc.constMetric(
+			mc.descriptors["usb_disk_present"],
+			prometheus.GaugeValue,
+			present,
+			host,
+		)
+		
+		for _, disk := range data.DiskStatus.Disks {
+			if total, err := disk.Total.Float64(); err == nil {
+				ch <- mc.constMetric(
+					mc.descriptors["usb_disk_total_bytes"],
+					prometheus.GaugeValue,
+					total,
+					host, disk.Name, disk.Label,
+				)
+			}
+			
+			if used, err := disk.Used.Float64(); err == nil {
+				ch <- mc.constMetric(
+					mc.descriptors["usb_disk_used_bytes"],
+					prometheus.GaugeValue,
+					used,
+					host, disk.Name, disk.Label,
+				)
+			}
+		}
+	}
+	
+	if data.SambaStatus != nil {
+		ch <- mc.constMetric(
+			mc.descriptors["samba_enabled"],
+			prometheus.GaugeValue,
+			float64(data.SambaStatus.Status),
+			host,
+		)
+	}
+}
+
+// loadSnapshot restores the router data persisted by a previous run
+func (mc *MetricsCollector) exportPortMetrics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.PortStatus == nil {
+		return
+	}
+	
+	host := mc.config.Router.Host
+	for _, port := range data.PortStatus.Ports {
+		portNumber := strconv.Itoa(port.Port)
+		
+		ch <- mc.constMetric(
+			mc.descriptors["lan_port_up"],
+			prometheus.GaugeValue,
+			float64(port.Link),
+			host, portNumber, port.Name,
+		)
+		
+		// Speed and duplex are meaningless while the link is down
+		if port.Link == 0 {
+			continue
+		}
+		
+		if speed, err := port.Speed.Float64(); err == nil {
+			ch <- mc.constMetric(
+				mc.descriptors["lan_port_speed_mbps"],
+				prometheus.GaugeValue,
+				speed,
+				host, portNumber, port.Name,
+			)
+		}
+		
+		if port.Duplex != "" {
+			ch <- mc.constMetric(
+				mc.descriptors["lan_port_duplex"],
+				prometheus.GaugeValue,
+				1,
+				host, portNumber, port.Name, strings.ToLower(port.Duplex),
+			)
+		}
+	}
+}
+
+func (mc *MetricsCollector) loadSnapshot() {
+	data := &models.RouterData{}
+	savedAt, err := cache.LoadSnapshot(mc.config.Cache.SnapshotFile, data)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Default.Warnf("Failed to load snapshot: %v", err)
+		}
+		return
+	}
+	
+	// A snapshot missing required data cannot be exported
+	if data.SystemStatus == nil || data.DeviceList == nil || data.WanInfo == nil || data.WifiDetails == nil {
+		logger.Default.Warnf("Ignoring incomplete snapshot %s", mc.config.Cache.SnapshotFile)
+		return
+	}
+	
+	// Snapshots written before the data carried its fetch time
+	if data.FetchedAt.IsZero() {
+		data.FetchedAt = savedAt
+	}
+	data.Source = models.SourceSnapshot
+	mc.restored = data
+	logger.Default.Infof("Restored snapshot from %s", savedAt.Format(time.RFC3339))
+}
+
+// exportRouterInfo reports the router model and firmware once the client
+// has read them from init_info
+func (mc *MetricsCollector) exportRouterInfo(ch chan<- prometheus.Metric) {
+	infoClient, ok := mc.client.(routerInfoClient)
+	if !ok {
+		return
+	}
+	info := infoClient.InitInfo()
+	if info == nil {
+		return
+	}
+	
+	ch <- mc.constMetric(
+		mc.descriptors["router_info"],
+		prometheus.GaugeValue,
+		1,
+		mc.config.Router.Host, info.Hardware, info.RomVersion, info.RouterName,
+	)
+}
+
+// exportAuthState reports the login session, also while the router is unreachable
+func (mc *MetricsCollector) exportAuthState(ch chan<- prometheus.Metric) {
+	authClient, ok := mc.client.(authStateClient)
+	if !ok {
+		return
+	}
+	
+	host := mc.config.Router.Host
+	current := authClient.AuthState()
+	for _, state := range client.AuthStates {
+		value := 0.0
+		if state == current {
+			value = 1
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["auth_state"],
+			prometheus.GaugeValue,
+			value,
+			host, state.String(),
+		)
+	}
+	
+	if failuresClient, ok := mc.client.(authFailuresClient); ok {
+		for reason, count := range failuresClient.AuthFailures() {
+			ch <- mc.constMetric(
+				mc.descriptors["auth_failures_total"],
+				prometheus.CounterValue,
+				float64(count),
+				host, reason,
+			)
+		}
+	}
+}
+
+// exportParseErrors reports response fields whose values could not be
+// parsed, which points to a firmware returning an unknown format
+func (mc *MetricsCollector) exportParseErrors(ch chan<- prometheus.Metric) {
+	host := mc.config.Router.Host
+	for field, count := range models.ParseErrors() {
+		ch <- mc.constMetric(
+			mc.descriptors["parse_errors_total"],
+			prometheus.CounterValue,
+			float64(count),
+			host, field,
+		)
+	}
+}
+
+func (mc *MetricsCollector) exportSnapshotMetrics(ch chan<- prometheus.Metric, current *collection) {
+	if mc.config.Cache.SnapshotFile == "" {
+		return
+	}
+	
+	host := mc.config.Router.Host
+	value := 0.0
+	if current.stale {
+		value = 1
+	}
+	
+	ch <- mc.constMetric(
+		mc.descriptors["snapshot_stale"],
+		prometheus.GaugeValue,
+		value,
+		host,
+	)
+	
+	if current.stale {
+		ch <- mc.constMetric(
+			mc.descriptors["snapshot_age_seconds"],
+			prometheus.GaugeValue,
+			time.Since(current.data.FetchedAt).Seconds(),
+			host,
+		)
+	}
+}
+
+// exportDataAge exports how old the served data is. Cached, revalidating and
+// snapshot data is served as if fresh, so this is what alerts on stale
+// metrics look at.
+func (mc *MetricsCollector) exportDataAge(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.FetchedAt.IsZero() {
+		return
+	}
+	ch <- mc.constMetric(
+		mc.descriptors["data_age_seconds"],
+		prometheus.GaugeValue,
+		max(time.Since(data.FetchedAt).Seconds(), 0),
+		mc.config.Router.Host,
+		string(data.Source),
+	)
+}
+
+// LastError returns the error of the most recent collection, nil if it
+// succeeded. Secrets in the message are redacted.
+func (mc *MetricsCollector) LastError() error {
+	current := mc.current.Load()
+	if current == nil {
+		return nil
+	}
+	return apperrors.RedactError(current.err)
+}
+
+// GetRegistry returns the registry of the exporter's own metrics. Router
+// metrics are not part of it, use Gatherer to collect everything.
 func (mc *MetricsCollector) GetRegistry() *prometheus.Registry {
 	return mc.metrics
 }
@@ -704,11 +2074,24 @@ func (mc *MetricsCollector) Close() error {
 	if mc.cache != nil {
 		mc.cache.Stop()
 	}
-	
-	// Final memory optimization before shutdown if enabled
-	if mc.memoryMonitor != nil && mc.config.Memory.ForceGCOnClose {
-		mc.memoryMonitor.OptimizeMemory()
-		mc.memoryMonitor.ForceGC()
+	if mc.syslog != nil {
+		if err := mc.syslog.Close(); err != nil {
+			logger.Default.Warnf("Failed to close the router log file: %v", err)
+		}
+	}
+	if mc.loki != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), lokiShutdownTimeout)
+		defer cancel()
+		if err := mc.loki.Shutdown(ctx); err != nil {
+			logger.Default.Warnf("Failed to push remaining logs to Loki: %v", err)
+		}
+	}
+	if mc.notifier != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), mc.config.Notify.Timeout)
+		defer cancel()
+		if err := mc.notifier.Shutdown(ctx); err != nil {
+			logger.Default.Warnf("Failed to send remaining notifications: %v", err)
+		}
 	}
 	
 	return nil
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Values of the connection label
+const (
+	connectionWired   = "wired"
+	connection24G     = "2.4G"
+	connection5G      = "5G"
+	connectionMesh    = "mesh"
+	connectionUnknown = "unknown"
+)
+
+// connectionTypes are always exported by count_connection, so a type
+// dropping to zero clients shows up as 0 instead of a missing series
+var connectionTypes = []string{connectionWired, connection24G, connection5G, connectionMesh}
+
+// deviceConnection classifies how a client reaches the network. Clients of a
+// mesh node carry the node's MAC as parent; the router cannot tell how they
+// are attached to that node, so they are reported as mesh. Otherwise the
+// devicelist type is 0 for wired, 1 for 2.4G and 2 for 5G clients.
+func deviceConnection(device *models.DeviceEntry) string {
+	if device == nil {
+		return ""
+	}
+	if device.Parent != "" {
+		return connectionMesh
+	}
+
+	switch device.Type {
+	case 0:
+		return connectionWired
+	case 1:
+		return connection24G
+	case 2:
+		return connection5G
+	}
+
+	// Some firmwares report other types, fall back to the interface name
+	if strings.HasPrefix(device.IfName, "eth") {
+		return connectionWired
+	}
+	return connectionUnknown
+}
+
+// exportConnectionCounts exports the number of clients in the device list
+// per connection type
+func (mc *MetricsCollector) exportConnectionCounts(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.DeviceList == nil {
+		return
+	}
+
+	counts := make(map[string]int, len(connectionTypes))
+	for _, connection := range connectionTypes {
+		counts[connection] = 0
+	}
+	for i := range data.DeviceList.List {
+		device := &data.DeviceList.List[i]
+		// Mesh nodes are infrastructure, not clients
+		if device.IsAP != 0 {
+			continue
+		}
+		counts[deviceConnection(device)]++
+	}
+
+	host := mc.config.Router.Host
+	for connection, count := range counts {
+		ch <- mc.constMetric(
+			mc.descriptors["count_connection"],
+			prometheus.GaugeValue,
+			float64(count),
+			host, connection,
+		)
+	}
+}
+
+// normalizeMAC upper-cases mac so parents match the devicelist entry of the
+// mesh node regardless of how the firmware formats them
+func normalizeMAC(mac string) string {
+	return strings.ToUpper(mac)
+}
+
+// meshNode is a mesh node as listed in the device list
+type meshNode struct {
+	mac  string
+	name string
+}
+
+// meshNodes maps the normalized MAC of every mesh node in the device list to
+// the node. The main router has no entry, its clients have an empty parent.
+func meshNodes(list *models.DeviceList) map[string]meshNode {
+	nodes := make(map[string]meshNode)
+	if list == nil {
+		return nodes
+	}
+	for _, device := range list.List {
+		if device.IsAP == 0 {
+			continue
+		}
+		nodes[normalizeMAC(device.Mac)] = meshNode{mac: device.Mac, name: deviceName(&device)}
+	}
+	return nodes
+}
+
+// exportMeshNodeClients exports the number of clients attached to each mesh
+// node and to the main router, showing how the load is spread
+func (mc *MetricsCollector) exportMeshNodeClients(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.DeviceList == nil {
+		return
+	}
+
+	nodes := meshNodes(data.DeviceList)
+	counts := map[string]int{"": 0}
+	for key := range nodes {
+		counts[key] = 0
+	}
+	for _, device := range data.DeviceList.List {
+		if device.IsAP != 0 {
+			continue
+		}
+		key := normalizeMAC(device.Parent)
+		if _, known := nodes[key]; !known && key != "" {
+			// Parent missing from the list, keep it as reported
+			nodes[key] = meshNode{mac: device.Parent}
+		}
+		counts[key]++
+	}
+
+	host := mc.config.Router.Host
+	for key, count := range counts {
+		node := nodes[key]
+		ch <- mc.constMetric(
+			mc.descriptors["mesh_node_clients"],
+			prometheus.GaugeValue,
+			float64(count),
+			host, node.mac, node.name,
+		)
+	}
+}
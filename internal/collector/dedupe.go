@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// Data types of duplicate_devices_dropped_total
+const (
+	duplicatesDeviceList   = "device_list"
+	duplicatesSystemStatus = "system_status"
+)
+
+// onlineSeconds parses an online duration, unparsable values sort last
+func onlineSeconds(value string) int64 {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return seconds
+}
+
+// fresher reports whether online duration b is more recent than a. A device
+// that roamed has been connected to its current node for the shortest time;
+// on a tie the later entry wins.
+func fresher(a, b string) bool {
+	secondsA, secondsB := onlineSeconds(a), onlineSeconds(b)
+	if secondsA < 0 || secondsB < 0 {
+		return secondsB >= 0 || secondsA < 0
+	}
+	return secondsB <= secondsA
+}
+
+// dedupeDeviceList keeps one entry per MAC, preferring online entries and
+// then the freshest. It returns a copy when entries were dropped, list
+// itself may be cached. The order of the remaining entries is kept.
+func dedupeDeviceList(list *models.DeviceList) (*models.DeviceList, int) {
+	if list == nil {
+		return nil, 0
+	}
+
+	kept := make(map[string]int, len(list.List))
+	deduped := make([]models.DeviceEntry, 0, len(list.List))
+	for _, device := range list.List {
+		key := normalizeMAC(device.Mac)
+		i, seen := kept[key]
+		if !seen {
+			kept[key] = len(deduped)
+			deduped = append(deduped, device)
+			continue
+		}
+		current := &deduped[i]
+		if (device.Online != 0) != (current.Online != 0) {
+			if device.Online != 0 {
+				*current = device
+			}
+			continue
+		}
+		if fresher(current.Statistics.Online, device.Statistics.Online) {
+			*current = device
+		}
+	}
+
+	dropped := len(list.List) - len(deduped)
+	if dropped == 0 {
+		return list, 0
+	}
+	copied := *list
+	copied.List = deduped
+	return &copied, dropped
+}
+
+// dedupeDeviceTraffic keeps one traffic entry per MAC, the freshest
+func dedupeDeviceTraffic(status *models.SystemStatus) (*models.SystemStatus, int) {
+	if status == nil {
+		return nil, 0
+	}
+
+	kept := make(map[string]int, len(status.Dev))
+	deduped := make([]models.DeviceInfo, 0, len(status.Dev))
+	for _, dev := range status.Dev {
+		key := normalizeMAC(dev.Mac)
+		i, seen := kept[key]
+		if !seen {
+			kept[key] = len(deduped)
+			deduped = append(deduped, dev)
+			continue
+		}
+		if fresher(string(deduped[i].Online), string(dev.Online)) {
+			deduped[i] = dev
+		}
+	}
+
+	dropped := len(status.Dev) - len(deduped)
+	if dropped == 0 {
+		return status, 0
+	}
+	copied := *status
+	copied.Dev = deduped
+	return &copied, dropped
+}
+
+// dropDuplicateDevices removes repeated MACs from freshly fetched data before
+// it is cached. Mesh roaming can make the router list a device twice, which
+// would export the same series twice and fail the scrape.
+func (mc *MetricsCollector) dropDuplicateDevices(data *models.RouterData) {
+	var dropped int
+	data.DeviceList, dropped = dedupeDeviceList(data.DeviceList)
+	if dropped > 0 {
+		logger.Default.Debugf("Dropped %d duplicate devices from the device list", dropped)
+		mc.collectorMetrics.RecordDuplicateDevices(duplicatesDeviceList, dropped)
+	}
+
+	data.SystemStatus, dropped = dedupeDeviceTraffic(data.SystemStatus)
+	if dropped > 0 {
+		logger.Default.Debugf("Dropped %d duplicate devices from the device traffic", dropped)
+		mc.collectorMetrics.RecordDuplicateDevices(duplicatesSystemStatus, dropped)
+	}
+}
@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// healthCheckTimeout bounds the collection attempted by CheckHealth, health
+// checks of container runtimes time out after a few seconds
+const healthCheckTimeout = 5 * time.Second
+
+// LastSuccess returns when router data was last fetched successfully, the
+// zero time if it never was
+func (mc *MetricsCollector) LastSuccess() time.Time {
+	nanos := mc.lastSuccess.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// CheckHealth reports an error when no router data was fetched within
+// maxAge. Before giving up it tries a collection itself, so the check also
+// works when nobody scrapes. After startup the exporter gets maxAge for its
+// first successful fetch.
+func (mc *MetricsCollector) CheckHealth(ctx context.Context, maxAge time.Duration) error {
+	if maxAge <= 0 || mc.collectionAge() <= maxAge {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	mc.refresh(ctx, time.Now())
+	if age := mc.collectionAge(); age > maxAge {
+		if last := mc.LastSuccess(); !last.IsZero() {
+			return fmt.Errorf("last successful router collection %s ago exceeds %s", age.Round(time.Second), maxAge)
+		}
+		return fmt.Errorf("no successful router collection within %s of startup", maxAge)
+	}
+	return nil
+}
+
+// collectionAge returns the time since the last successful fetch, or since
+// startup if there was none
+func (mc *MetricsCollector) collectionAge() time.Duration {
+	since := mc.LastSuccess()
+	if since.IsZero() {
+		since = mc.startedAt
+	}
+	return time.Since(since)
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// expectedDevice is a device declared in the inventory
+type expectedDevice struct {
+	name     string
+	required bool
+	// lastSeen is when the device was last online, startup if it never was
+	lastSeen time.Time
+	seen     bool
+	missing  bool
+}
+
+// inventoryTracker compares the device lists with the declared inventory and
+// reports required devices that are offline for too long
+type inventoryTracker struct {
+	mu           sync.Mutex
+	devices      map[string]*expectedDevice
+	missingAfter time.Duration
+	// fetchedAt is the time of the last device list, missing devices are
+	// judged by it rather than the current time, so an unreachable router
+	// does not make every device missing
+	fetchedAt time.Time
+	maxLength int
+
+	lastSeen *prometheus.Desc
+	missing  *prometheus.Desc
+}
+
+func newInventoryTracker(cfg config.InventoryConfig, namespace string, maxLabelLength int, startedAt time.Time) *inventoryTracker {
+	t := &inventoryTracker{
+		devices:      make(map[string]*expectedDevice, len(cfg.Devices)),
+		missingAfter: cfg.MissingAfter,
+		maxLength:    maxLabelLength,
+		lastSeen: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "expected_device_last_seen_timestamp_seconds"),
+			"预期设备最后一次在线的时间，启动后未在线过的设备不导出",
+			[]string{"mac", "name", "required"}, nil,
+		),
+		missing: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "expected_device_missing"),
+			"必须在线的设备离线超过 INVENTORY_MISSING_AFTER 时为 1",
+			[]string{"mac", "name"}, nil,
+		),
+	}
+	for mac, name := range cfg.Devices {
+		t.devices[normalizeMAC(mac)] = &expectedDevice{name: name, lastSeen: startedAt}
+	}
+	for _, device := range cfg.Required {
+		for mac, expected := range t.devices {
+			if mac == normalizeMAC(device) || expected.name == device {
+				expected.required = true
+			}
+		}
+	}
+	return t
+}
+
+func (t *inventoryTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.lastSeen
+	ch <- t.missing
+}
+
+// Collect exports the inventory once a device list was fetched
+func (t *inventoryTracker) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fetchedAt.IsZero() {
+		return
+	}
+	for mac, device := range t.devices {
+		name := sanitizeLabelValue(device.name, t.maxLength)
+		if device.seen {
+			ch <- prometheus.MustNewConstMetric(t.lastSeen, prometheus.GaugeValue,
+				float64(device.lastSeen.Unix()), mac, name, strconv.FormatBool(device.required))
+		}
+		if device.required {
+			missing := 0.0
+			if device.missing {
+				missing = 1
+			}
+			ch <- prometheus.MustNewConstMetric(t.missing, prometheus.GaugeValue, missing, mac, name)
+		}
+	}
+}
+
+// observe updates when the expected devices were last online and logs
+// required devices going missing or coming back
+func (t *inventoryTracker) observe(data *models.RouterData) {
+	if data.DeviceList == nil {
+		return
+	}
+	fetchedAt := data.FetchedAt
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !fetchedAt.After(t.fetchedAt) {
+		return
+	}
+	t.fetchedAt = fetchedAt
+
+	for i := range data.DeviceList.List {
+		device := &data.DeviceList.List[i]
+		if device.Online == 0 {
+			continue
+		}
+		if expected, ok := t.devices[normalizeMAC(device.Mac)]; ok {
+			expected.lastSeen = fetchedAt
+			expected.seen = true
+		}
+	}
+
+	for mac, device := range t.devices {
+		if !device.required {
+			continue
+		}
+		missing := fetchedAt.Sub(device.lastSeen) > t.missingAfter
+		switch {
+		case missing && !device.missing:
+			logger.Default.Warnf("Required device %s (%s) offline for %s", device.name, mac, fetchedAt.Sub(device.lastSeen).Round(time.Second))
+		case !missing && device.missing:
+			logger.Default.Infof("Required device %s (%s) online again", device.name, mac)
+		}
+		device.missing = missing
+	}
+}
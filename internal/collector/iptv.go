@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exportIPTVInfo exports the IPTV setup as labels of one series, so a reset by
+// the ISP shows as the series changing or disappearing
+func (mc *MetricsCollector) exportIPTVInfo(ch chan<- prometheus.Metric, data *models.RouterData) {
+	iptv := data.IPTVStatus
+	if iptv == nil {
+		return
+	}
+
+	enabled := "0"
+	if iptv.Enable.Value != 0 {
+		enabled = "1"
+	}
+	ch <- mc.constMetric(
+		mc.descriptors["iptv_info"],
+		prometheus.GaugeValue,
+		1,
+		mc.config.Router.Host, enabled, iptv.Mode, string(iptv.Port),
+		vlanLabel(iptv.InternetVLAN), vlanLabel(iptv.IPTVVLAN), vlanLabel(iptv.VoIPVLAN),
+	)
+}
+
+// vlanLabel returns a VLAN ID as label value, empty for an untagged service
+func vlanLabel(id models.FlexibleInt) string {
+	if !id.Valid || id.Value <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(id.Value, 10)
+}
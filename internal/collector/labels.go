@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/text/unicode/norm"
+)
+
+// truncationMark ends label values cut to the maximum length
+const truncationMark = "…"
+
+// Values of COLLECTORS_EMPTY_LABELS, the policy for devices the router
+// reports without an IP address or name
+const (
+	// emptyLabelsUnknown exports the device with unknown as label value
+	emptyLabelsUnknown = "unknown"
+	// emptyLabelsSkip leaves the device out
+	emptyLabelsSkip = "skip"
+)
+
+// labelValue applies the empty label policy to a label value. ok is false
+// when the metric is to be skipped.
+func (mc *MetricsCollector) labelValue(value string) (string, bool) {
+	if value != "" {
+		return value, true
+	}
+	if mc.config.Collectors.EmptyLabels == emptyLabelsSkip {
+		return "", false
+	}
+	return emptyLabelsUnknown, true
+}
+
+// deviceName returns the name of a device, falling back to the name the
+// device reported itself when none was set on the router
+func deviceName(device *models.DeviceEntry) string {
+	if device.Name != "" {
+		return device.Name
+	}
+	return device.OName
+}
+
+// deviceLabels returns the label values of the per-device metrics: ip,
+// mac, device_name, is_ap, connection, parent_mac and parent_name. device is
+// nil for traffic of a MAC missing from the device list. Labels the router
+// left empty follow the empty label policy, so a device is exported with the
+// same labels by every metric or not at all; ok is false when it is skipped.
+// An empty parent_mac is not missing, it means the main router.
+func (mc *MetricsCollector) deviceLabels(mac string, device *models.DeviceEntry, parents map[string]meshNode) ([]string, bool) {
+	var ip, name, isAP, connection, parent string
+	if device != nil {
+		if len(device.IP) > 0 {
+			ip = device.IP[0].IP
+		}
+		name = deviceName(device)
+		isAP = strconv.Itoa(device.IsAP)
+		connection = deviceConnection(device)
+		parent = device.Parent
+	}
+
+	labels := []string{ip, mac, name, isAP, connection}
+	for i, value := range labels {
+		var ok bool
+		if labels[i], ok = mc.labelValue(value); !ok {
+			return nil, false
+		}
+	}
+	return append(labels, parent, parents[normalizeMAC(parent)].name), true
+}
+
+// constMetric is prometheus.MustNewConstMetric with the label values
+// sanitized. Most label values are device names, SSIDs and other strings set
+// on the router, which may be anything.
+func (mc *MetricsCollector) constMetric(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) prometheus.Metric {
+	for i, labelValue := range labelValues {
+		labelValues[i] = sanitizeLabelValue(labelValue, mc.config.Server.MaxLabelLength)
+	}
+	return prometheus.MustNewConstMetric(desc, valueType, value, labelValues...)
+}
+
+// sanitizeLabelValue replaces invalid UTF-8, normalizes the value to NFC,
+// turns line breaks and other whitespace runs into a single space, drops
+// invisible characters and truncates the result to maxLength characters.
+// A maxLength of 0 disables truncation.
+func sanitizeLabelValue(value string, maxLength int) string {
+	if isCleanLabelValue(value, maxLength) {
+		return value
+	}
+
+	value = norm.NFC.String(strings.ToValidUTF8(value, "�"))
+
+	var b strings.Builder
+	b.Grow(len(value))
+	pendingSpace := false
+	for _, r := range value {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			pendingSpace = true
+		case unicode.IsPrint(r):
+			if pendingSpace && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			pendingSpace = false
+			b.WriteRune(r)
+		}
+		// Zero-width, private use and other unprintable characters are dropped
+	}
+	value = b.String()
+
+	if maxLength > 0 {
+		runes := []rune(value)
+		if len(runes) > maxLength {
+			value = strings.TrimSpace(string(runes[:maxLength-1])) + truncationMark
+		}
+	}
+	return value
+}
+
+// isCleanLabelValue reports whether value is printable ASCII that
+// sanitizeLabelValue would return unchanged, which is the case for nearly
+// every value
+func isCleanLabelValue(value string, maxLength int) bool {
+	if maxLength > 0 && len(value) > maxLength {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x20 || value[i] > 0x7e {
+			return false
+		}
+	}
+	return strings.TrimSpace(value) == value && !strings.Contains(value, "  ")
+}
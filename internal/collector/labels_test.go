@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// emptyLabelData has a device with an IP and a name, one without either that
+// is in the device list, and traffic of a MAC missing from the device list
+func emptyLabelData() *models.RouterData {
+	return &models.RouterData{
+		SystemStatus: &models.SystemStatus{Dev: []models.DeviceInfo{
+			{Mac: "AA:AA:AA:AA:AA:AA", Upload: models.NewFlexibleInt(100), Download: models.NewFlexibleInt(200), MaxUploadSpeed: "10", MaxDownloadSpeed: "20"},
+			{Mac: "BB:BB:BB:BB:BB:BB", Upload: models.NewFlexibleInt(300), Download: models.NewFlexibleInt(400), MaxUploadSpeed: "30", MaxDownloadSpeed: "40"},
+			{Mac: "CC:CC:CC:CC:CC:CC", Upload: models.NewFlexibleInt(500), Download: models.NewFlexibleInt(600), MaxUploadSpeed: "50", MaxDownloadSpeed: "60"},
+		}},
+		DeviceList: &models.DeviceList{List: []models.DeviceEntry{
+			{
+				Mac:        "AA:AA:AA:AA:AA:AA",
+				Name:       "laptop",
+				Type:       2,
+				IP:         []models.IPInfo{{IP: "192.168.31.10"}},
+				Statistics: models.DeviceStatistics{UpSpeed: "1", DownSpeed: "2", Online: "60"},
+			},
+			{
+				Mac:        "BB:BB:BB:BB:BB:BB",
+				Type:       1,
+				Statistics: models.DeviceStatistics{UpSpeed: "0", DownSpeed: "0", Online: "0"},
+			},
+		}},
+	}
+}
+
+// exportDeviceLabels runs the device export with the given empty label
+// policy and returns the label sets of every exported metric by metric name
+func exportDeviceLabels(t *testing.T, policy string) map[string][]map[string]string {
+	t.Helper()
+
+	mc := NewMetricsCollector(&config.Config{
+		Router:     config.RouterConfig{Host: "miwifi", Timeout: 5},
+		Server:     config.ServerConfig{Namespace: "miwifi", MaxLabelLength: 128},
+		Cache:      config.CacheConfig{TTL: time.Second},
+		Fetch:      config.FetchConfig{Parallelism: 1},
+		Collectors: config.CollectorsConfig{EmptyLabels: policy},
+	})
+	defer mc.Close()
+
+	names := make(map[*prometheus.Desc]string, len(mc.descriptors))
+	for name, desc := range mc.descriptors {
+		names[desc] = name
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	mc.exportDeviceMetrics(ch, emptyLabelData())
+	close(ch)
+
+	result := make(map[string][]map[string]string)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, pair := range m.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		name := names[metric.Desc()]
+		result[name] = append(result[name], labels)
+	}
+	return result
+}
+
+// exportedMACs returns the sorted mac labels of label sets
+func exportedMACs(labelSets []map[string]string) []string {
+	macs := make([]string, 0, len(labelSets))
+	for _, labels := range labelSets {
+		macs = append(macs, labels["mac"])
+	}
+	sort.Strings(macs)
+	return macs
+}
+
+func TestEmptyLabelPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		trafficMACs []string
+		speedMACs   []string
+	}{
+		{
+			policy:      emptyLabelsUnknown,
+			trafficMACs: []string{"AA:AA:AA:AA:AA:AA", "BB:BB:BB:BB:BB:BB", "CC:CC:CC:CC:CC:CC"},
+			speedMACs:   []string{"AA:AA:AA:AA:AA:AA", "BB:BB:BB:BB:BB:BB"},
+		},
+		{
+			policy:      emptyLabelsSkip,
+			trafficMACs: []string{"AA:AA:AA:AA:AA:AA"},
+			speedMACs:   []string{"AA:AA:AA:AA:AA:AA"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			exported := exportDeviceLabels(t, tt.policy)
+
+			for _, name := range []string{"device_upload_traffic", "device_download_traffic", "device_max_upload_speed", "device_max_download_speed"} {
+				if got := exportedMACs(exported[name]); strings.Join(got, ",") != strings.Join(tt.trafficMACs, ",") {
+					t.Errorf("%s exported %v, want %v", name, got, tt.trafficMACs)
+				}
+			}
+			for _, name := range []string{"device_upload_speed", "device_download_speed", "device_online_time"} {
+				if got := exportedMACs(exported[name]); strings.Join(got, ",") != strings.Join(tt.speedMACs, ",") {
+					t.Errorf("%s exported %v, want %v", name, got, tt.speedMACs)
+				}
+			}
+
+			for name, labelSets := range exported {
+				for _, labels := range labelSets {
+					for _, label := range []string{"ip", "mac", "device_name", "is_ap", "connection"} {
+						if labels[label] == "" {
+							t.Errorf("%s exported an empty %s label: %v", name, label, labels)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestEmptyLabelsDeduplicated checks that a device has exactly one series per
+// metric and the same labels in every device metric, so traffic and speed
+// series of a device without IP cannot be mistaken for different devices
+func TestEmptyLabelsDeduplicated(t *testing.T) {
+	exported := exportDeviceLabels(t, emptyLabelsUnknown)
+
+	byMAC := make(map[string]string)
+	for name, labelSets := range exported {
+		seen := make(map[string]bool)
+		for _, labels := range labelSets {
+			keys := make([]string, 0, len(labels))
+			for key, value := range labels {
+				keys = append(keys, key+"="+value)
+			}
+			sort.Strings(keys)
+			key := strings.Join(keys, ",")
+
+			if seen[key] {
+				t.Errorf("%s exported %s twice", name, key)
+			}
+			seen[key] = true
+
+			mac := labels["mac"]
+			if previous, ok := byMAC[mac]; ok && previous != key {
+				t.Errorf("%s labels %s differ from %s exported by another metric", name, key, previous)
+			}
+			byMAC[mac] = key
+		}
+	}
+
+	if got := byMAC["BB:BB:BB:BB:BB:BB"]; !strings.Contains(got, "ip=unknown") || !strings.Contains(got, "device_name=unknown") {
+		t.Errorf("device without IP and name exported as %s, want unknown ip and device_name", got)
+	}
+}
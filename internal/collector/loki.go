@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/pkg/loki"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lokiShutdownTimeout bounds the push of the entries still queued on exit
+const lokiShutdownTimeout = 5 * time.Second
+
+// setupLoki creates the pusher shared by everything sent to Loki
+func (mc *MetricsCollector) setupLoki() {
+	mc.loki = loki.NewPusher(mc.config.Loki.URL, mc.config.Loki.Headers)
+	mc.lokiErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: mc.config.Server.Namespace,
+		Name:      "loki_push_errors_total",
+		Help:      "推送到 Loki 失败的次数",
+	})
+	mc.loki.OnError(func(err error) {
+		mc.lokiErrors.Inc()
+		logger.Default.Warnf("Failed to push to Loki: %v", err)
+	})
+}
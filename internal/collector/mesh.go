@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exportMeshBackhaul exports the backhaul type, link rate and throughput of
+// every mesh satellite in the topology. Satellites chained behind another
+// satellite report the backhaul to that satellite.
+func (mc *MetricsCollector) exportMeshBackhaul(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.TopoGraph == nil {
+		return
+	}
+
+	var walk func(nodes []models.MeshNode)
+	walk = func(nodes []models.MeshNode) {
+		for i := range nodes {
+			mc.exportMeshNodeBackhaul(ch, &nodes[i])
+			walk(nodes[i].Leafs)
+		}
+	}
+	walk(data.TopoGraph.Graph.Leafs)
+}
+
+// exportMeshNodeBackhaul exports the backhaul of one satellite. Values the
+// firmware does not report are left out rather than exported as 0.
+func (mc *MetricsCollector) exportMeshNodeBackhaul(ch chan<- prometheus.Metric, node *models.MeshNode) {
+	if node.IsMain != 0 {
+		return
+	}
+
+	host := mc.config.Router.Host
+	mac, macOK := mc.labelValue(node.Mac)
+	name, nameOK := mc.labelValue(node.Name)
+	if !macOK || !nameOK {
+		return
+	}
+
+	if backhaul := strings.ToLower(node.Backhaul); backhaul != "" {
+		ch <- mc.constMetric(
+			mc.descriptors["mesh_backhaul_info"],
+			prometheus.GaugeValue,
+			1,
+			host, mac, name, backhaul,
+		)
+	}
+
+	values := []struct {
+		desc  string
+		value models.FlexibleInt
+	}{
+		{"mesh_backhaul_rate_mbps", node.BackhaulRate},
+		{"mesh_backhaul_upload_speed", node.BackhaulUpSpeed},
+		{"mesh_backhaul_download_speed", node.BackhaulDownSpeed},
+	}
+	for _, v := range values {
+		if !v.value.Valid {
+			continue
+		}
+		ch <- mc.constMetric(
+			mc.descriptors[v.desc],
+			prometheus.GaugeValue,
+			float64(v.value.Value),
+			host, mac, name,
+		)
+	}
+}
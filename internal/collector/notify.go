@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/helloworlde/miwifi-exporter/pkg/notify"
+)
+
+// notifyCheckInterval is how often RunNotifications checks whether the
+// router is reachable
+const notifyCheckInterval = 30 * time.Second
+
+// romUpdateClient is implemented by router clients that can check for
+// firmware updates. The demo data client cannot.
+type romUpdateClient interface {
+	CheckROMUpdate(ctx context.Context) (*models.ROMUpdate, error)
+}
+
+// RunNotifications watches for the router becoming unreachable and for
+// firmware updates until ctx is cancelled. New devices are notified by the
+// presence tracker. It returns at once when no notification channel is
+// configured.
+func (mc *MetricsCollector) RunNotifications(ctx context.Context) {
+	watchReachability := mc.notifier.Enabled(notify.EventRouterUnreachable)
+	checkFirmware := mc.notifier.Enabled(notify.EventFirmwareUpdate)
+	if !watchReachability && !checkFirmware {
+		return
+	}
+
+	ticker := time.NewTicker(notifyCheckInterval)
+	defer ticker.Stop()
+
+	var unreachableSince time.Time
+	var nextFirmwareCheck time.Time
+	var notifiedVersion string
+	for {
+		if watchReachability {
+			unreachableSince = mc.checkReachability(ctx, unreachableSince)
+		}
+		if checkFirmware && !time.Now().Before(nextFirmwareCheck) {
+			if version, ok := mc.checkFirmwareUpdate(ctx, notifiedVersion); ok {
+				notifiedVersion = version
+				nextFirmwareCheck = time.Now().Add(mc.config.Notify.FirmwareCheckInterval)
+			} else {
+				// Retry soon after a failed check, the router may still be
+				// starting
+				nextFirmwareCheck = time.Now().Add(10 * notifyCheckInterval)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkReachability notifies when no router data was fetched for
+// UnreachableAfter and again once it was. Like CheckHealth it tries a
+// collection itself, so it also works when nobody scrapes. since is when
+// the router was notified as unreachable, the zero time while it is not.
+func (mc *MetricsCollector) checkReachability(ctx context.Context, since time.Time) time.Time {
+	after := mc.config.Notify.UnreachableAfter
+	if mc.collectionAge() > after {
+		refreshCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		mc.refresh(refreshCtx, time.Now())
+		cancel()
+	}
+	if ctx.Err() != nil {
+		return since
+	}
+
+	host := mc.config.Router.Host
+	age := mc.collectionAge()
+	switch {
+	case age > after && since.IsZero():
+		message := fmt.Sprintf("No data could be fetched from the router at %s for %s.", mc.config.Router.IP, age.Round(time.Second))
+		if err := mc.LastError(); err != nil {
+			message += " Last error: " + err.Error()
+		}
+		mc.notifier.Notify(notify.Notification{
+			Event:   notify.EventRouterUnreachable,
+			Host:    host,
+			Title:   fmt.Sprintf("Router %s unreachable", host),
+			Message: message,
+		})
+		return time.Now().Add(-age)
+	case age <= after && !since.IsZero():
+		mc.notifier.Notify(notify.Notification{
+			Event:   notify.EventRouterUnreachable,
+			Host:    host,
+			Title:   fmt.Sprintf("Router %s reachable again", host),
+			Message: fmt.Sprintf("The router at %s answers again after %s.", mc.config.Router.IP, time.Since(since).Round(time.Second)),
+		})
+		return time.Time{}
+	}
+	return since
+}
+
+// checkFirmwareUpdate asks the router for a firmware update and notifies
+// about a version other than notified. It returns the latest version
+// notified about and whether the check succeeded.
+func (mc *MetricsCollector) checkFirmwareUpdate(ctx context.Context, notified string) (string, bool) {
+	updater, ok := mc.client.(romUpdateClient)
+	if !ok {
+		// Nothing to check, try again after the regular interval
+		return notified, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mc.config.Router.TimeoutOr(mc.config.Router.RequestTimeout))
+	defer cancel()
+	update, err := updater.CheckROMUpdate(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Default.Warnf("Failed to check for firmware updates: %v", err)
+		}
+		return notified, false
+	}
+	if update.NeedUpdate == 0 || update.Version == "" || update.Version == notified {
+		return notified, true
+	}
+
+	host := mc.config.Router.Host
+	message := fmt.Sprintf("Firmware %s is available for the router at %s.", update.Version, mc.config.Router.IP)
+	if changes := strings.TrimSpace(update.ChangeLog); changes != "" {
+		message += "\n" + changes
+	}
+	logger.Default.Infof("Firmware update %s available", update.Version)
+	mc.notifier.Notify(notify.Notification{
+		Event:   notify.EventFirmwareUpdate,
+		Host:    host,
+		Title:   fmt.Sprintf("Firmware update for %s", host),
+		Message: message,
+	})
+	return update.Version, true
+}
+
+// notifyNewDevice notifies about a device seen for the first time
+func (mc *MetricsCollector) notifyNewDevice(event deviceEvent) {
+	if !mc.notifier.Enabled(notify.EventNewDevice) {
+		return
+	}
+
+	message := fmt.Sprintf("%s (%s) joined via %s", event.Name, event.MAC, event.AP)
+	if event.IP != "" {
+		message += " with IP " + event.IP
+	}
+	mc.notifier.Notify(notify.Notification{
+		Event:   notify.EventNewDevice,
+		Host:    mc.config.Router.Host,
+		Title:   fmt.Sprintf("New device on %s", mc.config.Router.Host),
+		Message: message + ".",
+		Time:    event.Time,
+	})
+}
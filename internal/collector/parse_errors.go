@@ -0,0 +1,100 @@
+package collector
+
+import "github.com/helloworlde/miwifi-exporter/internal/models"
+
+// recordParseErrors counts the values of freshly fetched router data that
+// could not be parsed, by field. Cached data is not counted again, so the
+// count grows with the responses rather than with the scrapes.
+func recordParseErrors(data *models.RouterData) {
+	check := func(field string, err error) {
+		if err != nil {
+			models.RecordParseError(field)
+		}
+	}
+
+	if status := data.SystemStatus; status != nil {
+		for i := range status.Dev {
+			dev := &status.Dev[i]
+			check("dev.upload", dev.Upload.Err())
+			check("dev.download", dev.Download.Err())
+			check("dev.upspeed", dev.UpSpeed.Err())
+			check("dev.downspeed", dev.DownSpeed.Err())
+		}
+		for _, load := range status.CPU.Loads {
+			check("cpu.loads", load.Err())
+		}
+		if conntrack := status.Conntrack; conntrack != nil {
+			check("conntrack.count", conntrack.Count.Err())
+			check("conntrack.max", conntrack.Max.Err())
+		}
+	}
+	if data.SysInfo != nil {
+		for _, load := range data.SysInfo.CPU.Loads {
+			check("cpu.loads", load.Err())
+		}
+	}
+	if data.WanInfo != nil {
+		if dual := data.WanInfo.DualWAN; dual != nil {
+			check("dualwan.enable", dual.Enable.Err())
+		}
+		for _, port := range data.WanInfo.Wans {
+			check("wan.active", port.Active.Err())
+			check("wan.upspeed", port.UpSpeed.Err())
+			check("wan.downspeed", port.DownSpeed.Err())
+			check("wan.upload", port.Upload.Err())
+			check("wan.download", port.Download.Err())
+		}
+	}
+	if iptv := data.IPTVStatus; iptv != nil {
+		check("iptv.enable", iptv.Enable.Err())
+		check("iptv.internet_vlan", iptv.InternetVLAN.Err())
+		check("iptv.iptv_vlan", iptv.IPTVVLAN.Err())
+		check("iptv.voip_vlan", iptv.VoIPVLAN.Err())
+	}
+	if upnp := data.UPnPStatus; upnp != nil {
+		check("upnp.status", upnp.Status.Err())
+		for _, mapping := range upnp.List {
+			check("upnp.rport", mapping.ExternalPort.Err())
+			check("upnp.port", mapping.Port.Err())
+		}
+	}
+	if data.WifiDetails != nil {
+		for _, info := range data.WifiDetails.Info {
+			check("wifi.hidden", info.Hidden.Err())
+		}
+	}
+	if data.DiskStatus != nil {
+		for _, disk := range data.DiskStatus.Disks {
+			check("disk.total", disk.Total.Err())
+			check("disk.used", disk.Used.Err())
+		}
+	}
+	if data.PortStatus != nil {
+		for _, port := range data.PortStatus.Ports {
+			check("port.speed", port.Speed.Err())
+		}
+	}
+	if data.TopoGraph != nil {
+		var walk func(node *models.MeshNode)
+		walk = func(node *models.MeshNode) {
+			check("mesh.backhaul_rate", node.BackhaulRate.Err())
+			check("mesh.backhaul_upspeed", node.BackhaulUpSpeed.Err())
+			check("mesh.backhaul_downspeed", node.BackhaulDownSpeed.Err())
+			for i := range node.Leafs {
+				walk(&node.Leafs[i])
+			}
+		}
+		walk(&data.TopoGraph.Graph)
+	}
+	if data.WifiStatistics != nil {
+		for _, radio := range data.WifiStatistics.Radios {
+			check("radio.rx_packets", radio.RxPackets.Err())
+			check("radio.tx_packets", radio.TxPackets.Err())
+			check("radio.rx_errors", radio.RxErrors.Err())
+			check("radio.tx_errors", radio.TxErrors.Err())
+			check("radio.rx_dropped", radio.RxDropped.Err())
+			check("radio.tx_dropped", radio.TxDropped.Err())
+			check("radio.tx_retries", radio.TxRetries.Err())
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Plugin exports one group of metric families, such as system or wan.
+// Adding support for a new router feature means registering a fetch task
+// for its endpoint and a plugin exporting its metrics.
+type Plugin interface {
+	// Name identifies the plugin in COLLECTORS_ENABLED and COLLECTORS_DISABLED
+	Name() string
+	// Enabled reports whether the plugin runs with the given configuration
+	Enabled(cfg *config.Config) bool
+	// FetchTasks lists the fetch tasks whose data the plugin exports. Tasks
+	// that no enabled plugin needs are not requested from the router.
+	FetchTasks() []string
+	// FetchAndExport sends the plugin's metrics from the router data of one
+	// collection and does not contact the router itself. The fetch tasks of
+	// all enabled plugins run together when the data is collected, so every
+	// endpoint is requested once per collection however many plugins use
+	// it.
+	FetchAndExport(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData)
+}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   []Plugin
+)
+
+// RegisterPlugin adds a plugin to the collectors created afterwards. It
+// panics if a plugin with the same name is already registered.
+func RegisterPlugin(plugin Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	for _, existing := range plugins {
+		if existing.Name() == plugin.Name() {
+			panic(fmt.Sprintf("collector plugin %q already registered", plugin.Name()))
+		}
+	}
+	plugins = append(plugins, plugin)
+}
+
+// Plugins returns the registered plugins in registration order
+func Plugins() []Plugin {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+
+	result := make([]Plugin, len(plugins))
+	copy(result, plugins)
+	return result
+}
+
+// exportPlugin is a Plugin backed by one of the collector's export functions
+type exportPlugin struct {
+	name   string
+	tasks  []string
+	export func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData)
+	// requires optionally turns the plugin off based on its own settings
+	requires func(cfg *config.Config) bool
+}
+
+func (p *exportPlugin) Name() string {
+	return p.name
+}
+
+func (p *exportPlugin) Enabled(cfg *config.Config) bool {
+	if p.requires != nil && !p.requires(cfg) {
+		return false
+	}
+	return cfg.Collectors.IsEnabled(p.name)
+}
+
+func (p *exportPlugin) FetchTasks() []string {
+	return p.tasks
+}
+
+func (p *exportPlugin) FetchAndExport(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+	p.export(mc, ch, data)
+}
+
+// configurePlugins selects the enabled plugins and drops the fetch tasks
+// only used by disabled ones. Tasks claimed by consumerTasks are kept.
+func (mc *MetricsCollector) configurePlugins() {
+	registered := Plugins()
+
+	known := make(map[string]bool, len(registered))
+	for _, plugin := range registered {
+		known[plugin.Name()] = true
+	}
+	for _, name := range append(mc.config.Collectors.Enabled, mc.config.Collectors.Disabled...) {
+		if !known[name] {
+			logger.Default.Warnf("Ignoring unknown collector %q", name)
+		}
+	}
+
+	claimed := make(map[string]bool)
+	needed := make(map[string]bool)
+	for _, plugin := range registered {
+		enabled := plugin.Enabled(mc.config)
+		if enabled {
+			mc.plugins = append(mc.plugins, plugin)
+		}
+		for _, task := range plugin.FetchTasks() {
+			claimed[task] = true
+			if enabled {
+				needed[task] = true
+			}
+		}
+	}
+	for _, task := range mc.consumerTasks() {
+		needed[task] = true
+	}
+
+	// Tasks registered without a plugin keep running
+	for task := range claimed {
+		if !needed[task] {
+			mc.dataFetcher.RemoveTask(task)
+		}
+	}
+}
+
+// consumerTasks lists the fetch tasks whose data is used outside the
+// plugins, so disabling collectors does not take it away
+func (mc *MetricsCollector) consumerTasks() []string {
+	// The status page counts the online devices, presence and inventory
+	// tracking observe them
+	tasks := []string{"device_list"}
+	if mc.prober != nil {
+		// The WAN gateway is a probe target
+		tasks = append(tasks, "wan_info")
+	}
+	if mc.config.Cache.SnapshotFile != "" {
+		// A snapshot without these is not loaded on restart
+		tasks = append(tasks, "system_status", "device_list", "wan_info", "wifi_details")
+	}
+	return tasks
+}
+
+// EnabledCollectors returns the names of the plugins exporting metrics, in
+// registration order
+func (mc *MetricsCollector) EnabledCollectors() []string {
+	names := make([]string, 0, len(mc.plugins))
+	for _, plugin := range mc.plugins {
+		names = append(names, plugin.Name())
+	}
+	return names
+}
+
+func init() {
+	RegisterPlugin(&exportPlugin{
+		name:  "system",
+		tasks: []string{"system_status", "sys_info"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportSystemMetrics(ch, data)
+			mc.exportRouterInfo(ch)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "devices",
+		tasks: []string{"system_status", "device_list"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportDeviceMetrics(ch, data)
+			mc.exportConnectionCounts(ch, data)
+			mc.exportMeshNodeClients(ch, data)
+			mc.exportDeviceAuthorityMetrics(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "top_devices",
+		tasks: []string{"device_list"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportTopDevices(ch, data)
+		},
+		requires: func(cfg *config.Config) bool {
+			return cfg.Collectors.TopDevices > 0
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "wan",
+		tasks: []string{"system_status", "wan_info"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportWANMetrics(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "iptv",
+		tasks: []string{"iptv_status"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportIPTVInfo(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "upnp",
+		tasks: []string{"upnp_status"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportUPnPMappings(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "wifi",
+		tasks: []string{"wifi_details", "wps_status", "wifi_statistics"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportWiFiMetrics(ch, data)
+			mc.exportRadioStatistics(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "storage",
+		tasks: []string{"disk_status", "samba_status"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportStorageMetrics(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "mesh",
+		tasks: []string{"topo_graph"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportMeshBackhaul(ch, data)
+		},
+	})
+	RegisterPlugin(&exportPlugin{
+		name:  "ports",
+		tasks: []string{"port_status"},
+		export: func(mc *MetricsCollector, ch chan<- prometheus.Metric, data *models.RouterData) {
+			mc.exportPortMetrics(ch, data)
+		},
+	})
+}
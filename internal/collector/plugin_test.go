@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+)
+
+// fetchTaskNames returns the names of the tasks mc requests from the router
+func fetchTaskNames(mc *MetricsCollector) map[string]bool {
+	names := make(map[string]bool)
+	for _, task := range mc.dataFetcher.Tasks() {
+		names[task.Name] = true
+	}
+	return names
+}
+
+func TestDisabledCollectorsKeepConsumedTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		cache   config.CacheConfig
+		want    []string
+		dropped []string
+	}{
+		{
+			name:    "status page",
+			cache:   config.CacheConfig{TTL: time.Second},
+			want:    []string{"device_list"},
+			dropped: []string{"system_status", "wan_info", "wifi_details", "disk_status"},
+		},
+		{
+			name:    "snapshot",
+			cache:   config.CacheConfig{TTL: time.Second, SnapshotFile: t.TempDir() + "/snapshot.json"},
+			want:    []string{"system_status", "device_list", "wan_info", "wifi_details"},
+			dropped: []string{"disk_status", "topo_graph"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := NewMetricsCollector(&config.Config{
+				Router: config.RouterConfig{Host: "miwifi", Timeout: 5},
+				Server: config.ServerConfig{Namespace: "miwifi"},
+				Cache:  tt.cache,
+				Fetch:  config.FetchConfig{Parallelism: 4},
+				Collectors: config.CollectorsConfig{
+					TopDevices: 5,
+					Disabled:   []string{"system", "devices", "top_devices", "wan", "iptv", "upnp", "wifi", "storage", "mesh", "ports"},
+				},
+			})
+			defer mc.Close()
+
+			if enabled := mc.EnabledCollectors(); len(enabled) != 0 {
+				t.Fatalf("got enabled collectors %v, want none", enabled)
+			}
+			tasks := fetchTaskNames(mc)
+			for _, task := range tt.want {
+				if !tasks[task] {
+					t.Errorf("task %s dropped, want it kept for its consumer", task)
+				}
+			}
+			for _, task := range tt.dropped {
+				if tasks[task] {
+					t.Errorf("task %s kept, want it dropped with its collectors", task)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/helloworlde/miwifi-exporter/pkg/cache"
+	"github.com/helloworlde/miwifi-exporter/pkg/loki"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Device events reported by the presence tracker
+const (
+	deviceConnected    = "connect"
+	deviceDisconnected = "disconnect"
+)
+
+// deviceEvent is a device coming online or going offline between two fetches
+// of the device list
+type deviceEvent struct {
+	Event      string    `json:"event"`
+	MAC        string    `json:"mac"`
+	Name       string    `json:"name"`
+	IP         string    `json:"ip,omitempty"`
+	AP         string    `json:"ap"`
+	Connection string    `json:"connection,omitempty"`
+	Time       time.Time `json:"-"`
+}
+
+// knownDevice is a device that was in a device list at least once
+type knownDevice struct {
+	FirstSeen time.Time `json:"first_seen"`
+	// Name is the name the device had when it was first seen
+	Name string `json:"name"`
+}
+
+// presenceTracker compares the online devices of every new device list with
+// the previous one and remembers every device it has seen
+type presenceTracker struct {
+	mu        sync.Mutex
+	online    map[string]deviceEvent
+	fetchedAt time.Time
+	// known are the devices that were in any device list, online or not.
+	// They are saved to file, if set, and outlive restarts.
+	known map[string]knownDevice
+	// seeded is set once known holds the devices of a first device list
+	seeded    bool
+	file      string
+	maxLength int
+
+	events     *prometheus.CounterVec
+	newDevices prometheus.Counter
+	firstSeen  *prometheus.Desc
+}
+
+// newPresenceTracker returns a tracker that keeps the known devices in file,
+// or only in memory if file is empty
+func newPresenceTracker(namespace, file string, maxLabelLength int) *presenceTracker {
+	t := &presenceTracker{
+		known:     make(map[string]knownDevice),
+		file:      file,
+		maxLength: maxLabelLength,
+		events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "device_events_total",
+				Help:      "设备上线和下线的次数",
+			},
+			[]string{"event"},
+		),
+		newDevices: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "new_devices_total",
+			Help:      "首次出现在设备列表中的设备数",
+		}),
+		firstSeen: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "device_first_seen_timestamp_seconds"),
+			"在线设备首次出现在设备列表中的时间",
+			[]string{"mac", "device_name"}, nil,
+		),
+	}
+	t.events.WithLabelValues(deviceConnected)
+	t.events.WithLabelValues(deviceDisconnected)
+
+	if file != "" {
+		if _, err := cache.LoadSnapshot(file, &t.known); err == nil {
+			t.seeded = true
+			logger.Default.Infof("Loaded %d known devices from %s", len(t.known), file)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			logger.Default.Warnf("Ignoring known devices file %s: %v", file, err)
+			t.known = make(map[string]knownDevice)
+		}
+	}
+	return t
+}
+
+func (t *presenceTracker) Describe(ch chan<- *prometheus.Desc) {
+	t.events.Describe(ch)
+	t.newDevices.Describe(ch)
+	ch <- t.firstSeen
+}
+
+// Collect exports the counters and the first seen time of the devices that
+// are online now. Devices that left are not exported, so the series do not
+// pile up with every visitor or randomized MAC address.
+func (t *presenceTracker) Collect(ch chan<- prometheus.Metric) {
+	t.events.Collect(ch)
+	t.newDevices.Collect(ch)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for mac, state := range t.online {
+		known, ok := t.known[mac]
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(t.firstSeen, prometheus.GaugeValue,
+			float64(known.FirstSeen.Unix()), mac, sanitizeLabelValue(state.Name, t.maxLength))
+	}
+}
+
+// observe returns the devices that came online or went offline since the
+// previous device list, and the devices that were never seen before. Data
+// fetched at the same time as before, which was served from the cache, is
+// skipped. The first device list only sets the baseline, the devices online
+// at startup did not just connect. Without a saved list of known devices,
+// the devices of the first list are not new either.
+func (t *presenceTracker) observe(data *models.RouterData, host string) (events, added []deviceEvent) {
+	if data.DeviceList == nil {
+		return nil, nil
+	}
+	fetchedAt := data.FetchedAt
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !fetchedAt.After(t.fetchedAt) {
+		return nil, nil
+	}
+	t.fetchedAt = fetchedAt
+
+	parents := meshNodes(data.DeviceList)
+	online := make(map[string]deviceEvent, len(data.DeviceList.List))
+	for i := range data.DeviceList.List {
+		device := &data.DeviceList.List[i]
+		if device.Mac == "" {
+			continue
+		}
+		state := deviceEvent{
+			MAC:        normalizeMAC(device.Mac),
+			Name:       deviceName(device),
+			AP:         host,
+			Connection: deviceConnection(device),
+			Time:       fetchedAt,
+		}
+		if len(device.IP) > 0 {
+			state.IP = device.IP[0].IP
+		}
+		if node, ok := parents[normalizeMAC(device.Parent)]; ok && node.name != "" {
+			state.AP = node.name
+		}
+		if _, ok := t.known[state.MAC]; !ok {
+			t.known[state.MAC] = knownDevice{FirstSeen: fetchedAt, Name: state.Name}
+			if t.seeded {
+				added = append(added, state)
+			}
+		}
+		if device.Online != 0 {
+			online[state.MAC] = state
+		}
+	}
+	seeding := !t.seeded
+	t.seeded = true
+	t.newDevices.Add(float64(len(added)))
+	if len(added) > 0 || seeding {
+		t.save()
+	}
+
+	previous := t.online
+	t.online = online
+	if previous == nil {
+		return nil, added
+	}
+
+	for mac, state := range online {
+		if _, ok := previous[mac]; !ok {
+			state.Event = deviceConnected
+			events = append(events, state)
+		}
+	}
+	for mac, state := range previous {
+		if _, ok := online[mac]; !ok {
+			state.Event = deviceDisconnected
+			state.Time = fetchedAt
+			events = append(events, state)
+		}
+	}
+	for _, event := range events {
+		t.events.WithLabelValues(event.Event).Inc()
+	}
+	return events, added
+}
+
+// save writes the known devices to the file, if any. The caller holds mu.
+func (t *presenceTracker) save() {
+	if t.file == "" {
+		return
+	}
+	if err := cache.SaveSnapshot(t.file, t.known); err != nil {
+		logger.Default.Warnf("Failed to save known devices: %v", err)
+	}
+}
+
+// trackPresence reports the devices that connected or disconnected since
+// the previous collection and the devices seen for the first time
+func (mc *MetricsCollector) trackPresence(data *models.RouterData) {
+	if mc.presence == nil {
+		return
+	}
+
+	events, added := mc.presence.observe(data, mc.config.Router.Host)
+	for _, device := range added {
+		logger.Default.Infof("New device %s (%s) seen, access point %s", device.Name, device.MAC, device.AP)
+		mc.notifyNewDevice(device)
+	}
+	for _, event := range events {
+		logger.Default.Infof("Device %s (%s) %sed, access point %s", event.Name, event.MAC, event.Event, event.AP)
+		if mc.config.Loki.DeviceEvents {
+			mc.pushDeviceEvent(event)
+		}
+	}
+}
+
+// pushDeviceEvent pushes a device event to Loki as a JSON line
+func (mc *MetricsCollector) pushDeviceEvent(event deviceEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	maxLength := mc.config.Server.MaxLabelLength
+	mc.loki.Push(loki.Entry{
+		Labels: map[string]string{
+			"job":   "miwifi_device_events",
+			"host":  mc.config.Router.Host,
+			"event": event.Event,
+			"mac":   event.MAC,
+			"name":  sanitizeLabelValue(event.Name, maxLength),
+			"ap":    sanitizeLabelValue(event.AP, maxLength),
+		},
+		Time: event.Time,
+		Line: string(line),
+	})
+}
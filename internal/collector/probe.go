@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"context"
+	"net"
+
+	"github.com/helloworlde/miwifi-exporter/pkg/probe"
+)
+
+// probeTargets returns the router's LAN address and, once wan_info was
+// fetched, the WAN gateway
+func (mc *MetricsCollector) probeTargets() []probe.Target {
+	targets := []probe.Target{{Name: "router", Address: mc.config.Router.IP}}
+	current := mc.current.Load()
+	if current == nil || current.data == nil || current.data.WanInfo == nil {
+		return targets
+	}
+	if gateway := current.data.WanInfo.Info.GateWay; net.ParseIP(gateway) != nil {
+		targets = append(targets, probe.Target{Name: "wan_gateway", Address: gateway})
+	}
+	return targets
+}
+
+// RunProbes probes the router and the WAN gateway until ctx is cancelled. It
+// returns at once when probes are disabled.
+func (mc *MetricsCollector) RunProbes(ctx context.Context) {
+	if mc.prober == nil {
+		return
+	}
+	mc.prober.Run(ctx)
+}
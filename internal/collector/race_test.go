@@ -0,0 +1,7 @@
+//go:build race
+
+package collector
+
+// raceEnabled relaxes timing checks, the race detector slows code down
+// several times
+const raceEnabled = true
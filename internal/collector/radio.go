@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exportRadioStatistics exports the packet, error and retry counters of every
+// radio interface. A rising share of retries and errors on one radio points to
+// interference on its channel.
+func (mc *MetricsCollector) exportRadioStatistics(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.WifiStatistics == nil {
+		return
+	}
+
+	host := mc.config.Router.Host
+	for _, radio := range data.WifiStatistics.Radios {
+		if radio.IfName == "" {
+			continue
+		}
+
+		counters := []struct {
+			desc  string
+			value models.FlexibleInt
+		}{
+			{"wifi_radio_rx_packets_total", radio.RxPackets},
+			{"wifi_radio_tx_packets_total", radio.TxPackets},
+			{"wifi_radio_rx_errors_total", radio.RxErrors},
+			{"wifi_radio_tx_errors_total", radio.TxErrors},
+			{"wifi_radio_rx_dropped_total", radio.RxDropped},
+			{"wifi_radio_tx_dropped_total", radio.TxDropped},
+			{"wifi_radio_tx_retries_total", radio.TxRetries},
+		}
+		for _, c := range counters {
+			// Counters the driver does not keep are missing, not 0
+			if !c.value.Valid {
+				continue
+			}
+			ch <- mc.constMetric(
+				mc.descriptors[c.desc],
+				prometheus.CounterValue,
+				float64(c.value.Value),
+				host, radio.IfName,
+			)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// trafficSample is the traffic totals of a device at one router fetch
+type trafficSample struct {
+	upload   float64
+	download float64
+	at       time.Time
+}
+
+// deviceRate is the average traffic of a device between two router fetches
+type deviceRate struct {
+	upload   float64
+	download float64
+}
+
+// rateTracker derives per-device byte rates from the traffic totals of
+// consecutive router fetches, for backends that cannot compute rate()
+// themselves. Cached data is not a new sample, so rates cover the cache
+// interval.
+type rateTracker struct {
+	mu       sync.Mutex
+	previous map[string]trafficSample
+	rates    map[string]deviceRate
+}
+
+func newRateTracker() *rateTracker {
+	return &rateTracker{
+		previous: make(map[string]trafficSample),
+		rates:    make(map[string]deviceRate),
+	}
+}
+
+// observe records the totals of a fresh router fetch taken at at. A device
+// gets a rate once it was seen in the previous fetch too; totals going
+// backwards mean the router reset them, the device then has no rate until
+// the next fetch.
+func (t *rateTracker) observe(data *models.RouterData, at time.Time) {
+	if data == nil || data.SystemStatus == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[string]trafficSample, len(data.SystemStatus.Dev))
+	rates := make(map[string]deviceRate, len(data.SystemStatus.Dev))
+	for _, dev := range data.SystemStatus.Dev {
+		upload, errUp := dev.Upload.Float64()
+		download, errDown := dev.Download.Float64()
+		if errUp != nil || errDown != nil {
+			continue
+		}
+		sample := trafficSample{upload: upload, download: download, at: at}
+		current[dev.Mac] = sample
+
+		prev, ok := t.previous[dev.Mac]
+		if !ok || upload < prev.upload || download < prev.download {
+			continue
+		}
+		elapsed := at.Sub(prev.at).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		rates[dev.Mac] = deviceRate{
+			upload:   (upload - prev.upload) / elapsed,
+			download: (download - prev.download) / elapsed,
+		}
+	}
+
+	// Devices missing from this fetch are forgotten
+	t.previous = current
+	t.rates = rates
+}
+
+// rate returns the rate of the device with mac computed by the last observe
+func (t *rateTracker) rate(mac string) (deviceRate, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rates[mac]
+	return r, ok
+}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// scrapeTimeoutHeader is set by Prometheus to the scrape timeout in seconds
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeCollector binds the collector to the context of one scrape
+type scrapeCollector struct {
+	mc  *MetricsCollector
+	ctx context.Context
+	// exported is the collection of the last Collect call
+	exported *collection
+}
+
+func (sc *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	sc.mc.Describe(ch)
+}
+
+func (sc *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	sc.exported = sc.mc.collect(sc.ctx, ch)
+}
+
+// Gatherer returns a gatherer for a single collection bound to ctx. It
+// gathers the router metrics together with the registry from GetRegistry.
+// The configured constant labels are added to every metric.
+func (mc *MetricsCollector) Gatherer(ctx context.Context) prometheus.Gatherer {
+	return mc.gatherer(&scrapeCollector{mc: mc, ctx: ctx})
+}
+
+func (mc *MetricsCollector) gatherer(sc *scrapeCollector) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		registry := prometheus.NewRegistry()
+		registerer := prometheus.WrapRegistererWith(mc.config.Server.ConstLabels, registry)
+		if err := registerer.Register(sc); err != nil {
+			return nil, err
+		}
+		return prometheus.Gatherers{mc.metrics, registry}.Gather()
+	})
+}
+
+// CheckConstLabels reports an error if a configured constant label is also
+// the name of a metric label. Only the descriptors are checked, the router
+// is not contacted.
+func (mc *MetricsCollector) CheckConstLabels() error {
+	if mc.registerErr != nil {
+		return fmt.Errorf("invalid constant labels: %w", mc.registerErr)
+	}
+
+	registerer := prometheus.WrapRegistererWith(mc.config.Server.ConstLabels, prometheus.NewRegistry())
+	if err := registerer.Register(&scrapeCollector{mc: mc, ctx: context.Background()}); err != nil {
+		return fmt.Errorf("invalid constant labels: %w", err)
+	}
+	return nil
+}
+
+// Handler serves the metrics endpoint. Router requests are cancelled when the
+// scraper disconnects or its scrape timeout passes.
+//
+// With the cache enabled responses carry an ETag of the router data's fetch
+// time and may be cached until the data expires. A request whose
+// If-None-Match still names the cached data is answered with 304 without
+// collecting. The exporter's own metrics are not considered, they are only
+// refreshed once the router data changes.
+//
+// Responses are compressed with the configured gzip level instead of
+// promhttp's fixed one. Protobuf and OpenMetrics are negotiated by promhttp.
+func (mc *MetricsCollector) Handler(opts promhttp.HandlerOpts) http.Handler {
+	opts.DisableCompression = true
+	level := mc.config.Server.CompressionLevel
+	gzipPool := sync.Pool{New: func() any {
+		gz, _ := gzip.NewWriterLevel(nil, level)
+		return gz
+	}}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The format and encoding are negotiated
+		w.Header().Add("Vary", "Accept, Accept-Encoding")
+		// Keeps cache preloading going, background work does not
+		mc.cache.MarkScraped()
+		if mc.config.Cache.Enabled && mc.notModified(w, r) {
+			return
+		}
+
+		ctx := r.Context()
+		if timeout := scrapeTimeout(r); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		if level > 0 && gzipAccepted(r.Header) {
+			gz := gzipPool.Get().(*gzip.Writer)
+			defer gzipPool.Put(gz)
+			gz.Reset(w)
+			defer gz.Close()
+
+			w.Header().Set("Content-Encoding", "gzip")
+			w = &gzipResponseWriter{ResponseWriter: w, gz: gz}
+		}
+
+		sc := &scrapeCollector{mc: mc, ctx: ctx}
+		if mc.config.Cache.Enabled {
+			w = &cacheHeaderWriter{ResponseWriter: w, mc: mc, sc: sc}
+		}
+		promhttp.HandlerFor(mc.gatherer(sc), opts).ServeHTTP(w, r)
+	})
+}
+
+// notModified answers 304 and returns true when the request's If-None-Match
+// matches the current collection and its data is neither expired nor
+// superseded by a background refresh of the cache
+func (mc *MetricsCollector) notModified(w http.ResponseWriter, r *http.Request) bool {
+	match := r.Header.Get("If-None-Match")
+	current := mc.current.Load()
+	if match == "" || current == nil || current.data == nil || current.stale {
+		return false
+	}
+	if current.data.FetchedAt.UnixNano() != mc.cachedAt.Load() || mc.cacheMaxAge(current) <= 0 {
+		return false
+	}
+
+	etag := strings.TrimPrefix(collectionETag(current), "W/")
+	for _, candidate := range strings.Split(match, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			mc.setCacheHeaders(w.Header(), current)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// setCacheHeaders sets the validator and lifetime of a response exporting c
+func (mc *MetricsCollector) setCacheHeaders(header http.Header, c *collection) {
+	if c == nil || c.data == nil {
+		header.Set("Cache-Control", "no-cache")
+		return
+	}
+	header.Set("ETag", collectionETag(c))
+	maxAge := max(mc.cacheMaxAge(c), 0)
+	header.Set("Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge.Seconds())))
+}
+
+// cacheMaxAge returns how much longer the data of c is served from the
+// cache, zero or less once it expired
+func (mc *MetricsCollector) cacheMaxAge(c *collection) time.Duration {
+	if c.stale {
+		return 0
+	}
+	return time.Until(c.data.FetchedAt.Add(mc.config.Cache.TTL))
+}
+
+// collectionETag returns the entity tag of the router data of c. It is weak,
+// the exposition format, encoding and self metrics of responses vary.
+func collectionETag(c *collection) string {
+	return `W/"` + strconv.FormatInt(c.data.FetchedAt.UnixNano(), 36) + `"`
+}
+
+// cacheHeaderWriter sets the cache headers once the scrape collected, which
+// is before promhttp writes the response
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	mc          *MetricsCollector
+	sc          *scrapeCollector
+	wroteHeader bool
+}
+
+func (w *cacheHeaderWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK {
+			w.mc.setCacheHeaders(w.Header(), w.sc.exported)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// gzipAccepted reports whether the client accepts gzip encoded responses
+func gzipAccepted(header http.Header) bool {
+	for _, part := range strings.Split(header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		// gzip;q=0 explicitly refuses it
+		q, found := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		return !found || strings.Trim(q, "0.") != ""
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the response body
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.gz.Write(b)
+}
+
+// scrapeTimeout returns the timeout announced by Prometheus, zero if absent
+func scrapeTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,452 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/client"
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestMain(m *testing.M) {
+	// Failed and cancelled collections are logged
+	logger.Default = logger.NewWithOutput("error", "text", io.Discard)
+	os.Exit(m.Run())
+}
+
+// BenchmarkScrape measures the allocations of one uncached scrape of the demo
+// fixtures, from fetching the router data to the gathered metric families
+func BenchmarkScrape(b *testing.B) {
+	routerClient, err := client.NewFileRouterClient("../../fixtures/demo")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	mc := NewMetricsCollector(&config.Config{
+		Router: config.RouterConfig{Host: "miwifi", Timeout: 5},
+		Server: config.ServerConfig{Namespace: "miwifi"},
+		Cache:  config.CacheConfig{Enabled: false, TTL: time.Second},
+		Fetch:  config.FetchConfig{Parallelism: 4},
+	})
+	mc.SetClient(routerClient)
+	defer mc.Close()
+
+	gatherer := mc.Gatherer(context.Background())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := gatherer.Gather(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestHandlerNegotiation scrapes a large network in every exposition format,
+// with and without compression, and checks the router metrics and constant
+// labels of the per-scrape registry survive the negotiation
+func TestHandlerNegotiation(t *testing.T) {
+	mc := NewMetricsCollector(&config.Config{
+		Router: config.RouterConfig{Host: "miwifi", Timeout: 5},
+		Server: config.ServerConfig{
+			Namespace:        "miwifi",
+			ConstLabels:      map[string]string{"site": "home"},
+			CompressionLevel: 1,
+		},
+		Cache: config.CacheConfig{Enabled: true, TTL: time.Hour},
+		Fetch: config.FetchConfig{Parallelism: 4},
+	})
+	mc.SetClient(newFixtureClient(t, 300))
+	defer mc.Close()
+	handler := mc.Handler(promhttp.HandlerOpts{})
+
+	formats := []expfmt.Format{
+		expfmt.NewFormat(expfmt.TypeTextPlain),
+		expfmt.NewFormat(expfmt.TypeProtoDelim),
+	}
+	for _, format := range formats {
+		for _, encoding := range []string{"", "gzip"} {
+			t.Run(string(format)+"/"+encoding, func(t *testing.T) {
+				request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+				request.Header.Set("Accept", string(format))
+				request.Header.Set("Accept-Encoding", encoding)
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, request)
+
+				if response.Code != http.StatusOK {
+					t.Fatalf("status %d: %s", response.Code, response.Body)
+				}
+				if got := expfmt.ResponseFormat(response.Header()); got.FormatType() != format.FormatType() {
+					t.Fatalf("negotiated %q, want %q", got, format)
+				}
+				if got := response.Header().Get("Content-Encoding"); got != encoding {
+					t.Fatalf("Content-Encoding %q, want %q", got, encoding)
+				}
+
+				var body io.Reader = response.Body
+				if encoding == "gzip" {
+					gz, err := gzip.NewReader(body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					body = gz
+				}
+				families := decodeFamilies(t, body, expfmt.ResponseFormat(response.Header()))
+
+				devices := families["miwifi_device_upload_traffic"]
+				if devices == nil || len(devices.Metric) != 300 {
+					t.Fatalf("got %v, want 300 device series", devices)
+				}
+				if !hasLabel(devices.Metric[0], "site", "home") {
+					t.Errorf("constant label missing from %v", devices.Metric[0])
+				}
+			})
+		}
+	}
+}
+
+// blockingClient holds every system status request until release is closed
+// or the request is cancelled
+type blockingClient struct {
+	client.RouterClient
+	entered chan struct{}
+	once    sync.Once
+	release chan struct{}
+}
+
+func newBlockingClient(rc client.RouterClient) *blockingClient {
+	return &blockingClient{RouterClient: rc, entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (bc *blockingClient) GetSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
+	bc.once.Do(func() { close(bc.entered) })
+	select {
+	case <-bc.release:
+		return bc.RouterClient.GetSystemStatus(ctx)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// gatherDevices gathers mc and returns the number of device series, failing
+// when the gathering waits for longer than a second
+func gatherDevices(t *testing.T, mc *MetricsCollector) int {
+	t.Helper()
+
+	gathered := make(chan int, 1)
+	go func() {
+		families, err := mc.Gatherer(context.Background()).Gather()
+		if err != nil {
+			t.Error(err)
+		}
+		for _, family := range families {
+			if family.GetName() == "miwifi_device_upload_traffic" {
+				gathered <- len(family.Metric)
+				return
+			}
+		}
+		gathered <- 0
+	}()
+
+	select {
+	case devices := <-gathered:
+		return devices
+	case <-time.After(time.Second):
+		t.Fatal("scrape waited for the router")
+		return 0
+	}
+}
+
+// TestScrapeWithinRefreshInterval checks that a scrape within
+// ROUTER_REFRESH_INTERVAL of the background refresh exports its collection
+// even while the next refresh is still waiting for the router
+func TestScrapeWithinRefreshInterval(t *testing.T) {
+	mc := NewMetricsCollector(&config.Config{
+		Router: config.RouterConfig{Host: "miwifi", Timeout: 5, RefreshInterval: time.Minute},
+		Server: config.ServerConfig{Namespace: "miwifi"},
+		Cache:  config.CacheConfig{Enabled: false, TTL: time.Second},
+		Fetch:  config.FetchConfig{Parallelism: 4},
+	})
+	defer mc.Close()
+	fixtures := newFixtureClient(t, 10)
+	mc.SetClient(fixtures)
+	mc.refresh(context.Background(), time.Now())
+
+	slow := newBlockingClient(fixtures)
+	mc.SetClient(slow)
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		mc.refresh(context.Background(), time.Now())
+	}()
+	<-slow.entered
+
+	if devices := gatherDevices(t, mc); devices != 10 {
+		t.Errorf("got %d device series, want the 10 devices of the background collection", devices)
+	}
+	close(slow.release)
+	<-refreshed
+}
+
+func TestRunRefreshDisabled(t *testing.T) {
+	mc := NewMetricsCollector(&config.Config{
+		Router: config.RouterConfig{Host: "miwifi", Timeout: 5},
+		Server: config.ServerConfig{Namespace: "miwifi"},
+		Cache:  config.CacheConfig{Enabled: false, TTL: time.Second},
+		Fetch:  config.FetchConfig{Parallelism: 4},
+	})
+	defer mc.Close()
+	mc.SetClient(newFixtureClient(t, 10))
+
+	// Without ROUTER_REFRESH_INTERVAL the loop returns at once and only
+	// scrapes contact the router
+	mc.RunRefresh(context.Background())
+	if current := mc.current.Load(); current != nil {
+		t.Fatalf("RunRefresh collected %+v, want no collection", current)
+	}
+	if devices := gatherDevices(t, mc); devices != 10 {
+		t.Errorf("got %d device series, want the scrape to collect the 10 devices", devices)
+	}
+}
+
+// counterValue returns the value of the counter name with the given labels
+// in the collector's own registry, 0 if it was never incremented
+func counterValue(t *testing.T, mc *MetricsCollector, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := mc.metrics.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.Metric {
+			matches := true
+			for label, value := range labels {
+				matches = matches && hasLabel(metric, label, value)
+			}
+			if matches {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+// TestCancelledScrapeAbortsFetch checks that router requests of a scrape end
+// when the scraper disconnects or its announced scrape timeout passes
+func TestCancelledScrapeAbortsFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(ctx context.Context) (*http.Request, context.CancelFunc)
+	}{
+		{
+			name: "disconnect",
+			request: func(ctx context.Context) (*http.Request, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(ctx)
+				return httptest.NewRequest(http.MethodGet, "/metrics", nil).WithContext(ctx), cancel
+			},
+		},
+		{
+			name: "scrape timeout",
+			request: func(ctx context.Context) (*http.Request, context.CancelFunc) {
+				request := httptest.NewRequest(http.MethodGet, "/metrics", nil).WithContext(ctx)
+				request.Header.Set(scrapeTimeoutHeader, "0.05")
+				return request, func() {}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := NewMetricsCollector(&config.Config{
+				Router: config.RouterConfig{Host: "miwifi", Timeout: 60},
+				Server: config.ServerConfig{Namespace: "miwifi"},
+				Cache:  config.CacheConfig{Enabled: false, TTL: time.Second},
+				Fetch:  config.FetchConfig{Parallelism: 4},
+			})
+			defer mc.Close()
+			slow := newBlockingClient(newFixtureClient(t, 10))
+			defer close(slow.release)
+			mc.SetClient(slow)
+
+			request, cancel := tt.request(context.Background())
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				mc.Handler(promhttp.HandlerOpts{}).ServeHTTP(httptest.NewRecorder(), request)
+			}()
+			<-slow.entered
+			cancel()
+
+			select {
+			case <-served:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the scrape kept waiting for the router")
+			}
+			labels := map[string]string{"operation": "collect", "error_type": "scrape_cancelled"}
+			if got := counterValue(t, mc, "miwifi_collection_errors_total", labels); got != 1 {
+				t.Errorf("counted %v cancelled scrapes, want 1", got)
+			}
+			if current := mc.current.Load(); current != nil {
+				t.Errorf("the cancelled scrape published %+v, want no collection", current)
+			}
+		})
+	}
+}
+
+// countingClient counts the system status requests reaching the router
+type countingClient struct {
+	client.RouterClient
+	requests atomic.Int64
+}
+
+func (cc *countingClient) GetSystemStatus(ctx context.Context) (*models.SystemStatus, error) {
+	cc.requests.Add(1)
+	return cc.RouterClient.GetSystemStatus(ctx)
+}
+
+// TestScrapesThrottled checks that scrapes within ROUTER_MIN_COLLECT_INTERVAL
+// of the last collection export it again without contacting the router
+func TestScrapesThrottled(t *testing.T) {
+	mc := NewMetricsCollector(&config.Config{
+		Router: config.RouterConfig{Host: "miwifi", Timeout: 5, MinCollectInterval: time.Minute},
+		Server: config.ServerConfig{Namespace: "miwifi"},
+		Cache:  config.CacheConfig{Enabled: false, TTL: time.Second},
+		Fetch:  config.FetchConfig{Parallelism: 4},
+	})
+	defer mc.Close()
+	router := &countingClient{RouterClient: newFixtureClient(t, 10)}
+	mc.SetClient(router)
+
+	for i := 0; i < 3; i++ {
+		if devices := gatherDevices(t, mc); devices != 10 {
+			t.Fatalf("scrape %d got %d device series, want 10", i, devices)
+		}
+	}
+	// A health check is held to the interval too, but is no scrape
+	mc.refresh(context.Background(), time.Now())
+
+	if requests := router.requests.Load(); requests != 1 {
+		t.Errorf("router got %d requests, want only the first scrape to collect", requests)
+	}
+	if got := counterValue(t, mc, "miwifi_collections_throttled_total", nil); got != 2 {
+		t.Errorf("counted %v throttled collections, want the 2 skipped scrapes", got)
+	}
+}
+
+// releasingClient returns a new device list for every request and records
+// the lists handed back to it
+type releasingClient struct {
+	client.RouterClient
+	mu       sync.Mutex
+	released []*models.DeviceList
+}
+
+func (rc *releasingClient) GetDeviceList(ctx context.Context) (*models.DeviceList, error) {
+	list, err := rc.RouterClient.GetDeviceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	copied := *list
+	copied.List = append([]models.DeviceEntry(nil), list.List...)
+	return &copied, nil
+}
+
+func (rc *releasingClient) ReleaseDeviceList(list *models.DeviceList) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.released = append(rc.released, list)
+}
+
+func (rc *releasingClient) releasedLists() []*models.DeviceList {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]*models.DeviceList(nil), rc.released...)
+}
+
+// TestDeviceListReleasedAfterLastReader checks that the device list of a
+// superseded collection goes back to the client only once no scrape reads
+// it anymore, and never while the cache shares it
+func TestDeviceListReleasedAfterLastReader(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cached=%v", cached), func(t *testing.T) {
+			mc := NewMetricsCollector(&config.Config{
+				Router: config.RouterConfig{Host: "miwifi", Timeout: 5},
+				Server: config.ServerConfig{Namespace: "miwifi"},
+				Cache:  config.CacheConfig{Enabled: cached, TTL: time.Hour},
+				Fetch:  config.FetchConfig{Parallelism: 4},
+			})
+			defer mc.Close()
+			router := &releasingClient{RouterClient: newFixtureClient(t, 10)}
+			mc.SetClient(router)
+			ctx := context.Background()
+
+			mc.refresh(ctx, time.Now())
+			reader := mc.acquireCurrent()
+			first := reader.data.DeviceList
+			mc.refresh(ctx, time.Now())
+			if released := router.releasedLists(); len(released) != 0 {
+				t.Fatalf("released %d lists while a scrape reads the first one, want 0", len(released))
+			}
+
+			reader.unref()
+			released := router.releasedLists()
+			if cached {
+				if len(released) != 0 {
+					t.Fatalf("released %d cached lists, want 0", len(released))
+				}
+				return
+			}
+			if len(released) != 1 || released[0] != first {
+				t.Fatalf("released %v, want the list of the superseded collection", released)
+			}
+			if mc.current.Load().data.DeviceList == first {
+				t.Fatal("the current collection holds the released list")
+			}
+		})
+	}
+}
+
+func decodeFamilies(t *testing.T, r io.Reader, format expfmt.Format) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	families := make(map[string]*dto.MetricFamily)
+	// The protobuf decoder buffers every message, it must not read past it
+	decoder := expfmt.NewDecoder(bufio.NewReader(r), format)
+	for {
+		family := &dto.MetricFamily{}
+		if err := decoder.Decode(family); errors.Is(err, io.EOF) {
+			return families
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		families[family.GetName()] = family
+	}
+}
+
+func hasLabel(metric *dto.Metric, name, value string) bool {
+	for _, label := range metric.Label {
+		if label.GetName() == name && label.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	apperrors "github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	httputil "github.com/helloworlde/miwifi-exporter/pkg/http"
+)
+
+// snapshotDocument is the JSON served by SnapshotHandler
+type snapshotDocument struct {
+	CollectedAt time.Time `json:"collected_at"`
+	// Stale is set while the router is unreachable and the data is the
+	// snapshot persisted at SavedAt
+	Stale   bool               `json:"stale"`
+	SavedAt *time.Time         `json:"saved_at,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	Data    *models.RouterData `json:"data"`
+}
+
+// SnapshotHandler serves the router data of a collection as JSON, for
+// consumers that want the raw data without logging in to the router
+// themselves. A request collects like a scrape does, so it is answered from
+// the background refresh or the cache when they are enabled. Passwords and tokens are scrubbed.
+func (mc *MetricsCollector) SnapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Snapshot consumers keep cache preloading going like scrapes
+		mc.cache.MarkScraped()
+		current := mc.latest(r.Context(), time.Now())
+		if current == nil {
+			http.Error(w, "Request cancelled", http.StatusServiceUnavailable)
+			return
+		}
+		defer current.unref()
+
+		doc := snapshotDocument{
+			CollectedAt: current.finishedAt,
+			Stale:       current.stale,
+			Data:        current.data,
+		}
+		if current.stale {
+			doc.SavedAt = &current.data.FetchedAt
+		}
+		if current.err != nil {
+			doc.Error = apperrors.RedactError(current.err).Error()
+		}
+
+		body, err := json.Marshal(doc)
+		if err != nil {
+			http.Error(w, "Failed to encode snapshot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		status := http.StatusOK
+		if current.data == nil {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(httputil.ScrubJSON(body))
+	})
+}
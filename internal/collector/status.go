@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"time"
+
+	apperrors "github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// Status summarizes the latest collection, for the landing page
+type Status struct {
+	// CollectedAt is when the latest collection finished, the zero time
+	// before the first one
+	CollectedAt time.Time
+	// FetchedAt is when its data was fetched from the router, the zero time
+	// if it has no data
+	FetchedAt time.Time
+	Source    models.DataSource
+	// Stale is set while the data is the persisted snapshot
+	Stale bool
+	// Devices is the number of online devices
+	Devices int
+	// Error is the redacted error of the collection, if it failed
+	Error string
+}
+
+// Status returns a summary of the latest collection without collecting
+func (mc *MetricsCollector) Status() Status {
+	current := mc.acquireCurrent()
+	if current == nil {
+		return Status{}
+	}
+	defer current.unref()
+
+	status := Status{
+		CollectedAt: current.finishedAt,
+		Stale:       current.stale,
+	}
+	if current.err != nil {
+		status.Error = apperrors.RedactError(current.err).Error()
+	}
+	if data := current.data; data != nil {
+		status.FetchedAt = data.FetchedAt
+		status.Source = data.Source
+		if data.DeviceList != nil {
+			for i := range data.DeviceList.List {
+				if data.DeviceList.List[i].Online != 0 {
+					status.Devices++
+				}
+			}
+		}
+	}
+	return status
+}
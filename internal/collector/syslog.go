@@ -0,0 +1,18 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+)
+
+// RunSyslog receives the router's logs until ctx is cancelled. It returns at
+// once when router log collection is disabled.
+func (mc *MetricsCollector) RunSyslog(ctx context.Context) {
+	if mc.syslog == nil {
+		return
+	}
+	if err := mc.syslog.Run(ctx); err != nil {
+		logger.Default.Errorf("Router log collection stopped: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// deviceSpeed is the current speed of one client in one direction
+type deviceSpeed struct {
+	device *models.DeviceEntry
+	speed  float64
+}
+
+// rankDevices returns the clients ordered by the speed returned by speed,
+// fastest first. Ties are ordered by MAC so ranks do not flap.
+func rankDevices(list *models.DeviceList, speed func(*models.DeviceEntry) string) []deviceSpeed {
+	speeds := make([]deviceSpeed, 0, len(list.List))
+	for i := range list.List {
+		device := &list.List[i]
+		// Mesh nodes forward their clients' traffic, ranking them would
+		// hide the clients
+		if device.IsAP != 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(speed(device), 64)
+		if err != nil {
+			continue
+		}
+		speeds = append(speeds, deviceSpeed{device: device, speed: value})
+	}
+
+	sort.Slice(speeds, func(i, j int) bool {
+		if speeds[i].speed != speeds[j].speed {
+			return speeds[i].speed > speeds[j].speed
+		}
+		return speeds[i].device.Mac < speeds[j].device.Mac
+	})
+	return speeds
+}
+
+// exportTopDevices exports the clients with the highest current download and
+// upload speed by rank, a bounded number of series for dashboards that do not
+// ingest every device
+func (mc *MetricsCollector) exportTopDevices(ch chan<- prometheus.Metric, data *models.RouterData) {
+	if data.DeviceList == nil {
+		return
+	}
+
+	host := mc.config.Router.Host
+	n := mc.config.Collectors.TopDevices
+	export := func(desc *prometheus.Desc, speed func(*models.DeviceEntry) string) {
+		rank := 0
+		for _, top := range rankDevices(data.DeviceList, speed) {
+			if rank == n {
+				break
+			}
+			labels, ok := mc.deviceLabels(top.device.Mac, top.device, nil)
+			if !ok {
+				continue
+			}
+			rank++
+			// deviceLabels starts with ip, mac and device_name
+			ch <- mc.constMetric(
+				desc,
+				prometheus.GaugeValue,
+				top.speed,
+				host, strconv.Itoa(rank), labels[1], labels[2], labels[0],
+			)
+		}
+	}
+
+	export(mc.descriptors["top_device_download_speed"], func(device *models.DeviceEntry) string {
+		return device.Statistics.DownSpeed
+	})
+	export(mc.descriptors["top_device_upload_speed"], func(device *models.DeviceEntry) string {
+		return device.Statistics.UpSpeed
+	})
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exportUPnPMappings exports whether UPnP is enabled and the port mappings
+// clients opened with it. A mapping nobody expects, or a sudden rise in
+// their number, can be malware making a device reachable from the internet.
+func (mc *MetricsCollector) exportUPnPMappings(ch chan<- prometheus.Metric, data *models.RouterData) {
+	upnp := data.UPnPStatus
+	if upnp == nil {
+		return
+	}
+
+	host := mc.config.Router.Host
+	if upnp.Status.Valid {
+		enabled := 0.0
+		if upnp.Status.Value != 0 {
+			enabled = 1
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["upnp_enabled"],
+			prometheus.GaugeValue,
+			enabled,
+			host,
+		)
+	}
+
+	// An external port is mapped once per protocol, a repeated entry would
+	// fail the scrape with a duplicate series
+	seen := make(map[string]bool, len(upnp.List))
+	for _, mapping := range upnp.List {
+		if !mapping.ExternalPort.Valid {
+			continue
+		}
+		protocol := strings.ToUpper(mapping.Protocol)
+		externalPort := strconv.FormatInt(mapping.ExternalPort.Value, 10)
+		if seen[protocol+"/"+externalPort] {
+			continue
+		}
+		seen[protocol+"/"+externalPort] = true
+
+		clientPort := ""
+		if mapping.Port.Valid {
+			clientPort = strconv.FormatInt(mapping.Port.Value, 10)
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["upnp_mapping_info"],
+			prometheus.GaugeValue,
+			1,
+			host, protocol, externalPort, mapping.IP, clientPort, mapping.Name,
+		)
+	}
+
+	ch <- mc.constMetric(
+		mc.descriptors["upnp_mappings"],
+		prometheus.GaugeValue,
+		float64(len(seen)),
+		host,
+	)
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exportWANPorts exports the mode and every port of a dual WAN router, with
+// the port name as interface label. Single WAN routers only have the totals
+// exported by exportWANMetrics.
+func (mc *MetricsCollector) exportWANPorts(ch chan<- prometheus.Metric, data *models.RouterData) {
+	wanInfo := data.WanInfo
+	host := mc.config.Router.Host
+
+	if dual := wanInfo.DualWAN; dual != nil && dual.Enable.Value != 0 && dual.Mode != "" {
+		ch <- mc.constMetric(
+			mc.descriptors["wan_mode_info"],
+			prometheus.GaugeValue,
+			1,
+			host, dual.Mode,
+		)
+	}
+
+	for _, port := range wanInfo.Wans {
+		if port.Name == "" {
+			continue
+		}
+
+		ip := ""
+		if len(port.Info.Ipv4) > 0 {
+			ip = port.Info.Ipv4[0].IP
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["wan_interface_info"],
+			prometheus.GaugeValue,
+			1,
+			host, port.Name, port.Info.Details.IfName, port.Info.Details.WanType, ip,
+		)
+
+		linkUp := 0.0
+		if port.Info.Link != 0 {
+			linkUp = 1
+		}
+		ch <- mc.constMetric(
+			mc.descriptors["wan_interface_link_up"],
+			prometheus.GaugeValue,
+			linkUp,
+			host, port.Name,
+		)
+		ch <- mc.constMetric(
+			mc.descriptors["wan_interface_uptime_seconds"],
+			prometheus.GaugeValue,
+			float64(port.Info.Uptime),
+			host, port.Name,
+		)
+
+		// Traffic is a gauge like the WAN totals, the router resets it
+		values := []struct {
+			desc  string
+			value models.FlexibleInt
+		}{
+			{"wan_interface_active", port.Active},
+			{"wan_interface_upload_speed", port.UpSpeed},
+			{"wan_interface_download_speed", port.DownSpeed},
+			{"wan_interface_upload_traffic", port.Upload},
+			{"wan_interface_download_traffic", port.Download},
+		}
+		for _, v := range values {
+			// Values the firmware does not report are missing, not 0
+			if !v.value.Valid {
+				continue
+			}
+			ch <- mc.constMetric(
+				mc.descriptors[v.desc],
+				prometheus.GaugeValue,
+				float64(v.value.Value),
+				host, port.Name,
+			)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"github.com/helloworlde/miwifi-exporter/pkg/concurrent"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SetWorkerPool fetches the router endpoints on pool, which is shared with
+// the collectors of other routers, and exports its utilization. The fetches
+// of this router are keyed by its address and limited to FETCH_PARALLELISM
+// workers. Call it before CheckConstLabels.
+func (mc *MetricsCollector) SetWorkerPool(pool *concurrent.WorkerPool) {
+	mc.dataFetcher.UsePool(pool, mc.config.Router.IP)
+
+	namespace := mc.config.Server.Namespace
+	mc.register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_pool_workers",
+		Help:      "所有路由器共用的拉取线程数",
+	}, func() float64 {
+		return float64(pool.Stats().Workers)
+	}))
+	mc.register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_pool_busy_workers",
+		Help:      "正在请求路由器接口的拉取线程数",
+	}, func() float64 {
+		return float64(pool.Stats().Busy)
+	}))
+	mc.register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_pool_queued_tasks",
+		Help:      "等待空闲拉取线程的接口请求数",
+	}, func() float64 {
+		return float64(pool.Stats().Queued)
+	}))
+	mc.register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "worker_pool_tasks_total",
+		Help:      "拉取线程执行的接口请求总数",
+	}, func() float64 {
+		return float64(pool.Stats().Completed)
+	}))
+}
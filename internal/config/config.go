@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/caarlos0/env/v11"
 	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/common/model"
 )
 
 type Config struct {
@@ -17,27 +20,304 @@ type Config struct {
 	Cache     CacheConfig  `json:"cache" envPrefix:"CACHE_"`
 	Logging   LoggingConfig `json:"logging" envPrefix:"LOGGING_"`
 	Memory    MemoryConfig `json:"memory" envPrefix:"MEMORY_"`
+	Fetch     FetchConfig  `json:"fetch" envPrefix:"FETCH_"`
+	Collectors CollectorsConfig `json:"collectors" envPrefix:"COLLECTORS_"`
+	Health    HealthConfig `json:"health" envPrefix:"HEALTH_"`
+	Tracing   TracingConfig `json:"tracing" envPrefix:"TRACING_"`
+	Actions   ActionsConfig `json:"actions" envPrefix:"ACTIONS_"`
+	Probe     ProbeConfig  `json:"probe" envPrefix:"PROBE_"`
+	Histogram HistogramConfig `json:"histogram" envPrefix:"HISTOGRAM_"`
+	Syslog    SyslogConfig `json:"syslog" envPrefix:"SYSLOG_"`
+	Loki      LokiConfig   `json:"loki" envPrefix:"LOKI_"`
+	Notify    NotifyConfig `json:"notify" envPrefix:"NOTIFY_"`
+	Inventory InventoryConfig `json:"inventory" envPrefix:"INVENTORY_"`
 }
 
 type RouterConfig struct {
 	IP       string `json:"ip" env:"IP" validate:"required,ip"`
-	Password string `json:"password" env:"PASSWORD" validate:"required,min=1"`
+	// 登录用户名，小米路由器固件默认只有 admin
+	Username string `json:"username" env:"USERNAME" default:"admin" validate:"required"`
+	Password string `json:"password" env:"PASSWORD" validate:"required,min=1" secret:"true"`
 	Host     string `json:"host" env:"HOST" default:"miwifi"`
+	// 超时时间（秒），未单独设置 RequestTimeout、CollectTimeout 或 AuthTimeout 时用于它们
 	Timeout  int    `json:"timeout" env:"TIMEOUT" default:"30" validate:"min=1"`
+	// 单个 HTTP 请求的超时时间，一个接口的重试各自计时，0 表示使用 Timeout
+	RequestTimeout time.Duration `json:"request_timeout" env:"REQUEST_TIMEOUT" default:"0s" validate:"min=0"`
+	// 一次采集拉取所有接口的总时限，含重试，0 表示使用 Timeout；FETCH_TIMEOUTS 设置单个接口的时限
+	CollectTimeout time.Duration `json:"collect_timeout" env:"COLLECT_TIMEOUT" default:"0s" validate:"min=0"`
+	// 后台定时从路由器采集数据的间隔，间隔内的抓取直接返回后台采集的数据，0 表示不在后台采集，由抓取触发采集
+	RefreshInterval time.Duration `json:"refresh_interval" env:"REFRESH_INTERVAL" default:"0s" validate:"min=0"`
+	// 两次从路由器采集之间的最短间隔，间隔内的抓取直接返回上一次采集的数据，防止抓取间隔配置过短时频繁访问路由器，0 表示不限制
+	MinCollectInterval time.Duration `json:"min_collect_interval" env:"MIN_COLLECT_INTERVAL" default:"0s" validate:"min=0"`
+	// 一次登录（含重试）的时限，0 表示使用 Timeout
+	AuthTimeout time.Duration `json:"auth_timeout" env:"AUTH_TIMEOUT" default:"0s" validate:"min=0"`
+	// 登录 nonce 中的设备类型，网页登录为 0
+	NonceType int `json:"nonce_type" env:"NONCE_TYPE" default:"0" validate:"min=0"`
+	// 登录被拒绝时依次尝试另一种密码哈希和 nonce 设备类型，兼容 init_info 报告有误的固件
+	LoginFallback bool `json:"login_fallback" env:"LOGIN_FALLBACK" default:"true"`
+	// 每分钟最多发送的登录请求数，包括尝试其他登录格式的请求，避免密码错误时频繁登录导致路由器锁定
+	LoginsPerMinute int `json:"logins_per_minute" env:"LOGINS_PER_MINUTE" default:"5" validate:"min=1"`
+	// 路由器因登录次数过多拒绝登录后，下一次登录前至少等待的时间
+	LockoutBackoff time.Duration `json:"lockout_backoff" env:"LOCKOUT_BACKOFF" default:"15m" validate:"min=0"`
+	// 访问路由器使用的代理，支持 http、https、socks5 和 socks5h，例如通过 ssh -D 建立的 SOCKS 隧道 socks5://127.0.0.1:1080，为空时使用 HTTP_PROXY 环境变量
+	ProxyURL string `json:"proxy_url" env:"PROXY_URL" validate:"omitempty,url,proxyurl" secret:"true"`
+	// 加密保存登录会话的文件，重启后复用会话而不必重新登录，为空表示不保存
+	SessionFile string `json:"session_file" env:"SESSION_FILE"`
+	// 会话文件的密钥文件，不存在时自动生成，为空时使用 <session_file>.key
+	SessionKeyFile string `json:"session_key_file" env:"SESSION_KEY_FILE"`
+	// 单个路由器响应的最大大小（MB），超出时放弃读取，防止异常响应耗尽内存
+	MaxResponseMB int `json:"max_response_mb" env:"MAX_RESPONSE_MB" validate:"min=1"`
+}
+
+// TimeoutOr 返回 timeout，为 0 时返回 Timeout，用于 RequestTimeout、CollectTimeout 和 AuthTimeout
+func (r RouterConfig) TimeoutOr(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return time.Duration(r.Timeout) * time.Second
 }
 
 type ServerConfig struct {
 	Port         int           `json:"port" env:"PORT" default:"9001" validate:"min=1,max=65535"`
+	// 监听地址，可以有多个，例如 127.0.0.1 或 127.0.0.1:9001,[::1]，只写主机时使用 Port，为空表示监听所有网卡
+	ListenAddress []string `json:"listen_address" env:"LISTEN_ADDRESS" validate:"dive,listenaddress"`
 	MetricsPath  string        `json:"metrics_path" env:"METRICS_PATH" default:"/metrics"`
 	Namespace    string        `json:"namespace" env:"NAMESPACE" default:"miwifi"`
 	ReadTimeout  time.Duration `json:"read_timeout" env:"READ_TIMEOUT" default:"30s"`
 	WriteTimeout time.Duration `json:"write_timeout" env:"WRITE_TIMEOUT" default:"30s"`
 	IdleTimeout  time.Duration `json:"idle_timeout" env:"IDLE_TIMEOUT" default:"60s"`
+	WebConfigFile string       `json:"web_config_file" env:"WEB_CONFIG_FILE"`
+	// 导出 exporter 自身的 Go 运行时和进程指标(go_*、process_*)
+	RuntimeMetrics bool        `json:"runtime_metrics" env:"RUNTIME_METRICS" default:"true"`
+	// 路由器指标名中 namespace 之后的部分，例如 home 得到 miwifi_home_cpu_load，为空表示不使用
+	Subsystem string `json:"subsystem" env:"SUBSYSTEM" validate:"omitempty,labelname"`
+	// 附加到所有指标的固定标签，例如 site:home,rack:a
+	ConstLabels map[string]string `json:"const_labels" env:"CONST_LABELS" validate:"dive,keys,labelname,endkeys"`
+	// 来自路由器的标签值（设备名、SSID 等）的最大字符数，超出部分被截断，0 表示不限制
+	MaxLabelLength int `json:"max_label_length" env:"MAX_LABEL_LENGTH" validate:"min=0"`
+	// 客户端支持时指标响应的 gzip 压缩级别，1 最快，9 最小，0 表示不压缩
+	CompressionLevel int `json:"compression_level" env:"COMPRESSION_LEVEL" default:"6" validate:"min=0,max=9"`
+	// 关闭根路径 / 的 HTML 首页，/ 返回 404
+	DisableLandingPage bool `json:"disable_landing_page" env:"DISABLE_LANDING_PAGE"`
+	// 自定义首页的 Go html/template 模板文件，为空表示使用内置模板
+	LandingPageTemplate string `json:"landing_page_template" env:"LANDING_PAGE_TEMPLATE" validate:"omitempty,file"`
+	// 允许浏览器跨域调用 /api/ 接口的来源，例如 http://localhost:3000，* 表示任意来源，为空表示不允许跨域
+	CORSOrigins []string `json:"cors_origins" env:"CORS_ORIGINS" validate:"dive,corsorigin"`
+	// 为响应添加 X-Content-Type-Options 等标准安全响应头
+	SecurityHeaders bool `json:"security_headers" env:"SECURITY_HEADERS" default:"true"`
+}
+
+// MetricPrefix 返回路由器指标名的前缀，即 namespace 和可选的 subsystem
+func (s ServerConfig) MetricPrefix() string {
+	if s.Subsystem == "" {
+		return s.Namespace
+	}
+	return s.Namespace + "_" + s.Subsystem
 }
 
 type CacheConfig struct {
 	Enabled bool          `json:"enabled" env:"ENABLED" default:"true"`
 	TTL     time.Duration `json:"ttl" env:"TTL" default:"60s"`
+	// 过期后仍可返回旧数据并在后台刷新的最长时间，0 表示禁用
+	MaxStale time.Duration `json:"max_stale" env:"MAX_STALE" default:"0s" validate:"min=0"`
+	// 持久化最近一次数据的快照文件，重启后在路由器可用前返回旧数据，为空表示禁用
+	SnapshotFile string `json:"snapshot_file" env:"SNAPSHOT_FILE"`
+	// 在缓存过期前于后台重新拉取数据，抓取不必等待路由器
+	Preload bool `json:"preload" env:"PRELOAD" default:"false"`
+	// 两次预加载的间隔，0 表示 TTL 的一半
+	PreloadInterval time.Duration `json:"preload_interval" env:"PRELOAD_INTERVAL" default:"0s" validate:"min=0"`
+	// 每次间隔随机增减的最大比例，避免与其他轮询同步
+	PreloadJitter float64 `json:"preload_jitter" env:"PRELOAD_JITTER" default:"0.1" validate:"min=0,max=1"`
+	// 超过该时长没有抓取时暂停预加载，直到下一次抓取，0 表示不暂停；电池供电的路由器空闲时无线不必一直工作
+	PreloadIdleAfter time.Duration `json:"preload_idle_after" env:"PRELOAD_IDLE_AFTER" default:"5m" validate:"min=0"`
+}
+
+// FetchConfig 控制路由器接口的并发拉取
+type FetchConfig struct {
+	// 同时请求的接口数量，每个路由器单独计算
+	Parallelism int `json:"parallelism" env:"PARALLELISM" default:"4" validate:"min=1"`
+	// 所有路由器共用的拉取线程数，常驻运行，不随每次采集创建和销毁
+	PoolWorkers int `json:"pool_workers" env:"POOL_WORKERS" default:"16" validate:"min=1"`
+	// 按接口覆盖超时时间，例如 device_list:20s,system_status:3s
+	Timeouts map[string]time.Duration `json:"timeouts" env:"TIMEOUTS"`
+	// 失败时不影响本次采集的接口
+	BestEffort []string `json:"best_effort" env:"BEST_EFFORT"`
+	// 失败时本次采集失败的接口
+	Required []string `json:"required" env:"REQUIRED"`
+	// 接口请求因网络错误、超时或 5xx 失败后的重试次数，0 表示不重试
+	Retries int `json:"retries" env:"RETRIES" validate:"min=0"`
+	// 按接口覆盖重试次数，例如 device_list:0,system_status:3
+	EndpointRetries map[string]int `json:"endpoint_retries" env:"ENDPOINT_RETRIES" validate:"dive,min=0"`
+	// 第一次重试前的等待时间，之后每次翻倍
+	RetryDelay time.Duration `json:"retry_delay" env:"RETRY_DELAY" validate:"min=0"`
+	// 重试等待时间的上限，实际等待时间在上限的一半到上限之间随机选取
+	RetryMaxDelay time.Duration `json:"retry_max_delay" env:"RETRY_MAX_DELAY" validate:"min=0"`
+}
+
+// CollectorsConfig 选择导出哪些指标组，例如 system、devices、wan
+type CollectorsConfig struct {
+	// 只启用这些指标组，为空表示启用全部
+	Enabled []string `json:"enabled" env:"ENABLED"`
+	// 禁用的指标组，优先于 Enabled
+	Disabled []string `json:"disabled" env:"DISABLED"`
+	// 在导出端根据两次抓取之间的流量差计算设备每秒上传和下载字节数，供没有 rate() 的后端使用
+	DeviceRates bool `json:"device_rates" env:"DEVICE_RATES"`
+	// top_devices 指标组导出当前上传和下载速度最高的设备数，0 表示不导出
+	TopDevices int `json:"top_devices" env:"TOP_DEVICES" validate:"min=0"`
+	// 设备缺少 IP 或名称时的处理方式：unknown 用 unknown 作为标签值，skip 不导出该设备
+	EmptyLabels string `json:"empty_labels" env:"EMPTY_LABELS" validate:"oneof=unknown skip"`
+	// 比较每次拉取的设备列表，记录设备上线和下线
+	DeviceEvents bool `json:"device_events" env:"DEVICE_EVENTS" default:"false"`
+	// 保存见过的设备的文件，重启后仍能识别首次出现的新设备，为空时只保存在内存中；设置后同时启用设备上下线跟踪
+	KnownDevicesFile string `json:"known_devices_file" env:"KNOWN_DEVICES_FILE"`
+}
+
+// IsEnabled 判断指定名称的指标组是否启用
+func (c CollectorsConfig) IsEnabled(name string) bool {
+	for _, disabled := range c.Disabled {
+		if disabled == name {
+			return false
+		}
+	}
+	if len(c.Enabled) == 0 {
+		return true
+	}
+	for _, enabled := range c.Enabled {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HealthConfig 控制 /-/healthy 健康检查
+type HealthConfig struct {
+	// 最近一次成功采集路由器数据的最长间隔，超过后健康检查失败，0 表示不检查路由器
+	MaxCollectionAge time.Duration `json:"max_collection_age" env:"MAX_COLLECTION_AGE" default:"0s" validate:"min=0"`
+}
+
+// TracingConfig 控制采集过程的链路追踪，通过 OTLP/HTTP 导出
+type TracingConfig struct {
+	Enabled bool `json:"enabled" env:"ENABLED" default:"false"`
+	// OTLP/HTTP 接收端地址，span 发送到 <endpoint>/v1/traces
+	Endpoint string `json:"endpoint" env:"ENDPOINT" default:"http://localhost:4318" validate:"required,url"`
+	// 导出请求附带的请求头，例如 Authorization:Bearer xxx
+	Headers map[string]string `json:"headers" env:"HEADERS" secret:"true"`
+	// 上报的 service.name
+	ServiceName string `json:"service_name" env:"SERVICE_NAME" default:"miwifi-exporter"`
+}
+
+// ActionsConfig 控制通过 HTTP 接口在路由器上执行的操作，默认全部关闭
+type ActionsConfig struct {
+	// 允许通过 POST /api/v1/wol 唤醒局域网设备
+	WakeOnLAN bool `json:"wake_on_lan" env:"WAKE_ON_LAN" default:"false"`
+	// 允许通过 POST /api/v1/devices/block 和 /api/v1/devices/unblock 禁止或恢复设备访问外网
+	BlockDevices bool `json:"block_devices" env:"BLOCK_DEVICES" default:"false"`
+	// 调用操作接口时 Authorization: Bearer 请求头中的令牌，启用任一操作时必填
+	Token string `json:"token" env:"TOKEN" validate:"actiontoken" secret:"true"`
+}
+
+// Enabled 返回是否启用了任一操作
+func (a ActionsConfig) Enabled() bool {
+	return a.WakeOnLAN || a.BlockDevices
+}
+
+// ProbeConfig 控制从 exporter 主机到路由器 LAN 地址和 WAN 网关的延迟与丢包探测
+type ProbeConfig struct {
+	Enabled bool `json:"enabled" env:"ENABLED" default:"false"`
+	// 探测方式：icmp 发送 ping，需要运行用户在 net.ipv4.ping_group_range 中或有 CAP_NET_RAW；tcp 测量建立 TCP 连接的时间
+	Mode string `json:"mode" env:"MODE" default:"icmp" validate:"oneof=icmp tcp"`
+	// 两轮探测的间隔
+	Interval time.Duration `json:"interval" env:"INTERVAL" default:"15s" validate:"min=1s"`
+	// 每轮向每个目标发送的探测数
+	Count int `json:"count" env:"COUNT" default:"3" validate:"min=1"`
+	// 单次探测等待回复的时间，超时计为丢包
+	Timeout time.Duration `json:"timeout" env:"TIMEOUT" default:"1s" validate:"min=1ms"`
+	// tcp 方式连接的端口，连接被拒绝同样说明目标有回复
+	TCPPort int `json:"tcp_port" env:"TCP_PORT" default:"80" validate:"min=1,max=65535"`
+}
+
+// HistogramConfig 设置 exporter 自身耗时直方图的桶上界（秒），为空时使用内置的桶，
+// 路由器较慢、一次采集需要 10 秒以上时，内置的桶会让大部分观测值落入 +Inf
+type HistogramConfig struct {
+	// 一次采集的耗时 collection_duration_seconds，默认 0.005 到 10
+	CollectionBuckets []float64 `json:"collection_buckets" env:"COLLECTION_BUCKETS" validate:"buckets"`
+	// 单个路由器 HTTP 请求的耗时 http_request_duration_seconds，默认 0.1 到 10
+	HTTPBuckets []float64 `json:"http_buckets" env:"HTTP_BUCKETS" validate:"buckets"`
+	// 单个接口拉取的耗时 data_fetch_duration_seconds，默认 0.5 到 30
+	FetchBuckets []float64 `json:"fetch_buckets" env:"FETCH_BUCKETS" validate:"buckets"`
+}
+
+// SyslogConfig 控制接收路由器发送的 syslog 日志，需要在路由器上把远程日志服务器设置为 exporter
+type SyslogConfig struct {
+	Enabled bool `json:"enabled" env:"ENABLED" default:"false"`
+	// 接收 syslog 的 UDP 地址
+	ListenAddress string `json:"listen_address" env:"LISTEN_ADDRESS" default:":5514" validate:"hostname_port"`
+	// 把收到的日志原样追加到该文件，为空表示不保存
+	ForwardFile string `json:"forward_file" env:"FORWARD_FILE"`
+	// 把收到的日志推送到 LOKI_URL
+	ForwardLoki bool `json:"forward_loki" env:"FORWARD_LOKI" default:"false" validate:"lokiurl"`
+}
+
+// LokiConfig 设置推送日志的 Grafana Loki
+type LokiConfig struct {
+	// Loki 地址，例如 http://loki:3100，日志推送到 <url>/loki/api/v1/push
+	URL string `json:"url" env:"URL" validate:"omitempty,url"`
+	// 推送请求附带的请求头，例如 X-Scope-OrgID:home
+	Headers map[string]string `json:"headers" env:"HEADERS" secret:"true"`
+	// 把设备上线和下线事件推送到 Loki，同时启用设备上下线跟踪
+	DeviceEvents bool `json:"device_events" env:"DEVICE_EVENTS" default:"false" validate:"lokiurl"`
+}
+
+// NotifyConfig 设置事件通知，配置了任一渠道时发送，各渠道收到相同的通知
+type NotifyConfig struct {
+	// 通用 webhook，通知以 JSON POST 到该地址
+	WebhookURL string `json:"webhook_url" env:"WEBHOOK_URL" validate:"omitempty,url" secret:"true"`
+	// Telegram 机器人令牌，通知由该机器人发送到 TelegramChatID
+	TelegramToken string `json:"telegram_token" env:"TELEGRAM_TOKEN" secret:"true"`
+	// 接收通知的 Telegram 聊天 ID，设置 TelegramToken 时必填
+	TelegramChatID string `json:"telegram_chat_id" env:"TELEGRAM_CHAT_ID" validate:"telegramchat"`
+	// Bark 推送地址，包含设备密钥，例如 https://api.day.app/xxxx
+	BarkURL string `json:"bark_url" env:"BARK_URL" validate:"omitempty,url" secret:"true"`
+	// Server 酱 SendKey
+	ServerChanKey string `json:"serverchan_key" env:"SERVERCHAN_KEY" secret:"true"`
+	// 发送通知的事件
+	Events []string `json:"events" env:"EVENTS" default:"router_unreachable,new_device,firmware_update" validate:"dive,oneof=router_unreachable new_device firmware_update"`
+	// 路由器持续无法访问多久后发送通知，恢复时再通知一次
+	UnreachableAfter time.Duration `json:"unreachable_after" env:"UNREACHABLE_AFTER" default:"5m" validate:"min=1s"`
+	// 检查固件更新的间隔，路由器每次检查都会访问小米的升级服务器
+	FirmwareCheckInterval time.Duration `json:"firmware_check_interval" env:"FIRMWARE_CHECK_INTERVAL" default:"6h" validate:"min=1m"`
+	// 发送一条通知的超时时间
+	Timeout time.Duration `json:"timeout" env:"TIMEOUT" default:"10s" validate:"min=1s"`
+}
+
+// Configured 返回是否配置了任一通知渠道
+func (n NotifyConfig) Configured() bool {
+	return n.WebhookURL != "" || n.TelegramToken != "" || n.BarkURL != "" || n.ServerChanKey != ""
+}
+
+// Enabled 返回是否发送 event 事件的通知
+func (n NotifyConfig) Enabled(event string) bool {
+	if !n.Configured() {
+		return false
+	}
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// InventoryConfig 声明应当在网的设备，必需的设备离线过久时导出 expected_device_missing，
+// 例如摄像头、门锁等需要一直在线的设备
+type InventoryConfig struct {
+	// 预期设备的 MAC 到名称，环境变量中写作 MAC=名称，例如 AA:BB:CC:DD:EE:FF=门口摄像头,11:22:33:44:55:66=NAS
+	Devices map[string]string `json:"devices" env:"DEVICES" envKeyValSeparator:"=" validate:"dive,keys,mac,endkeys,required"`
+	// 必须在线的设备，Devices 中的 MAC 或名称
+	Required []string `json:"required" env:"REQUIRED" validate:"dive,inventorydevice"`
+	// 必须在线的设备离线多久后视为缺失
+	MissingAfter time.Duration `json:"missing_after" env:"MISSING_AFTER" default:"10m" validate:"min=0"`
 }
 
 type LoggingConfig struct {
@@ -47,8 +327,6 @@ type LoggingConfig struct {
 
 type MemoryConfig struct {
 	Enabled           bool `json:"enabled" env:"ENABLED" default:"true"`
-	OptimizeOnCollect bool `json:"optimize_on_collect" env:"OPTIMIZE_ON_COLLECT" default:"true"`
-	ForceGCOnClose    bool `json:"force_gc_on_close" env:"FORCE_GC_ON_CLOSE" default:"true"`
 	TrackAllocations  bool `json:"track_allocations" env:"TRACK_ALLOCATIONS" default:"true"`
 	EnablePoolStats   bool `json:"enable_pool_stats" env:"ENABLE_POOL_STATS" default:"true"`
 }
@@ -56,8 +334,13 @@ type MemoryConfig struct {
 var (
 	defaultConfig = Config{
 		Router: RouterConfig{
+			Username: "admin",
 			Host:    "miwifi",
+			LoginFallback: true,
+			LoginsPerMinute: 5,
+			LockoutBackoff: 15 * time.Minute,
 			Timeout: 30,
+			MaxResponseMB: 16,
 		},
 		Server: ServerConfig{
 			Port:         9001,
@@ -66,10 +349,16 @@ var (
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 30 * time.Second,
 			IdleTimeout:  60 * time.Second,
+			RuntimeMetrics: true,
+			MaxLabelLength: 128,
+			CompressionLevel: 6,
+			SecurityHeaders: true,
 		},
 		Cache: CacheConfig{
-			Enabled: true,
-			TTL:     10 * time.Second,
+			Enabled:          true,
+			TTL:              10 * time.Second,
+			PreloadJitter:    0.1,
+			PreloadIdleAfter: 5 * time.Minute,
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -77,15 +366,134 @@ var (
 		},
 		Memory: MemoryConfig{
 			Enabled:           true,
-			OptimizeOnCollect: true,
-			ForceGCOnClose:    true,
 			TrackAllocations:  true,
 			EnablePoolStats:   true,
 		},
+		Fetch: FetchConfig{
+			Parallelism:   4,
+			PoolWorkers:   16,
+			Retries:       2,
+			RetryDelay:    time.Second,
+			RetryMaxDelay: 10 * time.Second,
+		},
+		Collectors: CollectorsConfig{
+			TopDevices: 5,
+			EmptyLabels: "unknown",
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "miwifi-exporter",
+		},
+		Probe: ProbeConfig{
+			Mode:     "icmp",
+			Interval: 15 * time.Second,
+			Count:    3,
+			Timeout:  time.Second,
+			TCPPort:  80,
+		},
+		Syslog: SyslogConfig{
+			ListenAddress: ":5514",
+		},
+		Notify: NotifyConfig{
+			Events:                []string{"router_unreachable", "new_device", "firmware_update"},
+			UnreachableAfter:      5 * time.Minute,
+			FirmwareCheckInterval: 6 * time.Hour,
+			Timeout:               10 * time.Second,
+		},
+		Inventory: InventoryConfig{
+			MissingAfter: 10 * time.Minute,
+		},
 	}
 	validate = validator.New()
 )
 
+func init() {
+	// 监听地址，主机或 主机:端口，主机为空表示所有网卡
+	validate.RegisterValidation("listenaddress", func(fl validator.FieldLevel) bool {
+		address := fl.Field().String()
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return false
+			}
+			if host == "" {
+				return true
+			}
+			address = host
+		}
+		address = strings.Trim(address, "[]")
+		return address != "" && !strings.ContainsAny(address, ":/ ") || net.ParseIP(address) != nil
+	})
+	// 代理地址，net/http 只支持这些协议
+	validate.RegisterValidation("proxyurl", func(fl validator.FieldLevel) bool {
+		u, err := url.Parse(fl.Field().String())
+		if err != nil || u.Host == "" {
+			return false
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+			return true
+		}
+		return false
+	})
+	// 跨域来源，* 或只有协议、主机和端口的 http(s) 地址，与浏览器发送的 Origin 请求头一致
+	validate.RegisterValidation("corsorigin", func(fl validator.FieldLevel) bool {
+		origin := fl.Field().String()
+		if origin == "*" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+			u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+	})
+	// 启用任一操作时必须设置令牌，操作接口不能匿名调用
+	validate.RegisterValidation("actiontoken", func(fl validator.FieldLevel) bool {
+		actions, ok := fl.Parent().Interface().(ActionsConfig)
+		return !ok || !actions.Enabled() || fl.Field().String() != ""
+	})
+	// 直方图的桶上界必须为正数且严格递增，否则注册直方图时 panic
+	validate.RegisterValidation("buckets", func(fl validator.FieldLevel) bool {
+		buckets, ok := fl.Field().Interface().([]float64)
+		if !ok {
+			return false
+		}
+		for i, bound := range buckets {
+			if bound <= 0 || i > 0 && bound <= buckets[i-1] {
+				return false
+			}
+		}
+		return true
+	})
+	// 推送到 Loki 时必须设置 LOKI_URL
+	validate.RegisterValidation("lokiurl", func(fl validator.FieldLevel) bool {
+		cfg, ok := fl.Top().Interface().(Config)
+		return !ok || !fl.Field().Bool() || cfg.Loki.URL != ""
+	})
+	// 设置 Telegram 机器人令牌时必须设置接收通知的聊天
+	validate.RegisterValidation("telegramchat", func(fl validator.FieldLevel) bool {
+		notify, ok := fl.Parent().Interface().(NotifyConfig)
+		return !ok || notify.TelegramToken == "" || fl.Field().String() != ""
+	})
+	// 必须在线的设备要在预期设备中声明，可以用 MAC 或名称指定
+	validate.RegisterValidation("inventorydevice", func(fl validator.FieldLevel) bool {
+		cfg, ok := fl.Top().Interface().(Config)
+		if !ok {
+			return true
+		}
+		device := fl.Field().String()
+		for mac, name := range cfg.Inventory.Devices {
+			if strings.EqualFold(mac, device) || name == device {
+				return true
+			}
+		}
+		return false
+	})
+	// Prometheus 标签名，双下划线开头的名称为 Prometheus 保留
+	validate.RegisterValidation("labelname", func(fl validator.FieldLevel) bool {
+		name := fl.Field().String()
+		return model.LabelName(name).IsValid() && !strings.HasPrefix(name, model.ReservedLabelPrefix)
+	})
+}
+
 func Load() (*Config, error) {
 	cfg := defaultConfig
 
@@ -103,7 +511,7 @@ func Load() (*Config, error) {
 
 	// 验证配置
 	if err := validate.Struct(cfg); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+		return nil, fmt.Errorf("config validation failed: %w", explainValidation(err))
 	}
 
 	return &cfg, nil
@@ -130,12 +538,16 @@ func loadFromFile(cfg *Config) error {
 	// 这里简化处理，实际应该使用JSON解析器
 	// 为了向后兼容，我们保持原有的简单逻辑
 	ip := getFromFile(configFile, "ip")
+	username := getFromFile(configFile, "username")
 	password := getFromFile(configFile, "password")
 	port := getFromFile(configFile, "port")
 
 	if ip != "" {
 		cfg.Router.IP = ip
 	}
+	if username != "" {
+		cfg.Router.Username = username
+	}
 	if password != "" {
 		cfg.Router.Password = password
 	}
@@ -183,7 +595,10 @@ func getFromFile(filename, key string) string {
 }
 
 func (c *Config) Validate() error {
-	return validate.Struct(c)
+	if err := validate.Struct(c); err != nil {
+		return explainValidation(err)
+	}
+	return nil
 }
 
 func (c *Config) GetRouterURL() string {
@@ -191,5 +606,22 @@ func (c *Config) GetRouterURL() string {
 }
 
 func (c *Config) GetServerAddress() string {
-	return fmt.Sprintf(":%d", c.Server.Port)
+	return c.GetServerAddresses()[0]
+}
+
+// GetServerAddresses 返回所有监听地址，只有主机的项补上 Port
+func (c *Config) GetServerAddresses() []string {
+	port := strconv.Itoa(c.Server.Port)
+	if len(c.Server.ListenAddress) == 0 {
+		return []string{":" + port}
+	}
+
+	addresses := make([]string, 0, len(c.Server.ListenAddress))
+	for _, address := range c.Server.ListenAddress {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses
 }
\ No newline at end of file
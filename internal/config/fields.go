@@ -0,0 +1,287 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// secretMask 替换输出中的密码等敏感值
+const secretMask = "******"
+
+// field 描述配置中的一个叶子字段，由 Config 的 json、env、envPrefix、validate 和 secret 标签得到
+type field struct {
+	// JSON 路径，例如 router.ip
+	key string
+	// 环境变量名，例如 ROUTER_IP
+	env string
+	// Go 字段路径，例如 Router.IP，与校验错误的命名空间对应
+	goPath   string
+	validate string
+	secret   bool
+	index    []int
+	typ      reflect.Type
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configFields 按声明顺序列出 Config 的所有叶子字段
+func configFields() []field {
+	var fields []field
+	var walk func(t reflect.Type, index []int, key, env, goPath string)
+	walk = func(t reflect.Type, index []int, key, env, goPath string) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := strings.Split(sf.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			idx := append(append([]int{}, index...), i)
+			if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+				walk(sf.Type, idx, key+name+".", env+sf.Tag.Get("envPrefix"), goPath+sf.Name+".")
+				continue
+			}
+			f := field{
+				key:      key + name,
+				goPath:   goPath + sf.Name,
+				validate: sf.Tag.Get("validate"),
+				secret:   sf.Tag.Get("secret") == "true",
+				index:    idx,
+				typ:      sf.Type,
+			}
+			if tag := sf.Tag.Get("env"); tag != "" {
+				f.env = env + tag
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), nil, "", "", "")
+	return fields
+}
+
+// displayValue 把字段值转换为便于阅读的 JSON 值，时长输出为 30s 这样的字符串
+func displayValue(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Map && v.Type().Elem() == durationType:
+		result := make(map[string]string, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result[fmt.Sprint(iter.Key().Interface())] = time.Duration(iter.Value().Int()).String()
+		}
+		return result
+	case v.Kind() == reflect.Slice && v.IsNil():
+		return reflect.MakeSlice(v.Type(), 0, 0).Interface()
+	case v.Kind() == reflect.Map && v.IsNil():
+		return reflect.MakeMap(v.Type()).Interface()
+	}
+	return v.Interface()
+}
+
+// maskedValue 遮盖敏感字段，map 只遮盖值，保留键便于排查
+func maskedValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Map {
+		result := make(map[string]string, v.Len())
+		for _, key := range v.MapKeys() {
+			result[fmt.Sprint(key.Interface())] = secretMask
+		}
+		return result
+	}
+	if v.IsZero() {
+		return ""
+	}
+	return secretMask
+}
+
+// Effective 返回填充默认值后的生效配置，按 JSON 结构嵌套，敏感值已遮盖
+func (c *Config) Effective() map[string]interface{} {
+	result := make(map[string]interface{})
+	root := reflect.ValueOf(c).Elem()
+	for _, f := range configFields() {
+		value := root.FieldByIndex(f.index)
+		var display interface{}
+		if f.secret {
+			display = maskedValue(value)
+		} else {
+			display = displayValue(value)
+		}
+
+		parts := strings.Split(f.key, ".")
+		section := result
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := section[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				section[part] = next
+			}
+			section = next
+		}
+		section[parts[len(parts)-1]] = display
+	}
+	return result
+}
+
+// SchemaField 描述一个配置项，供部署工具生成或检查配置
+type SchemaField struct {
+	// JSON 配置文件中的路径，例如 router.ip
+	Key string `json:"key"`
+	// 环境变量名，例如 ROUTER_IP，为空表示只能在配置文件中设置
+	Env string `json:"env,omitempty"`
+	// 值的类型，例如 string、int、bool、duration、list of string、map of string to duration，
+	// list 和 map 在环境变量中写作 a,b 和 k:v,k2:v2
+	Type string `json:"type"`
+	// 默认值，时长写作 30s 这样的字符串
+	Default interface{} `json:"default"`
+	// go-playground/validator 的校验规则
+	Validate string `json:"validate,omitempty"`
+	// 敏感值，validate 输出时会被遮盖
+	Secret bool `json:"secret,omitempty"`
+}
+
+// Schema 按声明顺序列出所有配置项，由 Config 的结构体标签和默认配置生成
+func Schema() []SchemaField {
+	defaults := reflect.ValueOf(defaultConfig)
+	fields := configFields()
+	schema := make([]SchemaField, 0, len(fields))
+	for _, f := range fields {
+		schema = append(schema, SchemaField{
+			Key:      f.key,
+			Env:      f.env,
+			Type:     typeName(f.typ),
+			Default:  displayValue(defaults.FieldByIndex(f.index)),
+			Validate: f.validate,
+			Secret:   f.secret,
+		})
+	}
+	return schema
+}
+
+// typeName 返回配置项类型的名称，与 SchemaField.Type 的说明一致
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t.Kind() == reflect.Slice:
+		return "list of " + typeName(t.Elem())
+	case t.Kind() == reflect.Map:
+		return "map of " + typeName(t.Key()) + " to " + typeName(t.Elem())
+	}
+	return t.Kind().String()
+}
+
+// ValidationError 列出配置中所有不合法的字段，每一项都指明 JSON 键和环境变量
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// explainValidation 把 validator 的错误转换为 ValidationError，其他错误原样返回
+func explainValidation(err error) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	byPath := make(map[string]field)
+	for _, f := range configFields() {
+		byPath[f.goPath] = f
+	}
+
+	problems := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		// 命名空间形如 Config.Server.ConstLabels[host-name]，方括号中是 map 的键或列表的下标
+		namespace := strings.TrimPrefix(fe.StructNamespace(), "Config.")
+		mapKey := ""
+		if i := strings.Index(namespace, "["); i >= 0 {
+			mapKey = strings.TrimSuffix(namespace[i+1:], "]")
+			namespace = namespace[:i]
+		}
+
+		name := namespace
+		f, known := byPath[namespace]
+		if known {
+			name = f.key
+			if f.env != "" {
+				name += " (" + f.env + ")"
+			}
+		}
+		// Lists name the entry by value, maps by key
+		showValue := mapKey == ""
+		if mapKey != "" {
+			if known && f.typ.Kind() == reflect.Slice {
+				showValue = true
+			} else {
+				name += fmt.Sprintf(" key %q", mapKey)
+			}
+		}
+
+		problem := name + " " + describeRule(fe)
+		if known && !f.secret && showValue && fe.Tag() != "required" {
+			problem += fmt.Sprintf(", got %q", fmt.Sprint(displayValue(reflect.ValueOf(fe.Value()))))
+		}
+		problems = append(problems, problem)
+	}
+	sort.Strings(problems)
+	return &ValidationError{Problems: problems}
+}
+
+// describeRule 说明字段没有通过的校验规则
+func describeRule(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "ip":
+		return "must be an IP address such as 192.168.31.1"
+	case "url":
+		return "must be a URL such as http://localhost:4318"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "listenaddress":
+		return "must be a host or host:port such as 127.0.0.1, [::1] or 127.0.0.1:9001"
+	case "proxyurl":
+		return "must be an http://, https://, socks5:// or socks5h:// URL with a host, such as socks5://127.0.0.1:1080"
+	case "actiontoken":
+		return "is required when an action such as ACTIONS_WAKE_ON_LAN is enabled"
+	case "buckets":
+		return "must be positive bucket bounds in seconds in increasing order, e.g. 1,5,10,30,60"
+	case "hostname_port":
+		return "must be an address with a port such as :5514 or 0.0.0.0:5514"
+	case "lokiurl":
+		return "requires LOKI_URL to be set"
+	case "telegramchat":
+		return "is required when NOTIFY_TELEGRAM_TOKEN is set"
+	case "mac":
+		return "must be a MAC address such as AA:BB:CC:DD:EE:FF"
+	case "corsorigin":
+		return "must be * or an origin such as http://localhost:3000, without a path"
+	case "file":
+		return "must be an existing file"
+	case "inventorydevice":
+		return "must be a MAC address or name listed in INVENTORY_DEVICES"
+	case "labelname":
+		return "must be a Prometheus label name: letters, digits and underscores, not starting with a digit or __"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return "must not be empty"
+		}
+		if fe.Type() == durationType && fe.Param() == "0" {
+			return "must not be negative"
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	}
+	return fmt.Sprintf("failed the %q check", fe.Tag())
+}
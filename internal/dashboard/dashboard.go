@@ -0,0 +1,171 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Panel describes a single Grafana panel
+type Panel struct {
+	Title string
+	Type  string
+	Unit  string
+	Exprs []Target
+}
+
+// Target describes a Prometheus query rendered in a panel
+type Target struct {
+	Expr   string
+	Legend string
+}
+
+// Panels returns the default panel layout with metric names prefixed by namespace
+func Panels(namespace string) []Panel {
+	m := func(name string) string {
+		return fmt.Sprintf("%s_%s", namespace, name)
+	}
+
+	return []Panel{
+		{
+			Title: "CPU Load",
+			Type:  "timeseries",
+			Unit:  "percent",
+			Exprs: []Target{{Expr: m("cpu_load"), Legend: "{{host}}"}},
+		},
+		{
+			Title: "Memory Usage",
+			Type:  "timeseries",
+			Unit:  "percentunit",
+			Exprs: []Target{{Expr: m("memory_usage"), Legend: "{{host}}"}},
+		},
+		{
+			Title: "Online Devices",
+			Type:  "stat",
+			Unit:  "short",
+			Exprs: []Target{
+				{Expr: m("count_online"), Legend: "online"},
+				{Expr: m("count_all"), Legend: "all"},
+			},
+		},
+		{
+			Title: "Uptime",
+			Type:  "stat",
+			Unit:  "s",
+			Exprs: []Target{{Expr: m("uptime"), Legend: "{{host}}"}},
+		},
+		{
+			Title: "WAN Speed",
+			Type:  "timeseries",
+			Unit:  "Bps",
+			Exprs: []Target{
+				{Expr: m("wan_download_speed"), Legend: "download"},
+				{Expr: m("wan_upload_speed"), Legend: "upload"},
+			},
+		},
+		{
+			Title: "WAN Traffic",
+			Type:  "timeseries",
+			Unit:  "bytes",
+			Exprs: []Target{
+				{Expr: fmt.Sprintf("increase(%s[$__rate_interval])", m("wan_download_traffic")), Legend: "download"},
+				{Expr: fmt.Sprintf("increase(%s[$__rate_interval])", m("wan_upload_traffic")), Legend: "upload"},
+			},
+		},
+		{
+			Title: "Device Download Speed",
+			Type:  "timeseries",
+			Unit:  "Bps",
+			Exprs: []Target{{Expr: m("device_download_speed"), Legend: "{{device_name}} ({{ip}})"}},
+		},
+		{
+			Title: "Device Upload Speed",
+			Type:  "timeseries",
+			Unit:  "Bps",
+			Exprs: []Target{{Expr: m("device_upload_speed"), Legend: "{{device_name}} ({{ip}})"}},
+		},
+		{
+			Title: "WAN Blocked Devices",
+			Type:  "stat",
+			Unit:  "short",
+			Exprs: []Target{{Expr: m("count_wan_blocked"), Legend: "{{host}}"}},
+		},
+		{
+			Title: "USB Storage Usage",
+			Type:  "bargauge",
+			Unit:  "percentunit",
+			Exprs: []Target{{
+				Expr:   fmt.Sprintf("%s / %s", m("usb_disk_used_bytes"), m("usb_disk_total_bytes")),
+				Legend: "{{disk}} {{label}}",
+			}},
+		},
+		{
+			Title: "WiFi Networks",
+			Type:  "table",
+			Unit:  "short",
+			Exprs: []Target{{Expr: m("wifi_detail"), Legend: "{{ssid}}"}},
+		},
+	}
+}
+
+// Generate builds a Grafana dashboard JSON document for the given namespace
+func Generate(namespace string) ([]byte, error) {
+	const (
+		panelWidth  = 12
+		panelHeight = 8
+	)
+
+	panels := Panels(namespace)
+	rendered := make([]map[string]interface{}, 0, len(panels))
+
+	for i, p := range panels {
+		targets := make([]map[string]interface{}, 0, len(p.Exprs))
+		for j, t := range p.Exprs {
+			targets = append(targets, map[string]interface{}{
+				"refId":        string(rune('A' + j)),
+				"expr":         t.Expr,
+				"legendFormat": t.Legend,
+				"datasource":   map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			})
+		}
+
+		rendered = append(rendered, map[string]interface{}{
+			"id":         i + 1,
+			"title":      p.Title,
+			"type":       p.Type,
+			"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos": map[string]int{
+				"x": (i % 2) * panelWidth,
+				"y": (i / 2) * panelHeight,
+				"w": panelWidth,
+				"h": panelHeight,
+			},
+			"fieldConfig": map[string]interface{}{
+				"defaults": map[string]interface{}{"unit": p.Unit},
+			},
+			"targets": targets,
+		})
+	}
+
+	dashboard := map[string]interface{}{
+		"title":         "Xiaomi Router",
+		"uid":           fmt.Sprintf("%s-exporter", namespace),
+		"tags":          []string{"miwifi", "prometheus"},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+			},
+		},
+		"panels": rendered,
+	}
+
+	return json.MarshalIndent(dashboard, "", "  ")
+}
@@ -1,8 +1,10 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"time"
 )
@@ -15,6 +17,8 @@ const (
 	ErrorTypeTimeout        ErrorType = "timeout"
 	ErrorTypeValidation     ErrorType = "validation"
 	ErrorTypeInternal       ErrorType = "internal"
+	// ErrorTypeRouter is a request the router answered with an error code
+	ErrorTypeRouter         ErrorType = "router"
 )
 
 type AppError struct {
@@ -26,9 +30,9 @@ type AppError struct {
 
 func (e *AppError) Error() string {
 	if e.Cause != nil {
-		return fmt.Sprintf("%s: %s (caused by: %v)", e.Type, e.Message, e.Cause)
+		return Redact(fmt.Sprintf("%s: %s (caused by: %v)", e.Type, e.Message, e.Cause))
 	}
-	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+	return Redact(fmt.Sprintf("%s: %s", e.Type, e.Message))
 }
 
 func (e *AppError) Unwrap() error {
@@ -80,6 +84,15 @@ func NewInternalError(message string, cause error) *AppError {
 	}
 }
 
+func NewRouterError(message string, cause error) *AppError {
+	return &AppError{
+		Type:    ErrorTypeRouter,
+		Message: message,
+		Code:    http.StatusBadGateway,
+		Cause:   cause,
+	}
+}
+
 func IsAuthenticationError(err error) bool {
 	var appErr *AppError
 	return errors.As(err, &appErr) && appErr.Type == ErrorTypeAuthentication
@@ -100,6 +113,39 @@ func IsValidationError(err error) bool {
 	return errors.As(err, &appErr) && appErr.Type == ErrorTypeValidation
 }
 
+func IsRouterError(err error) bool {
+	var appErr *AppError
+	return errors.As(err, &appErr) && appErr.Type == ErrorTypeRouter
+}
+
+// Retryable reports whether a failed request may succeed when repeated.
+// Authentication, validation and router errors are answers of the router,
+// and a cancelled or expired request fails again right away.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsAuthenticationError(err) || IsValidationError(err) || IsRouterError(err) {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Backoff returns the delay before retry number attempt, counting from 1:
+// base doubled for every further attempt and capped at maxDelay, of which a
+// random half is dropped so clients failing together do not retry together
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := maxDelay
+	if attempt < 32 && base<<(attempt-1) > 0 && base<<(attempt-1) < maxDelay {
+		delay = base << (attempt - 1)
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
 type RetryHandler struct {
 	maxRetries int
 	maxDelay   time.Duration
@@ -119,10 +165,17 @@ func NewRetryHandler(maxRetries int, maxDelay time.Duration, logger Logger) *Ret
 	}
 }
 
-func (r *RetryHandler) WithRetry(fn func() error) error {
+// WithRetry calls fn until it succeeds, fails with an error that retrying
+// does not fix or maxRetries attempts were made. It gives up as soon as ctx
+// is done, also while waiting for the next attempt, and then returns an error
+// wrapping ctx.Err().
+func (r *RetryHandler) WithRetry(ctx context.Context, fn func() error) error {
 	var lastErr error
 	
 	for i := 0; i < r.maxRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return cancelledRetry(err, i, lastErr)
+		}
 		err := fn()
 		if err == nil {
 			return nil
@@ -130,20 +183,33 @@ func (r *RetryHandler) WithRetry(fn func() error) error {
 		
 		lastErr = err
 		
-		// 如果是验证错误，不重试
-		if IsAuthenticationError(err) || IsValidationError(err) {
+		// 路由器的明确答复和已取消的请求重试也不会成功
+		if !Retryable(err) {
 			return err
 		}
-		
-		// 计算延迟时间
-		delay := time.Duration(i+1) * time.Second
-		if delay > r.maxDelay {
-			delay = r.maxDelay
+		if i == r.maxRetries-1 {
+			break
 		}
 		
+		delay := Backoff(i+1, time.Second, r.maxDelay)
 		r.logger.Warnf("Attempt %d failed: %v, retrying in %v...", i+1, err, delay)
-		time.Sleep(delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return cancelledRetry(ctx.Err(), i+1, lastErr)
+		}
 	}
 	
-	return fmt.Errorf("after %d retries: %w", r.maxRetries, lastErr)
-}
\ No newline at end of file
+	return RedactError(fmt.Errorf("after %d attempts: %w", r.maxRetries, lastErr))
+}
+
+// cancelledRetry reports that retrying stopped because of cause, the error
+// of the cancelled context, after attempts attempts
+func cancelledRetry(cause error, attempts int, lastErr error) error {
+	if lastErr == nil {
+		return cause
+	}
+	return RedactError(fmt.Errorf("%w after %d attempts, last error: %v", cause, attempts, lastErr))
+}
@@ -0,0 +1,112 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type discardLogger struct{}
+
+func (discardLogger) Warnf(format string, args ...interface{})  {}
+func (discardLogger) Errorf(format string, args ...interface{}) {}
+
+// TestWithRetryStopsOnCancel checks that a cancelled context ends the wait
+// for the next attempt at once instead of sleeping through the backoff
+func TestWithRetryStopsOnCancel(t *testing.T) {
+	r := NewRetryHandler(3, 30*time.Second, discardLogger{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := r.WithRetry(ctx, func() error {
+		attempts++
+		time.AfterFunc(20*time.Millisecond, cancel)
+		return NewNetworkError("router unreachable", nil)
+	})
+
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("WithRetry returned after %v, want prompt return on cancellation", elapsed)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if Retryable(err) {
+		t.Errorf("Retryable(%v) = true, a cancelled retry must not be retried again", err)
+	}
+}
+
+func TestWithRetryDeadline(t *testing.T) {
+	r := NewRetryHandler(3, 30*time.Second, discardLogger{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := r.WithRetry(ctx, func() error {
+		return NewNetworkError("router unreachable", nil)
+	})
+
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("WithRetry returned after %v, want return at the deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWithRetryCancelledBeforeFirstAttempt(t *testing.T) {
+	r := NewRetryHandler(3, 30*time.Second, discardLogger{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := r.WithRetry(ctx, func() error {
+		called = true
+		return nil
+	})
+
+	if called {
+		t.Error("fn was called with a cancelled context")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []error
+		attempts int
+		wantErr  bool
+	}{
+		{name: "success", attempts: 1},
+		{name: "recovers", failures: []error{NewNetworkError("timeout", nil)}, attempts: 2},
+		{name: "not retryable", failures: []error{NewAuthenticationError("wrong password", nil)}, attempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRetryHandler(3, time.Second, discardLogger{})
+			attempts := 0
+			err := r.WithRetry(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tt.failures) {
+					return tt.failures[attempts-1]
+				}
+				return nil
+			})
+
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
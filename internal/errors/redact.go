@@ -0,0 +1,127 @@
+package errors
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RedactedValue replaces secrets in redacted text
+const RedactedValue = "***"
+
+// minSecretLength is the length below which registered secrets are not
+// masked literally. Short values occur inside ordinary words, masking them
+// would garble log lines and reveal where the secret's characters appear.
+// The field patterns still mask them in parameters and JSON.
+const minSecretLength = 8
+
+var (
+	redactPatterns = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		// stok path segment, e.g. /cgi-bin/luci/;stok=abc/api/...
+		{regexp.MustCompile(`;stok=[^/\s"&]*`), ";stok=" + RedactedValue},
+		// form and query parameters, e.g. password=abc&nonce=...
+		{regexp.MustCompile(`(?i)\b(password|passwd|pwd|token|stok|secret|nonce)=[^&\s"/;),]*`), "${1}=" + RedactedValue},
+		// JSON string fields, e.g. "token":"abc"
+		{regexp.MustCompile(`(?i)"(password|passwd|pwd|token|stok|secret)"\s*:\s*"[^"]*"`), `"${1}":"` + RedactedValue + `"`},
+		// SHA1 and SHA256 hex digests, as produced for the login password
+		{regexp.MustCompile(`\b([0-9a-fA-F]{64}|[0-9a-fA-F]{40})\b`), RedactedValue},
+	}
+
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret adds a literal value, such as the configured router
+// password, that Redact masks where it appears as a whole token. Values
+// shorter than minSecretLength are ignored.
+func RegisterSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	for _, existing := range secrets {
+		if existing == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+// Redact masks tokens, passwords, password hashes and stok values in s
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = maskToken(s, secret)
+	}
+	secretsMu.RUnlock()
+
+	for _, p := range redactPatterns {
+		s = p.pattern.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// maskToken replaces occurrences of secret in s that are not part of a longer
+// word
+func maskToken(s, secret string) string {
+	var b strings.Builder
+	rest := s
+	for {
+		i := strings.Index(rest, secret)
+		if i < 0 {
+			break
+		}
+		end := i + len(secret)
+		before, _ := utf8.DecodeLastRuneInString(rest[:i])
+		after, _ := utf8.DecodeRuneInString(rest[end:])
+		if isWordRune(before) || isWordRune(after) {
+			_, size := utf8.DecodeRuneInString(rest[i:])
+			b.WriteString(rest[:i+size])
+			rest = rest[i+size:]
+			continue
+		}
+		b.WriteString(rest[:i])
+		b.WriteString(RedactedValue)
+		rest = rest[end:]
+	}
+	if b.Len() == 0 {
+		return s
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+// isWordRune reports whether r continues a word, utf8.RuneError at the start
+// or end of the text does not
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// RedactError returns an error whose message is redacted. The original error
+// stays reachable through errors.Is and errors.As.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
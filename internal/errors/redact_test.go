@@ -0,0 +1,53 @@
+package errors
+
+import "testing"
+
+// withSecrets registers values for the duration of the test
+func withSecrets(t *testing.T, values ...string) {
+	secretsMu.Lock()
+	saved := secrets
+	secrets = nil
+	secretsMu.Unlock()
+	t.Cleanup(func() {
+		secretsMu.Lock()
+		secrets = saved
+		secretsMu.Unlock()
+	})
+
+	for _, value := range values {
+		RegisterSecret(value)
+	}
+}
+
+func TestRedactIgnoresShortSecrets(t *testing.T) {
+	withSecrets(t, "x", "offline")
+
+	for _, s := range []string{
+		"Starting miwifi-exporter",
+		"cache.max_stale=0s router.proxy_url= fetch.retry_max_delay=10s",
+		"demo mode: offline router",
+	} {
+		if got := Redact(s); got != s {
+			t.Errorf("Redact(%q) = %q, want it unchanged", s, got)
+		}
+	}
+	if got := Redact("login failed: password=x"); got != "login failed: password=***" {
+		t.Errorf("got %q, want the password parameter masked", got)
+	}
+}
+
+func TestRedactMasksSecretAsWholeToken(t *testing.T) {
+	withSecrets(t, "hunter2hunter2")
+
+	tests := map[string]string{
+		"login rejected for hunter2hunter2":  "login rejected for ***",
+		`dial "hunter2hunter2": refused`:     `dial "***": refused`,
+		"xhunter2hunter2 hunter2hunter2y":    "xhunter2hunter2 hunter2hunter2y",
+		"hunter2hunter2hunter2hunter2 found": "hunter2hunter2hunter2hunter2 found",
+	}
+	for s, want := range tests {
+		if got := Redact(s); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KitLogger adapts a Logger to the key-value interface of go-kit/log used by
+// the Prometheus exporter-toolkit. The level and msg pairs pick the method
+// and message, the other pairs are appended as key=value.
+type KitLogger struct {
+	Logger Logger
+}
+
+func (l KitLogger) Log(keyvals ...interface{}) error {
+	level, msg := "info", ""
+	var fields []string
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+
+		switch key {
+		case "level":
+			level = fmt.Sprint(value)
+		case "msg":
+			msg = fmt.Sprint(value)
+		default:
+			fields = append(fields, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+
+	if msg != "" {
+		fields = append([]string{msg}, fields...)
+	}
+	line := strings.Join(fields, " ")
+	switch level {
+	case "debug":
+		l.Logger.Debug(line)
+	case "warn":
+		l.Logger.Warn(line)
+	case "error":
+		l.Logger.Error(line)
+	default:
+		l.Logger.Info(line)
+	}
+	return nil
+}
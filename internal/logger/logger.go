@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"io"
 	"log"
 	"os"
+
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
 )
 
 type Logger interface {
@@ -27,6 +30,14 @@ type StandardLogger struct {
 }
 
 func New(level string, format string) Logger {
+	return NewWithOutput(level, format, os.Stdout)
+}
+
+// NewWithOutput creates a logger writing non-error levels to out
+func NewWithOutput(level string, format string, out io.Writer) Logger {
+	out = &redactingWriter{out: out}
+	stderr := &redactingWriter{out: os.Stderr}
+	
 	var flags int
 	
 	if format == "json" {
@@ -36,12 +47,24 @@ func New(level string, format string) Logger {
 	}
 
 	return &StandardLogger{
-		debug: log.New(os.Stdout, "DEBUG: ", flags),
-		info:  log.New(os.Stdout, "INFO: ", flags),
-		warn:  log.New(os.Stdout, "WARN: ", flags),
-		error: log.New(os.Stderr, "ERROR: ", flags),
-		fatal: log.New(os.Stderr, "FATAL: ", flags),
+		debug: log.New(out, "DEBUG: ", flags),
+		info:  log.New(out, "INFO: ", flags),
+		warn:  log.New(out, "WARN: ", flags),
+		error: log.New(stderr, "ERROR: ", flags),
+		fatal: log.New(stderr, "FATAL: ", flags),
+	}
+}
+
+// redactingWriter masks tokens and passwords before a log line is written
+type redactingWriter struct {
+	out io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, errors.Redact(string(p))); err != nil {
+		return 0, err
 	}
+	return len(p), nil
 }
 
 func (l *StandardLogger) Debug(args ...interface{}) {
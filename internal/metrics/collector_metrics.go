@@ -1,8 +1,11 @@
 package metrics
 
 import (
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/helloworlde/miwifi-exporter/internal/config"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -12,12 +15,23 @@ type CollectorMetrics struct {
 	collectionDuration *prometheus.HistogramVec
 	collectionErrors   *prometheus.CounterVec
 	collectionSuccess  *prometheus.CounterVec
+	// 因距上次采集不足最短间隔而复用上次数据的采集
+	collectionsThrottled prometheus.Counter
 	
 	// 缓存指标
 	cacheHits         *prometheus.CounterVec
 	cacheMisses       *prometheus.CounterVec
 	cacheEvictions    *prometheus.CounterVec
 	cacheSize         *prometheus.GaugeVec
+	cacheHitRatio     *prometheus.GaugeVec
+	cacheSynced       map[string]CacheCounters
+	cacheMu           sync.Mutex
+	
+	// 按路由器统计的缓存指标
+	routerCacheHits    *prometheus.CounterVec
+	routerCacheMisses  *prometheus.CounterVec
+	routerCacheEntries *prometheus.GaugeVec
+	routerCacheSynced  map[string]CacheCounters
 	
 	// HTTP客户端指标
 	httpRequestDuration *prometheus.HistogramVec
@@ -30,16 +44,31 @@ type CollectorMetrics struct {
 	dataFetchSuccess    *prometheus.CounterVec
 	dataFetchErrors     *prometheus.CounterVec
 	dataFetchTimeouts   *prometheus.CounterVec
-	
-	// 系统指标
-	memoryUsage     *prometheus.GaugeVec
-	goroutines      *prometheus.GaugeVec
-	uptime          *prometheus.GaugeVec
-	startTime       time.Time
+	// 路由器重复返回同一 MAC 时丢弃的设备条目
+	duplicateDevices    *prometheus.CounterVec
+	// 路由器以非零 code 拒绝的请求
+	routerCodeErrors    *prometheus.CounterVec
+	// 接口请求的重试次数，以及用完重试次数仍然失败的请求
+	fetchRetries          *prometheus.CounterVec
+	fetchRetriesExhausted *prometheus.CounterVec
 }
 
-// NewCollectorMetrics 创建新的收集器指标
-func NewCollectorMetrics(namespace string) *CollectorMetrics {
+// 未配置时耗时直方图使用的桶上界（秒）
+var (
+	defaultHTTPBuckets  = []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0}
+	defaultFetchBuckets = []float64{0.5, 1.0, 2.5, 5.0, 10.0, 30.0}
+)
+
+// bucketsOr 返回配置的桶，未配置时返回 fallback
+func bucketsOr(buckets, fallback []float64) []float64 {
+	if len(buckets) == 0 {
+		return fallback
+	}
+	return buckets
+}
+
+// NewCollectorMetrics 创建新的收集器指标，耗时直方图使用 buckets 中配置的桶
+func NewCollectorMetrics(namespace string, buckets config.HistogramConfig) *CollectorMetrics {
 	return &CollectorMetrics{
 		// 收集指标
 		collectionDuration: prometheus.NewHistogramVec(
@@ -47,7 +76,7 @@ func NewCollectorMetrics(namespace string) *CollectorMetrics {
 				Namespace: namespace,
 				Name:      "collection_duration_seconds",
 				Help:      "指标收集持续时间",
-				Buckets:   prometheus.DefBuckets,
+				Buckets:   bucketsOr(buckets.CollectionBuckets, prometheus.DefBuckets),
 			},
 			[]string{"operation"},
 		),
@@ -67,6 +96,13 @@ func NewCollectorMetrics(namespace string) *CollectorMetrics {
 			},
 			[]string{"operation"},
 		),
+		collectionsThrottled: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "collections_throttled_total",
+				Help:      "距上次采集不足 ROUTER_MIN_COLLECT_INTERVAL 而返回上次数据、未访问路由器的抓取次数",
+			},
+		),
 		
 		// 缓存指标
 		cacheHits: prometheus.NewCounterVec(
@@ -101,6 +137,40 @@ func NewCollectorMetrics(namespace string) *CollectorMetrics {
 			},
 			[]string{"cache_type"},
 		),
+		cacheHitRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cache_hit_ratio",
+				Help:      "缓存命中率",
+			},
+			[]string{"cache_type"},
+		),
+		cacheSynced: make(map[string]CacheCounters),
+		routerCacheHits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "router_cache_hits_total",
+				Help:      "按路由器统计的缓存命中总数",
+			},
+			[]string{"router"},
+		),
+		routerCacheMisses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "router_cache_misses_total",
+				Help:      "按路由器统计的缓存未命中总数",
+			},
+			[]string{"router"},
+		),
+		routerCacheEntries: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "router_cache_entries",
+				Help:      "每个路由器当前缓存的条目数",
+			},
+			[]string{"router"},
+		),
+		routerCacheSynced: make(map[string]CacheCounters),
 		
 		// HTTP客户端指标
 		httpRequestDuration: prometheus.NewHistogramVec(
@@ -108,7 +178,7 @@ func NewCollectorMetrics(namespace string) *CollectorMetrics {
 				Namespace: namespace,
 				Name:      "http_request_duration_seconds",
 				Help:      "HTTP请求持续时间",
-				Buckets:   []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0},
+				Buckets:   bucketsOr(buckets.HTTPBuckets, defaultHTTPBuckets),
 			},
 			[]string{"method", "endpoint", "status_code"},
 		),
@@ -145,7 +215,7 @@ func NewCollectorMetrics(namespace string) *CollectorMetrics {
 				Namespace: namespace,
 				Name:      "data_fetch_duration_seconds",
 				Help:      "数据获取操作持续时间",
-				Buckets:   []float64{0.5, 1.0, 2.5, 5.0, 10.0, 30.0},
+				Buckets:   bucketsOr(buckets.FetchBuckets, defaultFetchBuckets),
 			},
 			[]string{"data_type", "source"},
 		),
@@ -173,33 +243,38 @@ func NewCollectorMetrics(namespace string) *CollectorMetrics {
 			},
 			[]string{"data_type"},
 		),
-		
-		// 系统指标
-		memoryUsage: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
+		duplicateDevices: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
 				Namespace: namespace,
-				Name:      "memory_usage_bytes",
-				Help:      "内存使用量(字节)",
+				Name:      "duplicate_devices_dropped_total",
+				Help:      "因 MAC 重复（例如 mesh 漫游）而丢弃的设备条目总数",
 			},
-			[]string{"type"},
+			[]string{"data_type"},
 		),
-		goroutines: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
+		routerCodeErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
 				Namespace: namespace,
-				Name:      "goroutines",
-				Help:      "Goroutine数量",
+				Name:      "router_code_errors_total",
+				Help:      "路由器返回非零 code 的请求总数，kind 区分 token 过期、无权限、不支持的接口和其他错误",
 			},
-			[]string{},
+			[]string{"data_type", "code", "kind"},
 		),
-		uptime: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
+		fetchRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
 				Namespace: namespace,
-				Name:      "uptime_seconds",
-				Help:      "运行时间(秒)",
+				Name:      "data_fetch_retries_total",
+				Help:      "接口请求失败后的重试总数",
 			},
-			[]string{},
+			[]string{"data_type"},
+		),
+		fetchRetriesExhausted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "data_fetch_retries_exhausted_total",
+				Help:      "重试后仍然失败的接口请求总数",
+			},
+			[]string{"data_type"},
 		),
-		startTime: time.Now(),
 	}
 }
 
@@ -208,10 +283,15 @@ func (cm *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
 	cm.collectionDuration.Describe(ch)
 	cm.collectionErrors.Describe(ch)
 	cm.collectionSuccess.Describe(ch)
+	cm.collectionsThrottled.Describe(ch)
 	cm.cacheHits.Describe(ch)
 	cm.cacheMisses.Describe(ch)
 	cm.cacheEvictions.Describe(ch)
 	cm.cacheSize.Describe(ch)
+	cm.cacheHitRatio.Describe(ch)
+	cm.routerCacheHits.Describe(ch)
+	cm.routerCacheMisses.Describe(ch)
+	cm.routerCacheEntries.Describe(ch)
 	cm.httpRequestDuration.Describe(ch)
 	cm.httpRequestSize.Describe(ch)
 	cm.httpResponseSize.Describe(ch)
@@ -220,9 +300,10 @@ func (cm *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
 	cm.dataFetchSuccess.Describe(ch)
 	cm.dataFetchErrors.Describe(ch)
 	cm.dataFetchTimeouts.Describe(ch)
-	cm.memoryUsage.Describe(ch)
-	cm.goroutines.Describe(ch)
-	cm.uptime.Describe(ch)
+	cm.duplicateDevices.Describe(ch)
+	cm.routerCodeErrors.Describe(ch)
+	cm.fetchRetries.Describe(ch)
+	cm.fetchRetriesExhausted.Describe(ch)
 }
 
 // Collect 实现 prometheus.Collector 接口
@@ -230,10 +311,15 @@ func (cm *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
 	cm.collectionDuration.Collect(ch)
 	cm.collectionErrors.Collect(ch)
 	cm.collectionSuccess.Collect(ch)
+	cm.collectionsThrottled.Collect(ch)
 	cm.cacheHits.Collect(ch)
 	cm.cacheMisses.Collect(ch)
 	cm.cacheEvictions.Collect(ch)
 	cm.cacheSize.Collect(ch)
+	cm.cacheHitRatio.Collect(ch)
+	cm.routerCacheHits.Collect(ch)
+	cm.routerCacheMisses.Collect(ch)
+	cm.routerCacheEntries.Collect(ch)
 	cm.httpRequestDuration.Collect(ch)
 	cm.httpRequestSize.Collect(ch)
 	cm.httpResponseSize.Collect(ch)
@@ -242,9 +328,10 @@ func (cm *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
 	cm.dataFetchSuccess.Collect(ch)
 	cm.dataFetchErrors.Collect(ch)
 	cm.dataFetchTimeouts.Collect(ch)
-	cm.memoryUsage.Collect(ch)
-	cm.goroutines.Collect(ch)
-	cm.uptime.Collect(ch)
+	cm.duplicateDevices.Collect(ch)
+	cm.routerCodeErrors.Collect(ch)
+	cm.fetchRetries.Collect(ch)
+	cm.fetchRetriesExhausted.Collect(ch)
 }
 
 // RecordCollectionDuration 记录收集操作的持续时间
@@ -262,6 +349,11 @@ func (cm *CollectorMetrics) RecordCollectionSuccess(operation string) {
 	cm.collectionSuccess.WithLabelValues(operation).Inc()
 }
 
+// RecordCollectionThrottled 记录一次因最短采集间隔而复用上次数据的抓取
+func (cm *CollectorMetrics) RecordCollectionThrottled() {
+	cm.collectionsThrottled.Inc()
+}
+
 // RecordCacheHit 记录缓存命中
 func (cm *CollectorMetrics) RecordCacheHit(cacheType string) {
 	cm.cacheHits.WithLabelValues(cacheType).Inc()
@@ -282,6 +374,55 @@ func (cm *CollectorMetrics) SetCacheSize(cacheType string, size float64) {
 	cm.cacheSize.WithLabelValues(cacheType).Set(size)
 }
 
+// CacheCounters 缓存内部维护的累计统计
+type CacheCounters struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Size      int
+}
+
+// SyncCacheStats 将缓存内部的累计统计同步到Prometheus指标，只累加自上次同步以来的增量
+func (cm *CollectorMetrics) SyncCacheStats(cacheType string, current CacheCounters) {
+	cm.cacheMu.Lock()
+	defer cm.cacheMu.Unlock()
+	
+	last := cm.cacheSynced[cacheType]
+	
+	// 计数器被重置时(例如缓存重建)，以新值为基准
+	if current.Hits < last.Hits || current.Misses < last.Misses || current.Evictions < last.Evictions {
+		last = CacheCounters{}
+	}
+	
+	cm.cacheHits.WithLabelValues(cacheType).Add(float64(current.Hits - last.Hits))
+	cm.cacheMisses.WithLabelValues(cacheType).Add(float64(current.Misses - last.Misses))
+	cm.cacheEvictions.WithLabelValues(cacheType).Add(float64(current.Evictions - last.Evictions))
+	cm.cacheSize.WithLabelValues(cacheType).Set(float64(current.Size))
+	
+	if total := current.Hits + current.Misses; total > 0 {
+		cm.cacheHitRatio.WithLabelValues(cacheType).Set(float64(current.Hits) / float64(total))
+	}
+	
+	cm.cacheSynced[cacheType] = current
+}
+
+// SyncRouterCacheStats 将一个路由器的缓存统计同步到按路由器统计的指标，与 SyncCacheStats 一样只累加增量
+func (cm *CollectorMetrics) SyncRouterCacheStats(router string, current CacheCounters) {
+	cm.cacheMu.Lock()
+	defer cm.cacheMu.Unlock()
+	
+	last := cm.routerCacheSynced[router]
+	if current.Hits < last.Hits || current.Misses < last.Misses {
+		last = CacheCounters{}
+	}
+	
+	cm.routerCacheHits.WithLabelValues(router).Add(float64(current.Hits - last.Hits))
+	cm.routerCacheMisses.WithLabelValues(router).Add(float64(current.Misses - last.Misses))
+	cm.routerCacheEntries.WithLabelValues(router).Set(float64(current.Size))
+	
+	cm.routerCacheSynced[router] = current
+}
+
 // RecordHTTPRequestDuration 记录HTTP请求持续时间
 func (cm *CollectorMetrics) RecordHTTPRequestDuration(method, endpoint, statusCode string, duration time.Duration) {
 	cm.httpRequestDuration.WithLabelValues(method, endpoint, statusCode).Observe(duration.Seconds())
@@ -302,6 +443,27 @@ func (cm *CollectorMetrics) RecordHTTPRequestError(method, endpoint, errorType s
 	cm.httpRequestErrors.WithLabelValues(method, endpoint, errorType).Inc()
 }
 
+// RecordRequestDuration 实现 pkg/http.MetricsCollector，按接口名称记录路由器请求耗时，
+// 状态码为 0 表示请求未得到响应
+func (cm *CollectorMetrics) RecordRequestDuration(method, endpoint string, duration time.Duration, statusCode int) {
+	if statusCode == 0 {
+		cm.RecordHTTPRequestDuration(method, endpoint, "error", duration)
+		cm.RecordHTTPRequestError(method, endpoint, "transport")
+		return
+	}
+	cm.RecordHTTPRequestDuration(method, endpoint, strconv.Itoa(statusCode), duration)
+}
+
+// RecordRequestSize 实现 pkg/http.MetricsCollector
+func (cm *CollectorMetrics) RecordRequestSize(method, endpoint string, size int64) {
+	cm.RecordHTTPRequestSize(method, endpoint, size)
+}
+
+// RecordResponseSize 实现 pkg/http.MetricsCollector
+func (cm *CollectorMetrics) RecordResponseSize(method, endpoint string, size int64) {
+	cm.RecordHTTPResponseSize(method, endpoint, size)
+}
+
 // RecordDataFetchDuration 记录数据获取持续时间
 func (cm *CollectorMetrics) RecordDataFetchDuration(dataType, source string, duration time.Duration) {
 	cm.dataFetchDuration.WithLabelValues(dataType, source).Observe(duration.Seconds())
@@ -322,23 +484,24 @@ func (cm *CollectorMetrics) RecordDataFetchTimeout(dataType string) {
 	cm.dataFetchTimeouts.WithLabelValues(dataType).Inc()
 }
 
-// UpdateSystemMetrics 更新系统指标
-func (cm *CollectorMetrics) UpdateSystemMetrics() {
-	// 更新运行时间
-	uptime := time.Since(cm.startTime).Seconds()
-	cm.uptime.WithLabelValues().Set(uptime)
-	
-	// 内存使用量将由调用者更新
+// RecordDuplicateDevices 记录丢弃的重复设备条目
+func (cm *CollectorMetrics) RecordDuplicateDevices(dataType string, count int) {
+	cm.duplicateDevices.WithLabelValues(dataType).Add(float64(count))
+}
+
+// RecordRouterCodeError 记录路由器返回的错误码
+func (cm *CollectorMetrics) RecordRouterCodeError(dataType string, code int, kind string) {
+	cm.routerCodeErrors.WithLabelValues(dataType, strconv.Itoa(code), kind).Inc()
 }
 
-// SetMemoryUsage 设置内存使用量指标
-func (cm *CollectorMetrics) SetMemoryUsage(memType string, bytes float64) {
-	cm.memoryUsage.WithLabelValues(memType).Set(bytes)
+// RecordFetchRetries 记录接口请求的重试次数
+func (cm *CollectorMetrics) RecordFetchRetries(dataType string, retries int) {
+	cm.fetchRetries.WithLabelValues(dataType).Add(float64(retries))
 }
 
-// SetGoroutines 设置Goroutine数量
-func (cm *CollectorMetrics) SetGoroutines(count float64) {
-	cm.goroutines.WithLabelValues().Set(count)
+// RecordFetchRetriesExhausted 记录重试后仍然失败的接口请求
+func (cm *CollectorMetrics) RecordFetchRetriesExhausted(dataType string) {
+	cm.fetchRetriesExhausted.WithLabelValues(dataType).Inc()
 }
 
 // RecordCollectionStart 记录收集操作的开始
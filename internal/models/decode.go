@@ -0,0 +1,217 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// parseErrors counts the values that could not be parsed by field
+var parseErrors sync.Map
+
+// RecordParseError counts a value of field that could not be parsed
+func RecordParseError(field string) {
+	counter, ok := parseErrors.Load(field)
+	if !ok {
+		counter, _ = parseErrors.LoadOrStore(field, new(atomic.Uint64))
+	}
+	counter.(*atomic.Uint64).Add(1)
+}
+
+// ParseErrors returns the number of values that could not be parsed since
+// the start by field, e.g. "count.all". Such values decode as zero or are
+// left out instead of failing the whole response.
+func ParseErrors() map[string]uint64 {
+	errors := make(map[string]uint64)
+	parseErrors.Range(func(key, value interface{}) bool {
+		errors[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return errors
+}
+
+// lookup returns the value of the first of keys present in fields. Firmwares
+// name some fields differently, e.g. allWithoutMash and all_without_mash.
+func lookup(fields map[string]json.RawMessage, keys ...string) json.RawMessage {
+	for _, key := range keys {
+		if raw, ok := fields[key]; ok {
+			return raw
+		}
+	}
+	return nil
+}
+
+// scalar returns the text of a number or string value. Missing values, null
+// and empty strings are empty and not a failure.
+func scalar(raw json.RawMessage, field string) (string, bool) {
+	text, ok, invalid := flexibleScalar(raw)
+	if invalid != "" {
+		RecordParseError(field)
+	}
+	return text, ok
+}
+
+// parseFloat decodes a number encoded as a JSON number or a numeric string
+func parseFloat(raw json.RawMessage, field string) float64 {
+	s, ok := scalar(raw, field)
+	if !ok {
+		return 0
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		RecordParseError(field)
+		return 0
+	}
+	return value
+}
+
+// parseInt decodes an integer encoded as a JSON number or a numeric string.
+// Fractions are truncated, some firmwares report counts as 3.0.
+func parseInt(raw json.RawMessage, field string) int {
+	s, ok := scalar(raw, field)
+	if !ok {
+		return 0
+	}
+	if value, err := strconv.Atoi(s); err == nil {
+		return value
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		RecordParseError(field)
+		return 0
+	}
+	return int(value)
+}
+
+// parseString decodes a string, numbers are kept as their JSON text
+func parseString(raw json.RawMessage, field string) string {
+	s, _ := scalar(raw, field)
+	return s
+}
+
+// UnmarshalJSON accepts the temperature and uptime as numbers or strings
+func (s *SystemStatus) UnmarshalJSON(data []byte) error {
+	type plain SystemStatus
+	aux := struct {
+		*plain
+		Temperature json.RawMessage `json:"temperature"`
+		UpTime      json.RawMessage `json:"upTime"`
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.Temperature = parseInt(aux.Temperature, "temperature")
+	s.UpTime = parseString(aux.UpTime, "upTime")
+	return nil
+}
+
+// UnmarshalJSON accepts the counts as numbers or strings, and the camel case
+// names of newer firmwares
+func (c *DeviceCount) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	c.All = parseInt(lookup(fields, "all"), "count.all")
+	c.Online = parseInt(lookup(fields, "online"), "count.online")
+	c.AllWithoutMash = parseInt(lookup(fields, "all_without_mash", "allWithoutMash"), "count.all_without_mash")
+	c.OnlineWithoutMash = parseInt(lookup(fields, "online_without_mash", "onlineWithoutMash"), "count.online_without_mash")
+	return nil
+}
+
+// UnmarshalJSON accepts the core count, frequency and load as numbers or
+// strings
+func (c *CPUInfo) UnmarshalJSON(data []byte) error {
+	type plain CPUInfo
+	aux := struct {
+		*plain
+		Core json.RawMessage `json:"core"`
+		Hz   json.RawMessage `json:"hz"`
+		Load json.RawMessage `json:"load"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.Core = parseInt(aux.Core, "cpu.core")
+	c.Hz = parseString(aux.Hz, "cpu.hz")
+	c.Load = parseFloat(aux.Load, "cpu.load")
+	return nil
+}
+
+// UnmarshalJSON accepts the usage and total as numbers or strings
+func (m *MemoryInfo) UnmarshalJSON(data []byte) error {
+	type plain MemoryInfo
+	aux := struct {
+		*plain
+		Usage json.RawMessage `json:"usage"`
+		Total json.RawMessage `json:"total"`
+	}{plain: (*plain)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	m.Usage = parseFloat(aux.Usage, "mem.usage")
+	m.Total = parseString(aux.Total, "mem.total")
+	return nil
+}
+
+// UnmarshalJSON accepts the flags and counters of a device as numbers or
+// strings
+func (d *DeviceEntry) UnmarshalJSON(data []byte) error {
+	type plain DeviceEntry
+	aux := struct {
+		*plain
+		IsAP   json.RawMessage `json:"isap"`
+		Push   json.RawMessage `json:"push"`
+		Online json.RawMessage `json:"online"`
+		Times  json.RawMessage `json:"times"`
+		Type   json.RawMessage `json:"type"`
+	}{plain: (*plain)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	d.IsAP = parseInt(aux.IsAP, "device.isap")
+	d.Push = parseInt(aux.Push, "device.push")
+	d.Online = parseInt(aux.Online, "device.online")
+	d.Times = parseInt(aux.Times, "device.times")
+	d.Type = parseInt(aux.Type, "device.type")
+	return nil
+}
+
+// UnmarshalJSON accepts the speeds and online time as numbers or strings
+func (s *DeviceStatistics) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		DownSpeed json.RawMessage `json:"downspeed"`
+		Online    json.RawMessage `json:"online"`
+		UpSpeed   json.RawMessage `json:"upspeed"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.DownSpeed = parseString(aux.DownSpeed, "device.statistics.downspeed")
+	s.Online = parseString(aux.Online, "device.statistics.online")
+	s.UpSpeed = parseString(aux.UpSpeed, "device.statistics.upspeed")
+	return nil
+}
+
+// UnmarshalJSON accepts the link state, uptime and MTU as numbers or strings
+func (w *WanInfoDetails) UnmarshalJSON(data []byte) error {
+	type plain WanInfoDetails
+	aux := struct {
+		*plain
+		Status   json.RawMessage `json:"status"`
+		Uptime   json.RawMessage `json:"uptime"`
+		Ipv6Show json.RawMessage `json:"ipv6_show"`
+		Link     json.RawMessage `json:"link"`
+		Mtu      json.RawMessage `json:"mtu"`
+	}{plain: (*plain)(w)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	w.Status = parseInt(aux.Status, "wan.status")
+	w.Uptime = parseInt(aux.Uptime, "wan.uptime")
+	w.Ipv6Show = parseInt(aux.Ipv6Show, "wan.ipv6_show")
+	w.Link = parseInt(aux.Link, "wan.link")
+	w.Mtu = parseString(aux.Mtu, "wan.mtu")
+	return nil
+}
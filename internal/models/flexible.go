@@ -0,0 +1,169 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseError is a value of a flexible field that is neither a number nor a
+// numeric string
+type ParseError struct {
+	Value string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("unsupported value %s", e.Value)
+}
+
+// flexibleScalar returns the text of a JSON number or string. ok is false for
+// missing values, null and empty strings; invalid holds any other value.
+func flexibleScalar(data []byte) (text string, ok bool, invalid string) {
+	if len(data) == 0 || string(data) == "null" {
+		return "", false, ""
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", false, string(data)
+		}
+		s = strings.TrimSpace(s)
+		return s, s != "", ""
+	}
+	if data[0] == '-' || (data[0] >= '0' && data[0] <= '9') {
+		return string(data), true, ""
+	}
+	return "", false, string(data)
+}
+
+// FlexibleInt is an integer the router encodes as a JSON number or a numeric
+// string, depending on the firmware. A value of another type does not fail
+// the response, Float64 reports it instead.
+type FlexibleInt struct {
+	Value int64
+	// Valid is false when the value was missing, null, empty or unparsable
+	Valid bool
+	// invalid is the JSON of a value that could not be parsed
+	invalid string
+}
+
+// NewFlexibleInt returns a valid FlexibleInt of value
+func NewFlexibleInt(value int64) FlexibleInt {
+	return FlexibleInt{Value: value, Valid: true}
+}
+
+func (f *FlexibleInt) UnmarshalJSON(data []byte) error {
+	*f = FlexibleInt{}
+	text, ok, invalid := flexibleScalar(data)
+	if !ok {
+		f.invalid = invalid
+		return nil
+	}
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		f.Value, f.Valid = value, true
+		return nil
+	}
+	// Some firmwares report counters as 3.0 or in exponent notation
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		f.invalid = string(data)
+		return nil
+	}
+	f.Value, f.Valid = int64(value), true
+	return nil
+}
+
+func (f FlexibleInt) MarshalJSON() ([]byte, error) {
+	if !f.Valid {
+		return []byte("null"), nil
+	}
+	return strconv.AppendInt(nil, f.Value, 10), nil
+}
+
+// Err returns a *ParseError for a value that could not be parsed
+func (f FlexibleInt) Err() error {
+	if f.invalid != "" {
+		return &ParseError{Value: f.invalid}
+	}
+	return nil
+}
+
+// Float64 returns the value, 0 when it was missing. It fails with a
+// *ParseError for a value that could not be parsed.
+func (f FlexibleInt) Float64() (float64, error) {
+	if err := f.Err(); err != nil {
+		return 0, err
+	}
+	return float64(f.Value), nil
+}
+
+// FlexibleFloat is a number the router encodes as a JSON number or a numeric
+// string, such as a load in percent
+type FlexibleFloat struct {
+	Value float64
+	// Valid is false when the value was missing, null, empty or unparsable
+	Valid   bool
+	invalid string
+}
+
+// NewFlexibleFloat returns a valid FlexibleFloat of value
+func NewFlexibleFloat(value float64) FlexibleFloat {
+	return FlexibleFloat{Value: value, Valid: true}
+}
+
+func (f *FlexibleFloat) UnmarshalJSON(data []byte) error {
+	*f = FlexibleFloat{}
+	text, ok, invalid := flexibleScalar(data)
+	if !ok {
+		f.invalid = invalid
+		return nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		f.invalid = string(data)
+		return nil
+	}
+	f.Value, f.Valid = value, true
+	return nil
+}
+
+func (f FlexibleFloat) MarshalJSON() ([]byte, error) {
+	if !f.Valid {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, f.Value, 'g', -1, 64), nil
+}
+
+// Err returns a *ParseError for a value that could not be parsed
+func (f FlexibleFloat) Err() error {
+	if f.invalid != "" {
+		return &ParseError{Value: f.invalid}
+	}
+	return nil
+}
+
+// Float64 returns the value, 0 when it was missing. It fails with a
+// *ParseError for a value that could not be parsed.
+func (f FlexibleFloat) Float64() (float64, error) {
+	if err := f.Err(); err != nil {
+		return 0, err
+	}
+	return f.Value, nil
+}
+
+// FlexibleString is text some firmwares send as a JSON number, such as a
+// speed or an online duration. Numbers keep their JSON text, null and values
+// of other types decode as empty.
+type FlexibleString string
+
+func (f *FlexibleString) UnmarshalJSON(data []byte) error {
+	text, _, _ := flexibleScalar(data)
+	*f = FlexibleString(text)
+	return nil
+}
+
+// Float64 parses the text as a number
+func (f FlexibleString) Float64() (float64, error) {
+	return strconv.ParseFloat(string(f), 64)
+}
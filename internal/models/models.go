@@ -4,6 +4,7 @@ package models
 type SystemStatus struct {
 	Dev []DeviceInfo `json:"dev"`
 	Code int        `json:"code"`
+	Msg  string `json:"msg,omitempty"`
 	Mem  MemoryInfo `json:"mem"`
 	Temperature int        `json:"temperature"`
 	Count       DeviceCount `json:"count"`
@@ -11,18 +12,27 @@ type SystemStatus struct {
 	UpTime      string      `json:"upTime"`
 	CPU         CPUInfo     `json:"cpu"`
 	Wan         WanStatus   `json:"wan"`
+	// Conntrack is only reported by OpenWrt based ROMs
+	Conntrack *ConntrackInfo `json:"conntrack,omitempty"`
+}
+
+// ConntrackInfo is the usage of the connection tracking table, which limits
+// the number of NAT sessions the router can hold
+type ConntrackInfo struct {
+	Count FlexibleInt `json:"count"`
+	Max   FlexibleInt `json:"max"`
 }
 
 type DeviceInfo struct {
-	Mac              string      `json:"mac"`
-	MaxDownloadSpeed string      `json:"maxdownloadspeed"`
-	Upload           interface{} `json:"upload"`
-	UpSpeed          interface{} `json:"upspeed"`
-	DownSpeed        interface{} `json:"downspeed"`
-	Online           string      `json:"online"`
-	DevName          string      `json:"devname"`
-	MaxUploadSpeed   string      `json:"maxuploadspeed"`
-	Download         interface{} `json:"download"`
+	Mac              string         `json:"mac"`
+	MaxDownloadSpeed FlexibleString `json:"maxdownloadspeed"`
+	Upload           FlexibleInt    `json:"upload"`
+	UpSpeed          FlexibleInt    `json:"upspeed"`
+	DownSpeed        FlexibleInt    `json:"downspeed"`
+	Online           FlexibleString `json:"online"`
+	DevName          string         `json:"devname"`
+	MaxUploadSpeed   FlexibleString `json:"maxuploadspeed"`
+	Download         FlexibleInt    `json:"download"`
 }
 
 type MemoryInfo struct {
@@ -51,6 +61,8 @@ type CPUInfo struct {
 	Core int     `json:"core"`
 	Hz   string  `json:"hz"`
 	Load float64 `json:"load"`
+	// Loads holds the per-core load on firmwares that report it
+	Loads []FlexibleFloat `json:"loads"`
 }
 
 type WanStatus struct {
@@ -69,6 +81,7 @@ type DeviceList struct {
 	Mac  string        `json:"mac"`
 	List []DeviceEntry `json:"list"`
 	Code int           `json:"code"`
+	Msg  string `json:"msg,omitempty"`
 }
 
 type DeviceEntry struct {
@@ -85,6 +98,7 @@ type DeviceEntry struct {
 	Statistics DeviceStatistics `json:"statistics"`
 	Icon      string           `json:"icon"`
 	Type      int              `json:"type"`
+	IfName    string           `json:"ifname"`
 }
 
 type AuthorityInfo struct {
@@ -111,7 +125,33 @@ type DeviceStatistics struct {
 // WanInfo represents WAN information
 type WanInfo struct {
 	Info WanInfoDetails `json:"info"`
+	// DualWAN and Wans are only reported by dual WAN firmwares, Info is then
+	// the primary port
+	DualWAN *DualWANConfig `json:"dualwan,omitempty"`
+	Wans    []WanPort      `json:"wans,omitempty"`
 	Code int            `json:"code"`
+	Msg  string `json:"msg,omitempty"`
+}
+
+// DualWANConfig is how a dual WAN router uses its WAN ports
+type DualWANConfig struct {
+	Enable FlexibleInt `json:"enable"`
+	// Mode is balance when the ports share the traffic, failover when the
+	// second port only takes over during an outage of the first
+	Mode string `json:"mode"`
+}
+
+// WanPort is one WAN port of a dual WAN router with its own traffic
+type WanPort struct {
+	// Name identifies the port, e.g. wan or wan2
+	Name string         `json:"name"`
+	Info WanInfoDetails `json:"info"`
+	// Active is 1 while the port carries traffic
+	Active    FlexibleInt `json:"active"`
+	UpSpeed   FlexibleInt `json:"upspeed"`
+	DownSpeed FlexibleInt `json:"downspeed"`
+	Upload    FlexibleInt `json:"upload"`
+	Download  FlexibleInt `json:"download"`
 }
 
 type WanInfoDetails struct {
@@ -158,6 +198,7 @@ type WifiDetailAll struct {
 	Bsd  int           `json:"bsd"`
 	Info []WifiDetails `json:"info"`
 	Code int           `json:"code"`
+	Msg  string `json:"msg,omitempty"`
 }
 
 type WifiDetails struct {
@@ -173,7 +214,7 @@ type WifiDetails struct {
 	WeakThreshold string    `json:"weakthreshold"`
 	Device      string      `json:"device"`
 	Ax          string      `json:"ax"`
-	Hidden      interface{} `json:"hidden"`
+	Hidden      FlexibleInt `json:"hidden"`
 	Password    string      `json:"password"`
 	Channel     string      `json:"channel"`
 	TxPWR       string      `json:"txpwr"`
@@ -198,6 +239,7 @@ type Auth struct {
 // Router represents router configuration
 type Router struct {
 	IP       string
+	Username string
 	Password string
 	Headers  map[string]string
 	Session  interface{}
@@ -214,4 +256,159 @@ type InitInfo struct {
 	SerialNumber   string `json:"id"`
 	RouterName     string `json:"routername"`
 	NewEncryptMode int    `json:"newEncryptMode"`
-}
\ No newline at end of file
+}
+// DiskStatus represents USB storage information
+type DiskStatus struct {
+	Disks []DiskInfo `json:"disks"`
+	Code  int        `json:"code"`
+	Msg   string `json:"msg,omitempty"`
+}
+
+type DiskInfo struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Mount      string      `json:"mount"`
+	FileSystem string      `json:"fstype"`
+	Total      FlexibleInt `json:"total"`
+	Used       FlexibleInt `json:"used"`
+}
+
+// SysInfo represents system information from /api/misystem/sys_info, which
+// reports per-core CPU load on multi-core AX routers
+type SysInfo struct {
+	CPU  CPUInfo `json:"cpu"`
+	Code int     `json:"code"`
+	Msg  string `json:"msg,omitempty"`
+}
+
+// PortStatus represents the Ethernet port state from /api/xqnetwork/port_status
+type PortStatus struct {
+	Ports []PortInfo `json:"ports"`
+	Code  int        `json:"code"`
+	Msg   string `json:"msg,omitempty"`
+}
+
+type PortInfo struct {
+	Port int    `json:"port"`
+	Name string `json:"name"`
+	// Link is 1 when a cable is connected and the link is up
+	Link int `json:"link"`
+	// Speed is the negotiated speed in Mbps
+	Speed  FlexibleInt `json:"speed"`
+	Duplex string      `json:"duplex"`
+}
+
+// WPSStatus represents the WPS state from /api/xqnetwork/wps_status
+type WPSStatus struct {
+	// Status is 0 while WPS is disabled
+	Status int `json:"status"`
+	Code   int `json:"code"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+// TopoGraph represents the mesh topology from /api/misystem/topo_graph. The
+// graph is rooted at the main router, satellites are its leafs.
+type TopoGraph struct {
+	Show  int      `json:"show"`
+	Graph MeshNode `json:"graph"`
+	Code  int      `json:"code"`
+	Msg   string   `json:"msg,omitempty"`
+}
+
+// MeshNode is the main router or a satellite in the mesh topology. The
+// backhaul fields are only reported for satellites, and the rate and speeds
+// only by firmwares that measure the backhaul.
+type MeshNode struct {
+	Name     string `json:"name"`
+	Hardware string `json:"hardware"`
+	IP       string `json:"ip"`
+	Mac      string `json:"mac"`
+	IsMain   int    `json:"is_main"`
+	// Backhaul is wired or wireless
+	Backhaul string `json:"backhaul"`
+	// BackhaulRate is the negotiated link rate in Mbps
+	BackhaulRate FlexibleInt `json:"backhaul_rate"`
+	// BackhaulUpSpeed and BackhaulDownSpeed are the current throughput in
+	// bytes per second towards and from the upstream node
+	BackhaulUpSpeed   FlexibleInt `json:"backhaul_upspeed"`
+	BackhaulDownSpeed FlexibleInt `json:"backhaul_downspeed"`
+	Leafs             []MeshNode  `json:"leafs"`
+}
+
+// WifiStatistics represents the per-radio packet counters from
+// /api/xqnetwork/wifi_statistics. Only some firmwares provide the endpoint.
+type WifiStatistics struct {
+	Radios []RadioStatistics `json:"radios"`
+	Code   int               `json:"code"`
+	Msg    string            `json:"msg,omitempty"`
+}
+
+// RadioStatistics are the counters of one radio interface since it was last
+// brought up. Firmwares report them as numbers or numeric strings, counters a
+// driver does not keep are missing.
+type RadioStatistics struct {
+	IfName    string      `json:"ifname"`
+	RxPackets FlexibleInt `json:"rx_packets"`
+	TxPackets FlexibleInt `json:"tx_packets"`
+	RxErrors  FlexibleInt `json:"rx_errors"`
+	TxErrors  FlexibleInt `json:"tx_errors"`
+	RxDropped FlexibleInt `json:"rx_dropped"`
+	TxDropped FlexibleInt `json:"tx_dropped"`
+	// TxRetries counts frames sent again because they were not acknowledged
+	TxRetries FlexibleInt `json:"tx_retries"`
+}
+
+// IPTVStatus represents the IPTV and VLAN setup of the WAN port from
+// /api/xqnetwork/iptv. Only some firmwares provide the endpoint.
+type IPTVStatus struct {
+	// Enable is 1 when IPTV is set up
+	Enable FlexibleInt `json:"enable"`
+	// Mode is bridge when a LAN port passes the ISP's IPTV traffic through,
+	// vlan when the router tags the services with VLAN IDs
+	Mode string `json:"mode"`
+	// Port is the LAN port of the set-top box
+	Port         FlexibleString `json:"port"`
+	InternetVLAN FlexibleInt    `json:"internet_vlan"`
+	IPTVVLAN     FlexibleInt    `json:"iptv_vlan"`
+	VoIPVLAN     FlexibleInt    `json:"voip_vlan"`
+	Code         int            `json:"code"`
+	Msg          string         `json:"msg,omitempty"`
+}
+
+// UPnPStatus represents the UPnP port mappings from /api/xqsystem/upnp,
+// which clients on the LAN open on the WAN address without asking anyone
+type UPnPStatus struct {
+	// Status is 1 when UPnP is enabled
+	Status FlexibleInt   `json:"status"`
+	List   []UPnPMapping `json:"list"`
+	Code   int           `json:"code"`
+	Msg    string        `json:"msg,omitempty"`
+}
+
+// UPnPMapping forwards ExternalPort of the WAN address to Port of IP
+type UPnPMapping struct {
+	Protocol     string      `json:"protocol"`
+	ExternalPort FlexibleInt `json:"rport"`
+	IP           string      `json:"ip"`
+	Port         FlexibleInt `json:"port"`
+	// Name is the description the client gave the mapping
+	Name string `json:"name"`
+}
+
+// ROMUpdate represents the answer of /api/xqsystem/check_rom_update. The
+// router asks the Xiaomi update server, so the check takes a few seconds.
+type ROMUpdate struct {
+	// NeedUpdate is 1 when a newer firmware is available
+	NeedUpdate int    `json:"needUpdate"`
+	Version    string `json:"version"`
+	ChangeLog  string `json:"changeLog"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg,omitempty"`
+}
+
+// SambaStatus represents Samba file sharing status
+type SambaStatus struct {
+	Status int `json:"status"`
+	Code   int `json:"code"`
+	Msg    string `json:"msg,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// DataSource is where the router data of a collection was read from
+type DataSource string
+
+const (
+	// SourceLive is data just fetched from the router
+	SourceLive DataSource = "live"
+	// SourceCache is data served from the cache
+	SourceCache DataSource = "cache"
+	// SourceSnapshot is data restored from the snapshot file while the
+	// router is unreachable
+	SourceSnapshot DataSource = "snapshot"
+)
+
+// RouterData holds the responses of all router endpoints fetched in one
+// collection. Optional endpoints the router does not support are nil. The
+// fields keep their Go names in JSON, which is the format of snapshot files.
+type RouterData struct {
+	SystemStatus   *SystemStatus
+	DeviceList     *DeviceList
+	WanInfo        *WanInfo
+	WifiDetails    *WifiDetailAll
+	DiskStatus     *DiskStatus
+	SambaStatus    *SambaStatus
+	SysInfo        *SysInfo
+	PortStatus     *PortStatus
+	WPSStatus      *WPSStatus
+	TopoGraph      *TopoGraph
+	WifiStatistics *WifiStatistics
+	IPTVStatus     *IPTVStatus
+	UPnPStatus     *UPnPStatus
+
+	// FetchedAt is when the data was fetched from the router, also for data
+	// served from the cache or a snapshot
+	FetchedAt time.Time
+	Source    DataSource
+}
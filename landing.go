@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/collector"
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+)
+
+// landingPageData is what the landing page template is executed with
+type landingPageData struct {
+	Version     string
+	Commit      string
+	GoVersion   string
+	MetricsPath string
+	Routers     []landingRouter
+	Collectors  []string
+	// Status is the latest collection, a request does not collect
+	Status collector.Status
+}
+
+// landingRouter is a router the exporter collects from
+type landingRouter struct {
+	Host    string
+	Address string
+}
+
+// landingFuncs are the functions available to landing page templates
+var landingFuncs = template.FuncMap{
+	"age": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String()
+	},
+}
+
+const defaultLandingTemplate = `<!DOCTYPE html>
+<html>
+<head>
+    <title>MiWiFi Exporter</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 40px; }
+        .container { max-width: 800px; margin: 0 auto; }
+        .header { text-align: center; margin-bottom: 30px; }
+        .metrics { background: #f5f5f5; padding: 20px; border-radius: 5px; margin-bottom: 20px; }
+        .metric-link { display: inline-block; margin: 10px; padding: 10px 20px; background: #007bff; color: white; text-decoration: none; border-radius: 3px; }
+        .metric-link:hover { background: #0056b3; }
+        .error { color: #b00020; }
+        td { padding: 2px 12px 2px 0; }
+        .footer { text-align: center; margin-top: 30px; color: #666; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>MiWiFi Exporter</h1>
+            <p>Prometheus exporter for Xiaomi WiFi routers</p>
+        </div>
+
+        <div class="metrics">
+            <h2>Available Endpoints</h2>
+            <a href="{{.MetricsPath}}" class="metric-link">Metrics</a>
+            <a href="/health" class="metric-link">Health Check</a>
+            <a href="/api/v1/snapshot" class="metric-link">Snapshot (JSON)</a>
+        </div>
+
+        <div class="metrics">
+            <h2>Routers</h2>
+            <table>
+            {{- range .Routers}}
+                <tr><td>{{.Host}}</td><td>{{.Address}}</td></tr>
+            {{- end}}
+            </table>
+        </div>
+
+        <div class="metrics">
+            <h2>Last Collection</h2>
+            {{- with .Status}}
+            {{- if .CollectedAt.IsZero}}
+            <p>No collection yet, the first scrape collects.</p>
+            {{- else}}
+            <table>
+                <tr><td>Collected</td><td>{{age .CollectedAt}} ago</td></tr>
+                {{- if not .FetchedAt.IsZero}}
+                <tr><td>Data fetched</td><td>{{age .FetchedAt}} ago from {{.Source}}{{if .Stale}} (stale snapshot){{end}}</td></tr>
+                <tr><td>Online devices</td><td>{{.Devices}}</td></tr>
+                {{- end}}
+                {{- if .Error}}
+                <tr><td>Error</td><td class="error">{{.Error}}</td></tr>
+                {{- end}}
+            </table>
+            {{- end}}
+            {{- end}}
+        </div>
+
+        <div class="metrics">
+            <h2>Enabled Collectors</h2>
+            <p>{{range $i, $name := .Collectors}}{{if $i}}, {{end}}{{$name}}{{end}}</p>
+        </div>
+
+        <div class="footer">
+            <p>Version: {{.Version}} | Commit: {{.Commit}} | {{.GoVersion}}</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+// landingPage returns the handler of /, rendering the built-in template or
+// the one in SERVER_LANDING_PAGE_TEMPLATE
+func landingPage(cfg *config.Config, metricsCollector *collector.MetricsCollector) (http.Handler, error) {
+	text := defaultLandingTemplate
+	if cfg.Server.LandingPageTemplate != "" {
+		custom, err := os.ReadFile(cfg.Server.LandingPageTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read landing page template: %w", err)
+		}
+		text = string(custom)
+	}
+	tmpl, err := template.New("landing").Funcs(landingFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse landing page template: %w", err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		data := landingPageData{
+			Version:     version,
+			Commit:      commit,
+			GoVersion:   runtime.Version(),
+			MetricsPath: cfg.Server.MetricsPath,
+			Routers:     []landingRouter{{Host: cfg.Router.Host, Address: cfg.Router.IP}},
+			Collectors:  metricsCollector.EnabledCollectors(),
+			Status:      metricsCollector.Status(),
+		}
+		// Rendered into a buffer, a failing template answers 500 instead of
+		// half a page
+		var page bytes.Buffer
+		if err := tmpl.Execute(&page, data); err != nil {
+			logger.Default.Errorf("Failed to render landing page: %v", err)
+			http.Error(w, "Failed to render landing page", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	}), nil
+}
@@ -2,20 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/helloworlde/miwifi-exporter/internal/client"
 	"github.com/helloworlde/miwifi-exporter/internal/collector"
 	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/dashboard"
+	apperrors "github.com/helloworlde/miwifi-exporter/internal/errors"
 	"github.com/helloworlde/miwifi-exporter/internal/logger"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/helloworlde/miwifi-exporter/pkg/concurrent"
+	"github.com/helloworlde/miwifi-exporter/pkg/systemd"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
 var (
@@ -25,43 +34,184 @@ var (
 )
 
 func main() {
+	// Subcommands are dispatched before the global flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(runServiceCommand(os.Args[2:]))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(runExporter(ctx, os.Args[1:]))
+}
+
+// runExporter parses the command line flags in args and runs the exporter
+// until ctx is cancelled. It returns the process exit code.
+func runExporter(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	var (
-		showVersion = flag.Bool("version", false, "Show version information")
-		configFile  = flag.String("config", "", "Path to configuration file")
+		showVersion     = flags.Bool("version", false, "Show version information")
+		configFile      = flags.String("config", "", "Path to configuration file")
+		exportDashboard = flags.Bool("export-dashboard", false, "Print a Grafana dashboard JSON for the configured namespace and exit")
+		printSchema     = flags.Bool("print-config-schema", false, "Print all configuration keys, environment variables, defaults and validation rules as JSON and exit")
+		once            = flags.Bool("once", false, "Collect metrics once, print them to stdout and exit")
+		recordDir       = flags.String("record-responses", "", "Save raw router API responses (passwords scrubbed) to this directory")
+		logCurl         = flags.Bool("log-curl", false, "Log every router API request as an equivalent curl command (secrets masked)")
+		replayDir       = flags.String("replay", "", "Collect once from responses saved with --record-responses, print the metrics and exit")
+		demoData        = flags.String("demo-data", "", "Serve metrics from JSON fixtures in this directory instead of a router")
+		webConfigFile   = flags.String("web.config.file", "", "Path to configuration file that can enable TLS or authentication")
+		systemdSocket   = flags.Bool("web.systemd-socket", false, "Use systemd socket activation listeners instead of port listeners (Linux only)")
+		debug           = flags.Bool("debug", false, "Serve pprof and expvar debug endpoints on --debug.listen-address")
+		debugAddress    = flags.String("debug.listen-address", "localhost:6060", "Address of the debug endpoints enabled by --debug")
+		listenAddresses stringSliceFlag
 	)
-	flag.Parse()
+	flags.Var(&listenAddresses, "web.listen-address", "Address on which to expose metrics and web interface, repeatable (default from server.listen_address and server.port)")
+	flags.Parse(args)
 
 	if *showVersion {
 		fmt.Printf("miwifi-exporter %s\n", version)
 		fmt.Printf("commit: %s\n", commit)
 		fmt.Printf("built: %s\n", date)
-		os.Exit(0)
+		return 0
+	}
+
+	if *printSchema {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(config.Schema()); err != nil {
+			fmt.Printf("Failed to print configuration schema: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *exportDashboard {
+		if err := printDashboard(*configFile); err != nil {
+			fmt.Printf("Failed to export dashboard: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *replayDir != "" {
+		return replay(*configFile, *replayDir)
+	}
+
+	if *demoData != "" {
+		setOfflineDefaults()
 	}
 
 	// Load configuration
 	cfg, err := loadConfiguration(*configFile)
 	if err != nil {
 		fmt.Printf("Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Initialize logger
-	logger.Init(cfg.Logging.Level, cfg.Logging.Format)
-	logger.Default.Info("Starting miwifi-exporter")
+	if *once {
+		// Keep stdout clean for the exposition output
+		logger.Default = logger.NewWithOutput(cfg.Logging.Level, cfg.Logging.Format, os.Stderr)
+	} else {
+		logger.Init(cfg.Logging.Level, cfg.Logging.Format)
+	}
+	logger.Default.Infof("Starting miwifi-exporter %s (commit %s, built %s)", version, commit, date)
 	logger.Default.Infof("Configuration loaded - Router: %s, Server Port: %d", cfg.Router.IP, cfg.Server.Port)
 
+	shutdownTracing := setupTracing(cfg.Tracing)
+	defer shutdownTracing()
+
 	// Create router client
-	routerClient := client.NewMiWiFiClient(cfg)
+	var routerClient client.RouterClient
+	if *demoData != "" {
+		fileClient, err := client.NewFileRouterClient(*demoData)
+		if err != nil {
+			logger.Default.Fatalf("Failed to load demo data: %v", err)
+		}
+		logger.Default.Infof("Serving demo data from %s, no router is contacted", *demoData)
+		routerClient = fileClient
+	} else {
+		miwifiClient := client.NewMiWiFiClient(cfg)
+		if *recordDir != "" {
+			if err := miwifiClient.RecordResponses(*recordDir); err != nil {
+				logger.Default.Fatalf("Failed to enable response recording: %v", err)
+			}
+			logger.Default.Infof("Recording router API responses to %s", *recordDir)
+		}
+		if *logCurl {
+			miwifiClient.LogCurlCommands()
+		}
+		routerClient = miwifiClient
+	}
 
 	// Create metrics collector
 	metricsCollector := collector.NewMetricsCollector(cfg)
+	metricsCollector.RegisterBuildInfo(version, commit)
+	// The fetch workers live as long as the exporter and are shared by all
+	// routers
+	fetchPool := concurrent.NewWorkerPool(cfg.Fetch.PoolWorkers)
+	fetchPool.Start()
+	defer func() {
+		// Let fetches still running, e.g. of a scrape that outlived the
+		// server shutdown, finish their router requests
+		drainCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := fetchPool.Drain(drainCtx); err != nil {
+			logger.Default.Warnf("Fetch workers did not finish: %v", err)
+		}
+	}()
+	metricsCollector.SetWorkerPool(fetchPool)
 	metricsCollector.SetClient(routerClient)
+	if err := metricsCollector.CheckConstLabels(); err != nil {
+		logger.Default.Errorf("%v", err)
+		return 1
+	}
+	logStartupBanner(cfg, metricsCollector)
+
+	if *once {
+		return collectOnce(metricsCollector)
+	}
 
 	// Setup HTTP server
-	server := setupHTTPServer(cfg, metricsCollector.GetRegistry())
+	server := setupHTTPServer(cfg, routerClient, metricsCollector)
+
+	if *webConfigFile != "" {
+		cfg.Server.WebConfigFile = *webConfigFile
+	}
+	if len(listenAddresses) == 0 {
+		listenAddresses = cfg.GetServerAddresses()
+	}
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: (*[]string)(&listenAddresses),
+		WebSystemdSocket:   systemdSocket,
+		WebConfigFile:      &cfg.Server.WebConfigFile,
+	}
+
+	if *debug {
+		startDebugServer(ctx, *debugAddress)
+	}
 
 	// Start server
-	startServer(server, cfg, routerClient, metricsCollector)
+	startServer(ctx, server, webFlags, routerClient, metricsCollector)
+	return 0
+}
+
+// stringSliceFlag collects repeated occurrences of a command line flag
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 func loadConfiguration(configFile string) (*config.Config, error) {
@@ -74,14 +224,104 @@ func loadConfiguration(configFile string) (*config.Config, error) {
 		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
 	
+	// Mask the password wherever it could end up in logs or errors
+	apperrors.RegisterSecret(cfg.Router.Password)
+	
 	return cfg, nil
 }
 
-func setupHTTPServer(cfg *config.Config, registry *prometheus.Registry) *http.Server {
+// collectOnce performs a single collection and writes the text exposition
+// format to stdout. It returns the process exit code.
+func collectOnce(metricsCollector *collector.MetricsCollector) int {
+	defer metricsCollector.Close()
+
+	families, err := metricsCollector.Gatherer(context.Background()).Gather()
+	if err != nil {
+		logger.Default.Errorf("Failed to gather metrics: %v", err)
+		return 1
+	}
+
+	encoder := expfmt.NewEncoder(os.Stdout, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			logger.Default.Errorf("Failed to encode metrics: %v", err)
+			return 1
+		}
+	}
+
+	if err := metricsCollector.LastError(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// setOfflineDefaults fills in the router address and password, which are
+// required by the configuration but unused when no router is contacted
+func setOfflineDefaults() {
+	for key, value := range map[string]string{"ROUTER_IP": "127.0.0.1", "ROUTER_PASSWORD": "offline"} {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+// replay runs a single collection against responses recorded with
+// --record-responses. It returns the process exit code.
+func replay(configFile, dir string) int {
+	setOfflineDefaults()
+
+	cfg, err := loadConfiguration(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	cfg.Cache.Enabled = false
+	cfg.Cache.SnapshotFile = ""
+	cfg.Collectors.KnownDevicesFile = ""
+
+	logger.Default = logger.NewWithOutput(cfg.Logging.Level, cfg.Logging.Format, os.Stderr)
+	logger.Default.Infof("Replaying router API responses from %s", dir)
+
+	metricsCollector := collector.NewMetricsCollector(cfg)
+	metricsCollector.SetClient(client.NewReplayClient(cfg, dir))
+
+	return collectOnce(metricsCollector)
+}
+
+// printDashboard writes the Grafana dashboard to stdout, falling back to the
+// default namespace when no complete configuration is available
+func printDashboard(configFile string) error {
+	namespace := "miwifi"
+	if cfg, err := loadConfiguration(configFile); err == nil {
+		namespace = cfg.Server.MetricPrefix()
+	} else {
+		server := config.ServerConfig{Namespace: namespace, Subsystem: os.Getenv("SERVER_SUBSYSTEM")}
+		if ns := os.Getenv("SERVER_NAMESPACE"); ns != "" {
+			server.Namespace = ns
+		}
+		namespace = server.MetricPrefix()
+	}
+
+	out, err := dashboard.Generate(namespace)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func setupHTTPServer(cfg *config.Config, routerClient client.RouterClient, metricsCollector *collector.MetricsCollector) *http.Server {
 	mux := http.NewServeMux()
 	
-	// Metrics endpoint
-	mux.Handle(cfg.Server.MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	// Metrics endpoint, cancelling router requests when the scrape is abandoned
+	mux.Handle(cfg.Server.MetricsPath, metricsCollector.Handler(promhttp.HandlerOpts{}))
+	
+	// Router data of the latest collection as JSON for non-Prometheus consumers
+	mux.Handle("/api/v1/snapshot", metricsCollector.SnapshotHandler())
+	
+	// Opt-in actions on the router, such as Wake-on-LAN
+	registerActions(mux, cfg.Actions, routerClient)
 	
 	// Health check endpoint
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -89,86 +329,105 @@ func setupHTTPServer(cfg *config.Config, registry *prometheus.Registry) *http.Se
 		w.Write([]byte("OK"))
 	})
 	
-	// Root endpoint
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
+	// Health check for container runtimes, optionally failing when the
+	// router could not be read for HEALTH_MAX_COLLECTION_AGE
+	mux.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
+		if err := metricsCollector.CheckHealth(r.Context(), cfg.Health.MaxCollectionAge); err != nil {
+			http.Error(w, "Unhealthy: "+err.Error(), http.StatusServiceUnavailable)
 			return
 		}
-		
-		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(`<!DOCTYPE html>
-<html>
-<head>
-    <title>MiWiFi Exporter</title>
-    <style>
-        body { font-family: Arial, sans-serif; margin: 40px; }
-        .container { max-width: 800px; margin: 0 auto; }
-        .header { text-align: center; margin-bottom: 30px; }
-        .metrics { background: #f5f5f5; padding: 20px; border-radius: 5px; }
-        .metric-link { display: inline-block; margin: 10px; padding: 10px 20px; background: #007bff; color: white; text-decoration: none; border-radius: 3px; }
-        .metric-link:hover { background: #0056b3; }
-        .footer { text-align: center; margin-top: 30px; color: #666; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>MiWiFi Exporter</h1>
-            <p>Prometheus exporter for Xiaomi WiFi routers</p>
-        </div>
-        
-        <div class="metrics">
-            <h2>Available Endpoints</h2>
-            <a href="` + cfg.Server.MetricsPath + `" class="metric-link">Metrics</a>
-            <a href="/health" class="metric-link">Health Check</a>
-        </div>
-        
-        <div class="footer">
-            <p>Version: ` + version + ` | Commit: ` + commit + `</p>
-        </div>
-    </div>
-</body>
-</html>`))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Healthy"))
 	})
 	
+	// Landing page, left out for a bare 404 on /
+	if !cfg.Server.DisableLandingPage {
+		landing, err := landingPage(cfg, metricsCollector)
+		if err != nil {
+			logger.Default.Fatalf("%v", err)
+		}
+		mux.Handle("/", landing)
+	}
+	
 	return &http.Server{
 		Addr:         cfg.GetServerAddress(),
-		Handler:      mux,
+		Handler:      withSecurityHeaders(cfg.Server, mux),
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 }
 
-func startServer(server *http.Server, cfg *config.Config, routerClient client.RouterClient, metricsCollector *collector.MetricsCollector) {
-	// Setup graceful shutdown
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+// sessionMaintainer is implemented by router clients that log in again in
+// the background after losing their session
+type sessionMaintainer interface {
+	MaintainSession(ctx context.Context)
+}
+
+// authStateClient is implemented by router clients that keep a login session
+type authStateClient interface {
+	AuthState() client.AuthState
+}
+
+// startServer serves the metrics until ctx is cancelled, then shuts down
+// gracefully
+func startServer(ctx context.Context, server *http.Server, webFlags *web.FlagConfig, routerClient client.RouterClient, metricsCollector *collector.MetricsCollector) {
+	// Tell systemd the exporter is ready once the first listener is served,
+	// the listeners are bound by then
+	var ready sync.Once
+	server.BaseContext = func(net.Listener) context.Context {
+		ready.Do(func() { systemd.NotifyReady(logger.Default) })
+		return context.Background()
+	}
 	
 	// Start server in goroutine
 	go func() {
-		logger.Default.Infof("Starting server on %s", server.Addr)
-		logger.Default.Infof("Metrics available at http://localhost:%d%s", cfg.Server.Port, cfg.Server.MetricsPath)
+		logger.Default.Infof("Starting server on %s", strings.Join(*webFlags.WebListenAddresses, ", "))
 		
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := web.ListenAndServe(server, webFlags, logger.KitLogger{Logger: logger.Default}); err != nil && err != http.ErrServerClosed {
 			logger.Default.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 	
-	// Test initial connection
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// Test initial connection, the login is bounded by ROUTER_AUTH_TIMEOUT
+	// A session resumed from ROUTER_SESSION_FILE is kept, logging in again is
+	// what persisting it avoids
+	if session, ok := routerClient.(authStateClient); ok && session.AuthState() == client.AuthStateAuthenticated {
+		logger.Default.Info("Using the resumed router session")
+		logRouterInfo(routerClient)
+	} else {
+		logger.Default.Info("Testing router connection...")
+		if err := routerClient.Authenticate(ctx); err != nil {
+			logger.Default.Errorf("Failed to authenticate with router: %v", err)
+			logger.Default.Warn("Please check your router IP and password in configuration")
+		} else {
+			logRouterInfo(routerClient)
+		}
+	}
 	
-	logger.Default.Info("Testing router connection...")
-	if err := routerClient.Authenticate(ctx); err != nil {
-		logger.Default.Errorf("Failed to authenticate with router: %v", err)
-		logger.Default.Warn("Please check your router IP and password in configuration")
+	// Keep logging in while the router is unreachable, so scrapes recover
+	// without a restart
+	if maintainer, ok := routerClient.(sessionMaintainer); ok {
+		go maintainer.MaintainSession(ctx)
 	}
 	
-	// Wait for shutdown signal
-	<-done
+	// Fetch the router data in the background when ROUTER_REFRESH_INTERVAL
+	// is set, scrapes within the interval export the latest collection
+	go metricsCollector.RunRefresh(ctx)
+	
+	// Measure the latency to the router and the WAN gateway when enabled
+	go metricsCollector.RunProbes(ctx)
+	
+	// Receive the router's logs when enabled
+	go metricsCollector.RunSyslog(ctx)
+	
+	// Send notifications about router events when a channel is configured
+	go metricsCollector.RunNotifications(ctx)
+	
+	// Wait for a shutdown signal or service stop request
+	<-ctx.Done()
 	logger.Default.Info("Shutting down server...")
+	systemd.NotifyStopping()
 	
 	// Graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
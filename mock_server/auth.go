@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockKey 登录页面中的加密 key，与 handleWebPage 保持一致
+const mockKey = "mock_key_123456"
+
+// AuthOptions 认证模拟选项
+type AuthOptions struct {
+	// 登录用户名，为空时接受任意用户名
+	Username string
+	// 登录密码，为空时接受任意密码
+	Password string
+	// init_info 返回的 newEncryptMode，0 使用 SHA1，1 使用 SHA256
+	EncryptMode int
+	// 模拟 init_info 报告错误的固件，-1 表示与 EncryptMode 一致
+	ReportedEncryptMode int
+	// 只接受该设备类型的 nonce，-1 表示接受任意类型
+	NonceType int
+	// 连续登录失败指定次数后锁定登录，0 表示不锁定
+	LockoutAfter int
+	// 锁定持续时间
+	LockoutDuration time.Duration
+	// token 有效期，0 表示不过期
+	TokenTTL time.Duration
+	// token 在处理指定数量的接口请求后失效，0 表示不限制
+	ExpireAfter int
+}
+
+// authState 当前会话状态
+type authState struct {
+	mu         sync.Mutex
+	opts       AuthOptions
+	token      string
+	issued     time.Time
+	requests   int
+	revoked    bool
+	failLogins int
+	// 连续失败的登录次数和锁定的截止时间
+	failedLogins int
+	lockedUntil  time.Time
+}
+
+var stokPattern = regexp.MustCompile(`;stok=([^/]*)`)
+
+func newAuthState(opts AuthOptions) *authState {
+	return &authState{opts: opts}
+}
+
+// login 校验登录请求，成功时签发新的 token，locked 表示登录已被锁定
+func (a *authState) login(username, password, nonce string) (token string, ok bool, locked bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if time.Now().Before(a.lockedUntil) {
+		return "", false, true
+	}
+
+	if !a.accepts(username, password, nonce) {
+		a.failedLogins++
+		if a.opts.LockoutAfter > 0 && a.failedLogins >= a.opts.LockoutAfter {
+			log.Printf("Locking logins for %v after %d failed attempts", a.opts.LockoutDuration, a.failedLogins)
+			a.lockedUntil = time.Now().Add(a.opts.LockoutDuration)
+			a.failedLogins = 0
+		}
+		return "", false, false
+	}
+	a.failedLogins = 0
+
+	a.token = generateMockToken()
+	a.issued = time.Now()
+	a.requests = 0
+	a.revoked = false
+	return a.token, true, false
+}
+
+// accepts 检查用户名、nonce 和密码哈希
+func (a *authState) accepts(username, password, nonce string) bool {
+	if a.failLogins > 0 {
+		a.failLogins--
+		return false
+	}
+
+	if a.opts.Username != "" && username != a.opts.Username {
+		return false
+	}
+	if a.opts.NonceType >= 0 && !strings.HasPrefix(nonce, fmt.Sprintf("%d_", a.opts.NonceType)) {
+		return false
+	}
+	if a.opts.Password != "" && password != a.expectedPassword(nonce) {
+		return false
+	}
+	return true
+}
+
+// expectedPassword 按路由器的算法计算登录密码哈希
+func (a *authState) expectedPassword(nonce string) string {
+	if a.opts.EncryptMode == 1 {
+		return hashHex(sha256.New, nonce+hashHex(sha256.New, a.opts.Password+mockKey))
+	}
+	return hashHex(sha1.New, nonce+hashHex(sha1.New, a.opts.Password+mockKey))
+}
+
+// reportedEncryptMode 返回 init_info 中的 newEncryptMode
+func (a *authState) reportedEncryptMode() int {
+	if a.opts.ReportedEncryptMode >= 0 {
+		return a.opts.ReportedEncryptMode
+	}
+	return a.opts.EncryptMode
+}
+
+// validate 检查请求中的 stok 是否有效，并计入请求次数
+func (a *authState) validate(stok string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" || stok != a.token || a.revoked {
+		return false
+	}
+	if a.opts.TokenTTL > 0 && time.Since(a.issued) > a.opts.TokenTTL {
+		return false
+	}
+
+	a.requests++
+	if a.opts.ExpireAfter > 0 && a.requests > a.opts.ExpireAfter {
+		return false
+	}
+
+	return true
+}
+
+func hashHex(newHash func() hash.Hash, data string) string {
+	h := newHash()
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkToken 校验带 stok 的请求，token 无效时按路由器格式返回 401 并返回 false。
+// 不带 stok 的请求(例如直接 curl 调试)不做校验。
+func (ms *MockServer) checkToken(w http.ResponseWriter, r *http.Request) bool {
+	matches := stokPattern.FindStringSubmatch(r.URL.Path)
+	if matches == nil {
+		return true
+	}
+
+	if ms.auth.validate(matches[1]) {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": 401,
+		"msg":  "Invalid token",
+	})
+	return false
+}
+
+// handleExpireToken 使当前 token 立即失效，模拟会话中途过期
+func (ms *MockServer) handleExpireToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ms.auth.mu.Lock()
+	ms.auth.revoked = true
+	ms.auth.mu.Unlock()
+
+	log.Printf("Token expired by request")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFailLogin 让接下来的 count 次登录失败，模拟密码错误
+func (ms *MockServer) handleFailLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count := 1
+	if value := r.URL.Query().Get("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, fmt.Sprintf("invalid count %q", value), http.StatusBadRequest)
+			return
+		}
+		count = parsed
+	}
+
+	ms.auth.mu.Lock()
+	ms.auth.failLogins = count
+	ms.auth.mu.Unlock()
+
+	log.Printf("Next %d login(s) will fail", count)
+	w.WriteHeader(http.StatusNoContent)
+}
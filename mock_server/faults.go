@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Duration 支持 "200ms" 形式的时长配置
+type Duration time.Duration
+
+// UnmarshalJSON 解析字符串或纳秒数形式的时长
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(time.Duration(v))
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+	return nil
+}
+
+// MarshalJSON 以 "200ms" 形式输出时长
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Fault 单个接口的故障注入配置，概率取值 0 到 1
+type Fault struct {
+	// 固定延迟
+	Latency Duration `json:"latency"`
+	// 在固定延迟基础上随机增加的最大延迟
+	Jitter Duration `json:"jitter"`
+	// 前 N 次请求必定返回 5xx，用于确定性地测试重试
+	FailFirst int `json:"fail_first"`
+	// 返回 5xx 的概率
+	ErrorRate float64 `json:"error_rate"`
+	// 返回的 HTTP 状态码，默认 500
+	ErrorStatus int `json:"error_status"`
+	// 返回截断 JSON 的概率
+	TruncateRate float64 `json:"truncate_rate"`
+	// 直接重置连接的概率
+	ResetRate float64 `json:"reset_rate"`
+	// 非零时以 HTTP 200 返回该 code 和 Msg 而不是数据，例如 401 表示 token 失效
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// faultInjector 按接口注入故障，随机数种子固定时结果可复现
+type faultInjector struct {
+	mu       sync.Mutex
+	faults   map[string]Fault
+	requests map[string]int
+	rng      *rand.Rand
+}
+
+func newFaultInjector(faults map[string]Fault, seed int64) *faultInjector {
+	if faults == nil {
+		faults = make(map[string]Fault)
+	}
+	return &faultInjector{
+		faults:   faults,
+		requests: make(map[string]int),
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// set 替换全部故障配置并重置请求计数
+func (fi *faultInjector) set(faults map[string]Fault) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.faults = faults
+	fi.requests = make(map[string]int)
+}
+
+// faultAction 一次请求要执行的故障
+type faultAction struct {
+	delay    time.Duration
+	status   int
+	truncate bool
+	reset    bool
+	code     int
+	msg      string
+}
+
+// decide 根据配置决定本次请求的故障，接口未配置时使用 "*" 的配置
+func (fi *faultInjector) decide(endpoint string) (faultAction, bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fault, ok := fi.faults[endpoint]
+	if !ok {
+		fault, ok = fi.faults["*"]
+	}
+	if !ok {
+		return faultAction{}, false
+	}
+
+	fi.requests[endpoint]++
+	action := faultAction{delay: time.Duration(fault.Latency)}
+	if fault.Jitter > 0 {
+		action.delay += time.Duration(fi.rng.Int63n(int64(fault.Jitter)))
+	}
+
+	errorStatus := fault.ErrorStatus
+	if errorStatus == 0 {
+		errorStatus = http.StatusInternalServerError
+	}
+
+	switch {
+	case fi.requests[endpoint] <= fault.FailFirst:
+		action.status = errorStatus
+	case fi.rng.Float64() < fault.ResetRate:
+		action.reset = true
+	case fi.rng.Float64() < fault.ErrorRate:
+		action.status = errorStatus
+	case fi.rng.Float64() < fault.TruncateRate:
+		action.truncate = true
+	case fault.Code != 0:
+		action.code = fault.Code
+		action.msg = fault.Msg
+	}
+
+	return action, true
+}
+
+// serveWithFaults 执行故障注入，未被故障拦截时调用 next
+func (ms *MockServer) serveWithFaults(w http.ResponseWriter, r *http.Request, endpoint string, next http.HandlerFunc) {
+	action, ok := ms.faults.decide(endpoint)
+	if !ok {
+		next(w, r)
+		return
+	}
+
+	if action.delay > 0 {
+		select {
+		case <-time.After(action.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case action.reset:
+		resetConnection(w)
+	case action.status != 0:
+		http.Error(w, http.StatusText(action.status), action.status)
+	case action.code != 0:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": action.code, "msg": action.msg})
+	case action.truncate:
+		recorder := httptest.NewRecorder()
+		next(recorder, r)
+		body := recorder.Body.Bytes()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body[:len(body)/2])
+	default:
+		next(w, r)
+	}
+}
+
+// resetConnection 以 RST 关闭连接，模拟路由器中途断开
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection reset not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// handleFaults 运行时查看或替换故障配置，POST 的请求体为接口名称到 Fault 的映射
+func (ms *MockServer) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ms.faults.mu.Lock()
+		defer ms.faults.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ms.faults.faults)
+	case http.MethodPost:
+		faults := make(map[string]Fault)
+		if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ms.faults.set(faults)
+		log.Printf("Fault injection updated for %d endpoint(s)", len(faults))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+)
+
+// NetworkOptions 合成大型网络的参数，用于压测标签基数和内存占用
+type NetworkOptions struct {
+	// 终端设备数量，不含 mesh 节点
+	Devices int
+	// mesh 子节点数量，不含主路由
+	MeshNodes int
+	// 随机数种子，相同种子生成相同的设备列表
+	Seed int64
+	// 双WAN工作模式 balance 或 failover，为空表示单WAN
+	DualWAN string
+	// 每次请求设备列表时每个终端设备切换在线状态的概率，用于测试设备上下线事件
+	Churn float64
+}
+
+// MeshNode mesh 拓扑中的一个节点，对应 misystem/topo_graph 的返回结构
+type MeshNode struct {
+	Name     string `json:"name"`
+	Hardware string `json:"hardware"`
+	IP       string `json:"ip"`
+	Mac      string `json:"mac"`
+	IsMain   int    `json:"is_main"`
+	Backhaul string `json:"backhaul,omitempty"`
+	// 回程协商速率（Mbps）和当前上下行速度（字节/秒），只有子节点有
+	BackhaulRate      string      `json:"backhaul_rate,omitempty"`
+	BackhaulUpSpeed   string      `json:"backhaul_upspeed,omitempty"`
+	BackhaulDownSpeed string      `json:"backhaul_downspeed,omitempty"`
+	Leafs             []*MeshNode `json:"leafs"`
+}
+
+// 生成设备名称时使用的前缀，模拟家庭网络中常见的设备类型
+var deviceNamePrefixes = []string{
+	"iPhone", "Android", "MacBook", "Windows-PC", "iPad",
+	"Xiaomi-TV", "Mi-Camera", "ESP32", "Printer", "NAS",
+}
+
+// generateNetwork 生成指定数量的终端设备和 mesh 节点，替换默认或场景中的设备数据
+func (ms *MockServer) generateNetwork(opts NetworkOptions) {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	platform := ms.systemInfo.Hardware.Platform
+
+	ms.meshGraph = ms.mainMeshNode()
+
+	devices := make([]MockDevice, 0, opts.Devices+opts.MeshNodes)
+	// 空字符串表示直接连接主路由
+	parents := []string{""}
+
+	for i := 0; i < opts.MeshNodes; i++ {
+		// 无线回程使用 160MHz 或 80MHz 频宽，有线回程为千兆网口
+		backhaul, rate := "wireless", []string{"2402", "1201"}[rng.Intn(2)]
+		if i%2 == 1 {
+			backhaul, rate = "wired", "1000"
+		}
+
+		node := &MeshNode{
+			Name:              fmt.Sprintf("Mesh-Node-%d", i+1),
+			Hardware:          platform,
+			IP:                generatedIP(i),
+			Mac:               generatedMac(0x4d, i),
+			Backhaul:          backhaul,
+			BackhaulRate:      rate,
+			BackhaulUpSpeed:   fmt.Sprintf("%d", rng.Intn(1<<22)),
+			BackhaulDownSpeed: fmt.Sprintf("%d", rng.Intn(1<<24)),
+			Leafs:             []*MeshNode{},
+		}
+		ms.meshGraph.Leafs = append(ms.meshGraph.Leafs, node)
+		parents = append(parents, node.Mac)
+
+		devices = append(devices, MockDevice{
+			Mac:       node.Mac,
+			IP:        []MockIP{{IP: node.IP}},
+			Name:      node.Name,
+			IsAP:      1,
+			Online:    1,
+			Authority: MockAuthority{Wan: 1, Lan: 1},
+			Statistics: MockDeviceStats{
+				Online:    fmt.Sprintf("%d", 86400+rng.Intn(7*86400)),
+				UpSpeed:   "0",
+				DownSpeed: "0",
+			},
+			Upload:   "0",
+			Download: "0",
+		})
+	}
+
+	ms.systemInfo.Dev = make([]MockDev, 0, opts.Devices)
+	for i := 0; i < opts.Devices; i++ {
+		// 约 20% 为有线设备，其余平均分布在 2.4G 和 5G
+		deviceType := 1 + rng.Intn(2)
+		if rng.Float64() < 0.2 {
+			deviceType = 0
+		}
+		wan := 1
+		if rng.Float64() < 0.05 {
+			wan = 0
+		}
+
+		upload := fmt.Sprintf("%d", rng.Int63n(1<<32))
+		download := fmt.Sprintf("%d", rng.Int63n(1<<34))
+
+		device := MockDevice{
+			Mac:       generatedMac(0x00, i),
+			IP:        []MockIP{{IP: generatedIP(opts.MeshNodes + i)}},
+			Name:      fmt.Sprintf("%s-%04d", deviceNamePrefixes[rng.Intn(len(deviceNamePrefixes))], i+1),
+			Parent:    parents[rng.Intn(len(parents))],
+			Type:      deviceType,
+			Online:    1,
+			Authority: MockAuthority{Wan: wan, Lan: 1},
+			Statistics: MockDeviceStats{
+				Online:    fmt.Sprintf("%d", rng.Intn(7*86400)),
+				UpSpeed:   fmt.Sprintf("%d", rng.Intn(1<<20)),
+				DownSpeed: fmt.Sprintf("%d", rng.Intn(1<<22)),
+			},
+			Upload:   upload,
+			Download: download,
+		}
+		devices = append(devices, device)
+
+		ms.systemInfo.Dev = append(ms.systemInfo.Dev, MockDev{
+			Mac:              device.Mac,
+			Upload:           upload,
+			Download:         download,
+			MaxUploadSpeed:   fmt.Sprintf("%d", rng.Intn(1<<23)),
+			MaxDownloadSpeed: fmt.Sprintf("%d", rng.Intn(1<<25)),
+		})
+	}
+
+	ms.devices = devices
+	ms.systemInfo.Count = MockCount{
+		All:               len(devices),
+		Online:            len(devices),
+		AllWithoutMash:    opts.Devices,
+		OnlineWithoutMash: opts.Devices,
+	}
+	// 每个设备平均保持几十个连接，设备多时连接跟踪表接近上限
+	if ms.systemInfo.Conntrack != nil {
+		ms.systemInfo.Conntrack.Count = min(40*opts.Devices, ms.systemInfo.Conntrack.Max)
+	}
+}
+
+// mainMeshNode 返回代表主路由的拓扑根节点
+func (ms *MockServer) mainMeshNode() *MeshNode {
+	return &MeshNode{
+		Name:     "MiWiFi",
+		Hardware: ms.systemInfo.Hardware.Platform,
+		IP:       "192.168.31.1",
+		Mac:      ms.systemInfo.Hardware.Mac,
+		IsMain:   1,
+		Leafs:    []*MeshNode{},
+	}
+}
+
+// generatedIP 按序号分配 192.168.31.2 起的地址，每个网段使用 2-251，超出后进入下一个网段
+func generatedIP(index int) string {
+	return fmt.Sprintf("192.168.%d.%d", 31+index/250, 2+index%250)
+}
+
+// generatedMac 按序号生成本地管理的 MAC 地址，kind 区分 mesh 节点和终端设备
+func generatedMac(kind byte, index int) string {
+	return fmt.Sprintf("02:%02x:00:%02x:%02x:%02x", kind, byte(index>>16), byte(index>>8), byte(index))
+}
+
+// handleTopoGraph 处理 mesh 拓扑请求，未生成 mesh 网络时只返回主路由
+func (ms *MockServer) handleTopoGraph(w http.ResponseWriter, r *http.Request) {
+	graph := ms.meshGraph
+	if graph == nil {
+		graph = ms.mainMeshNode()
+	}
+
+	response := map[string]interface{}{
+		"code":  0,
+		"show":  1,
+		"graph": graph,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,11 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -15,12 +15,22 @@ import (
 // MockServer 模拟小米路由器的API服务
 type MockServer struct {
 	server     *http.Server
-	authToken  string
+	auth       *authState
+	faults     *faultInjector
 	port       int
 	devices    []MockDevice
 	wifiInfo   MockWiFiInfo
 	wanInfo    MockWanInfo
 	systemInfo MockSystemInfo
+	meshGraph  *MeshNode
+	scenario   *Scenario
+	started    time.Time
+	// dualWAN 为双WAN工作模式，为空表示单WAN
+	dualWAN    string
+	// churn 为终端设备每次切换在线状态的概率
+	churn      float64
+	// romUpdate 为可升级的固件版本，为空表示已是最新版本
+	romUpdate  string
 }
 
 // MockDevice 模拟设备信息
@@ -28,12 +38,21 @@ type MockDevice struct {
 	Mac         string            `json:"mac"`
 	IP          []MockIP          `json:"ip"`
 	Name        string            `json:"name"`
-	IsAP        int               `json:"is_ap"`
+	IsAP        int               `json:"isap"`
+	Parent      string            `json:"parent"`
+	Type        int               `json:"type"`
+	Online      int               `json:"online"`
+	Authority   MockAuthority     `json:"authority"`
 	Statistics  MockDeviceStats   `json:"statistics"`
 	Upload      interface{}       `json:"upload"`
 	Download    interface{}       `json:"download"`
 }
 
+type MockAuthority struct {
+	Wan int `json:"wan"`
+	Lan int `json:"lan"`
+}
+
 type MockIP struct {
 	IP string `json:"ip"`
 }
@@ -46,12 +65,22 @@ type MockDeviceStats struct {
 
 // MockWiFiInfo 模拟WiFi信息
 type MockWiFiInfo struct {
+	// 双频合一(band steering)开关，1 为开启
+	Bsd  int              `json:"bsd"`
 	Info []MockWiFiDetail `json:"info"`
 }
 
 type MockWiFiDetail struct {
+	IfName      string            `json:"ifname"`
 	Ssid        string            `json:"ssid"`
 	Status      string            `json:"status"`
+	Encryption  string            `json:"encryption"`
+	Bandwidth   string            `json:"bandwidth"`
+	TxPWR       string            `json:"txpwr"`
+	Hidden      string            `json:"hidden"`
+	Ax          string            `json:"ax"`
+	Bsd         string            `json:"bsd"`
+	Password    string            `json:"password"`
 	ChannelInfo MockChannelInfo   `json:"channelInfo"`
 }
 
@@ -66,8 +95,9 @@ type MockWanInfo struct {
 }
 
 type MockWanDetail struct {
+	GateWay  string         `json:"gateWay"`
 	Ipv4     []MockIPv4     `json:"ipv4"`
-	Ipv6Info MockIPv6Info   `json:"ipv6Info"`
+	Ipv6Info MockIPv6Info   `json:"ipv6_info"`
 }
 
 type MockIPv4 struct {
@@ -76,7 +106,11 @@ type MockIPv4 struct {
 }
 
 type MockIPv6Info struct {
-	IP6Addr []string `json:"ip6Addr"`
+	WanType      string        `json:"wanType"`
+	IfName       string        `json:"ifname"`
+	IP6Addr      []string      `json:"ip6addr"`
+	LanIP6Prefix []interface{} `json:"lan_ip6prefix"`
+	LanIP6Addr   []interface{} `json:"lan_ip6addr"`
 }
 
 // MockSystemInfo 模拟系统信息
@@ -89,6 +123,14 @@ type MockSystemInfo struct {
 	UpTime    string        `json:"upTime"`
 	Hardware  MockHardware  `json:"hardware"`
 	Code      int           `json:"code"`
+	// Conntrack 只有基于 OpenWrt 的固件才会返回
+	Conntrack *MockConntrack `json:"conntrack,omitempty"`
+}
+
+// MockConntrack 模拟连接跟踪表的使用情况
+type MockConntrack struct {
+	Count int `json:"count"`
+	Max   int `json:"max"`
 }
 
 type MockCPU struct {
@@ -103,16 +145,21 @@ type MockMemory struct {
 }
 
 type MockDev struct {
-	Mac       string      `json:"mac"`
-	Upload    interface{} `json:"upload"`
-	Download  interface{} `json:"download"`
+	Mac              string      `json:"mac"`
+	Upload           interface{} `json:"upload"`
+	Download         interface{} `json:"download"`
+	MaxUploadSpeed   string      `json:"maxuploadspeed"`
+	MaxDownloadSpeed string      `json:"maxdownloadspeed"`
 }
 
 type MockWan struct {
-	UpSpeed   string `json:"upSpeed"`
-	DownSpeed string `json:"downSpeed"`
-	Upload    string `json:"upload"`
-	Download  string `json:"download"`
+	UpSpeed          string `json:"upSpeed"`
+	DownSpeed        string `json:"downSpeed"`
+	MaxUploadSpeed   string `json:"maxuploadspeed"`
+	MaxDownloadSpeed string `json:"maxdownloadspeed"`
+	History          string `json:"history"`
+	Upload           string `json:"upload"`
+	Download         string `json:"download"`
 }
 
 type MockCount struct {
@@ -132,32 +179,44 @@ type MockHardware struct {
 // InitInfo 初始化信息
 type InitInfo struct {
 	Hardware      string `json:"hardware"`
-	RomVersion    string `json:"rom_version"`
-	SerialNumber  string `json:"serial_number"`
-	RouterName    string `json:"router_name"`
-	NewEncryptMode int   `json:"new_encrypt_mode"`
+	RomVersion    string `json:"romversion"`
+	SerialNumber  string `json:"id"`
+	RouterName    string `json:"routername"`
+	NewEncryptMode int   `json:"newEncryptMode"`
 }
 
 // NewMockServer 创建新的mock服务器
-func NewMockServer(port int) *MockServer {
+func NewMockServer(port int, scenario *Scenario, authOpts AuthOptions, faults *faultInjector, network NetworkOptions) *MockServer {
 	mockServer := &MockServer{
-		port:      port,
-		authToken: generateMockToken(),
+		port:    port,
+		auth:    newAuthState(authOpts),
+		faults:  faults,
+		started: time.Now(),
+		dualWAN: network.DualWAN,
+		churn:   network.Churn,
 	}
 
 	// 初始化模拟数据
 	mockServer.initializeMockData()
+	if scenario != nil {
+		mockServer.applyScenario(scenario)
+	}
+	if network.Devices > 0 || network.MeshNodes > 0 {
+		mockServer.generateNetwork(network)
+	}
 
-	// 设置路由
+	// 设置路由，带 stok 的请求路径形如 /cgi-bin/luci/;stok=xxx/api/...，由子树路由统一分发
 	mux := http.NewServeMux()
 	mux.HandleFunc("/cgi-bin/luci/web", mockServer.handleWebPage)
 	mux.HandleFunc("/cgi-bin/luci/api/xqsystem/init_info", mockServer.handleInitInfo)
 	mux.HandleFunc("/cgi-bin/luci/api/xqsystem/login", mockServer.handleLogin)
-	mux.HandleFunc("/cgi-bin/luci/;stok=", mockServer.handleAuthRequest)
-	mux.HandleFunc("/cgi-bin/luci/api/misystem/status", mockServer.handleSystemStatus)
-	mux.HandleFunc("/cgi-bin/luci/api/misystem/devicelist", mockServer.handleDeviceList)
-	mux.HandleFunc("/cgi-bin/luci/api/xqnetwork/wan_info", mockServer.handleWanInfo)
-	mux.HandleFunc("/cgi-bin/luci/api/xqnetwork/wifi_detail_all", mockServer.handleWifiDetails)
+	mux.HandleFunc("/cgi-bin/luci/", mockServer.handleAuthRequest)
+	mux.HandleFunc("/mock/expire-token", mockServer.handleExpireToken)
+	mux.HandleFunc("/mock/fail-login", mockServer.handleFailLogin)
+	mux.HandleFunc("/mock/faults", mockServer.handleFaults)
+	for endpoint := range mockServer.endpoints() {
+		mux.HandleFunc("/cgi-bin/luci/api/"+endpoint, mockServer.handleAuthRequest)
+	}
 
 	mockServer.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
@@ -176,6 +235,9 @@ func (ms *MockServer) initializeMockData() {
 			IP:   []MockIP{{IP: "192.168.31.100"}},
 			Name: "iPhone-13",
 			IsAP: 0,
+			Type: 2,
+			Online: 1,
+			Authority: MockAuthority{Wan: 1, Lan: 1},
 			Statistics: MockDeviceStats{
 				Online:    "3600",
 				UpSpeed:   "1024",
@@ -189,6 +251,9 @@ func (ms *MockServer) initializeMockData() {
 			IP:   []MockIP{{IP: "192.168.31.101"}},
 			Name: "MacBook-Pro",
 			IsAP: 0,
+			Type: 0,
+			Online: 1,
+			Authority: MockAuthority{Wan: 1, Lan: 1},
 			Statistics: MockDeviceStats{
 				Online:    "7200",
 				UpSpeed:   "512",
@@ -202,6 +267,9 @@ func (ms *MockServer) initializeMockData() {
 			IP:   []MockIP{{IP: "192.168.31.102"}},
 			Name: "Android-Phone",
 			IsAP: 0,
+			Type: 1,
+			Online: 1,
+			Authority: MockAuthority{Wan: 0, Lan: 1},
 			Statistics: MockDeviceStats{
 				Online:    "1800",
 				UpSpeed:   "256",
@@ -214,18 +282,35 @@ func (ms *MockServer) initializeMockData() {
 
 	// 模拟WiFi信息
 	ms.wifiInfo = MockWiFiInfo{
+		Bsd: 1,
 		Info: []MockWiFiDetail{
 			{
-				Ssid:   "MiWiFi_5G",
-				Status: "on",
+				IfName:     "wl0",
+				Ssid:       "MiWiFi_5G",
+				Status:     "on",
+				Encryption: "psk2+ccmp",
+				Bandwidth:  "80",
+				TxPWR:      "max",
+				Hidden:     "0",
+				Ax:         "1",
+				Bsd:        "1",
+				Password:   "mock-wifi-password",
 				ChannelInfo: MockChannelInfo{
 					BandList: []string{"5"},
 					Channel:  149,
 				},
 			},
 			{
-				Ssid:   "MiWiFi_2.4G",
-				Status: "on",
+				IfName:     "wl1",
+				Ssid:       "MiWiFi_2.4G",
+				Status:     "on",
+				Encryption: "mixed-psk",
+				Bandwidth:  "20",
+				TxPWR:      "mid",
+				Hidden:     "0",
+				Ax:         "1",
+				Bsd:        "1",
+				Password:   "mock-wifi-password",
 				ChannelInfo: MockChannelInfo{
 					BandList: []string{"2.4"},
 					Channel:  6,
@@ -237,6 +322,7 @@ func (ms *MockServer) initializeMockData() {
 	// 模拟WAN信息
 	ms.wanInfo = MockWanInfo{
 		Info: MockWanDetail{
+			GateWay: "100.100.100.1",
 			Ipv4: []MockIPv4{
 				{
 					IP:   "100.100.100.100",
@@ -244,13 +330,25 @@ func (ms *MockServer) initializeMockData() {
 				},
 			},
 			Ipv6Info: MockIPv6Info{
+				WanType: "dhcp6",
+				IfName:  "pppoe-wan",
 				IP6Addr: []string{"2001:db8::1"},
+				LanIP6Prefix: []interface{}{
+					map[string]interface{}{"prefix": "2001:db8:1200::", "mask": 60},
+				},
+				LanIP6Addr: []interface{}{
+					map[string]interface{}{"ip": "2001:db8:1200::1", "mask": 64},
+				},
 			},
 		},
 	}
 
 	// 模拟系统信息
 	ms.systemInfo = MockSystemInfo{
+		Conntrack: &MockConntrack{
+			Count: 1200,
+			Max:   16384,
+		},
 		CPU: MockCPU{
 			Core: 4,
 			Hz:   "800000000",
@@ -262,26 +360,35 @@ func (ms *MockServer) initializeMockData() {
 		},
 		Dev: []MockDev{
 			{
-				Mac:      "aa:bb:cc:dd:ee:ff",
-				Upload:   "1048576",
-				Download: "2097152",
+				Mac:              "aa:bb:cc:dd:ee:ff",
+				Upload:           "1048576",
+				Download:         "2097152",
+				MaxUploadSpeed:   "524288",
+				MaxDownloadSpeed: "2097152",
 			},
 			{
-				Mac:      "ff:ee:dd:cc:bb:aa",
-				Upload:   "524288",
-				Download: "1048576",
+				Mac:              "ff:ee:dd:cc:bb:aa",
+				Upload:           "524288",
+				Download:         "1048576",
+				MaxUploadSpeed:   "262144",
+				MaxDownloadSpeed: "1048576",
 			},
 			{
-				Mac:      "11:22:33:44:55:66",
-				Upload:   "262144",
-				Download: "524288",
+				Mac:              "11:22:33:44:55:66",
+				Upload:           "262144",
+				Download:         "524288",
+				MaxUploadSpeed:   "65536",
+				MaxDownloadSpeed: "262144",
 			},
 		},
 		Wan: MockWan{
-			UpSpeed:   "100.5",
-			DownSpeed: "200.8",
-			Upload:    "1073741824",
-			Download:  "2147483648",
+			UpSpeed:          "100.5",
+			DownSpeed:        "200.8",
+			MaxUploadSpeed:   "12582912",
+			MaxDownloadSpeed: "104857600",
+			History:          "180,220,150,3400,260,190,205,4100,230,175",
+			Upload:           "1073741824",
+			Download:         "2147483648",
 		},
 		Count: MockCount{
 			All:             3,
@@ -302,13 +409,15 @@ func (ms *MockServer) initializeMockData() {
 
 // generateMockToken 生成模拟token
 func generateMockToken() string {
-	return fmt.Sprintf("mock_token_%d", time.Now().Unix())
+	return fmt.Sprintf("mock_token_%d", time.Now().UnixNano())
 }
 
 // Start 启动mock服务器
 func (ms *MockServer) Start() error {
 	log.Printf("Mock MiWiFi server starting on port %d", ms.port)
-	log.Printf("Auth token: %s", ms.authToken)
+	if ms.auth.opts.Password != "" {
+		log.Printf("Login requires password %q (encrypt mode %d)", ms.auth.opts.Password, ms.auth.opts.EncryptMode)
+	}
 	return ms.server.ListenAndServe()
 }
 
@@ -327,7 +436,10 @@ func (ms *MockServer) handleWebPage(w http.ResponseWriter, r *http.Request) {
 </head>
 <body>
     <script>
-        var key = 'mock_key_123456';
+        var Encrypt = {
+            key: '` + mockKey + `',
+            iv: 'mock_iv_000000',
+        };
         var deviceId = 'mock_device_id_789012';
     </script>
 </body>
@@ -344,7 +456,7 @@ func (ms *MockServer) handleInitInfo(w http.ResponseWriter, r *http.Request) {
 		RomVersion:    "2.28.123",
 		SerialNumber:  "1234567890",
 		RouterName:    "MiWiFi-Test",
-		NewEncryptMode: 1,
+		NewEncryptMode: ms.auth.reportedEncryptMode(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -355,40 +467,103 @@ func (ms *MockServer) handleInitInfo(w http.ResponseWriter, r *http.Request) {
 func (ms *MockServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	
+	token, ok, locked := ms.auth.login(r.PostForm.Get("username"), r.PostForm.Get("password"), r.PostForm.Get("nonce"))
+	if locked {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 403,
+			"msg":  "too many login attempts, try again later",
+		})
+		return
+	}
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 401,
+			"msg":  "not auth",
+		})
+		return
+	}
+	
 	response := map[string]interface{}{
-		"token": ms.authToken,
-		"url":   fmt.Sprintf("/cgi-bin/luci/;stok=%s/web", ms.authToken),
+		"code":  0,
+		"token": token,
+		"url":   fmt.Sprintf("/cgi-bin/luci/;stok=%s/web", token),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// endpoints 返回接口名称到处理函数的映射，名称与场景文件中的键一致
+func (ms *MockServer) endpoints() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"misystem/status":           ms.handleSystemStatus,
+		"misystem/devicelist":       ms.handleDeviceList,
+		"xqnetwork/wan_info":        ms.handleWanInfo,
+		"xqnetwork/wifi_detail_all": ms.handleWifiDetails,
+		"xqdisk/disk_info":          ms.handleDiskInfo,
+		"xqsystem/samba_status":     ms.handleSambaStatus,
+		"misystem/topo_graph":       ms.handleTopoGraph,
+		"misystem/sys_info":         ms.handleSysInfo,
+		"xqnetwork/port_status":     ms.handlePortStatus,
+		"xqnetwork/wps_status":      ms.handleWPSStatus,
+		"xqnetwork/wifi_statistics": ms.handleWifiStatistics,
+		"xqnetwork/iptv":            ms.handleIPTV,
+		"xqsystem/upnp":             ms.handleUPnP,
+		"xqnetwork/wol":             ms.handleWakeOnLAN,
+		"xqsystem/set_mac_filter":   ms.handleSetMacFilter,
+		"xqsystem/check_rom_update": ms.handleCheckROMUpdate,
+	}
+}
+
 // handleAuthRequest 处理需要认证的请求
 func (ms *MockServer) handleAuthRequest(w http.ResponseWriter, r *http.Request) {
 	// 检查URL路径以确定具体的API端点
-	path := r.URL.Path
+	idx := strings.Index(r.URL.Path, "/api/")
+	if idx < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	endpoint := strings.Trim(r.URL.Path[idx+len("/api/"):], "/")
 	
-	if strings.Contains(path, "api/misystem/status") {
-		ms.handleSystemStatus(w, r)
-	} else if strings.Contains(path, "api/misystem/devicelist") {
-		ms.handleDeviceList(w, r)
-	} else if strings.Contains(path, "api/xqnetwork/wan_info") {
-		ms.handleWanInfo(w, r)
-	} else if strings.Contains(path, "api/xqnetwork/wifi_detail_all") {
-		ms.handleWifiDetails(w, r)
-	} else {
+	handler, ok := ms.endpoints()[endpoint]
+	if !ok {
 		http.NotFound(w, r)
+		return
 	}
+	
+	ms.serveWithFaults(w, r, endpoint, func(w http.ResponseWriter, r *http.Request) {
+		if !ms.checkToken(w, r) {
+			return
+		}
+		
+		// 场景文件中定义的错误和原始响应优先
+		if ms.serveScenario(w, endpoint) {
+			return
+		}
+		
+		handler(w, r)
+	})
 }
 
 // handleSystemStatus 处理系统状态请求
 func (ms *MockServer) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
+	if !ms.randomize() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ms.systemInfo)
+		return
+	}
+	
 	// 随机变化一些数据以模拟真实环境
 	ms.systemInfo.CPU.Load = 20 + rand.Float64()*20
 	ms.systemInfo.Mem.Usage = 0.5 + rand.Float64()*0.3
 	ms.systemInfo.Wan.UpSpeed = fmt.Sprintf("%.1f", 80+rand.Float64()*40)
 	ms.systemInfo.Wan.DownSpeed = fmt.Sprintf("%.1f", 150+rand.Float64()*100)
+	if ms.systemInfo.Conntrack != nil {
+		conntrack := ms.systemInfo.Conntrack
+		conntrack.Count = max(0, min(conntrack.Count+rand.Intn(201)-100, conntrack.Max))
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ms.systemInfo)
@@ -397,7 +572,7 @@ func (ms *MockServer) handleSystemStatus(w http.ResponseWriter, r *http.Request)
 // handleDeviceList 处理设备列表请求
 func (ms *MockServer) handleDeviceList(w http.ResponseWriter, r *http.Request) {
 	// 随机更新设备统计数据
-	for i := range ms.devices {
+	for i := 0; ms.randomize() && i < len(ms.devices); i++ {
 		device := &ms.devices[i]
 		device.Statistics.UpSpeed = fmt.Sprintf("%d", 500+rand.Intn(1000))
 		device.Statistics.DownSpeed = fmt.Sprintf("%d", 1000+rand.Intn(2000))
@@ -415,6 +590,13 @@ func (ms *MockServer) handleDeviceList(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// 按概率切换终端设备的在线状态，模拟设备上线和下线
+	for i := 0; ms.churn > 0 && i < len(ms.devices); i++ {
+		if ms.devices[i].IsAP == 0 && rand.Float64() < ms.churn {
+			ms.devices[i].Online = 1 - ms.devices[i].Online
+		}
+	}
+
 	response := map[string]interface{}{
 		"code": 0,
 		"list": ms.devices,
@@ -424,21 +606,205 @@ func (ms *MockServer) handleDeviceList(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleSysInfo 处理系统信息请求，返回每个CPU核心的负载
+func (ms *MockServer) handleSysInfo(w http.ResponseWriter, r *http.Request) {
+	loads := make([]float64, ms.systemInfo.CPU.Core)
+	for i := range loads {
+		loads[i] = ms.systemInfo.CPU.Load
+		if ms.randomize() {
+			loads[i] = 10 + rand.Float64()*50
+		}
+	}
+
+	response := map[string]interface{}{
+		"code": 0,
+		"cpu": map[string]interface{}{
+			"core":  ms.systemInfo.CPU.Core,
+			"hz":    ms.systemInfo.CPU.Hz,
+			"load":  ms.systemInfo.CPU.Load,
+			"loads": loads,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handlePortStatus 处理网口状态请求
+func (ms *MockServer) handlePortStatus(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"code": 0,
+		"ports": []map[string]interface{}{
+			{"port": 1, "name": "WAN", "link": 1, "speed": 1000, "duplex": "full"},
+			{"port": 2, "name": "LAN1", "link": 1, "speed": 2500, "duplex": "full"},
+			{"port": 3, "name": "LAN2", "link": 1, "speed": 100, "duplex": "half"},
+			{"port": 4, "name": "LAN3", "link": 0, "speed": 0, "duplex": ""},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleWPSStatus 处理WPS状态请求，status 为 0 表示关闭
+func (ms *MockServer) handleWPSStatus(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"code":   0,
+		"status": 0,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleWifiStatistics 处理无线接口统计请求，计数器随运行时间增长，
+// 5G 接口以数字返回，2.4G 接口以字符串返回，与不同固件的行为一致
+func (ms *MockServer) handleWifiStatistics(w http.ResponseWriter, r *http.Request) {
+	seconds := int64(time.Since(ms.started).Seconds()) + 1
+	radios := make([]map[string]interface{}, 0, len(ms.wifiInfo.Info))
+	for i, info := range ms.wifiInfo.Info {
+		// 2.4G 干扰更多，错误和重传比例更高
+		lossy := int64(i + 1)
+		counters := map[string]int64{
+			"rx_packets": seconds * 1200,
+			"tx_packets": seconds * 1500,
+			"rx_errors":  seconds * lossy / 10,
+			"tx_errors":  seconds * lossy / 50,
+			"rx_dropped": seconds * lossy / 100,
+			"tx_dropped": seconds * lossy / 200,
+			"tx_retries": seconds * 60 * lossy,
+		}
+		radio := map[string]interface{}{"ifname": info.IfName}
+		for name, value := range counters {
+			if i == 0 {
+				radio[name] = value
+			} else {
+				radio[name] = strconv.FormatInt(value, 10)
+			}
+		}
+		radios = append(radios, radio)
+	}
+
+	response := map[string]interface{}{
+		"code":   0,
+		"radios": radios,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleIPTV 处理IPTV设置请求，模拟通过VLAN 45 提供IPTV、VLAN 10 上网的运营商
+func (ms *MockServer) handleIPTV(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"code":          0,
+		"enable":        1,
+		"mode":          "vlan",
+		"port":          "4",
+		"internet_vlan": 10,
+		"iptv_vlan":     45,
+		"voip_vlan":     0,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleUPnP 处理UPnP端口映射请求，端口以数字和字符串两种形式返回
+func (ms *MockServer) handleUPnP(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"code":   0,
+		"status": 1,
+		"list": []map[string]interface{}{
+			{"protocol": "UDP", "rport": 3074, "ip": "192.168.31.120", "port": 3074, "name": "Xbox"},
+			{"protocol": "TCP", "rport": "51413", "ip": "192.168.31.36", "port": "51413", "name": "Transmission at 51413"},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleWakeOnLAN 处理网络唤醒请求，只记录日志，不发送唤醒包
+func (ms *MockServer) handleWakeOnLAN(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"code": 0}
+	mac := r.FormValue("mac")
+	if r.Method != http.MethodPost || mac == "" {
+		response = map[string]interface{}{"code": 1523, "msg": "参数错误"}
+	} else {
+		log.Printf("Wake-on-LAN requested for %s", mac)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSetMacFilter 处理禁止或恢复设备访问外网的请求，wan=0 表示禁止，只记录日志
+func (ms *MockServer) handleSetMacFilter(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"code": 0}
+	mac, wan := r.FormValue("mac"), r.FormValue("wan")
+	if r.Method != http.MethodPost || mac == "" || (wan != "0" && wan != "1") {
+		response = map[string]interface{}{"code": 1523, "msg": "参数错误"}
+	} else if wan == "0" {
+		log.Printf("Internet access blocked for %s", mac)
+	} else {
+		log.Printf("Internet access allowed for %s", mac)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // handleWanInfo 处理WAN信息请求
 func (ms *MockServer) handleWanInfo(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"code": 0,
 		"info": ms.wanInfo.Info,
 	}
+	if ms.dualWAN != "" {
+		response["dualwan"] = map[string]interface{}{"enable": 1, "mode": ms.dualWAN}
+		response["wans"] = ms.wanPorts()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// wanPorts 生成双WAN的两个端口，流量随运行时间增长，主备模式下只有主端口承载流量
+func (ms *MockServer) wanPorts() []map[string]interface{} {
+	seconds := int64(time.Since(ms.started).Seconds()) + 1
+	ports := make([]map[string]interface{}, 0, 2)
+	for i, name := range []string{"wan", "wan2"} {
+		active, share := 0, int64(0)
+		if ms.dualWAN == "balance" || i == 0 {
+			active, share = 1, int64(2-i)
+		}
+		info := map[string]interface{}{
+			"gateWay": fmt.Sprintf("100.%d.100.1", 100+i),
+			"status":  1,
+			"link":    1,
+			"uptime":  seconds,
+			"details": map[string]interface{}{"ifname": fmt.Sprintf("eth%d", 4+i), "wanType": []string{"pppoe", "dhcp"}[i]},
+			"ipv4":    []map[string]interface{}{{"ip": fmt.Sprintf("100.%d.100.100", 100+i), "mask": "255.255.255.0"}},
+		}
+		ports = append(ports, map[string]interface{}{
+			"name":      name,
+			"info":      info,
+			"active":    active,
+			"upspeed":   strconv.FormatInt(share*40000, 10),
+			"downspeed": strconv.FormatInt(share*250000, 10),
+			"upload":    share * seconds * 40000,
+			"download":  share * seconds * 250000,
+		})
+	}
+	return ports
+}
+
 // handleWifiDetails 处理WiFi详情请求
 func (ms *MockServer) handleWifiDetails(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"code": 0,
+		"bsd":  ms.wifiInfo.Bsd,
 		"info": ms.wifiInfo.Info,
 	}
 
@@ -446,15 +812,107 @@ func (ms *MockServer) handleWifiDetails(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleDiskInfo 处理USB存储信息请求
+func (ms *MockServer) handleDiskInfo(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"code": 0,
+		"disks": []map[string]interface{}{
+			{
+				"name":   "sda1",
+				"label":  "Media",
+				"mount":  "/mnt/sda1",
+				"fstype": "ext4",
+				"total":  "1000204886016",
+				"used":   "412316860416",
+			},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSambaStatus 处理Samba状态请求
+func (ms *MockServer) handleSambaStatus(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"code":   0,
+		"status": 1,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCheckROMUpdate 处理固件升级检查请求
+func (ms *MockServer) handleCheckROMUpdate(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"code": 0, "needUpdate": 0}
+	if ms.romUpdate != "" {
+		response["needUpdate"] = 1
+		response["version"] = ms.romUpdate
+		response["changeLog"] = "修复已知问题，提升系统稳定性"
+		response["size"] = 28311552
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func main() {
-	port := 8080
-	if len(os.Args) > 1 {
-		if p, err := strconv.Atoi(os.Args[1]); err == nil {
+	portFlag := flag.Int("port", 8080, "Port to listen on")
+	scenarioFile := flag.String("scenario", "", "YAML or JSON scenario file overriding the mock data")
+	var authOpts AuthOptions
+	flag.StringVar(&authOpts.Username, "username", "admin", "Username required to log in, any username is accepted when empty")
+	flag.StringVar(&authOpts.Password, "password", "", "Password required to log in, any password is accepted when empty")
+	flag.IntVar(&authOpts.EncryptMode, "encrypt-mode", 1, "newEncryptMode reported by init_info: 0 for SHA1, 1 for SHA256 password hashing")
+	flag.IntVar(&authOpts.ReportedEncryptMode, "report-encrypt-mode", -1, "newEncryptMode reported by init_info when it differs from -encrypt-mode, as on some ROM builds")
+	flag.IntVar(&authOpts.NonceType, "nonce-type", -1, "Only accept login nonces with this device type, -1 accepts any")
+	flag.IntVar(&authOpts.LockoutAfter, "lockout-after", 0, "Lock logins after this many consecutive failed attempts, 0 disables the lockout")
+	flag.DurationVar(&authOpts.LockoutDuration, "lockout-duration", time.Minute, "How long logins stay locked")
+	flag.DurationVar(&authOpts.TokenTTL, "token-ttl", 0, "Expire issued tokens after this duration, 0 disables expiry")
+	flag.IntVar(&authOpts.ExpireAfter, "expire-after", 0, "Expire issued tokens after this many API requests, 0 disables expiry")
+	seed := flag.Int64("seed", 1, "Random seed for fault injection and generated devices, fixed seeds make results reproducible")
+	latency := flag.Duration("latency", 0, "Artificial latency added to every API endpoint")
+	errorRate := flag.Float64("error-rate", 0, "Probability (0-1) of answering any API endpoint with HTTP 500")
+	var network NetworkOptions
+	flag.IntVar(&network.Devices, "devices", 0, "Generate this many synthetic client devices instead of the built-in list")
+	flag.IntVar(&network.MeshNodes, "mesh-nodes", 0, "Generate this many mesh satellite nodes and spread the generated devices across them")
+	flag.StringVar(&network.DualWAN, "dual-wan", "", "Report two WAN ports in this mode, balance or failover, instead of a single WAN")
+	flag.Float64Var(&network.Churn, "device-churn", 0, "Probability (0-1) of every client device going offline or online again on each device list request")
+	romUpdate := flag.String("rom-update", "", "Report this firmware version as available update, the firmware is up to date when empty")
+	flag.Parse()
+
+	// 兼容旧的用法: mock_server <port>
+	port := *portFlag
+	if flag.NArg() > 0 {
+		if p, err := strconv.Atoi(flag.Arg(0)); err == nil {
 			port = p
 		}
 	}
 
-	mockServer := NewMockServer(port)
+	var scenario *Scenario
+	if *scenarioFile != "" {
+		loaded, err := LoadScenario(*scenarioFile)
+		if err != nil {
+			log.Fatalf("Failed to load scenario: %v", err)
+		}
+		scenario = loaded
+		log.Printf("Loaded scenario from %s", *scenarioFile)
+	}
+
+	faults := make(map[string]Fault)
+	if scenario != nil {
+		for endpoint, fault := range scenario.Faults {
+			faults[endpoint] = fault
+		}
+	}
+	// 命令行参数作用于未单独配置的接口
+	if _, ok := faults["*"]; !ok && (*latency > 0 || *errorRate > 0) {
+		faults["*"] = Fault{Latency: Duration(*latency), ErrorRate: *errorRate}
+	}
+
+	network.Seed = *seed
+	mockServer := NewMockServer(port, scenario, authOpts, newFaultInjector(faults, *seed), network)
+	mockServer.romUpdate = *romUpdate
 	
 	log.Printf("Mock MiWiFi Server for Testing")
 	log.Printf("=================================")
@@ -463,7 +921,10 @@ func main() {
 	log.Printf("Configuration for exporter:")
 	log.Printf("  IP: localhost")
 	log.Printf("  Port: %d", port)
-	log.Printf("  Password: any_password")
+	log.Printf("  Password: any_password (or the value of -password)")
+	if network.Devices > 0 || network.MeshNodes > 0 {
+		log.Printf("  Generated network: %d device(s), %d mesh node(s)", network.Devices, network.MeshNodes)
+	}
 	log.Printf("=================================")
 
 	if err := mockServer.Start(); err != nil && err != http.ErrServerClosed {
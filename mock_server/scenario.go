@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario 场景文件，覆盖默认的模拟数据，用于复现用户反馈的固件数据
+type Scenario struct {
+	// 为 false 时返回固定数据，便于测试断言；未设置时加载场景后默认不随机化
+	Randomize *bool            `json:"randomize"`
+	Devices   []MockDevice     `json:"devices"`
+	WiFi      []MockWiFiDetail `json:"wifi"`
+	Wan       *MockWanDetail   `json:"wan"`
+	System    *MockSystemInfo  `json:"system"`
+	// 按接口名称(例如 misystem/status)直接返回的原始响应
+	Responses map[string]json.RawMessage `json:"responses"`
+	// 按接口名称返回的错误
+	Errors map[string]ErrorScenario `json:"errors"`
+	// 按接口名称注入的延迟和故障，"*" 作用于所有未单独配置的接口
+	Faults map[string]Fault `json:"faults"`
+}
+
+// ErrorScenario 描述一个接口的错误响应
+type ErrorScenario struct {
+	// HTTP 状态码，默认 200 (路由器通常以 200 返回业务错误)
+	Status int `json:"status"`
+	// 路由器业务错误码
+	Code int `json:"code"`
+	// 错误信息
+	Message string `json:"msg"`
+	// 原始响应体，设置后忽略 code 和 msg，可用于构造非法 JSON
+	Body string `json:"body"`
+}
+
+// LoadScenario 读取 YAML 或 JSON 场景文件
+func LoadScenario(path string) (*Scenario, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML 是 JSON 的超集，先解析为通用结构再转成 JSON，使字段名与接口 JSON 保持一致
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert scenario: %w", err)
+	}
+
+	scenario := &Scenario{}
+	if err := json.Unmarshal(normalized, scenario); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario: %w", err)
+	}
+
+	return scenario, nil
+}
+
+// applyScenario 用场景数据替换默认模拟数据
+func (ms *MockServer) applyScenario(scenario *Scenario) {
+	ms.scenario = scenario
+
+	if scenario.Devices != nil {
+		ms.devices = scenario.Devices
+	}
+	if scenario.WiFi != nil {
+		ms.wifiInfo.Info = scenario.WiFi
+	}
+	if scenario.Wan != nil {
+		ms.wanInfo.Info = *scenario.Wan
+	}
+	if scenario.System != nil {
+		ms.systemInfo = *scenario.System
+	}
+}
+
+// randomize 返回是否随机变化模拟数据
+func (ms *MockServer) randomize() bool {
+	if ms.scenario == nil {
+		return true
+	}
+	return ms.scenario.Randomize != nil && *ms.scenario.Randomize
+}
+
+// serveScenario 返回场景中为接口定义的错误或原始响应，已处理时返回 true
+func (ms *MockServer) serveScenario(w http.ResponseWriter, endpoint string) bool {
+	if ms.scenario == nil {
+		return false
+	}
+
+	if scenarioErr, ok := ms.scenario.Errors[endpoint]; ok {
+		status := scenarioErr.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if scenarioErr.Body != "" {
+			w.Write([]byte(scenarioErr.Body))
+			return true
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": scenarioErr.Code,
+			"msg":  scenarioErr.Message,
+		})
+		return true
+	}
+
+	if response, ok := ms.scenario.Responses[endpoint]; ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+		return true
+	}
+
+	return false
+}
@@ -2,35 +2,61 @@ package cache
 
 import (
 	"context"
+	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/helloworlde/miwifi-exporter/internal/models"
 )
 
-// RouterSmartCache is a specialized smart cache for router data
+// RouterSmartCache is a specialized smart cache for router data. The data
+// of each router is accessed through Router, keyed by the router identity.
 type RouterSmartCache struct {
 	cache      *SmartCache
 	ttl        time.Duration
 	preload    bool
 	mu         sync.RWMutex
 	background *BackgroundLoader
+	
+	// Stale-while-revalidate: expired entries are served for up to maxStale
+	// while refresh runs in the background
+	maxStale   time.Duration
+	refresh    RefreshFunc
+	refreshing atomic.Bool
+	
+	// lastScrape is when MarkScraped was last called, in Unix nanoseconds,
+	// so preloading can pause while nobody scrapes
+	lastScrape atomic.Int64
 }
 
-// BackgroundLoader handles background data loading
-type BackgroundLoader struct {
-	cache      *RouterSmartCache
-	dataLoader DataLoader
-	interval   time.Duration
-	stop       chan struct{}
+// RefreshFunc reloads the cached router data, used for background revalidation
+type RefreshFunc func(ctx context.Context) error
+
+// preloadTimeout bounds a single background preload or revalidation
+const preloadTimeout = 30 * time.Second
+
+// PreloadOptions configures the background loader
+type PreloadOptions struct {
+	// Interval between two preloads
+	Interval time.Duration
+	// Jitter shifts every interval by a random fraction of up to Jitter in
+	// either direction, so the router is not polled in lockstep
+	Jitter float64
+	// IdleAfter pauses preloading while MarkScraped was not called for this
+	// long, and until it was called once. Zero preloads regardless of
+	// scrapes.
+	IdleAfter time.Duration
 }
 
-// DataLoader defines the interface for loading router data
-type DataLoader interface {
-	GetSystemStatus(ctx context.Context) (*models.SystemStatus, error)
-	GetDeviceList(ctx context.Context) (*models.DeviceList, error)
-	GetWanInfo(ctx context.Context) (*models.WanInfo, error)
-	GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error)
+// BackgroundLoader refreshes the cached router data before it expires, so
+// scrapes do not wait for the router
+type BackgroundLoader struct {
+	cache   *RouterSmartCache
+	refresh RefreshFunc
+	options PreloadOptions
+	stop    chan struct{}
 }
 
 // NewRouterSmartCache creates a new smart router cache
@@ -42,8 +68,9 @@ func NewRouterSmartCache(ttl time.Duration, sizeLimit int, preload bool) *Router
 	}
 }
 
-// SetDataLoader sets the data loader for background preloading
-func (rc *RouterSmartCache) SetDataLoader(loader DataLoader, interval time.Duration) {
+// SetPreloader sets the function refreshing the cached data in the
+// background. It only runs if the cache was created with preload set.
+func (rc *RouterSmartCache) SetPreloader(refresh RefreshFunc, options PreloadOptions) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	
@@ -53,10 +80,10 @@ func (rc *RouterSmartCache) SetDataLoader(loader DataLoader, interval time.Durat
 	}
 	
 	rc.background = &BackgroundLoader{
-		cache:      rc,
-		dataLoader: loader,
-		interval:   interval,
-		stop:       make(chan struct{}),
+		cache:   rc,
+		refresh: refresh,
+		options: options,
+		stop:    make(chan struct{}),
 	}
 	
 	if rc.preload {
@@ -64,56 +91,272 @@ func (rc *RouterSmartCache) SetDataLoader(loader DataLoader, interval time.Durat
 	}
 }
 
+// SetStaleWhileRevalidate enables serving expired entries for up to maxStale
+// while refresh reloads them asynchronously. A zero maxStale disables it.
+func (rc *RouterSmartCache) SetStaleWhileRevalidate(maxStale time.Duration, refresh RefreshFunc) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	rc.maxStale = maxStale
+	rc.refresh = refresh
+}
+
+// get looks up a key, serving stale values and triggering a background
+// refresh when stale-while-revalidate is enabled
+func (rc *RouterSmartCache) get(key string) (interface{}, bool) {
+	value, age, found := rc.cache.GetWithAge(key)
+	if !found {
+		return nil, false
+	}
+	
+	if age <= rc.ttl {
+		return value, true
+	}
+	
+	rc.mu.RLock()
+	maxStale, refresh := rc.maxStale, rc.refresh
+	rc.mu.RUnlock()
+	
+	// Entries are only retained past ttl when stale serving is enabled
+	if maxStale <= 0 || refresh == nil || age > rc.ttl+maxStale {
+		return nil, false
+	}
+	
+	rc.revalidate(refresh)
+	return value, true
+}
+
+// set stores a value, retaining it long enough to be served stale
+func (rc *RouterSmartCache) set(key string, value interface{}) {
+	rc.mu.RLock()
+	retention := rc.ttl + rc.maxStale
+	rc.mu.RUnlock()
+	
+	rc.cache.Set(key, value, retention)
+}
+
+// revalidate starts a background refresh unless one is already running
+func (rc *RouterSmartCache) revalidate(refresh RefreshFunc) {
+	if !rc.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	
+	go func() {
+		defer rc.refreshing.Store(false)
+		
+		ctx, cancel := context.WithTimeout(context.Background(), preloadTimeout)
+		defer cancel()
+		
+		// Errors are ignored, the stale value stays until it exceeds max staleness
+		_ = refresh(ctx)
+	}()
+}
+
+// MarkScraped records that a client read the router data. Only scrapes
+// count, reads of the preloader and other background work do not keep
+// preloading going.
+func (rc *RouterSmartCache) MarkScraped() {
+	rc.lastScrape.Store(time.Now().UnixNano())
+}
+
+// Revalidating reports whether a background refresh is in progress
+func (rc *RouterSmartCache) Revalidating() bool {
+	return rc.refreshing.Load()
+}
+
+// routerKeySeparator separates the router identity from the entry name in
+// cache keys
+const routerKeySeparator = "/"
+
+// RouterEntries is the view of the cache holding the data of one router. The
+// entries of different routers never collide, each key is prefixed with the
+// router identity.
+type RouterEntries struct {
+	cache  *RouterSmartCache
+	router string
+}
+
+// Router returns the entries of the router identified by router, usually its
+// address
+func (rc *RouterSmartCache) Router(router string) RouterEntries {
+	return RouterEntries{cache: rc, router: router}
+}
+
+func (re RouterEntries) get(entry string) (interface{}, bool) {
+	return re.cache.get(re.router + routerKeySeparator + entry)
+}
+
+func (re RouterEntries) set(entry string, value interface{}) {
+	re.cache.set(re.router+routerKeySeparator+entry, value)
+}
+
 // GetSystemStatus retrieves system status from cache
-func (rc *RouterSmartCache) GetSystemStatus() (*models.SystemStatus, bool) {
-	if value, found := rc.cache.Get("system_status"); found {
+func (re RouterEntries) GetSystemStatus() (*models.SystemStatus, bool) {
+	if value, found := re.get("system_status"); found {
 		return value.(*models.SystemStatus), true
 	}
 	return nil, false
 }
 
 // SetSystemStatus stores system status in cache
-func (rc *RouterSmartCache) SetSystemStatus(value *models.SystemStatus) {
-	rc.cache.Set("system_status", value, rc.ttl)
+func (re RouterEntries) SetSystemStatus(value *models.SystemStatus) {
+	re.set("system_status", value)
 }
 
 // GetDeviceList retrieves device list from cache
-func (rc *RouterSmartCache) GetDeviceList() (*models.DeviceList, bool) {
-	if value, found := rc.cache.Get("device_list"); found {
+func (re RouterEntries) GetDeviceList() (*models.DeviceList, bool) {
+	if value, found := re.get("device_list"); found {
 		return value.(*models.DeviceList), true
 	}
 	return nil, false
 }
 
 // SetDeviceList stores device list in cache
-func (rc *RouterSmartCache) SetDeviceList(value *models.DeviceList) {
-	rc.cache.Set("device_list", value, rc.ttl)
+func (re RouterEntries) SetDeviceList(value *models.DeviceList) {
+	re.set("device_list", value)
 }
 
 // GetWanInfo retrieves WAN info from cache
-func (rc *RouterSmartCache) GetWanInfo() (*models.WanInfo, bool) {
-	if value, found := rc.cache.Get("wan_info"); found {
+func (re RouterEntries) GetWanInfo() (*models.WanInfo, bool) {
+	if value, found := re.get("wan_info"); found {
 		return value.(*models.WanInfo), true
 	}
 	return nil, false
 }
 
 // SetWanInfo stores WAN info in cache
-func (rc *RouterSmartCache) SetWanInfo(value *models.WanInfo) {
-	rc.cache.Set("wan_info", value, rc.ttl)
+func (re RouterEntries) SetWanInfo(value *models.WanInfo) {
+	re.set("wan_info", value)
 }
 
 // GetWifiDetails retrieves WiFi details from cache
-func (rc *RouterSmartCache) GetWifiDetails() (*models.WifiDetailAll, bool) {
-	if value, found := rc.cache.Get("wifi_details"); found {
+func (re RouterEntries) GetWifiDetails() (*models.WifiDetailAll, bool) {
+	if value, found := re.get("wifi_details"); found {
 		return value.(*models.WifiDetailAll), true
 	}
 	return nil, false
 }
 
 // SetWifiDetails stores WiFi details in cache
-func (rc *RouterSmartCache) SetWifiDetails(value *models.WifiDetailAll) {
-	rc.cache.Set("wifi_details", value, rc.ttl)
+func (re RouterEntries) SetWifiDetails(value *models.WifiDetailAll) {
+	re.set("wifi_details", value)
+}
+
+// GetDiskStatus retrieves USB disk status from cache
+func (re RouterEntries) GetDiskStatus() (*models.DiskStatus, bool) {
+	if value, found := re.get("disk_status"); found {
+		return value.(*models.DiskStatus), true
+	}
+	return nil, false
+}
+
+// SetDiskStatus stores USB disk status in cache
+func (re RouterEntries) SetDiskStatus(value *models.DiskStatus) {
+	re.set("disk_status", value)
+}
+
+// GetSambaStatus retrieves Samba status from cache
+func (re RouterEntries) GetSambaStatus() (*models.SambaStatus, bool) {
+	if value, found := re.get("samba_status"); found {
+		return value.(*models.SambaStatus), true
+	}
+	return nil, false
+}
+
+// SetSambaStatus stores Samba status in cache
+func (re RouterEntries) SetSambaStatus(value *models.SambaStatus) {
+	re.set("samba_status", value)
+}
+
+// GetSysInfo retrieves system info from cache
+func (re RouterEntries) GetSysInfo() (*models.SysInfo, bool) {
+	if value, found := re.get("sys_info"); found {
+		return value.(*models.SysInfo), true
+	}
+	return nil, false
+}
+
+// SetSysInfo stores system info in cache
+func (re RouterEntries) SetSysInfo(value *models.SysInfo) {
+	re.set("sys_info", value)
+}
+
+// GetPortStatus retrieves Ethernet port status from cache
+func (re RouterEntries) GetPortStatus() (*models.PortStatus, bool) {
+	if value, found := re.get("port_status"); found {
+		return value.(*models.PortStatus), true
+	}
+	return nil, false
+}
+
+// SetPortStatus stores Ethernet port status in cache
+func (re RouterEntries) SetPortStatus(value *models.PortStatus) {
+	re.set("port_status", value)
+}
+
+// GetWPSStatus retrieves WPS status from cache
+func (re RouterEntries) GetWPSStatus() (*models.WPSStatus, bool) {
+	if value, found := re.get("wps_status"); found {
+		return value.(*models.WPSStatus), true
+	}
+	return nil, false
+}
+
+// SetWPSStatus stores WPS status in cache
+func (re RouterEntries) SetWPSStatus(value *models.WPSStatus) {
+	re.set("wps_status", value)
+}
+
+// GetTopoGraph retrieves mesh topology from cache
+func (re RouterEntries) GetTopoGraph() (*models.TopoGraph, bool) {
+	if value, found := re.get("topo_graph"); found {
+		return value.(*models.TopoGraph), true
+	}
+	return nil, false
+}
+
+// SetTopoGraph stores mesh topology in cache
+func (re RouterEntries) SetTopoGraph(value *models.TopoGraph) {
+	re.set("topo_graph", value)
+}
+
+// GetWifiStatistics retrieves WiFi radio statistics from cache
+func (re RouterEntries) GetWifiStatistics() (*models.WifiStatistics, bool) {
+	if value, found := re.get("wifi_statistics"); found {
+		return value.(*models.WifiStatistics), true
+	}
+	return nil, false
+}
+
+// SetWifiStatistics stores WiFi radio statistics in cache
+func (re RouterEntries) SetWifiStatistics(value *models.WifiStatistics) {
+	re.set("wifi_statistics", value)
+}
+
+// GetIPTVStatus retrieves IPTV status from cache
+func (re RouterEntries) GetIPTVStatus() (*models.IPTVStatus, bool) {
+	if value, found := re.get("iptv_status"); found {
+		return value.(*models.IPTVStatus), true
+	}
+	return nil, false
+}
+
+// SetIPTVStatus stores IPTV status in cache
+func (re RouterEntries) SetIPTVStatus(value *models.IPTVStatus) {
+	re.set("iptv_status", value)
+}
+
+// GetUPnPStatus retrieves UPnP mappings from cache
+func (re RouterEntries) GetUPnPStatus() (*models.UPnPStatus, bool) {
+	if value, found := re.get("upnp_status"); found {
+		return value.(*models.UPnPStatus), true
+	}
+	return nil, false
+}
+
+// SetUPnPStatus stores UPnP mappings in cache
+func (re RouterEntries) SetUPnPStatus(value *models.UPnPStatus) {
+	re.set("upnp_status", value)
 }
 
 // GetStats returns cache statistics
@@ -121,6 +364,42 @@ func (rc *RouterSmartCache) GetStats() *CacheStats {
 	return rc.cache.GetStats()
 }
 
+// GetKeyStats returns per-key access statistics, keyed by router and cache
+// entry name as in "192.168.31.1/wan_info"
+func (rc *RouterSmartCache) GetKeyStats() map[string]KeyStats {
+	return rc.cache.GetAllKeyStats()
+}
+
+// GetRouterStats returns the statistics of the entries of every router seen
+// so far, keyed by router. Evictions are only counted for the whole cache.
+func (rc *RouterSmartCache) GetRouterStats() map[string]*CacheStats {
+	stats := make(map[string]*CacheStats)
+	routerStats := func(key string) *CacheStats {
+		router, _, _ := strings.Cut(key, routerKeySeparator)
+		s, ok := stats[router]
+		if !ok {
+			s = &CacheStats{}
+			stats[router] = s
+		}
+		return s
+	}
+	
+	for key, ks := range rc.cache.GetAllKeyStats() {
+		s := routerStats(key)
+		s.Hits += ks.Hits
+		s.Misses += ks.Misses
+	}
+	for _, key := range rc.cache.Keys() {
+		routerStats(key).Size++
+	}
+	for _, s := range stats {
+		if total := s.Hits + s.Misses; total > 0 {
+			s.HitRate = float64(s.Hits) / float64(total)
+		}
+	}
+	return stats
+}
+
 // Clear clears all cached data
 func (rc *RouterSmartCache) Clear() {
 	rc.cache.Clear()
@@ -139,81 +418,22 @@ func (rc *RouterSmartCache) Stop() {
 	rc.cache.Stop()
 }
 
-// PreloadData preloads all data into cache
-func (rc *RouterSmartCache) PreloadData(ctx context.Context, loader DataLoader) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, 4)
-	
-	wg.Add(4)
-	
-	// Load system status
-	go func() {
-		defer wg.Done()
-		if status, err := loader.GetSystemStatus(ctx); err == nil {
-			rc.SetSystemStatus(status)
-		} else {
-			errChan <- err
-		}
-	}()
-	
-	// Load device list
-	go func() {
-		defer wg.Done()
-		if devices, err := loader.GetDeviceList(ctx); err == nil {
-			rc.SetDeviceList(devices)
-		} else {
-			errChan <- err
-		}
-	}()
-	
-	// Load WAN info
-	go func() {
-		defer wg.Done()
-		if wan, err := loader.GetWanInfo(ctx); err == nil {
-			rc.SetWanInfo(wan)
-		} else {
-			errChan <- err
-		}
-	}()
-	
-	// Load WiFi details
-	go func() {
-		defer wg.Done()
-		if wifi, err := loader.GetWifiDetails(ctx); err == nil {
-			rc.SetWifiDetails(wifi)
-		} else {
-			errChan <- err
-		}
-	}()
-	
-	wg.Wait()
-	close(errChan)
-	
-	// Check for errors
-	for err := range errChan {
-		if err != nil {
-			return err
-		}
-	}
-	
-	return nil
-}
-
-// Start starts the background loader
+// Start runs the background loader until Stop is called
 func (bl *BackgroundLoader) Start() {
-	ticker := time.NewTicker(bl.interval)
+	timer := time.NewTimer(bl.nextDelay())
+	defer timer.Stop()
 	
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
+	for {
+		select {
+		case <-timer.C:
+			if bl.active() {
 				bl.preloadData()
-			case <-bl.stop:
-				ticker.Stop()
-				return
 			}
+			timer.Reset(bl.nextDelay())
+		case <-bl.stop:
+			return
 		}
-	}()
+	}
 }
 
 // Stop stops the background loader
@@ -221,11 +441,36 @@ func (bl *BackgroundLoader) Stop() {
 	close(bl.stop)
 }
 
-// preloadData preloads data in the background
+// nextDelay returns the interval shifted by a random jitter
+func (bl *BackgroundLoader) nextDelay() time.Duration {
+	delay := bl.options.Interval
+	if jitter := bl.options.Jitter; jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * jitter * float64(delay))
+	}
+	return max(delay, time.Second)
+}
+
+// active reports whether the data was scraped recently enough to keep it
+// fresh
+func (bl *BackgroundLoader) active() bool {
+	if bl.options.IdleAfter <= 0 {
+		return true
+	}
+	lastScrape := bl.cache.lastScrape.Load()
+	return lastScrape != 0 && time.Since(time.Unix(0, lastScrape)) <= bl.options.IdleAfter
+}
+
+// preloadData refreshes the data unless a stale-while-revalidate refresh is
+// already doing so
 func (bl *BackgroundLoader) preloadData() {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if !bl.cache.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer bl.cache.refreshing.Store(false)
+	
+	ctx, cancel := context.WithTimeout(context.Background(), preloadTimeout)
 	defer cancel()
 	
-	// Ignore errors in background loading
-	_ = bl.cache.PreloadData(ctx, bl.dataLoader)
-}
\ No newline at end of file
+	// Errors are ignored, the next scrape fetches the data itself
+	_ = bl.refresh(ctx)
+}
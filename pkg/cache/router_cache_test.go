@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+func TestRouterSmartCacheKeysByRouter(t *testing.T) {
+	rc := NewRouterSmartCache(time.Minute, 0, false)
+	defer rc.Stop()
+
+	first, second := rc.Router("192.168.31.1"), rc.Router("192.168.32.1")
+	first.SetWanInfo(&models.WanInfo{Info: models.WanInfoDetails{Mac: "first"}})
+	second.SetWanInfo(&models.WanInfo{Info: models.WanInfoDetails{Mac: "second"}})
+
+	if info, ok := first.GetWanInfo(); !ok || info.Info.Mac != "first" {
+		t.Fatalf("first router got %+v, %v, want its own WAN info", info, ok)
+	}
+	if info, ok := second.GetWanInfo(); !ok || info.Info.Mac != "second" {
+		t.Fatalf("second router got %+v, %v, want its own WAN info", info, ok)
+	}
+	if _, ok := second.GetDeviceList(); ok {
+		t.Fatal("expected a miss for an entry that was never set")
+	}
+
+	stats := rc.GetRouterStats()
+	if len(stats) != 2 {
+		t.Fatalf("got stats for %d routers, want 2", len(stats))
+	}
+	if s := stats["192.168.31.1"]; s.Hits != 1 || s.Misses != 0 || s.Size != 1 {
+		t.Fatalf("first router stats %+v, want 1 hit, 0 misses, 1 entry", s)
+	}
+	if s := stats["192.168.32.1"]; s.Hits != 1 || s.Misses != 1 || s.Size != 1 || s.HitRate != 0.5 {
+		t.Fatalf("second router stats %+v, want 1 hit, 1 miss, 1 entry", s)
+	}
+}
+
+// TestPreloadPausesWithoutScrapes runs the preloader for a few seconds and
+// is skipped with -short
+func TestPreloadPausesWithoutScrapes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("preloader timing skipped in short mode")
+	}
+
+	rc := NewRouterSmartCache(time.Minute, 0, true)
+	defer rc.Stop()
+
+	var preloads atomic.Int64
+	entries := rc.Router("192.168.31.1")
+	rc.SetPreloader(func(ctx context.Context) error {
+		// Reading the cache from the preloader is no scrape
+		entries.GetWanInfo()
+		entries.SetWanInfo(&models.WanInfo{})
+		preloads.Add(1)
+		return nil
+	}, PreloadOptions{Interval: time.Second, IdleAfter: 1500 * time.Millisecond})
+
+	// Nothing is preloaded before the first scrape, reads alone do not count
+	entries.GetWanInfo()
+	time.Sleep(1200 * time.Millisecond)
+	if n := preloads.Load(); n != 0 {
+		t.Fatalf("preloaded %d times before the first scrape, want 0", n)
+	}
+
+	rc.MarkScraped()
+	deadline := time.Now().Add(5 * time.Second)
+	for preloads.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if preloads.Load() == 0 {
+		t.Fatal("no preload after a scrape")
+	}
+
+	// IdleAfter after the scrape preloading stops, although the preloader
+	// keeps reading the cache
+	time.Sleep(1500 * time.Millisecond)
+	paused := preloads.Load()
+	time.Sleep(1500 * time.Millisecond)
+	if n := preloads.Load(); n != paused {
+		t.Fatalf("preloaded %d more times without scrapes, want preloading paused", n-paused)
+	}
+}
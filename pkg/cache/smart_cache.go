@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,10 +14,10 @@ type SmartCache struct {
 	ttl        time.Duration
 	cleanup    *time.Ticker
 	stop       chan struct{}
-	stats      *CacheStats
-	hits       int64
-	misses     int64
-	evictions  int64
+	hits       atomic.Int64
+	misses     atomic.Int64
+	evictions  atomic.Int64
+	keyStats   sync.Map // map[string]*keyCounters
 	sizeLimit  int
 	cleanupCtx context.Context
 	cleanupCancel context.CancelFunc
@@ -31,12 +32,29 @@ type CacheStats struct {
 	HitRate   float64 `json:"hit_rate"`
 }
 
-// SmartCacheItem represents a cached item with metadata
+// KeyStats represents access statistics of a single cache key
+type KeyStats struct {
+	Hits       int64     `json:"hits"`
+	Misses     int64     `json:"misses"`
+	HitRate    float64   `json:"hit_rate"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+// keyCounters holds the per-key counters, updated without the cache lock
+type keyCounters struct {
+	hits       atomic.Int64
+	misses     atomic.Int64
+	lastAccess atomic.Int64 // unix nanoseconds
+}
+
+// SmartCacheItem represents a cached item with metadata. Items are immutable
+// after insertion except for the atomic access fields.
 type SmartCacheItem struct {
 	value       interface{}
 	expiration  time.Time
-	accessed    time.Time
-	accessCount int64
+	created     time.Time
+	accessed    atomic.Int64 // unix nanoseconds
+	accessCount atomic.Int64
 	size        int
 }
 
@@ -48,7 +66,6 @@ func NewSmartCache(ttl time.Duration, sizeLimit int) *SmartCache {
 		items:      make(map[string]*SmartCacheItem),
 		ttl:        ttl,
 		stop:       make(chan struct{}),
-		stats:      &CacheStats{},
 		sizeLimit:  sizeLimit,
 		cleanupCtx: ctx,
 		cleanupCancel: cancel,
@@ -63,30 +80,78 @@ func NewSmartCache(ttl time.Duration, sizeLimit int) *SmartCache {
 
 // Get retrieves a value from cache with access tracking
 func (sc *SmartCache) Get(key string) (interface{}, bool) {
+	value, _, found := sc.GetWithAge(key)
+	return value, found
+}
+
+// GetWithAge retrieves a value from cache together with the time elapsed since it was stored
+func (sc *SmartCache) GetWithAge(key string) (interface{}, time.Duration, bool) {
 	sc.mu.RLock()
-	
 	item, found := sc.items[key]
-	if !found {
-		sc.mu.RUnlock()
-		sc.misses++
-		return nil, false
-	}
+	sc.mu.RUnlock()
 	
-	// Check if expired
-	if item.IsExpired() {
-		sc.mu.RUnlock()
-		sc.deleteExpired(key)
-		sc.misses++
-		return nil, false
+	now := time.Now()
+	counters := sc.keyCounters(key)
+	counters.lastAccess.Store(now.UnixNano())
+	
+	if !found || item.IsExpired() {
+		if found {
+			sc.deleteExpired(key, item)
+		}
+		sc.misses.Add(1)
+		counters.misses.Add(1)
+		return nil, 0, false
 	}
 	
 	// Update access statistics
-	item.accessed = time.Now()
-	item.accessCount++
-	sc.hits++
+	item.accessed.Store(now.UnixNano())
+	item.accessCount.Add(1)
+	sc.hits.Add(1)
+	counters.hits.Add(1)
 	
-	sc.mu.RUnlock()
-	return item.value, true
+	return item.value, now.Sub(item.created), true
+}
+
+// keyCounters returns the statistics of a key, creating them on first access
+func (sc *SmartCache) keyCounters(key string) *keyCounters {
+	if counters, ok := sc.keyStats.Load(key); ok {
+		return counters.(*keyCounters)
+	}
+	counters, _ := sc.keyStats.LoadOrStore(key, &keyCounters{})
+	return counters.(*keyCounters)
+}
+
+// GetKeyStats returns the access statistics of a single key
+func (sc *SmartCache) GetKeyStats(key string) (KeyStats, bool) {
+	counters, ok := sc.keyStats.Load(key)
+	if !ok {
+		return KeyStats{}, false
+	}
+	return counters.(*keyCounters).snapshot(), true
+}
+
+// GetAllKeyStats returns the access statistics of every key seen so far
+func (sc *SmartCache) GetAllKeyStats() map[string]KeyStats {
+	stats := make(map[string]KeyStats)
+	sc.keyStats.Range(func(key, counters interface{}) bool {
+		stats[key.(string)] = counters.(*keyCounters).snapshot()
+		return true
+	})
+	return stats
+}
+
+func (kc *keyCounters) snapshot() KeyStats {
+	stats := KeyStats{
+		Hits:   kc.hits.Load(),
+		Misses: kc.misses.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	if nanos := kc.lastAccess.Load(); nanos > 0 {
+		stats.LastAccess = time.Unix(0, nanos)
+	}
+	return stats
 }
 
 // Set stores a value in cache with intelligent eviction
@@ -109,13 +174,15 @@ func (sc *SmartCache) Set(key string, value interface{}, ttl time.Duration) {
 		expiration = time.Now().Add(sc.ttl)
 	}
 	
-	sc.items[key] = &SmartCacheItem{
-		value:       value,
-		expiration:  expiration,
-		accessed:    time.Now(),
-		accessCount: 1,
-		size:        size,
+	item := &SmartCacheItem{
+		value:      value,
+		expiration: expiration,
+		created:    time.Now(),
+		size:       size,
 	}
+	item.accessed.Store(item.created.UnixNano())
+	item.accessCount.Store(1)
+	sc.items[key] = item
 }
 
 // Delete removes a value from cache
@@ -125,7 +192,7 @@ func (sc *SmartCache) Delete(key string) {
 	
 	if _, exists := sc.items[key]; exists {
 		delete(sc.items, key)
-		sc.evictions++
+		sc.evictions.Add(1)
 	}
 }
 
@@ -134,30 +201,44 @@ func (sc *SmartCache) Clear() {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 	
+	sc.evictions.Add(int64(len(sc.items)))
 	sc.items = make(map[string]*SmartCacheItem)
-	sc.evictions += int64(len(sc.items))
 }
 
 // GetStats returns cache statistics
 func (sc *SmartCache) GetStats() *CacheStats {
 	sc.mu.RLock()
-	defer sc.mu.RUnlock()
+	size := len(sc.items)
+	sc.mu.RUnlock()
 	
-	total := sc.hits + sc.misses
+	hits, misses := sc.hits.Load(), sc.misses.Load()
+	total := hits + misses
 	hitRate := 0.0
 	if total > 0 {
-		hitRate = float64(sc.hits) / float64(total)
+		hitRate = float64(hits) / float64(total)
 	}
 	
 	return &CacheStats{
-		Hits:      sc.hits,
-		Misses:    sc.misses,
-		Evictions: sc.evictions,
-		Size:      len(sc.items),
+		Hits:      hits,
+		Misses:    misses,
+		Evictions: sc.evictions.Load(),
+		Size:      size,
 		HitRate:   hitRate,
 	}
 }
 
+// Keys returns the keys of the items held, counted as in the cache size
+func (sc *SmartCache) Keys() []string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	
+	keys := make([]string, 0, len(sc.items))
+	for key := range sc.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 // cleanupRoutine periodically cleans up expired items
 func (sc *SmartCache) cleanupRoutine() {
 	for {
@@ -180,7 +261,7 @@ func (sc *SmartCache) cleanupExpired() {
 	for key, item := range sc.items {
 		if item.IsExpired() {
 			delete(sc.items, key)
-			sc.evictions++
+			sc.evictions.Add(1)
 		}
 	}
 }
@@ -193,13 +274,14 @@ func (sc *SmartCache) evictLRU(count int) {
 	
 	// Find items with oldest access time
 	var oldestKeys []string
-	oldestTime := time.Now()
+	oldestTime := time.Now().UnixNano()
 	
 	for key, item := range sc.items {
-		if item.accessed.Before(oldestTime) || len(oldestKeys) == 0 {
+		accessed := item.accessed.Load()
+		if accessed < oldestTime || len(oldestKeys) == 0 {
 			oldestKeys = []string{key}
-			oldestTime = item.accessed
-		} else if item.accessed.Equal(oldestTime) {
+			oldestTime = accessed
+		} else if accessed == oldestTime {
 			oldestKeys = append(oldestKeys, key)
 		}
 	}
@@ -207,7 +289,7 @@ func (sc *SmartCache) evictLRU(count int) {
 	// Evict items
 	for i := 0; i < len(oldestKeys) && i < count; i++ {
 		delete(sc.items, oldestKeys[i])
-		sc.evictions++
+		sc.evictions.Add(1)
 	}
 }
 
@@ -218,14 +300,15 @@ func (sc *SmartCache) Stop() {
 	sc.cleanup.Stop()
 }
 
-// deleteExpired safely deletes an expired item
-func (sc *SmartCache) deleteExpired(key string) {
+// deleteExpired safely deletes an expired item unless it was replaced
+// after the caller observed it
+func (sc *SmartCache) deleteExpired(key string, expired *SmartCacheItem) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 	
-	if _, exists := sc.items[key]; exists {
+	if current, exists := sc.items[key]; exists && current == expired {
 		delete(sc.items, key)
-		sc.evictions++
+		sc.evictions.Add(1)
 	}
 }
 
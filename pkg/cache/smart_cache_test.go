@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSmartCacheConcurrentAccess hammers the cache from many goroutines. Run
+// with -race to verify the statistics are updated without data races.
+func TestSmartCacheConcurrentAccess(t *testing.T) {
+	sc := NewSmartCache(time.Millisecond*50, 16)
+	defer sc.Stop()
+
+	const (
+		workers    = 32
+		iterations = 2000
+		keys       = 24
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				key := fmt.Sprintf("key-%d", (w+i)%keys)
+				switch i % 10 {
+				case 0:
+					sc.Set(key, i, 0)
+				case 1:
+					sc.Delete(key)
+				case 2:
+					sc.GetStats()
+				case 3:
+					sc.GetKeyStats(key)
+				case 4:
+					sc.GetAllKeyStats()
+				default:
+					sc.Get(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := sc.GetStats()
+	gets := int64(workers * iterations * 5 / 10)
+	if stats.Hits+stats.Misses != gets {
+		t.Fatalf("hits+misses = %d, want %d", stats.Hits+stats.Misses, gets)
+	}
+	if stats.Size > 16 {
+		t.Fatalf("size = %d exceeds limit 16", stats.Size)
+	}
+
+	var keyHits, keyMisses int64
+	for _, ks := range sc.GetAllKeyStats() {
+		keyHits += ks.Hits
+		keyMisses += ks.Misses
+	}
+	if keyHits != stats.Hits || keyMisses != stats.Misses {
+		t.Fatalf("per-key totals %d/%d do not match cache totals %d/%d", keyHits, keyMisses, stats.Hits, stats.Misses)
+	}
+}
+
+func TestSmartCacheKeyStats(t *testing.T) {
+	sc := NewSmartCache(time.Minute, 0)
+	defer sc.Stop()
+
+	if _, ok := sc.GetKeyStats("wan_info"); ok {
+		t.Fatal("expected no stats for an unseen key")
+	}
+
+	sc.Get("wan_info")
+	sc.Set("wan_info", "value", 0)
+	sc.Get("wan_info")
+	sc.Get("wan_info")
+
+	ks, ok := sc.GetKeyStats("wan_info")
+	if !ok {
+		t.Fatal("expected stats for wan_info")
+	}
+	if ks.Hits != 2 || ks.Misses != 1 {
+		t.Fatalf("got hits=%d misses=%d, want 2/1", ks.Hits, ks.Misses)
+	}
+	if ks.HitRate < 0.66 || ks.HitRate > 0.67 {
+		t.Fatalf("unexpected hit rate %f", ks.HitRate)
+	}
+	if ks.LastAccess.IsZero() {
+		t.Fatal("expected last access time to be set")
+	}
+}
+
+func TestSmartCacheExpiredEntryReplacedConcurrently(t *testing.T) {
+	sc := NewSmartCache(time.Minute, 0)
+	defer sc.Stop()
+
+	sc.Set("system_status", "old", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found := sc.Get("system_status"); found {
+		t.Fatal("expected expired entry to miss")
+	}
+
+	sc.Set("system_status", "new", 0)
+	if value, found := sc.Get("system_status"); !found || value != "new" {
+		t.Fatalf("got %v/%v, want new/true", value, found)
+	}
+}
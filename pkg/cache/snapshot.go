@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotFile is the on-disk layout of a persisted snapshot
+type snapshotFile struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// SaveSnapshot writes data as JSON to path. The file is replaced atomically so
+// a crash never leaves a truncated snapshot behind.
+func SaveSnapshot(path string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	content, err := json.Marshal(snapshotFile{SavedAt: time.Now(), Data: payload})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot into data and returns
+// the time it was saved
+func LoadSnapshot(path string, data interface{}) (time.Time, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var file snapshotFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	if err := json.Unmarshal(file.Data, data); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode snapshot data: %w", err)
+	}
+
+	return file.SavedAt, nil
+}
@@ -6,103 +6,189 @@ import (
 	"sync"
 	"time"
 
+	"github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
 	"github.com/helloworlde/miwifi-exporter/internal/models"
+	"github.com/helloworlde/miwifi-exporter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // DataFetcher handles concurrent data fetching from router
 type DataFetcher struct {
 	timeout      time.Duration
-	maxRetries   int
+	retries      int
 	retryDelay   time.Duration
+	maxRetryDelay time.Duration
+	parallelism  int
+	tasks        []FetchTask
+	observer     TaskObserver
+	// pool runs the tasks when set, shared with the fetchers of other
+	// routers; otherwise every fetch starts its own workers
+	pool         *WorkerPool
+	poolKey      string
 }
 
-// NewDataFetcher creates a new data fetcher
-func NewDataFetcher(timeout time.Duration, maxRetries int, retryDelay time.Duration) *DataFetcher {
+// TaskObserver is notified after every fetch task with its duration, the
+// number of attempts made and the final error
+type TaskObserver func(task string, duration time.Duration, attempts int, err error)
+
+// NewDataFetcher creates a new data fetcher running the registered fetch
+// tasks. Failed tasks with Retry set are repeated up to retries times, the
+// delay starting at retryDelay and doubling up to 10 times retryDelay.
+func NewDataFetcher(timeout time.Duration, retries int, retryDelay time.Duration) *DataFetcher {
 	return &DataFetcher{
-		timeout:    timeout,
-		maxRetries: maxRetries,
-		retryDelay: retryDelay,
+		timeout:       timeout,
+		retries:       retries,
+		retryDelay:    retryDelay,
+		maxRetryDelay: 10 * retryDelay,
+		parallelism:   defaultParallelism,
+		tasks:         DefaultFetchTasks(),
+	}
+}
+
+// SetMaxRetryDelay caps the delay between retries
+func (df *DataFetcher) SetMaxRetryDelay(delay time.Duration) {
+	if delay > 0 {
+		df.maxRetryDelay = delay
+	}
+}
+
+// SetParallelism sets how many tasks run at the same time
+func (df *DataFetcher) SetParallelism(parallelism int) {
+	if parallelism > 0 {
+		df.parallelism = parallelism
+		if df.pool != nil {
+			df.pool.SetLimit(df.poolKey, parallelism)
+		}
+	}
+}
+
+// UsePool runs the tasks on the started, long-lived pool instead of workers
+// created for every fetch. key identifies the router in the pool, at most
+// the parallelism of this fetcher of its tasks run at once.
+func (df *DataFetcher) UsePool(pool *WorkerPool, key string) {
+	df.pool = pool
+	df.poolKey = key
+	pool.SetLimit(key, df.parallelism)
+}
+
+// AddTask adds a fetch task to this fetcher only
+func (df *DataFetcher) AddTask(task FetchTask) {
+	df.tasks = append(df.tasks, task)
+}
+
+// Tasks returns the fetch tasks run by this fetcher
+func (df *DataFetcher) Tasks() []FetchTask {
+	return df.tasks
+}
+
+// RemoveTask removes the named task from this fetcher. It reports whether
+// the task existed.
+func (df *DataFetcher) RemoveTask(name string) bool {
+	for i := range df.tasks {
+		if df.tasks[i].Name == name {
+			df.tasks = append(df.tasks[:i], df.tasks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetTaskTimeout overrides the timeout of the named task. It reports whether
+// the task exists.
+func (df *DataFetcher) SetTaskTimeout(name string, timeout time.Duration) bool {
+	for i := range df.tasks {
+		if df.tasks[i].Name == name {
+			df.tasks[i].Timeout = timeout
+			return true
+		}
+	}
+	return false
+}
+
+// SetTaskRetries overrides how often the named task is retried, zero turns
+// retries off. It reports whether the task exists.
+func (df *DataFetcher) SetTaskRetries(name string, retries int) bool {
+	for i := range df.tasks {
+		if df.tasks[i].Name == name {
+			df.tasks[i].Retry = retries > 0
+			df.tasks[i].Retries = retries
+			return true
+		}
 	}
+	return false
+}
+
+// SetTaskOptional marks the named task as best-effort or required. It reports
+// whether the task exists.
+func (df *DataFetcher) SetTaskOptional(name string, optional bool) bool {
+	for i := range df.tasks {
+		if df.tasks[i].Name == name {
+			df.tasks[i].Optional = optional
+			return true
+		}
+	}
+	return false
+}
+
+// SetObserver sets the callback receiving per-task durations
+func (df *DataFetcher) SetObserver(observer TaskObserver) {
+	df.observer = observer
 }
 
 // FetchData fetches all router data concurrently
-func (df *DataFetcher) FetchData(ctx context.Context, client RouterClient) (*RouterData, error) {
+func (df *DataFetcher) FetchData(ctx context.Context, client RouterClient) (*models.RouterData, error) {
 	ctx, cancel := context.WithTimeout(ctx, df.timeout)
 	defer cancel()
 	
 	// Create tasks for concurrent execution
-	tasks := []Task{
-		{
-			ID: 0,
-			Work: func() (interface{}, error) {
-				return df.fetchWithRetry(ctx, func() (interface{}, error) {
-					return client.GetSystemStatus(ctx)
-				})
-			},
-		},
-		{
-			ID: 1,
-			Work: func() (interface{}, error) {
-				return df.fetchWithRetry(ctx, func() (interface{}, error) {
-					return client.GetDeviceList(ctx)
-				})
-			},
-		},
-		{
-			ID: 2,
-			Work: func() (interface{}, error) {
-				return df.fetchWithRetry(ctx, func() (interface{}, error) {
-					return client.GetWanInfo(ctx)
-				})
+	tasks := make([]Task[storeFunc], len(df.tasks))
+	for i, fetchTask := range df.tasks {
+		fetchTask := fetchTask
+		tasks[i] = Task[storeFunc]{
+			ID: i,
+			Work: func() (storeFunc, error) {
+				return df.runTask(ctx, client, fetchTask)
 			},
-		},
-		{
-			ID: 3,
-			Work: func() (interface{}, error) {
-				return df.fetchWithRetry(ctx, func() (interface{}, error) {
-					return client.GetWifiDetails(ctx)
-				})
-			},
-		},
+		}
 	}
 	
 	// Execute tasks concurrently
-	results, err := ExecuteWithTimeout(ctx, tasks, df.timeout)
+	var results []Result[storeFunc]
+	var err error
+	if df.pool != nil {
+		results, err = Execute(ctx, df.pool, df.poolKey, tasks, df.timeout)
+	} else {
+		results, err = ExecuteWithLimit(ctx, tasks, df.timeout, df.parallelism)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch data concurrently: %w", err)
 	}
 	
 	// Process results
-	data := &RouterData{}
+	data := &models.RouterData{}
 	var firstError error
 	
-	for _, result := range results {
+	// Results are in the order of the tasks
+	for i, result := range results {
+		fetchTask := df.tasks[i]
+		if panicErr, ok := result.Error.(*PanicError); ok {
+			logger.Default.Errorf("Fetching %s panicked: %v\n%s", fetchTask.Name, panicErr.Value, panicErr.Stack)
+		}
 		if result.Error != nil {
+			if fetchTask.Optional {
+				continue
+			}
 			if firstError == nil {
-				firstError = result.Error
+				firstError = fmt.Errorf("%s: %w", fetchTask.Name, result.Error)
 			}
 			continue
 		}
 		
-		switch result.ID {
-		case 0:
-			if status, ok := result.Value.(*models.SystemStatus); ok {
-				data.SystemStatus = status
-			}
-		case 1:
-			if devices, ok := result.Value.(*models.DeviceList); ok {
-				data.DeviceList = devices
-			}
-		case 2:
-			if wan, ok := result.Value.(*models.WanInfo); ok {
-				data.WanInfo = wan
-			}
-		case 3:
-			if wifi, ok := result.Value.(*models.WifiDetailAll); ok {
-				data.WifiDetails = wifi
-			}
-		}
+		result.Value(data)
 	}
+	data.FetchedAt = time.Now()
+	data.Source = models.SourceLive
 	
 	if firstError != nil {
 		return data, fmt.Errorf("some data fetches failed: %w", firstError)
@@ -111,38 +197,70 @@ func (df *DataFetcher) FetchData(ctx context.Context, client RouterClient) (*Rou
 	return data, nil
 }
 
-// fetchWithRetry fetches data with retry logic
-func (df *DataFetcher) fetchWithRetry(ctx context.Context, fetchFunc func() (interface{}, error)) (interface{}, error) {
-	var lastError error
+// runTask runs a single fetch task with its own timeout and reports its duration
+func (df *DataFetcher) runTask(ctx context.Context, client RouterClient, task FetchTask) (storeFunc, error) {
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+	
+	ctx, span := tracing.Start(ctx, "fetch "+task.Name)
+	defer span.End()
+	span.SetAttributes(attribute.String("fetch.task", task.Name))
 	
-	for i := 0; i < df.maxRetries; i++ {
+	start := time.Now()
+	
+	var store storeFunc
+	var err error
+	attempts := 0
+	fetch := func() (storeFunc, error) {
+		attempts++
+		return task.Endpoint.fetch(ctx, client)
+	}
+	if task.Retry {
+		retries := df.retries
+		if task.Retries > 0 {
+			retries = task.Retries
+		}
+		store, err = df.fetchWithRetry(ctx, task.Name, retries, fetch)
+	} else {
+		store, err = fetch()
+	}
+	span.SetAttributes(
+		attribute.Int("fetch.attempts", attempts),
+		attribute.Bool("fetch.optional", task.Optional),
+	)
+	tracing.RecordError(span, err)
+	
+	if df.observer != nil {
+		df.observer(task.Name, time.Since(start), attempts, err)
+	}
+	
+	return store, err
+}
+
+// fetchWithRetry repeats fetchFunc up to retries times while it fails with a
+// retryable error. This is the only place router requests are retried: the
+// client itself only repeats a request once after logging in again.
+func (df *DataFetcher) fetchWithRetry(ctx context.Context, name string, retries int, fetchFunc func() (storeFunc, error)) (storeFunc, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := fetchFunc()
+		if err == nil || attempt == retries || !errors.Retryable(err) {
+			return result, err
+		}
+		
+		delay := errors.Backoff(attempt+1, df.retryDelay, df.maxRetryDelay)
+		logger.Default.Warnf("Fetching %s failed: %v, retry %d/%d in %v", name, err, attempt+1, retries, delay)
+		
+		timer := time.NewTimer(delay)
 		select {
+		case <-timer.C:
 		case <-ctx.Done():
+			timer.Stop()
 			return nil, ctx.Err()
-		default:
-			result, err := fetchFunc()
-			if err == nil {
-				return result, nil
-			}
-			
-			lastError = err
-			
-			// If this is the last retry, don't wait
-			if i == df.maxRetries-1 {
-				break
-			}
-			
-			// Wait before retrying
-			select {
-			case <-time.After(df.retryDelay):
-				continue
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			}
 		}
 	}
-	
-	return nil, lastError
 }
 
 // RouterClient defines the interface for router data fetching
@@ -151,19 +269,20 @@ type RouterClient interface {
 	GetDeviceList(ctx context.Context) (*models.DeviceList, error)
 	GetWanInfo(ctx context.Context) (*models.WanInfo, error)
 	GetWifiDetails(ctx context.Context) (*models.WifiDetailAll, error)
-}
-
-// RouterData contains all router data
-type RouterData struct {
-	SystemStatus *models.SystemStatus
-	DeviceList   *models.DeviceList
-	WanInfo      *models.WanInfo
-	WifiDetails  *models.WifiDetailAll
+	GetDiskStatus(ctx context.Context) (*models.DiskStatus, error)
+	GetSambaStatus(ctx context.Context) (*models.SambaStatus, error)
+	GetSysInfo(ctx context.Context) (*models.SysInfo, error)
+	GetPortStatus(ctx context.Context) (*models.PortStatus, error)
+	GetWPSStatus(ctx context.Context) (*models.WPSStatus, error)
+	GetTopoGraph(ctx context.Context) (*models.TopoGraph, error)
+	GetWifiStatistics(ctx context.Context) (*models.WifiStatistics, error)
+	GetIPTVStatus(ctx context.Context) (*models.IPTVStatus, error)
+	GetUPnPStatus(ctx context.Context) (*models.UPnPStatus, error)
 }
 
 // FetchResult represents the result of a fetch operation
 type FetchResult struct {
-	Data      *RouterData
+	Data      *models.RouterData
 	Duration  time.Duration
 	TimedOut  bool
 	Errors    []error
@@ -210,9 +329,9 @@ type FetchProgress struct {
 }
 
 // NewParallelFetcher creates a new parallel fetcher
-func NewParallelFetcher(timeout time.Duration, maxRetries int, retryDelay time.Duration) *ParallelFetcher {
+func NewParallelFetcher(timeout time.Duration, retries int, retryDelay time.Duration) *ParallelFetcher {
 	return &ParallelFetcher{
-		fetcher:  NewDataFetcher(timeout, maxRetries, retryDelay),
+		fetcher:  NewDataFetcher(timeout, retries, retryDelay),
 		progress: &FetchProgress{},
 	}
 }
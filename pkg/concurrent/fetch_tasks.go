@@ -0,0 +1,241 @@
+package concurrent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/models"
+)
+
+// FetchTask describes a single router endpoint fetched by the DataFetcher
+type FetchTask struct {
+	// Name identifies the task in metrics and logs
+	Name string
+	// Timeout bounds a single run of the task, zero uses the fetcher timeout
+	Timeout time.Duration
+	// Optional tasks may fail without failing the whole fetch
+	Optional bool
+	// Retry enables the fetcher retry policy for this task
+	Retry bool
+	// Retries overrides the number of retries of the fetcher, zero uses it
+	Retries int
+	// Endpoint fetches the response and stores it into the router data,
+	// usually an Endpoint[T]
+	Endpoint Fetcher
+}
+
+// Fetcher fetches a router endpoint. Implement it with Endpoint[T].
+type Fetcher interface {
+	// fetch calls the endpoint and returns the function storing its response
+	fetch(ctx context.Context, client RouterClient) (storeFunc, error)
+}
+
+// storeFunc saves a fetched response into the router data
+type storeFunc func(data *models.RouterData)
+
+// Endpoint is a router endpoint whose response has type T. The compiler
+// checks that Store takes what Fetch returns.
+type Endpoint[T any] struct {
+	// Fetch calls the router endpoint
+	Fetch func(ctx context.Context, client RouterClient) (T, error)
+	// Store saves a successful response into the router data
+	Store func(data *models.RouterData, value T)
+}
+
+func (e Endpoint[T]) fetch(ctx context.Context, client RouterClient) (storeFunc, error) {
+	value, err := e.Fetch(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return func(data *models.RouterData) {
+		e.Store(data, value)
+	}, nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []FetchTask
+)
+
+// RegisterFetchTask adds a task to the default task list used by new fetchers
+func RegisterFetchTask(task FetchTask) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = append(registry, task)
+}
+
+// DefaultFetchTasks returns a copy of the registered fetch tasks
+func DefaultFetchTasks() []FetchTask {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	tasks := make([]FetchTask, len(registry))
+	copy(tasks, registry)
+	return tasks
+}
+
+func init() {
+	RegisterFetchTask(FetchTask{
+		Name:  "system_status",
+		Retry: true,
+		Endpoint: Endpoint[*models.SystemStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.SystemStatus, error) {
+				return client.GetSystemStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.SystemStatus) {
+				data.SystemStatus = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:  "device_list",
+		Retry: true,
+		Endpoint: Endpoint[*models.DeviceList]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.DeviceList, error) {
+				return client.GetDeviceList(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.DeviceList) {
+				data.DeviceList = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:  "wan_info",
+		Retry: true,
+		Endpoint: Endpoint[*models.WanInfo]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.WanInfo, error) {
+				return client.GetWanInfo(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.WanInfo) {
+				data.WanInfo = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:  "wifi_details",
+		Retry: true,
+		Endpoint: Endpoint[*models.WifiDetailAll]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.WifiDetailAll, error) {
+				return client.GetWifiDetails(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.WifiDetailAll) {
+				data.WifiDetails = value
+			},
+		},
+	})
+
+	// Storage endpoints only exist on routers with USB ports
+	RegisterFetchTask(FetchTask{
+		Name:     "disk_status",
+		Optional: true,
+		Endpoint: Endpoint[*models.DiskStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.DiskStatus, error) {
+				return client.GetDiskStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.DiskStatus) {
+				data.DiskStatus = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:     "samba_status",
+		Optional: true,
+		Endpoint: Endpoint[*models.SambaStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.SambaStatus, error) {
+				return client.GetSambaStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.SambaStatus) {
+				data.SambaStatus = value
+			},
+		},
+	})
+
+	// Per-core CPU load is only reported by multi-core AX firmwares
+	RegisterFetchTask(FetchTask{
+		Name:     "sys_info",
+		Optional: true,
+		Endpoint: Endpoint[*models.SysInfo]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.SysInfo, error) {
+				return client.GetSysInfo(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.SysInfo) {
+				data.SysInfo = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:     "port_status",
+		Optional: true,
+		Endpoint: Endpoint[*models.PortStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.PortStatus, error) {
+				return client.GetPortStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.PortStatus) {
+				data.PortStatus = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:     "wps_status",
+		Optional: true,
+		Endpoint: Endpoint[*models.WPSStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.WPSStatus, error) {
+				return client.GetWPSStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.WPSStatus) {
+				data.WPSStatus = value
+			},
+		},
+	})
+	// Routers without satellites answer the topology with the main router only
+	RegisterFetchTask(FetchTask{
+		Name:     "topo_graph",
+		Optional: true,
+		Endpoint: Endpoint[*models.TopoGraph]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.TopoGraph, error) {
+				return client.GetTopoGraph(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.TopoGraph) {
+				data.TopoGraph = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:     "wifi_statistics",
+		Optional: true,
+		Endpoint: Endpoint[*models.WifiStatistics]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.WifiStatistics, error) {
+				return client.GetWifiStatistics(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.WifiStatistics) {
+				data.WifiStatistics = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:     "iptv_status",
+		Optional: true,
+		Endpoint: Endpoint[*models.IPTVStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.IPTVStatus, error) {
+				return client.GetIPTVStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.IPTVStatus) {
+				data.IPTVStatus = value
+			},
+		},
+	})
+	RegisterFetchTask(FetchTask{
+		Name:     "upnp_status",
+		Optional: true,
+		Endpoint: Endpoint[*models.UPnPStatus]{
+			Fetch: func(ctx context.Context, client RouterClient) (*models.UPnPStatus, error) {
+				return client.GetUPnPStatus(ctx)
+			},
+			Store: func(data *models.RouterData, value *models.UPnPStatus) {
+				data.UPnPStatus = value
+			},
+		},
+	})
+}
@@ -2,44 +2,136 @@ package concurrent
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// WorkerPool represents a pool of workers for concurrent tasks
+// ErrPoolStopped is returned for tasks submitted to a stopped or draining pool
+var ErrPoolStopped = errors.New("worker pool stopped")
+
+// WorkerPool represents a pool of workers for concurrent tasks. A pool
+// started once can run the batches of many callers with Execute, each key
+// limited to its own number of concurrent tasks. The pool is not tied to a
+// result type, tasks of any type share its workers.
 type WorkerPool struct {
 	workers   int
-	taskChan  chan Task
-	resultChan chan Result
+	taskChan  chan job
 	wg        sync.WaitGroup
 	ctx       context.Context
 	cancel    context.CancelFunc
+	
+	// stopped is set under stateMu once no more tasks are accepted, submitters
+	// hold it for reading so the task channel is never closed under them
+	stateMu   sync.RWMutex
+	stopped   bool
+	closeOnce sync.Once
+	done      chan struct{}
+	
+	// limits holds a semaphore per key set with SetLimit
+	limitsMu  sync.Mutex
+	limits    map[string]chan struct{}
+	busy      atomic.Int64
+	completed atomic.Uint64
 }
 
-// Task represents a unit of work
-type Task struct {
+// Task represents a unit of work producing a T
+type Task[T any] struct {
 	ID   int
-	Work func() (interface{}, error)
+	Work func() (T, error)
 }
 
 // Result represents the result of a task
-type Result struct {
+type Result[T any] struct {
 	ID     int
-	Value  interface{}
+	Value  T
 	Error  error
 	Elapsed time.Duration
 }
 
+// job is a queued task together with where its result goes
+type job interface {
+	// run runs the task, a panic becomes its error
+	run()
+	// deliver sends the result, giving up when done is closed
+	deliver(done <-chan struct{})
+	// drop is called instead of run for a task left in the queue when the
+	// pool stopped or its caller gave up
+	drop()
+	// abandoned reports whether the caller gave up before the task started
+	abandoned() bool
+}
+
+// taskJob is the job of a Task[T]
+type taskJob[T any] struct {
+	task    Task[T]
+	result  Result[T]
+	results chan<- Result[T]
+	// release frees the slot of the task's key, nil without a limit
+	release func()
+	// ctx is done once the caller no longer waits for the result, nil for
+	// tasks sent with Submit
+	ctx context.Context
+}
+
+func (j *taskJob[T]) run() {
+	start := time.Now()
+	j.result.ID = j.task.ID
+	j.result.Value, j.result.Error = runTask(j.task)
+	j.result.Elapsed = time.Since(start)
+	j.drop()
+}
+
+func (j *taskJob[T]) drop() {
+	if j.release != nil {
+		j.release()
+	}
+}
+
+func (j *taskJob[T]) abandoned() bool {
+	return j.ctx != nil && j.ctx.Err() != nil
+}
+
+func (j *taskJob[T]) deliver(done <-chan struct{}) {
+	select {
+	case j.results <- j.result:
+	case <-done:
+	}
+}
+
+// PoolStats is a snapshot of the utilization of a worker pool
+type PoolStats struct {
+	Workers int
+	// Busy is the number of workers running a task
+	Busy int
+	// Queued is the number of submitted tasks no worker picked up yet
+	Queued int
+	// Completed is the number of tasks run since the pool was created
+	Completed uint64
+}
+
+// defaultParallelism is the number of workers used by ExecuteWithTimeout
+const defaultParallelism = 4
+
 // NewWorkerPool creates a new worker pool
 func NewWorkerPool(workers int) *WorkerPool {
+	return newWorkerPool(workers, workers*2)
+}
+
+// newWorkerPool creates a worker pool whose task channel holds buffer entries
+func newWorkerPool(workers, buffer int) *WorkerPool {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &WorkerPool{
 		workers:    workers,
-		taskChan:   make(chan Task, workers*2),
-		resultChan: make(chan Result, workers*2),
+		taskChan:   make(chan job, buffer),
 		ctx:        ctx,
 		cancel:     cancel,
+		limits:     make(map[string]chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
@@ -51,26 +143,92 @@ func (wp *WorkerPool) Start() {
 	}
 }
 
-// Stop stops the worker pool
+// Stop stops the worker pool. Running tasks finish, queued tasks are
+// dropped. Stopping a stopped pool does nothing.
 func (wp *WorkerPool) Stop() {
 	wp.cancel()
-	close(wp.taskChan)
-	wp.wg.Wait()
-	close(wp.resultChan)
+	wp.shutdown()
 }
 
-// Submit submits a task to the worker pool
-func (wp *WorkerPool) Submit(task Task) {
+// Drain stops accepting tasks and waits until the queued and running tasks
+// finished, their results are still delivered and must be received. When ctx is done first,
+// queued tasks are dropped and ctx's error is returned without waiting for
+// the running tasks.
+func (wp *WorkerPool) Drain(ctx context.Context) error {
+	go wp.shutdown()
+	
 	select {
-	case wp.taskChan <- task:
+	case <-wp.done:
+		return nil
+	case <-ctx.Done():
+		wp.cancel()
+		return ctx.Err()
+	}
+}
+
+// shutdown closes the task channel once, waits for the workers to exit and
+// drops the tasks they left in the queue
+func (wp *WorkerPool) shutdown() {
+	wp.closeOnce.Do(func() {
+		wp.stateMu.Lock()
+		wp.stopped = true
+		close(wp.taskChan)
+		wp.stateMu.Unlock()
+		
+		wp.wg.Wait()
+		for j := range wp.taskChan {
+			j.drop()
+		}
+		close(wp.done)
+	})
+}
+
+// Submit submits a task to the worker pool, its result is sent to results.
+// It returns ErrPoolStopped when the pool is stopped or draining.
+func Submit[T any](wp *WorkerPool, task Task[T], results chan<- Result[T]) error {
+	return wp.submit(context.Background(), &taskJob[T]{task: task, results: results})
+}
+
+// submit queues j until ctx is done
+func (wp *WorkerPool) submit(ctx context.Context, j job) error {
+	wp.stateMu.RLock()
+	defer wp.stateMu.RUnlock()
+	
+	if wp.stopped {
+		return ErrPoolStopped
+	}
+	select {
+	case wp.taskChan <- j:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	case <-wp.ctx.Done():
 		// Pool is being stopped
+		return ErrPoolStopped
+	}
+}
+
+// SetLimit limits how many tasks of key run at once in Execute, zero or less
+// removes the limit. Tasks already running keep their previous limit.
+func (wp *WorkerPool) SetLimit(key string, limit int) {
+	wp.limitsMu.Lock()
+	defer wp.limitsMu.Unlock()
+	
+	if limit <= 0 {
+		delete(wp.limits, key)
+		return
 	}
+	wp.limits[key] = make(chan struct{}, limit)
 }
 
-// Results returns a channel for receiving results
-func (wp *WorkerPool) Results() <-chan Result {
-	return wp.resultChan
+// Stats returns the current utilization of the pool
+func (wp *WorkerPool) Stats() PoolStats {
+	return PoolStats{
+		Workers:   wp.workers,
+		Busy:      int(wp.busy.Load()),
+		Queued:    len(wp.taskChan),
+		Completed: wp.completed.Load(),
+	}
 }
 
 // worker processes tasks from the task channel
@@ -79,27 +237,20 @@ func (wp *WorkerPool) worker(id int) {
 	
 	for {
 		select {
-		case task, ok := <-wp.taskChan:
+		case j, ok := <-wp.taskChan:
 			if !ok {
 				return // Channel closed, worker should exit
 			}
-			
-			start := time.Now()
-			value, err := task.Work()
-			elapsed := time.Since(start)
-			
-			result := Result{
-				ID:      task.ID,
-				Value:   value,
-				Error:   err,
-				Elapsed: elapsed,
+			if j.abandoned() {
+				j.drop()
+				continue
 			}
 			
-			select {
-			case wp.resultChan <- result:
-			case <-wp.ctx.Done():
-				return
-			}
+			wp.busy.Add(1)
+			j.run()
+			wp.busy.Add(-1)
+			wp.completed.Add(1)
+			j.deliver(wp.ctx.Done())
 			
 		case <-wp.ctx.Done():
 			return
@@ -107,40 +258,91 @@ func (wp *WorkerPool) worker(id int) {
 	}
 }
 
+// runTask runs task, turning a panic into its error so the worker survives
+// and the result is still delivered
+func runTask[T any](task Task[T]) (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero T
+			value, err = zero, &PanicError{ID: task.ID, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	
+	return task.Work()
+}
+
 // ExecuteWithTimeout executes tasks with a timeout
-func ExecuteWithTimeout(ctx context.Context, tasks []Task, timeout time.Duration) ([]Result, error) {
+func ExecuteWithTimeout[T any](ctx context.Context, tasks []Task[T], timeout time.Duration) ([]Result[T], error) {
+	return ExecuteWithLimit(ctx, tasks, timeout, defaultParallelism)
+}
+
+// ExecuteWithLimit executes tasks with a timeout, running at most parallelism tasks at once
+func ExecuteWithLimit[T any](ctx context.Context, tasks []Task[T], timeout time.Duration, parallelism int) ([]Result[T], error) {
 	if len(tasks) == 0 {
 		return nil, nil
 	}
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
 	
-	// Create worker pool with appropriate number of workers
-	workers := min(len(tasks), 4) // Limit to 4 concurrent workers
-	pool := NewWorkerPool(workers)
+	// Create worker pool with appropriate number of workers. The channels hold
+	// every task so submitting never blocks on busy workers.
+	workers := min(len(tasks), parallelism)
+	pool := newWorkerPool(workers, len(tasks))
 	pool.Start()
 	defer pool.Stop()
 	
-	// Submit tasks
-	for i, task := range tasks {
-		task.ID = i
-		pool.Submit(task)
+	return Execute(ctx, pool, "", tasks, timeout)
+}
+
+// Execute runs tasks on the started pool and returns their results in the
+// order of tasks. At most the limit set for key run at once, other callers
+// share the remaining workers. Tasks not started when ctx is done or timeout
+// passes are dropped, workers skip them instead of running them.
+func Execute[T any](ctx context.Context, wp *WorkerPool, key string, tasks []Task[T], timeout time.Duration) ([]Result[T], error) {
+	if len(tasks) == 0 {
+		return nil, nil
 	}
 	
+	// Workers finishing after the caller gave up must not block, so the
+	// channel holds every result
+	replies := make(chan Result[T], len(tasks))
+	submitCtx, cancel := context.WithCancel(ctx)
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- submitAll(submitCtx, wp, key, tasks, replies)
+	}()
+	// Nothing is submitted after returning, the caller may stop the pool
+	defer func() {
+		cancel()
+		if submitted != nil {
+			<-submitted
+		}
+	}()
+	
 	// Collect results with timeout
-	results := make([]Result, len(tasks))
+	results := make([]Result[T], len(tasks))
 	received := 0
 	
-	resultTimeout := time.After(timeout)
+	resultTimeout := time.NewTimer(timeout)
+	defer resultTimeout.Stop()
 	
 	for received < len(tasks) {
 		select {
-		case result := <-pool.Results():
+		case result := <-replies:
 			results[result.ID] = result
 			received++
 			
+		case err := <-submitted:
+			submitted = nil
+			if err != nil && ctx.Err() == nil {
+				return nil, err
+			}
+			
 		case <-ctx.Done():
 			return nil, ctx.Err()
 			
-		case <-resultTimeout:
+		case <-resultTimeout.C:
 			return nil, &TimeoutError{
 				Timeout:    timeout,
 				Received:   received,
@@ -153,6 +355,46 @@ func ExecuteWithTimeout(ctx context.Context, tasks []Task, timeout time.Duration
 	return results, nil
 }
 
+// submitAll submits tasks numbered by their index, waiting for a free slot
+// of key's limit before each, until ctx is done or the pool stopped
+func submitAll[T any](ctx context.Context, wp *WorkerPool, key string, tasks []Task[T], replies chan<- Result[T]) error {
+	wp.limitsMu.Lock()
+	sem := wp.limits[key]
+	wp.limitsMu.Unlock()
+	
+	for i, task := range tasks {
+		task.ID = i
+		j := &taskJob[T]{task: task, results: replies, ctx: ctx}
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			// The slot is freed once the task ran or was dropped
+			j.release = func() { <-sem }
+		}
+		
+		if err := wp.submit(ctx, j); err != nil {
+			j.drop()
+			return err
+		}
+	}
+	return nil
+}
+
+// PanicError is the error of a task whose Work panicked
+type PanicError struct {
+	ID    int
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task %d panicked: %v", e.ID, e.Value)
+}
+
 // TimeoutError represents a timeout error
 type TimeoutError struct {
 	Timeout   time.Duration
@@ -167,12 +409,4 @@ func (e *TimeoutError) Error() string {
 
 func (e *TimeoutError) TimeoutReached() bool {
 	return true
-}
-
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
 }
\ No newline at end of file
@@ -0,0 +1,242 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// limitedTasks returns n tasks recording the highest number of them running
+// at once in peak
+func limitedTasks(n int, peak *atomic.Int64) []Task[int] {
+	var running atomic.Int64
+	tasks := make([]Task[int], n)
+	for i := range tasks {
+		i := i
+		tasks[i] = Task[int]{Work: func() (int, error) {
+			now := running.Add(1)
+			defer running.Add(-1)
+			for {
+				old := peak.Load()
+				if now <= old || peak.CompareAndSwap(old, now) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return i, nil
+		}}
+	}
+	return tasks
+}
+
+func TestWorkerPoolExecuteLimitsEachKey(t *testing.T) {
+	pool := NewWorkerPool(8)
+	pool.Start()
+	defer pool.Stop()
+	pool.SetLimit("192.168.31.1", 2)
+	pool.SetLimit("192.168.32.1", 3)
+
+	var wg sync.WaitGroup
+	for key, limit := range map[string]int64{"192.168.31.1": 2, "192.168.32.1": 3} {
+		key, limit := key, limit
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var peak atomic.Int64
+			results, err := Execute(context.Background(), pool, key, limitedTasks(8, &peak), time.Second)
+			if err != nil {
+				t.Errorf("%s: %v", key, err)
+				return
+			}
+			for i, result := range results {
+				if result.Value != i {
+					t.Errorf("%s: result %d has value %v, want results in task order", key, i, result.Value)
+				}
+			}
+			if got := peak.Load(); got > limit {
+				t.Errorf("%s: %d tasks ran at once, want at most %d", key, got, limit)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := pool.Stats(); stats.Completed != 16 || stats.Busy != 0 || stats.Workers != 8 {
+		t.Fatalf("got stats %+v, want 16 completed tasks on 8 idle workers", stats)
+	}
+}
+
+func TestExecuteWithTimeoutRecoversPanics(t *testing.T) {
+	tasks := []Task[string]{
+		{Work: func() (string, error) { panic("boom") }},
+		{Work: func() (string, error) { return "ok", nil }},
+	}
+
+	results, err := ExecuteWithLimit(context.Background(), tasks, time.Second, 1)
+	if err != nil {
+		t.Fatalf("got %v, want the results of both tasks", err)
+	}
+	var panicErr *PanicError
+	if !errors.As(results[0].Error, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("got error %v, want a PanicError with the panic value", results[0].Error)
+	}
+	// The only worker survived the panic
+	if results[0].Value != "" || results[1].Error != nil || results[1].Value != "ok" {
+		t.Fatalf("got %+v for the second task, want its value", results[1])
+	}
+}
+
+func TestWorkerPoolSubmitAfterStop(t *testing.T) {
+	pool := NewWorkerPool(2)
+	pool.Start()
+	pool.Stop()
+	// Stopping twice is harmless
+	pool.Stop()
+
+	noop := Task[struct{}]{Work: func() (struct{}, error) { return struct{}{}, nil }}
+	if err := Submit(pool, noop, make(chan Result[struct{}], 1)); !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("Submit after Stop returned %v, want ErrPoolStopped", err)
+	}
+	_, err := Execute(context.Background(), pool, "", []Task[struct{}]{noop}, time.Second)
+	if !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("Execute after Stop returned %v, want ErrPoolStopped", err)
+	}
+}
+
+func TestWorkerPoolDrainRunsQueuedTasks(t *testing.T) {
+	pool := NewWorkerPool(1)
+	pool.Start()
+
+	release := make(chan struct{})
+	results := make(chan Result[int], 2)
+	for i := 0; i < 2; i++ {
+		i := i
+		err := Submit(pool, Task[int]{ID: i, Work: func() (int, error) {
+			<-release
+			return i * 10, nil
+		}}, results)
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+
+	// The first task is running, the second one queued
+	for pool.Stats().Busy != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- pool.Drain(context.Background()) }()
+	close(release)
+
+	if err := <-drained; err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if result := <-results; result.Error != nil || result.Value != result.ID*10 {
+			t.Fatalf("got %+v, want the value of task %d", result, result.ID)
+		}
+	}
+	if err := Submit(pool, Task[int]{Work: func() (int, error) { return 0, nil }}, results); !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("Submit after Drain returned %v, want ErrPoolStopped", err)
+	}
+}
+
+func TestWorkerPoolDrainGivesUp(t *testing.T) {
+	pool := NewWorkerPool(1)
+	pool.Start()
+
+	release := make(chan struct{})
+	defer close(release)
+	Submit(pool, Task[int]{Work: func() (int, error) {
+		<-release
+		return 0, nil
+	}}, make(chan Result[int], 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline of the drain", err)
+	}
+}
+
+func TestWorkerPoolDropReleasesLimit(t *testing.T) {
+	pool := NewWorkerPool(1)
+	pool.Start()
+	pool.SetLimit("192.168.31.1", 2)
+
+	release := make(chan struct{})
+	tasks := make([]Task[int], 2)
+	for i := range tasks {
+		tasks[i] = Task[int]{Work: func() (int, error) {
+			<-release
+			return 0, nil
+		}}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Execute(ctx, pool, "192.168.31.1", tasks, time.Minute)
+
+	// The first task is running, the second one queued holding a slot
+	for stats := pool.Stats(); stats.Busy != 1 || stats.Queued != 1; stats = pool.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+
+	expired, cancelDrain := context.WithCancel(context.Background())
+	cancelDrain()
+	if err := pool.Drain(expired); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the error of the drain context", err)
+	}
+	close(release)
+	<-pool.done
+
+	if held := len(pool.limits["192.168.31.1"]); held != 0 {
+		t.Fatalf("%d slots still held after the queued task was dropped, want 0", held)
+	}
+}
+
+func TestExecuteSkipsAbandonedTasks(t *testing.T) {
+	pool := NewWorkerPool(1)
+	pool.Start()
+
+	release := make(chan struct{})
+	var ran atomic.Int64
+	tasks := []Task[int]{
+		{Work: func() (int, error) {
+			<-release
+			return 0, nil
+		}},
+		{Work: func() (int, error) {
+			ran.Add(1)
+			return 1, nil
+		}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	executed := make(chan error, 1)
+	go func() {
+		_, err := Execute(ctx, pool, "", tasks, time.Minute)
+		executed <- err
+	}()
+
+	// The first task is running, the second one queued
+	for stats := pool.Stats(); stats.Busy != 1 || stats.Queued != 1; stats = pool.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-executed; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the error of the cancelled context", err)
+	}
+	close(release)
+	if err := pool.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	if n := ran.Load(); n != 0 {
+		t.Fatalf("the queued task ran %d times after its caller gave up, want 0", n)
+	}
+	if completed := pool.Stats().Completed; completed != 1 {
+		t.Fatalf("got %d completed tasks, want only the running one", completed)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -17,6 +18,10 @@ type Config struct {
 	DisableKeepAlives   bool          `json:"disable_keep_alives" default:"false"`
 	MaxConnsPerHost     int           `json:"max_conns_per_host" default:"100"`
 	DisableCompression  bool          `json:"disable_compression" default:"false"`
+	// ProxyURL routes every request through an http, https, socks5 or
+	// socks5h proxy. Nil uses the HTTP_PROXY and HTTPS_PROXY environment
+	// variables.
+	ProxyURL *url.URL `json:"-"`
 }
 
 // DefaultConfig returns default HTTP client configuration
@@ -39,8 +44,13 @@ func NewOptimizedClient(cfg *Config) *http.Client {
 		cfg = DefaultConfig()
 	}
 
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != nil {
+		proxy = http.ProxyURL(cfg.ProxyURL)
+	}
+
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: proxy,
 		DialContext: (&net.Dialer{
 			Timeout:   cfg.Timeout,
 			KeepAlive: 30 * time.Second,
@@ -79,11 +89,12 @@ func NewMetricsClient(cfg *Config, metricsCollector MetricsCollector) *http.Clie
 	return client
 }
 
-// MetricsCollector defines the interface for HTTP metrics collection
+// MetricsCollector defines the interface for HTTP metrics collection.
+// endpoint is the logical name returned by EndpointLabel, never the raw URL.
 type MetricsCollector interface {
-	RecordRequestDuration(method, url string, duration time.Duration, statusCode int)
-	RecordRequestSize(method, url string, size int64)
-	RecordResponseSize(method, url string, size int64)
+	RecordRequestDuration(method, endpoint string, duration time.Duration, statusCode int)
+	RecordRequestSize(method, endpoint string, size int64)
+	RecordResponseSize(method, endpoint string, size int64)
 }
 
 // MetricsTransport wraps http.Transport to collect metrics
@@ -103,10 +114,11 @@ func NewMetricsTransport(transport http.RoundTripper, metricsCollector MetricsCo
 // RoundTrip implements http.RoundTripper
 func (m *MetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	start := time.Now()
+	endpoint := EndpointLabel(req.URL)
 	
 	// Record request size
 	if req.ContentLength > 0 {
-		m.metricsCollector.RecordRequestSize(req.Method, req.URL.String(), req.ContentLength)
+		m.metricsCollector.RecordRequestSize(req.Method, endpoint, req.ContentLength)
 	}
 	
 	resp, err := m.transport.RoundTrip(req)
@@ -114,15 +126,15 @@ func (m *MetricsTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	
 	if err == nil {
 		// Record successful request
-		m.metricsCollector.RecordRequestDuration(req.Method, req.URL.String(), duration, resp.StatusCode)
+		m.metricsCollector.RecordRequestDuration(req.Method, endpoint, duration, resp.StatusCode)
 		
 		// Record response size
 		if resp.ContentLength > 0 {
-			m.metricsCollector.RecordResponseSize(req.Method, req.URL.String(), resp.ContentLength)
+			m.metricsCollector.RecordResponseSize(req.Method, endpoint, resp.ContentLength)
 		}
 	} else {
 		// Record failed request
-		m.metricsCollector.RecordRequestDuration(req.Method, req.URL.String(), duration, 0)
+		m.metricsCollector.RecordRequestDuration(req.Method, endpoint, duration, 0)
 	}
 	
 	return resp, err
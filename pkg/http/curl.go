@@ -0,0 +1,114 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// formSecretPattern matches form fields masked in curl commands. The
+	// login nonce is masked too, with it the password hash can be brute
+	// forced offline.
+	formSecretPattern = regexp.MustCompile(`(?i)(password|passwd|pwd|token|stok|secret|nonce)`)
+	// headerSecretPattern matches headers masked in curl commands
+	headerSecretPattern = regexp.MustCompile(`(?i)(authorization|cookie|token|secret|key)`)
+)
+
+// CurlTransport passes every request to log as an equivalent curl command
+// with secrets masked, so users can reproduce router requests by hand
+type CurlTransport struct {
+	transport http.RoundTripper
+	log       func(command string)
+}
+
+// NewCurlTransport creates a transport logging each request before sending
+// it with transport
+func NewCurlTransport(transport http.RoundTripper, log func(command string)) *CurlTransport {
+	return &CurlTransport{
+		transport: transport,
+		log:       log,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (c *CurlTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	c.log(CurlCommand(req, body))
+	return c.transport.RoundTrip(req)
+}
+
+// CurlCommand returns a curl command line sending req with body. The stok
+// token, credential headers and secret form fields are masked.
+func CurlCommand(req *http.Request, body []byte) string {
+	var b strings.Builder
+	b.WriteString("curl")
+	if req.Method != http.MethodGet && !(req.Method == http.MethodPost && len(body) > 0) {
+		b.WriteString(" -X " + req.Method)
+	}
+	b.WriteString(" " + shellQuote(SanitizeURL(req.URL)))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			if headerSecretPattern.MatchString(name) {
+				value = scrubbedValue
+			}
+			b.WriteString(" -H " + shellQuote(name+": "+value))
+		}
+	}
+
+	if len(body) > 0 {
+		b.WriteString(" --data-raw " + shellQuote(sanitizeBody(req.Header.Get("Content-Type"), body)))
+	}
+	return b.String()
+}
+
+// sanitizeBody masks secret fields of a form or JSON request body
+func sanitizeBody(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/x-www-form-urlencoded" {
+		scrubbed := ScrubJSON(body)
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, scrubbed); err == nil {
+			return compact.String()
+		}
+		return string(scrubbed)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return scrubbedValue
+	}
+	for key := range form {
+		if formSecretPattern.MatchString(key) {
+			form.Set(key, scrubbedValue)
+		}
+	}
+	return strings.ReplaceAll(form.Encode(), url.QueryEscape(scrubbedValue), scrubbedValue)
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
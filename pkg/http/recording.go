@@ -0,0 +1,162 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// scrubbedValue replaces secrets in recorded responses
+const scrubbedValue = "***"
+
+var (
+	secretKeyPattern   = regexp.MustCompile(`(?i)(password|passwd|pwd|token|stok|secret)`)
+	stokInValuePattern = regexp.MustCompile(`;stok=[^/"]*`)
+)
+
+// EndpointFileName maps a router API path such as
+// /cgi-bin/luci/;stok=xxx/api/misystem/status to misystem_status.json
+func EndpointFileName(path string) (string, bool) {
+	idx := strings.Index(path, "/api/")
+	if idx < 0 {
+		return "", false
+	}
+
+	endpoint := strings.Trim(path[idx+len("/api/"):], "/")
+	if endpoint == "" {
+		return "", false
+	}
+
+	return strings.ReplaceAll(endpoint, "/", "_") + ".json", true
+}
+
+// RecordingTransport saves every router API response to a directory with
+// secrets scrubbed, so users can attach them to bug reports
+type RecordingTransport struct {
+	transport http.RoundTripper
+	dir       string
+	mu        sync.Mutex
+}
+
+// NewRecordingTransport creates a transport recording responses into dir
+func NewRecordingTransport(transport http.RoundTripper, dir string) (*RecordingTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	return &RecordingTransport{
+		transport: transport,
+		dir:       dir,
+	}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	name, ok := EndpointFileName(req.URL.Path)
+	if !ok {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Recording is best-effort and must never break collection
+	_ = os.WriteFile(filepath.Join(r.dir, name), ScrubJSON(body), 0o644)
+
+	return resp, nil
+}
+
+// ScrubJSON replaces password and token values in a JSON document. Input
+// that is not valid JSON is returned with only stok path segments removed.
+func ScrubJSON(body []byte) []byte {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return stokInValuePattern.ReplaceAll(body, []byte(";stok="+scrubbedValue))
+	}
+
+	out, err := json.MarshalIndent(scrubValue(doc), "", "  ")
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func scrubValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if secretKeyPattern.MatchString(key) {
+				if _, isString := child.(string); isString {
+					v[key] = scrubbedValue
+					continue
+				}
+			}
+			v[key] = scrubValue(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = scrubValue(child)
+		}
+		return v
+	case string:
+		return stokInValuePattern.ReplaceAllString(v, ";stok="+scrubbedValue)
+	default:
+		return v
+	}
+}
+
+// ReplayTransport serves router API responses from files written by
+// RecordingTransport instead of contacting the router
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport creates a transport replaying responses from dir
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, ok := EndpointFileName(req.URL.Path)
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for %s", SanitizeURL(req.URL))
+	}
+
+	body, err := os.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s: %w", name, err)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
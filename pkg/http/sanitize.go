@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// otherEndpoint labels requests that are not router API calls
+const otherEndpoint = "other"
+
+var endpointNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// EndpointLabel maps a router request URL to a logical endpoint name such as
+// "devicelist" or "wan_info", for use as a metric label. The stok path
+// segment is never part of the result, and unrecognised paths collapse to
+// "other" so the label cardinality stays bounded.
+func EndpointLabel(u *url.URL) string {
+	if u == nil {
+		return otherEndpoint
+	}
+
+	path := strings.TrimRight(u.Path, "/")
+	if strings.HasSuffix(path, "/cgi-bin/luci/web") {
+		return "web"
+	}
+
+	idx := strings.Index(path, "/api/")
+	if idx < 0 {
+		return otherEndpoint
+	}
+
+	endpoint := path[idx+len("/api/"):]
+	if slash := strings.LastIndex(endpoint, "/"); slash >= 0 {
+		endpoint = endpoint[slash+1:]
+	}
+	if !endpointNamePattern.MatchString(endpoint) {
+		return otherEndpoint
+	}
+	return endpoint
+}
+
+// SanitizeURL returns u as a string with the stok token and any credential
+// query parameters replaced, so it can be logged safely
+func SanitizeURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	sanitized := *u
+	sanitized.User = nil
+	sanitized.Path = stokInValuePattern.ReplaceAllString(u.Path, ";stok="+scrubbedValue)
+	sanitized.RawPath = ""
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if secretKeyPattern.MatchString(key) {
+				query.Set(key, scrubbedValue)
+			}
+		}
+		sanitized.RawQuery = query.Encode()
+	}
+
+	return sanitized.String()
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/helloworlde/miwifi-exporter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingTransport records a client span for every request, named after the
+// logical endpoint so the stok token never reaches the tracing backend
+type TracingTransport struct {
+	transport http.RoundTripper
+}
+
+// NewTracingTransport wraps transport with request spans
+func NewTracingTransport(transport http.RoundTripper) *TracingTransport {
+	return &TracingTransport{transport: transport}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !tracing.Enabled() {
+		return t.transport.RoundTrip(req)
+	}
+
+	endpoint := EndpointLabel(req.URL)
+	_, span := tracing.Start(req.Context(), req.Method+" "+endpoint, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.request.method", req.Method),
+		attribute.String("router.endpoint", endpoint),
+		attribute.String("server.address", req.URL.Hostname()),
+	)
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		tracing.RecordError(span, statusError(resp.Status))
+	}
+	return resp, nil
+}
+
+// statusError reports an unsuccessful HTTP status on a span
+type statusError string
+
+func (e statusError) Error() string {
+	return "HTTP " + string(e)
+}
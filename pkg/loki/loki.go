@@ -0,0 +1,222 @@
+// Package loki pushes log lines to Grafana Loki using its JSON push API
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxQueuedEntries bounds the memory used while Loki is slow or
+	// unreachable; further entries are dropped
+	maxQueuedEntries = 4096
+	// maxBatchSize is the number of entries that triggers a push before the
+	// flush interval passes
+	maxBatchSize = 1000
+	// flushInterval is how often queued entries are pushed
+	flushInterval = 2 * time.Second
+	// pushTimeout bounds a single push request
+	pushTimeout = 10 * time.Second
+	// pushPath is the path of the push API below the Loki base URL
+	pushPath = "/loki/api/v1/push"
+)
+
+// Entry is one log line of the stream identified by its labels
+type Entry struct {
+	Labels map[string]string
+	Time   time.Time
+	Line   string
+}
+
+// Pusher sends entries to Loki. Entries are queued and pushed in batches by
+// a background goroutine.
+type Pusher struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	queue    chan Entry
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	onError  func(error)
+}
+
+// NewPusher creates a pusher for the Loki at url, for example
+// http://loki:3100, and starts its background goroutine. headers are added to
+// every push request, for example X-Scope-OrgID.
+func NewPusher(url string, headers map[string]string) *Pusher {
+	url = strings.TrimRight(url, "/")
+	if !strings.HasSuffix(url, pushPath) {
+		url += pushPath
+	}
+
+	p := &Pusher{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: pushTimeout},
+		queue:   make(chan Entry, maxQueuedEntries),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// OnError sets a callback for failed pushes. It must be called before the
+// first entry is pushed.
+func (p *Pusher) OnError(fn func(error)) {
+	p.onError = fn
+}
+
+// Push queues an entry. It returns false if the entry was dropped because
+// the queue is full.
+func (p *Pusher) Push(entry Entry) bool {
+	select {
+	case p.queue <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown pushes the queued entries and stops the pusher, waiting at most
+// until ctx is done
+func (p *Pusher) Shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		p.stopOnce.Do(func() { close(p.done) })
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pusher) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, maxBatchSize)
+	push := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.push(batch); err != nil && p.onError != nil {
+			p.onError(err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-p.queue:
+			batch = append(batch, entry)
+			if len(batch) >= maxBatchSize {
+				push()
+			}
+		case <-ticker.C:
+			push()
+		case flushed := <-p.flush:
+			for drained := false; !drained; {
+				select {
+				case entry := <-p.queue:
+					batch = append(batch, entry)
+				default:
+					drained = true
+				}
+			}
+			push()
+			close(flushed)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// push posts one batch of entries, grouped into streams by their labels
+func (p *Pusher) push(entries []Entry) error {
+	var payload pushRequest
+	streams := make(map[string]int)
+	for _, entry := range entries {
+		key := streamKey(entry.Labels)
+		i, ok := streams[key]
+		if !ok {
+			i = len(payload.Streams)
+			streams[key] = i
+			payload.Streams = append(payload.Streams, stream{Stream: entry.Labels})
+		}
+		payload.Streams[i].Values = append(payload.Streams[i].Values,
+			[2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), entry.Line})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode log entries: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push %d log entries: %w", len(entries), err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to push %d log entries: Loki answered %s: %s", len(entries), resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// streamKey identifies the stream of a label set
+func streamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(name)
+		key.WriteByte(0)
+		key.WriteString(labels[name])
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// The types below follow the JSON encoding of the Loki push API
+
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
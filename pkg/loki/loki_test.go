@@ -0,0 +1,173 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a Loki push API keeping the requests it got
+type receiver struct {
+	mu       sync.Mutex
+	requests []pushRequest
+	headers  []http.Header
+	pushed   chan struct{}
+	status   int
+}
+
+func newReceiver(t *testing.T, status int) (*receiver, *httptest.Server) {
+	t.Helper()
+
+	rc := &receiver{pushed: make(chan struct{}, 16), status: status}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != pushPath {
+			t.Errorf("got %s %s, want POST %s", r.Method, r.URL.Path, pushPath)
+		}
+		var request pushRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding the push request: %v", err)
+		}
+
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, request)
+		rc.headers = append(rc.headers, r.Header.Clone())
+		rc.mu.Unlock()
+
+		if rc.status != http.StatusNoContent {
+			http.Error(w, "entry too far behind", rc.status)
+		} else {
+			w.WriteHeader(rc.status)
+		}
+		rc.pushed <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	return rc, server
+}
+
+func (rc *receiver) received() ([]pushRequest, []http.Header) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]pushRequest(nil), rc.requests...), append([]http.Header(nil), rc.headers...)
+}
+
+func shutdown(t *testing.T, p *Pusher) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestPushBatches(t *testing.T) {
+	rc, server := newReceiver(t, http.StatusNoContent)
+	p := NewPusher(server.URL+"/", map[string]string{"X-Scope-OrgID": "home"})
+
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < maxBatchSize+1; i++ {
+		entry := Entry{
+			Labels: map[string]string{"job": "miwifi_syslog", "level": "info"},
+			Time:   start.Add(time.Duration(i) * time.Millisecond),
+			Line:   "line " + strconv.Itoa(i),
+		}
+		if !p.Push(entry) {
+			t.Fatalf("entry %d dropped, want it queued", i)
+		}
+	}
+
+	// A full batch is pushed without waiting for the flush interval
+	select {
+	case <-rc.pushed:
+	case <-time.After(flushInterval / 2):
+		t.Fatal("no push after a full batch")
+	}
+	requests, headers := rc.received()
+	if len(requests) != 1 || len(requests[0].Streams) != 1 || len(requests[0].Streams[0].Values) != maxBatchSize {
+		t.Fatalf("got %+v, want one stream of %d entries", requests, maxBatchSize)
+	}
+	if header := headers[0].Get("X-Scope-OrgID"); header != "home" {
+		t.Errorf("got X-Scope-OrgID %q, want the configured header", header)
+	}
+	if ct := headers[0].Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", ct)
+	}
+	first := requests[0].Streams[0].Values[0]
+	if first[0] != strconv.FormatInt(start.UnixNano(), 10) || first[1] != "line 0" {
+		t.Errorf("got first value %q, want the entry's time in nanoseconds and its line", first)
+	}
+
+	// Shutting down pushes the rest
+	shutdown(t, p)
+	requests, _ = rc.received()
+	if len(requests) != 2 || len(requests[1].Streams) != 1 || len(requests[1].Streams[0].Values) != 1 {
+		t.Fatalf("got %d requests, want the remaining entry pushed on shutdown", len(requests))
+	}
+	if last := requests[1].Streams[0].Values[0][1]; last != "line "+strconv.Itoa(maxBatchSize) {
+		t.Errorf("got %q, want the last entry", last)
+	}
+}
+
+func TestPushGroupsStreamsByLabels(t *testing.T) {
+	rc, server := newReceiver(t, http.StatusNoContent)
+	p := NewPusher(server.URL+pushPath, nil)
+
+	now := time.Now()
+	entries := []Entry{
+		{Labels: map[string]string{"job": "miwifi_syslog", "app": "dnsmasq"}, Time: now, Line: "a"},
+		{Labels: map[string]string{"job": "miwifi_devices"}, Time: now, Line: "b"},
+		{Labels: map[string]string{"app": "dnsmasq", "job": "miwifi_syslog"}, Time: now, Line: "c"},
+		// A value containing the other label must not collide with it
+		{Labels: map[string]string{"job": "miwifi_syslog\x00app\x00dnsmasq"}, Time: now, Line: "d"},
+	}
+	for _, entry := range entries {
+		p.Push(entry)
+	}
+	shutdown(t, p)
+
+	requests, _ := rc.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want one batch", len(requests))
+	}
+	lines := make(map[string]string)
+	for _, s := range requests[0].Streams {
+		var values []string
+		for _, value := range s.Values {
+			values = append(values, value[1])
+		}
+		lines[s.Stream["job"]+"/"+s.Stream["app"]] = strings.Join(values, ",")
+	}
+	want := map[string]string{
+		"miwifi_syslog/dnsmasq":            "a,c",
+		"miwifi_devices/":                  "b",
+		"miwifi_syslog\x00app\x00dnsmasq/": "d",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got streams %q, want %q", lines, want)
+	}
+	for stream, values := range want {
+		if lines[stream] != values {
+			t.Errorf("got %q in stream %q, want %q", lines[stream], stream, values)
+		}
+	}
+}
+
+func TestPushReportsErrors(t *testing.T) {
+	_, server := newReceiver(t, http.StatusBadRequest)
+	p := NewPusher(server.URL, nil)
+
+	var errs []error
+	p.OnError(func(err error) { errs = append(errs, err) })
+	p.Push(Entry{Labels: map[string]string{"job": "miwifi_syslog"}, Time: time.Now(), Line: "a"})
+	shutdown(t, p)
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "400 Bad Request: entry too far behind") {
+		t.Fatalf("got errors %v, want the status and message of Loki", errs)
+	}
+}
@@ -3,6 +3,7 @@ package memory
 import (
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -17,7 +18,9 @@ type MemoryMonitor struct {
 	poolStats         *prometheus.GaugeVec
 	allocationCounter *prometheus.CounterVec
 	
-	// Memory pools
+	// Memory pools, created on first use
+	poolsOnce       sync.Once
+	poolsReady      atomic.Bool
 	bufferPool      *BufferPool
 	jsonPool        *ObjectPool
 	requestPool     *ObjectPool
@@ -25,8 +28,6 @@ type MemoryMonitor struct {
 	
 	// Tracking
 	mu              sync.RWMutex
-	lastGC          time.Time
-	gcCount         uint32
 	allocations     map[string]int64
 	optimizations   map[string]int64
 	
@@ -78,10 +79,6 @@ func NewMemoryMonitor(namespace string) *MemoryMonitor {
 			},
 			[]string{"type", "action"},
 		),
-		bufferPool:      NewBufferPool(),
-		jsonPool:        NewObjectPool(func() interface{} { return map[string]interface{}{} }),
-		requestPool:     NewObjectPool(func() interface{} { return []byte{} }),
-		responsePool:    NewObjectPool(func() interface{} { return []byte{} }),
 		allocations:     make(map[string]int64),
 		optimizations:   make(map[string]int64),
 		trackAllocations: true,
@@ -89,8 +86,19 @@ func NewMemoryMonitor(namespace string) *MemoryMonitor {
 	}
 }
 
+// initPools creates the memory pools on first use
+func (mm *MemoryMonitor) initPools() {
+	mm.poolsOnce.Do(func() {
+		mm.bufferPool = NewBufferPool()
+		mm.jsonPool = NewObjectPool(func() interface{} { return map[string]interface{}{} })
+		mm.requestPool = NewObjectPool(func() interface{} { return []byte{} })
+		mm.responsePool = NewObjectPool(func() interface{} { return []byte{} })
+		mm.poolsReady.Store(true)
+	})
+}
+
 // Configure configures the memory monitor with settings
-func (mm *MemoryMonitor) Configure(enabled, optimizeOnCollect, forceGCOnClose, trackAllocations, enablePoolStats bool) {
+func (mm *MemoryMonitor) Configure(trackAllocations, enablePoolStats bool) {
 	mm.trackAllocations = trackAllocations
 	mm.enableGCStats = enablePoolStats
 }
@@ -143,6 +151,10 @@ func (mm *MemoryMonitor) updateMetrics() {
 
 // updatePoolStats updates memory pool statistics
 func (mm *MemoryMonitor) updatePoolStats() {
+	if !mm.poolsReady.Load() {
+		return
+	}
+	
 	// Buffer pool stats
 	smallCreated, smallReused := mm.bufferPool.small.Stats()
 	mediumCreated, mediumReused := mm.bufferPool.medium.Stats()
@@ -213,21 +225,25 @@ func (mm *MemoryMonitor) RecordOptimization(typeName string, bytesSaved int64) {
 
 // GetBuffer returns a buffer from the pool
 func (mm *MemoryMonitor) GetBuffer(size int) []byte {
+	mm.initPools()
 	return mm.bufferPool.GetBuffer(size)
 }
 
 // PutBuffer returns a buffer to the pool
 func (mm *MemoryMonitor) PutBuffer(buf []byte) {
+	mm.initPools()
 	mm.bufferPool.PutBuffer(buf)
 }
 
 // GetJSONObject returns a JSON object from the pool
 func (mm *MemoryMonitor) GetJSONObject() map[string]interface{} {
+	mm.initPools()
 	return mm.jsonPool.Get().(map[string]interface{})
 }
 
 // PutJSONObject returns a JSON object to the pool
 func (mm *MemoryMonitor) PutJSONObject(obj map[string]interface{}) {
+	mm.initPools()
 	// Clear the object before returning to pool
 	for k := range obj {
 		delete(obj, k)
@@ -237,33 +253,28 @@ func (mm *MemoryMonitor) PutJSONObject(obj map[string]interface{}) {
 
 // GetRequestBuffer returns a request buffer from the pool
 func (mm *MemoryMonitor) GetRequestBuffer() []byte {
+	mm.initPools()
 	return mm.requestPool.Get().([]byte)
 }
 
 // PutRequestBuffer returns a request buffer to the pool
 func (mm *MemoryMonitor) PutRequestBuffer(buf []byte) {
+	mm.initPools()
 	mm.requestPool.Put(buf[:0])
 }
 
 // GetResponseBuffer returns a response buffer from the pool
 func (mm *MemoryMonitor) GetResponseBuffer() []byte {
+	mm.initPools()
 	return mm.responsePool.Get().([]byte)
 }
 
 // PutResponseBuffer returns a response buffer to the pool
 func (mm *MemoryMonitor) PutResponseBuffer(buf []byte) {
+	mm.initPools()
 	mm.responsePool.Put(buf[:0])
 }
 
-// ForceGC forces garbage collection and updates stats
-func (mm *MemoryMonitor) ForceGC() {
-	runtime.GC()
-	mm.mu.Lock()
-	mm.lastGC = time.Now()
-	mm.gcCount++
-	mm.mu.Unlock()
-}
-
 // GetStats returns memory statistics
 func (mm *MemoryMonitor) GetStats() map[string]interface{} {
 	mm.mu.RLock()
@@ -280,12 +291,15 @@ func (mm *MemoryMonitor) GetStats() map[string]interface{} {
 		"num_gc":            m.NumGC,
 		"pause_total_ns":    m.PauseTotalNs,
 		"next_gc":           m.NextGC,
-		"gc_count":          mm.gcCount,
-		"last_gc":           mm.lastGC,
+		"last_gc":           time.Unix(0, int64(m.LastGC)),
 		"allocations":       len(mm.allocations),
 		"optimizations":     mm.optimizations,
 	}
 	
+	if !mm.poolsReady.Load() {
+		return stats
+	}
+	
 	// Add pool stats
 	bufferCreated, bufferReused := mm.bufferPool.Stats()
 	stats["buffer_pool_created"] = bufferCreated
@@ -298,24 +312,6 @@ func (mm *MemoryMonitor) GetStats() map[string]interface{} {
 	return stats
 }
 
-// OptimizeMemory performs memory optimizations
-func (mm *MemoryMonitor) OptimizeMemory() {
-	// Force garbage collection
-	mm.ForceGC()
-	
-	// Reset pools if they're too large
-	mm.resetPoolsIfNeeded()
-	
-	// Record optimization
-	mm.RecordOptimization("gc_optimization", 0)
-}
-
-// resetPoolsIfNeeded resets pools if they've grown too large
-func (mm *MemoryMonitor) resetPoolsIfNeeded() {
-	// This could be enhanced with logic to track pool sizes
-	// and reset them when they exceed certain thresholds
-}
-
 // MemoryUsageSnapshot captures a snapshot of current memory usage
 type MemoryUsageSnapshot struct {
 	Timestamp       time.Time              `json:"timestamp"`
@@ -363,6 +359,10 @@ func (mm *MemoryMonitor) TakeSnapshot() *MemoryUsageSnapshot {
 		snapshot.Optimizations[k] = v
 	}
 	
+	if !mm.poolsReady.Load() {
+		return snapshot
+	}
+	
 	// Add pool stats
 	bufferCreated, bufferReused := mm.bufferPool.Stats()
 	snapshot.PoolStats["buffer_created"] = bufferCreated
@@ -0,0 +1,266 @@
+// Package notify sends notifications about router events to webhooks and
+// push services: a generic JSON webhook, Telegram, Bark and ServerChan.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Events that can be notified, the values of NOTIFY_EVENTS
+const (
+	EventRouterUnreachable = "router_unreachable"
+	EventNewDevice         = "new_device"
+	EventFirmwareUpdate    = "firmware_update"
+)
+
+// Notification is one message sent to every channel
+type Notification struct {
+	// Event is the configured event the notification belongs to
+	Event   string    `json:"event"`
+	Host    string    `json:"host"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// channel delivers notifications to one service
+type channel interface {
+	name() string
+	send(ctx context.Context, client *http.Client, n Notification) error
+}
+
+// Notifier sends notifications to the configured channels. Sending happens
+// in the background, so reporting an event never delays a collection.
+type Notifier struct {
+	config   config.NotifyConfig
+	channels []channel
+	client   *http.Client
+	wg       sync.WaitGroup
+	sent     *prometheus.CounterVec
+}
+
+// New returns a notifier for the channels configured in cfg, or nil if
+// there is none
+func New(cfg config.NotifyConfig, namespace string) *Notifier {
+	var channels []channel
+	if cfg.WebhookURL != "" {
+		channels = append(channels, webhook{url: cfg.WebhookURL})
+	}
+	if cfg.TelegramToken != "" {
+		channels = append(channels, telegram{token: cfg.TelegramToken, chatID: cfg.TelegramChatID})
+	}
+	if cfg.BarkURL != "" {
+		channels = append(channels, bark{url: cfg.BarkURL})
+	}
+	if cfg.ServerChanKey != "" {
+		channels = append(channels, serverChan{key: cfg.ServerChanKey})
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	n := &Notifier{
+		config:   cfg,
+		channels: channels,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		sent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "notifications_total",
+				Help:      "发送的事件通知数，result 为 success 或 failure",
+			},
+			[]string{"channel", "result"},
+		),
+	}
+	for _, c := range channels {
+		n.sent.WithLabelValues(c.name(), "success")
+		n.sent.WithLabelValues(c.name(), "failure")
+	}
+	return n
+}
+
+func (n *Notifier) Describe(ch chan<- *prometheus.Desc) {
+	n.sent.Describe(ch)
+}
+
+func (n *Notifier) Collect(ch chan<- prometheus.Metric) {
+	n.sent.Collect(ch)
+}
+
+// Enabled reports whether notifications of event are sent. It is false for
+// a nil notifier.
+func (n *Notifier) Enabled(event string) bool {
+	return n != nil && n.config.Enabled(event)
+}
+
+// Notify sends notification to every channel in the background if its event
+// is enabled. Failures are logged and counted.
+func (n *Notifier) Notify(notification Notification) {
+	if !n.Enabled(notification.Event) {
+		return
+	}
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+
+	for _, c := range n.channels {
+		n.wg.Add(1)
+		go func(c channel) {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), n.config.Timeout)
+			defer cancel()
+			if err := c.send(ctx, n.client, notification); err != nil {
+				n.sent.WithLabelValues(c.name(), "failure").Inc()
+				logger.Default.Warnf("Failed to send %s notification to %s: %v", notification.Event, c.name(), err)
+				return
+			}
+			n.sent.WithLabelValues(c.name(), "success").Inc()
+		}(c)
+	}
+}
+
+// Shutdown waits for the notifications still being sent, at most until ctx
+// is done
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// webhook posts the notification as JSON
+type webhook struct {
+	url string
+}
+
+func (webhook) name() string { return "webhook" }
+
+func (w webhook) send(ctx context.Context, client *http.Client, n Notification) error {
+	return postJSON(ctx, client, w.url, n, nil)
+}
+
+// telegram sends the notification as message of a bot
+type telegram struct {
+	token  string
+	chatID string
+}
+
+func (telegram) name() string { return "telegram" }
+
+func (t telegram) send(ctx context.Context, client *http.Client, n Notification) error {
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err := postJSON(ctx, client, "https://api.telegram.org/bot"+t.token+"/sendMessage", map[string]string{
+		"chat_id": t.chatID,
+		"text":    n.Title + "\n" + n.Message,
+	}, &result)
+	if err == nil && !result.OK {
+		err = fmt.Errorf("telegram answered: %s", result.Description)
+	}
+	return err
+}
+
+// bark pushes the notification to an iOS device with the Bark app. The URL
+// contains the device key.
+type bark struct {
+	url string
+}
+
+func (bark) name() string { return "bark" }
+
+func (b bark) send(ctx context.Context, client *http.Client, n Notification) error {
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err := postJSON(ctx, client, strings.TrimRight(b.url, "/"), map[string]string{
+		"title": n.Title,
+		"body":  n.Message,
+		"group": "miwifi",
+	}, &result)
+	if err == nil && result.Code != 0 && result.Code != http.StatusOK {
+		err = fmt.Errorf("bark answered %d: %s", result.Code, result.Message)
+	}
+	return err
+}
+
+// serverChan forwards the notification to WeChat with ServerChan
+type serverChan struct {
+	key string
+}
+
+func (serverChan) name() string { return "serverchan" }
+
+func (s serverChan) send(ctx context.Context, client *http.Client, n Notification) error {
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err := postJSON(ctx, client, "https://sctapi.ftqq.com/"+s.key+".send", map[string]string{
+		"title": n.Title,
+		"desp":  n.Message,
+	}, &result)
+	if err == nil && result.Code != 0 {
+		err = fmt.Errorf("serverchan answered %d: %s", result.Code, result.Message)
+	}
+	return err
+}
+
+// postJSON posts payload to target and decodes the answer into result, if
+// not nil. Errors never contain target, the URLs of most channels contain
+// their secret.
+func postJSON(ctx context.Context, client *http.Client, target string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("answered %s: %s", resp.Status, bytes.TrimSpace(answer))
+	}
+	if result != nil && len(bytes.TrimSpace(answer)) > 0 {
+		if err := json.Unmarshal(answer, result); err != nil {
+			return fmt.Errorf("failed to decode answer: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// request is a notification request received by the test server
+type request struct {
+	// url is the URL the notifier requested, before it was sent to the
+	// test server
+	url         string
+	contentType string
+	body        map[string]interface{}
+}
+
+// recorder answers every request sent through its transport, whatever the
+// host, and keeps them
+type recorder struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []request
+}
+
+func newRecorder(t *testing.T) *recorder {
+	t.Helper()
+
+	rec := &recorder{}
+	rec.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("decoding %s: %v", body, err)
+		}
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, request{
+			url:         r.Header.Get("X-Original-URL"),
+			contentType: r.Header.Get("Content-Type"),
+			body:        payload,
+		})
+		rec.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	t.Cleanup(rec.server.Close)
+	return rec
+}
+
+// RoundTrip sends req to the test server, recording the URL it was for
+func (rec *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, _ := url.Parse(rec.server.URL)
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Original-URL", req.URL.String())
+	req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (rec *recorder) received() []request {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]request(nil), rec.requests...)
+}
+
+func sentTotal(t *testing.T, n *Notifier, channel, result string) float64 {
+	t.Helper()
+
+	metric := &dto.Metric{}
+	if err := n.sent.WithLabelValues(channel, result).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestNewWithoutChannels(t *testing.T) {
+	n := New(config.NotifyConfig{Events: []string{EventNewDevice}, Timeout: time.Second}, "miwifi")
+	if n != nil {
+		t.Fatal("got a notifier without channels, want nil")
+	}
+	// A nil notifier sends nothing
+	if n.Enabled(EventNewDevice) {
+		t.Fatal("nil notifier enabled")
+	}
+	n.Notify(Notification{Event: EventNewDevice})
+}
+
+func TestNotifyPayloads(t *testing.T) {
+	rec := newRecorder(t)
+	n := New(config.NotifyConfig{
+		WebhookURL:     "http://alerts.example.com/hook",
+		TelegramToken:  "123:secret",
+		TelegramChatID: "-1001",
+		Events:         []string{EventNewDevice, EventRouterUnreachable},
+		Timeout:        time.Second,
+	}, "miwifi")
+	n.client = &http.Client{Transport: rec, Timeout: time.Second}
+
+	if !n.Enabled(EventNewDevice) || n.Enabled(EventFirmwareUpdate) {
+		t.Fatal("got the wrong events enabled, want those of the configuration")
+	}
+	// Disabled events are not sent
+	n.Notify(Notification{Event: EventFirmwareUpdate, Title: "firmware"})
+
+	at := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	n.Notify(Notification{
+		Event:   EventNewDevice,
+		Host:    "192.168.31.1",
+		Title:   "New device",
+		Message: "iPhone (aa:bb:cc:dd:ee:ff) joined",
+		Time:    at,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	requests := make(map[string]request)
+	for _, r := range rec.received() {
+		requests[r.url] = r
+	}
+	if len(requests) != 2 {
+		t.Fatalf("got requests %+v, want one per channel for the enabled event", requests)
+	}
+
+	hook, ok := requests["http://alerts.example.com/hook"]
+	if !ok {
+		t.Fatalf("no webhook request in %+v", requests)
+	}
+	wantHook := map[string]interface{}{
+		"event":   EventNewDevice,
+		"host":    "192.168.31.1",
+		"title":   "New device",
+		"message": "iPhone (aa:bb:cc:dd:ee:ff) joined",
+		"time":    "2024-03-01T08:30:00Z",
+	}
+	if len(hook.body) != len(wantHook) {
+		t.Errorf("got webhook payload %v, want %v", hook.body, wantHook)
+	}
+	for key, value := range wantHook {
+		if hook.body[key] != value {
+			t.Errorf("got webhook %s %v, want %v", key, hook.body[key], value)
+		}
+	}
+
+	tg, ok := requests["https://api.telegram.org/bot123:secret/sendMessage"]
+	if !ok {
+		t.Fatalf("no Telegram request in %+v", requests)
+	}
+	if tg.body["chat_id"] != "-1001" || tg.body["text"] != "New device\niPhone (aa:bb:cc:dd:ee:ff) joined" {
+		t.Errorf("got Telegram payload %v, want the chat and the title above the message", tg.body)
+	}
+
+	for _, r := range requests {
+		if r.contentType != "application/json" {
+			t.Errorf("got Content-Type %q for %s, want application/json", r.contentType, r.url)
+		}
+	}
+	for _, channel := range []string{"webhook", "telegram"} {
+		if got := sentTotal(t, n, channel, "success"); got != 1 {
+			t.Errorf("got %v successful %s notifications, want 1", got, channel)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// icmpPayload identifies the exporter's echo requests in packet captures
+var icmpPayload = []byte("miwifi-exporter")
+
+// icmpPinger sends ICMP echo requests. It prefers unprivileged ping sockets
+// and falls back to raw sockets, which need root or CAP_NET_RAW.
+type icmpPinger struct {
+	privileged bool
+	timeout    time.Duration
+	id         int
+	seq        atomic.Uint32
+}
+
+// newICMPPinger returns a pinger using the first socket type the exporter is
+// allowed to open
+func newICMPPinger(timeout time.Duration) (*icmpPinger, error) {
+	p := &icmpPinger{timeout: timeout, id: os.Getpid() & 0xffff}
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		p.privileged = true
+		conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ICMP probes are not permitted, allow pings with the net.ipv4.ping_group_range sysctl, grant CAP_NET_RAW or use PROBE_MODE=tcp: %w", err)
+	}
+	conn.Close()
+	return p, nil
+}
+
+func (p *icmpPinger) Ping(ctx context.Context, address string) (time.Duration, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return 0, fmt.Errorf("%q is not an IP address", address)
+	}
+
+	network, listen, protocol := "udp4", "0.0.0.0", 1
+	var request, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.To4() == nil {
+		network, listen, protocol = "udp6", "::", 58
+		request, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if p.privileged {
+		network = "ip4:icmp"
+		if protocol == 58 {
+			network = "ip6:ipv6-icmp"
+		}
+		dst = &net.IPAddr{IP: ip}
+	}
+
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(p.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	// Unblock the read when ctx is cancelled before the deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	seq := int(p.seq.Add(1) & 0xffff)
+	message := icmp.Message{Type: request, Body: &icmp.Echo{ID: p.id, Seq: seq, Data: icmpPayload}}
+	packet, err := message.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(packet, dst); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		answer, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || answer.Type != reply {
+			continue
+		}
+		// Ping sockets replace the ID with their port, raw sockets see the
+		// replies to every process on the host
+		echo, ok := answer.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (p.privileged && echo.ID != p.id) {
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
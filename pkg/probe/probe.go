@@ -0,0 +1,149 @@
+// Package probe measures the round trip time and packet loss from the
+// exporter host to the router and beyond it, which tells a bad WiFi or LAN
+// link from a bad ISP.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rttBuckets range from a wired LAN to a congested uplink, in seconds
+var rttBuckets = []float64{.0005, .001, .002, .005, .01, .02, .05, .1, .2, .5, 1}
+
+// Target is an address probed under a name, such as the router
+type Target struct {
+	Name    string
+	Address string
+}
+
+// pinger sends one probe to address and returns its round trip time
+type pinger interface {
+	Ping(ctx context.Context, address string) (time.Duration, error)
+}
+
+// Prober probes the targets in rounds and exports the results
+type Prober struct {
+	config  config.ProbeConfig
+	targets func() []Target
+	pinger  pinger
+
+	rtt  *prometheus.HistogramVec
+	sent *prometheus.CounterVec
+	lost *prometheus.CounterVec
+}
+
+// New returns a prober of the targets returned by targets, which is called
+// before every round. ICMP probes fail when the exporter may not send pings.
+func New(cfg config.ProbeConfig, namespace string, targets func() []Target) (*Prober, error) {
+	p := &Prober{
+		config:  cfg,
+		targets: targets,
+		rtt: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "probe_rtt_seconds",
+				Help:      "从 exporter 主机到目标的往返时间",
+				Buckets:   rttBuckets,
+			},
+			[]string{"target"},
+		),
+		sent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "probe_packets_sent_total",
+				Help:      "发送的探测总数",
+			},
+			[]string{"target"},
+		),
+		lost: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "probe_packets_lost_total",
+				Help:      "超时或失败的探测总数",
+			},
+			[]string{"target"},
+		),
+	}
+
+	switch cfg.Mode {
+	case "tcp":
+		p.pinger = &tcpPinger{port: cfg.TCPPort, timeout: cfg.Timeout}
+	case "icmp":
+		icmp, err := newICMPPinger(cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		p.pinger = icmp
+	default:
+		return nil, fmt.Errorf("unknown probe mode %q", cfg.Mode)
+	}
+	return p, nil
+}
+
+func (p *Prober) Describe(ch chan<- *prometheus.Desc) {
+	p.rtt.Describe(ch)
+	p.sent.Describe(ch)
+	p.lost.Describe(ch)
+}
+
+func (p *Prober) Collect(ch chan<- prometheus.Metric) {
+	p.rtt.Collect(ch)
+	p.sent.Collect(ch)
+	p.lost.Collect(ch)
+}
+
+// Run probes the targets every interval until ctx is cancelled
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeAll runs one round, probing the targets in parallel so a lossy
+// target does not delay the others
+func (p *Prober) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, target := range p.targets() {
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			p.probe(ctx, target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// probe sends the probes of one round to target, one after another
+func (p *Prober) probe(ctx context.Context, target Target) {
+	// Export the loss from the first round on, also while it is zero
+	lost := p.lost.WithLabelValues(target.Name)
+	for i := 0; i < p.config.Count && ctx.Err() == nil; i++ {
+		rtt, err := p.pinger.Ping(ctx, target.Address)
+		if err != nil && ctx.Err() != nil {
+			// Shutting down, the probe is not counted
+			return
+		}
+		p.sent.WithLabelValues(target.Name).Inc()
+		if err != nil {
+			lost.Inc()
+			logger.Default.Debugf("Probe of %s (%s) failed: %v", target.Name, target.Address, err)
+			continue
+		}
+		p.rtt.WithLabelValues(target.Name).Observe(rtt.Seconds())
+	}
+}
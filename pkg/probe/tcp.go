@@ -0,0 +1,33 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// tcpPinger measures the time to establish a TCP connection. It needs no
+// privileges but also measures the target's network stack.
+type tcpPinger struct {
+	port    int
+	timeout time.Duration
+}
+
+func (p *tcpPinger) Ping(ctx context.Context, address string) (time.Duration, error) {
+	dialer := net.Dialer{Timeout: p.timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(p.port)))
+	rtt := time.Since(start)
+	if err != nil {
+		// A refused connection is an answer from the target as well
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return rtt, nil
+		}
+		return 0, err
+	}
+	conn.Close()
+	return rtt, nil
+}
@@ -0,0 +1,124 @@
+package probe
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMain(m *testing.M) {
+	logger.Default = logger.NewWithOutput("error", "text", io.Discard)
+	m.Run()
+}
+
+// listen accepts connections on a local port until the test ends
+func listen(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func probeCounts(t *testing.T, p *Prober, target string) (sent, lost float64, observed uint64) {
+	t.Helper()
+
+	metric := &dto.Metric{}
+	if err := p.sent.WithLabelValues(target).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	sent = metric.GetCounter().GetValue()
+	if err := p.lost.WithLabelValues(target).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	lost = metric.GetCounter().GetValue()
+	histogram, err := p.rtt.GetMetricWithLabelValues(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metric = &dto.Metric{}
+	if err := histogram.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return sent, lost, metric.GetHistogram().GetSampleCount()
+}
+
+func TestTCPProbe(t *testing.T) {
+	port := listen(t)
+	targets := []Target{{Name: "router", Address: "127.0.0.1"}}
+	p, err := New(config.ProbeConfig{
+		Mode:    "tcp",
+		Count:   3,
+		Timeout: 100 * time.Millisecond,
+		TCPPort: port,
+	}, "miwifi", func() []Target { return targets })
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	p.probeAll(context.Background())
+	if sent, lost, observed := probeCounts(t, p, "router"); sent != 3 || lost != 0 || observed != 3 {
+		t.Errorf("got %v sent, %v lost and %d round trips, want 3, 0 and 3", sent, lost, observed)
+	}
+
+	// Every round probes the targets returned at its start
+	targets = append(targets, Target{Name: "gateway", Address: "127.0.0.1"})
+	p.probeAll(context.Background())
+	if sent, _, observed := probeCounts(t, p, "router"); sent != 6 || observed != 6 {
+		t.Errorf("got %v sent and %d round trips to the router after two rounds, want 6", sent, observed)
+	}
+	if sent, _, observed := probeCounts(t, p, "gateway"); sent != 3 || observed != 3 {
+		t.Errorf("got %v sent and %d round trips to the added target, want 3", sent, observed)
+	}
+}
+
+func TestTCPProbeRefused(t *testing.T) {
+	// A port nobody listens on anymore
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	pinger := &tcpPinger{port: port, timeout: time.Second}
+	if _, err := pinger.Ping(context.Background(), "127.0.0.1"); err != nil {
+		t.Fatalf("got %v, want a refused connection to count as an answer", err)
+	}
+}
+
+func TestTCPProbeCancelled(t *testing.T) {
+	port := listen(t)
+	p, err := New(config.ProbeConfig{Mode: "tcp", Count: 3, Timeout: time.Second, TCPPort: port}, "miwifi",
+		func() []Target { return []Target{{Name: "router", Address: "127.0.0.1"}} })
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Probes interrupted by shutting down are not counted as lost
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.probeAll(ctx)
+	if sent, lost, _ := probeCounts(t, p, "router"); sent != 0 || lost != 0 {
+		t.Errorf("got %v sent and %v lost after cancelling, want none", sent, lost)
+	}
+}
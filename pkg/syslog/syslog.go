@@ -0,0 +1,212 @@
+// Package syslog receives the system log of the router as a remote syslog
+// target, counts the events worth alerting on and optionally forwards the
+// lines to a file or Loki.
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/pkg/loki"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxMessageSize is the largest syslog datagram read, longer ones are
+// truncated
+const maxMessageSize = 8192
+
+// events are the log lines counted by syslog_events_total. The patterns
+// match the messages of dnsmasq, hostapd and netifd on OpenWrt based
+// firmwares.
+var events = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"dhcp_nak", regexp.MustCompile(`DHCPNAK`)},
+	{"wifi_deauth", regexp.MustCompile(`(?i)\bdeauthenticated\b|\bdisassociated\b`)},
+	{"wan_down", regexp.MustCompile(`Interface 'wan\w*' is now down`)},
+	{"wan_reconnect", regexp.MustCompile(`Interface 'wan\w*' is now up`)},
+}
+
+// severities are the names of the syslog severity levels, used as Loki level
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var (
+	// priPattern matches the priority every syslog message starts with
+	priPattern = regexp.MustCompile(`^<(\d{1,3})>`)
+	// appPattern matches the tag of a BSD syslog message, e.g. dnsmasq[123]:
+	appPattern = regexp.MustCompile(`(?:^|\s)([A-Za-z][\w./-]*)(?:\[\d+\])?: `)
+)
+
+// Receiver listens for syslog messages from the router
+type Receiver struct {
+	config config.SyslogConfig
+	host   string
+	loki   *loki.Pusher
+
+	fileMu sync.Mutex
+	file   *os.File
+
+	messages      prometheus.Counter
+	events        *prometheus.CounterVec
+	forwardErrors *prometheus.CounterVec
+}
+
+// New returns a receiver for the router host. Lines are pushed to pusher
+// when forwarding to Loki is enabled, pusher may be nil otherwise.
+func New(cfg config.SyslogConfig, namespace, host string, pusher *loki.Pusher) (*Receiver, error) {
+	r := &Receiver{
+		config: cfg,
+		host:   host,
+		messages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "syslog_messages_total",
+			Help:      "收到的路由器 syslog 日志总数",
+		}),
+		events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "syslog_events_total",
+				Help:      "路由器日志中出现的事件总数",
+			},
+			[]string{"event"},
+		),
+		forwardErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "syslog_forward_errors_total",
+				Help:      "转发路由器日志失败的次数",
+			},
+			[]string{"target"},
+		),
+	}
+	// Export the events from the start, also while they never happened
+	for _, event := range events {
+		r.events.WithLabelValues(event.name)
+	}
+
+	if cfg.ForwardFile != "" {
+		file, err := os.OpenFile(cfg.ForwardFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open syslog forward file: %w", err)
+		}
+		r.file = file
+		r.forwardErrors.WithLabelValues("file")
+	}
+	if cfg.ForwardLoki && pusher != nil {
+		r.loki = pusher
+		r.forwardErrors.WithLabelValues("loki")
+	}
+	return r, nil
+}
+
+func (r *Receiver) Describe(ch chan<- *prometheus.Desc) {
+	r.messages.Describe(ch)
+	r.events.Describe(ch)
+	r.forwardErrors.Describe(ch)
+}
+
+func (r *Receiver) Collect(ch chan<- prometheus.Metric) {
+	r.messages.Collect(ch)
+	r.events.Collect(ch)
+	r.forwardErrors.Collect(ch)
+}
+
+// Run receives messages until ctx is cancelled. It fails when the listen
+// address cannot be bound.
+func (r *Receiver) Run(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", r.config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen for syslog messages: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	logger.Default.Infof("Receiving router logs on udp %s", conn.LocalAddr())
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to receive syslog message: %w", err)
+		}
+		r.handle(buf[:n], time.Now())
+	}
+}
+
+// Close closes the forward file
+func (r *Receiver) Close() error {
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// handle counts and forwards one received message
+func (r *Receiver) handle(packet []byte, received time.Time) {
+	line := string(bytes.TrimRight(packet, "\r\n\x00"))
+	if line == "" {
+		return
+	}
+	r.messages.Inc()
+
+	severity, text := "", line
+	if match := priPattern.FindStringSubmatch(line); match != nil {
+		priority, _ := strconv.Atoi(match[1])
+		severity = severities[priority%8]
+		text = line[len(match[0]):]
+	}
+
+	for _, event := range events {
+		if event.pattern.MatchString(text) {
+			r.events.WithLabelValues(event.name).Inc()
+		}
+	}
+
+	r.writeFile(text)
+	if r.loki != nil {
+		labels := map[string]string{"job": "miwifi_syslog", "host": r.host}
+		if severity != "" {
+			labels["level"] = severity
+		}
+		if match := appPattern.FindStringSubmatch(text); match != nil {
+			labels["app"] = match[1]
+		}
+		// Only lines dropped from a full queue are counted here, failed
+		// pushes are reported by the owner of the pusher
+		if !r.loki.Push(loki.Entry{Labels: labels, Time: received, Line: text}) {
+			r.forwardErrors.WithLabelValues("loki").Inc()
+		}
+	}
+}
+
+// writeFile appends a line to the forward file, if any
+func (r *Receiver) writeFile(text string) {
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	if _, err := r.file.WriteString(text + "\n"); err != nil {
+		r.forwardErrors.WithLabelValues("file").Inc()
+		logger.Default.Warnf("Failed to write router log to %s: %v", r.config.ForwardFile, err)
+	}
+}
@@ -0,0 +1,142 @@
+package syslog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	"github.com/helloworlde/miwifi-exporter/pkg/loki"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// lokiStream is a stream of a push request as decoded by the test server
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func counter(t *testing.T, r *Receiver, event string) float64 {
+	t.Helper()
+
+	metric := &dto.Metric{}
+	if err := r.events.WithLabelValues(event).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestHandleParsesLines(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		streams []lokiStream
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Streams []lokiStream `json:"streams"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding the push request: %v", err)
+		}
+		mu.Lock()
+		streams = append(streams, request.Streams...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	forwardFile := filepath.Join(t.TempDir(), "router.log")
+	pusher := loki.NewPusher(server.URL, nil)
+	r, err := New(config.SyslogConfig{ForwardFile: forwardFile, ForwardLoki: true}, "miwifi", "192.168.31.1", pusher)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer r.Close()
+
+	received := time.Unix(1700000000, 0)
+	packets := []string{
+		"<30>Nov 14 22:13:20 dnsmasq-dhcp[2345]: DHCPNAK(br-lan) 192.168.31.20 aa:bb:cc:dd:ee:ff wrong network\n",
+		"<29>Nov 14 22:13:21 netifd: Interface 'wan' is now down\r\n",
+		"<29>Nov 14 22:13:25 netifd: Interface 'wan6' is now up\x00",
+		"hostapd: wl0: STA aa:bb:cc:dd:ee:ff IEEE 802.11: deauthenticated due to inactivity",
+		"\n",
+	}
+	for _, packet := range packets {
+		r.handle([]byte(packet), received)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pusher.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	metric := &dto.Metric{}
+	if err := r.messages.Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	if got := metric.GetCounter().GetValue(); got != 4 {
+		t.Errorf("got %v messages, want 4 without the empty one", got)
+	}
+	for _, event := range []string{"dhcp_nak", "wan_down", "wan_reconnect", "wifi_deauth"} {
+		if got := counter(t, r, event); got != 1 {
+			t.Errorf("got %v %s events, want 1", got, event)
+		}
+	}
+
+	// The priority is stripped from the forwarded lines
+	written, err := os.ReadFile(forwardFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(written), "\n"), "\n")
+	want := []string{
+		"Nov 14 22:13:20 dnsmasq-dhcp[2345]: DHCPNAK(br-lan) 192.168.31.20 aa:bb:cc:dd:ee:ff wrong network",
+		"Nov 14 22:13:21 netifd: Interface 'wan' is now down",
+		"Nov 14 22:13:25 netifd: Interface 'wan6' is now up",
+		"hostapd: wl0: STA aa:bb:cc:dd:ee:ff IEEE 802.11: deauthenticated due to inactivity",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got forwarded lines %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("got forwarded line %q, want %q", lines[i], want[i])
+		}
+	}
+
+	// The severity comes from the priority and the app from the tag
+	labels := make(map[string]map[string]string)
+	mu.Lock()
+	for _, s := range streams {
+		for _, value := range s.Values {
+			labels[value[1]] = s.Stream
+		}
+	}
+	mu.Unlock()
+	wantLabels := map[string]map[string]string{
+		want[0]: {"job": "miwifi_syslog", "host": "192.168.31.1", "level": "info", "app": "dnsmasq-dhcp"},
+		want[1]: {"job": "miwifi_syslog", "host": "192.168.31.1", "level": "notice", "app": "netifd"},
+		want[3]: {"job": "miwifi_syslog", "host": "192.168.31.1", "app": "hostapd"},
+	}
+	for line, wantStream := range wantLabels {
+		got := labels[line]
+		if len(got) != len(wantStream) {
+			t.Errorf("got labels %v for %q, want %v", got, line, wantStream)
+			continue
+		}
+		for name, value := range wantStream {
+			if got[name] != value {
+				t.Errorf("got labels %v for %q, want %v", got, line, wantStream)
+				break
+			}
+		}
+	}
+}
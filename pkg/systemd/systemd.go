@@ -0,0 +1,25 @@
+package systemd
+
+import (
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// Logger is the logging interface used by the systemd package
+type Logger interface {
+	Infof(format string, args ...interface{})
+}
+
+// NotifyReady tells systemd that the listeners accept connections, for
+// units with Type=notify. It does nothing when not started by systemd.
+func NotifyReady(logger Logger) {
+	if sent, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+		logger.Infof("Failed to notify systemd about readiness: %v", err)
+	} else if sent {
+		logger.Infof("Notified systemd that the exporter is ready")
+	}
+}
+
+// NotifyStopping tells systemd that the exporter is shutting down
+func NotifyStopping() {
+	daemon.SdNotify(false, daemon.SdNotifyStopping)
+}
@@ -0,0 +1,98 @@
+// Package tracing records spans of refreshes, scrapes and router requests
+// with OpenTelemetry and exports them with OTLP over HTTP. Until Setup
+// installed a tracer provider spans are not recorded.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer creating every span
+const instrumentationName = "github.com/helloworlde/miwifi-exporter"
+
+// Config configures the OTLP exporter
+type Config struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, for example
+	// http://localhost:4318. Spans are posted to Endpoint/v1/traces.
+	Endpoint string
+	// Headers are added to every export request, for example for
+	// authentication
+	Headers map[string]string
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+	// ServiceVersion is reported as the service.version resource attribute
+	ServiceVersion string
+	// Redact, if set, masks secrets in recorded errors before they leave the
+	// process
+	Redact func(string) string
+}
+
+var (
+	enabled atomic.Bool
+	redact  atomic.Pointer[func(string) string]
+)
+
+// Setup installs a tracer provider exporting spans in batches to
+// cfg.Endpoint. Shutting the provider down exports the remaining spans.
+func Setup(cfg Config) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimRight(cfg.Endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []attribute.KeyValue{attribute.String("service.name", cfg.ServiceName)}
+	if cfg.ServiceVersion != "" {
+		attributes = append(attributes, attribute.String("service.version", cfg.ServiceVersion))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attributes...)),
+	)
+
+	if cfg.Redact != nil {
+		redact.Store(&cfg.Redact)
+	}
+	otel.SetTracerProvider(provider)
+	enabled.Store(true)
+	return provider, nil
+}
+
+// Enabled reports whether spans are exported
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Start begins a span named name as a child of the span in ctx and returns a
+// context carrying it
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// RecordError marks span as failed with err, with secrets in the message
+// masked. A nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+
+	message := err.Error()
+	if fn := redact.Load(); fn != nil {
+		message = (*fn)(message)
+	}
+	span.RecordError(errors.New(message))
+	span.SetStatus(codes.Error, message)
+}
@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an OTLP/HTTP receiver keeping the export requests it got
+type collector struct {
+	mu       sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+	headers  []http.Header
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	t.Helper()
+
+	c := &collector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("got %s %s, want POST /v1/traces", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading the export request: %v", err)
+			return
+		}
+		request := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, request); err != nil {
+			t.Errorf("decoding the export request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		c.requests = append(c.requests, request)
+		c.headers = append(c.headers, r.Header.Clone())
+		c.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		response, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+// spans returns the spans exported so far by name, along with the resource
+// attributes they were exported with
+func (c *collector) spans() (map[string]*tracepb.Span, map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	spans := make(map[string]*tracepb.Span)
+	resource := make(map[string]string)
+	for _, request := range c.requests {
+		for _, rs := range request.ResourceSpans {
+			for _, kv := range rs.GetResource().GetAttributes() {
+				resource[kv.Key] = kv.Value.GetStringValue()
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					spans[span.Name] = span
+				}
+			}
+		}
+	}
+	return spans, resource
+}
+
+func attributeValue(attributes []*commonpb.KeyValue, key string) *commonpb.AnyValue {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+func TestExportSpans(t *testing.T) {
+	c, server := newCollector(t)
+	provider, err := Setup(Config{
+		Endpoint:       server.URL + "/",
+		Headers:        map[string]string{"Authorization": "Bearer test"},
+		ServiceName:    "miwifi-exporter",
+		ServiceVersion: "1.2.3",
+		Redact: func(s string) string {
+			return strings.ReplaceAll(s, "secret-token", "***")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if !Enabled() {
+		t.Fatal("tracing not enabled after Setup")
+	}
+
+	ctx, parent := Start(context.Background(), "refresh")
+	_, child := Start(ctx, "router.request")
+	child.SetAttributes(attribute.String("router.api", "misystem/status"), attribute.Int("http.status_code", 502))
+	RecordError(child, errors.New("GET /cgi-bin/luci/;stok=secret-token/api/misystem/status: bad gateway"))
+	RecordError(child, nil)
+	child.End()
+	parent.End()
+
+	// The spans are batched, shutting down the provider exports them
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	spans, resource := c.spans()
+	if resource["service.name"] != "miwifi-exporter" || resource["service.version"] != "1.2.3" {
+		t.Errorf("got resource %v, want the service name and version", resource)
+	}
+	if header := c.headers[0].Get("Authorization"); header != "Bearer test" {
+		t.Errorf("got Authorization %q, want the configured header", header)
+	}
+
+	parentSpan, childSpan := spans["refresh"], spans["router.request"]
+	if parentSpan == nil || childSpan == nil {
+		t.Fatalf("got spans %v, want refresh and router.request", spans)
+	}
+	if string(childSpan.ParentSpanId) != string(parentSpan.SpanId) || string(childSpan.TraceId) != string(parentSpan.TraceId) {
+		t.Errorf("router.request is not a child of refresh")
+	}
+	if value := attributeValue(childSpan.Attributes, "router.api"); value.GetStringValue() != "misystem/status" {
+		t.Errorf("got router.api %v, want misystem/status", value)
+	}
+	if value := attributeValue(childSpan.Attributes, "http.status_code"); value.GetIntValue() != 502 {
+		t.Errorf("got http.status_code %v, want 502", value)
+	}
+
+	status := childSpan.GetStatus()
+	if status.GetCode() != tracepb.Status_STATUS_CODE_ERROR {
+		t.Errorf("got status %v, want an error", status)
+	}
+	if strings.Contains(status.GetMessage(), "secret-token") || !strings.Contains(status.GetMessage(), "stok=***") {
+		t.Errorf("got status message %q, want the token masked", status.GetMessage())
+	}
+	if len(childSpan.Events) != 1 {
+		t.Fatalf("got %d events, want the recorded error only", len(childSpan.Events))
+	}
+	message := attributeValue(childSpan.Events[0].Attributes, "exception.message").GetStringValue()
+	if strings.Contains(message, "secret-token") || !strings.Contains(message, "stok=***") {
+		t.Errorf("got exception message %q, want the token masked", message)
+	}
+	if parentSpan.GetStatus().GetCode() == tracepb.Status_STATUS_CODE_ERROR {
+		t.Errorf("refresh marked as failed, want only the child to be")
+	}
+}
@@ -1,12 +1,20 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
 	"strings"
 )
 
-// InterfaceToFloat64 converts various interface types to float64
+// ErrUnsupportedType is returned by InterfaceToFloat64 for values that are
+// neither numbers nor strings
+var ErrUnsupportedType = errors.New("unsupported type")
+
+// InterfaceToFloat64 converts various interface types to float64. Values of
+// other types, including nil, fail with ErrUnsupportedType rather than
+// converting to 0 unnoticed.
 func InterfaceToFloat64(n interface{}) (float64, error) {
 	switch x := n.(type) {
 	case string:
@@ -28,7 +36,7 @@ func InterfaceToFloat64(n interface{}) (float64, error) {
 	case uint:
 		return float64(x), nil
 	default:
-		return 0.0, nil
+		return 0.0, fmt.Errorf("%w %T", ErrUnsupportedType, n)
 	}
 }
 
@@ -55,6 +63,53 @@ func SubNetMaskToLen(netmask string) (int, error) {
 	return ones, nil
 }
 
+// ParseIPv6Prefix extracts the prefix and its length from a router IPv6 prefix
+// entry, which is either a "prefix/len" string or an object with prefix and
+// mask fields depending on firmware
+func ParseIPv6Prefix(entry interface{}) (string, int, bool) {
+	var prefix, length string
+	
+	switch v := entry.(type) {
+	case string:
+		prefix = v
+	case map[string]interface{}:
+		for _, key := range []string{"prefix", "ip", "address"} {
+			if p, ok := v[key].(string); ok && p != "" {
+				prefix = p
+				break
+			}
+		}
+		for _, key := range []string{"mask", "length", "prefix_len"} {
+			if l, ok := v[key]; ok {
+				if f, err := InterfaceToFloat64(l); err == nil && f > 0 {
+					length = strconv.Itoa(int(f))
+					break
+				}
+			}
+		}
+	default:
+		return "", 0, false
+	}
+	
+	if i := strings.Index(prefix, "/"); i >= 0 {
+		if length == "" {
+			length = prefix[i+1:]
+		}
+		prefix = prefix[:i]
+	}
+	
+	if net.ParseIP(prefix) == nil {
+		return "", 0, false
+	}
+	
+	bits, err := strconv.Atoi(length)
+	if err != nil || bits < 0 || bits > 128 {
+		return "", 0, false
+	}
+	
+	return prefix, bits, true
+}
+
 // ParseCPUFrequency parses CPU frequency string to MHz
 func ParseCPUFrequency(freqStr string) float64 {
 	switch {
@@ -70,6 +125,20 @@ func ParseCPUFrequency(freqStr string) float64 {
 	return 0.0
 }
 
+// ParseFloatSeries parses a comma-separated series such as "120,0,3400".
+// Entries that are not numbers are skipped.
+func ParseFloatSeries(series string) []float64 {
+	var values []float64
+	for _, field := range strings.Split(series, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
 // ParseMemorySize parses memory size string to MB
 func ParseMemorySize(memStr string) float64 {
 	if value, err := strconv.ParseFloat(strings.TrimSuffix(memStr, "MB"), 64); err == nil {
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// serviceName is the name registered with the Windows service manager
+	serviceName        = "miwifi-exporter"
+	serviceDisplayName = "MiWiFi Exporter"
+	serviceDescription = "Prometheus exporter for Xiaomi WiFi routers"
+)
+
+const serviceUsage = `Usage: miwifi-exporter service <command> [flags]
+
+Commands:
+  install [flags]  Register the exporter as a Windows service started with flags
+  uninstall        Remove the Windows service
+  run [flags]      Run as a Windows service, used by the service manager
+`
+
+// runServiceCommand handles the service subcommand. It returns the process
+// exit code.
+func runServiceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, serviceUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		err = installService(args[1:])
+	case "uninstall":
+		err = uninstallService()
+	case "run":
+		err = runService(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, serviceUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "service %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// errServiceUnsupported is returned by the service subcommands outside
+// Windows, where systemd or another init system runs the exporter
+var errServiceUnsupported = errors.New("Windows services are not available on this platform, use a systemd unit instead")
+
+func installService(args []string) error {
+	return errServiceUnsupported
+}
+
+func uninstallService() error {
+	return errServiceUnsupported
+}
+
+func runService(args []string) error {
+	return errServiceUnsupported
+}
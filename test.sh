@@ -40,7 +40,7 @@ trap cleanup SIGINT SIGTERM
 # 检查文件是否存在
 if [ ! -f "miwifi_exporter" ]; then
     echo "错误: miwifi_exporter 二进制文件不存在"
-    echo "请先运行: go build -o miwifi_exporter main.go"
+    echo "请先运行: go build -o miwifi_exporter ."
     exit 1
 fi
 
@@ -52,12 +52,12 @@ fi
 # 1. 构建mock服务器
 echo "步骤 1: 构建mock服务器..."
 cd mock_server
-go build -o mock_server main.go
+go build -o mock_server .
 cd ..
 
 # 2. 启动mock服务器
 echo "步骤 2: 启动mock服务器 (端口 $MOCK_SERVER_PORT)..."
-./mock_server/mock_server $MOCK_SERVER_PORT &
+./mock_server/mock_server -port $MOCK_SERVER_PORT &
 MOCK_SERVER_PID=$!
 echo "Mock服务器PID: $MOCK_SERVER_PID"
 
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+	apperrors "github.com/helloworlde/miwifi-exporter/internal/errors"
+	"github.com/helloworlde/miwifi-exporter/internal/logger"
+	"github.com/helloworlde/miwifi-exporter/pkg/tracing"
+	"go.opentelemetry.io/otel"
+)
+
+// tracingShutdownTimeout bounds the export of the spans still queued on exit
+const tracingShutdownTimeout = 5 * time.Second
+
+// setupTracing installs the OTLP tracer when tracing is enabled. The returned
+// function exports the remaining spans and must be called before exiting.
+func setupTracing(cfg config.TracingConfig) func() {
+	if !cfg.Enabled {
+		return func() {}
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Default.Warnf("Failed to export traces: %v", apperrors.RedactError(err))
+	}))
+	provider, err := tracing.Setup(tracing.Config{
+		Endpoint:       cfg.Endpoint,
+		Headers:        cfg.Headers,
+		ServiceName:    cfg.ServiceName,
+		ServiceVersion: version,
+		Redact:         apperrors.Redact,
+	})
+	if err != nil {
+		logger.Default.Warnf("Tracing disabled, failed to create the OTLP exporter: %v", err)
+		return func() {}
+	}
+	logger.Default.Infof("Exporting traces to %s", cfg.Endpoint)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.Default.Warnf("Failed to export remaining traces: %v", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/helloworlde/miwifi-exporter/internal/config"
+)
+
+// routerDialTimeout bounds the reachability check of validate --check-router
+const routerDialTimeout = 5 * time.Second
+
+// runValidate loads and validates the configuration without starting the
+// exporter. The effective configuration, with defaults filled in and secrets
+// masked, is printed to stdout as JSON; findings go to stderr. It returns the
+// process exit code.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Path to configuration file")
+	checkRouter := fs.Bool("check-router", false, "Also check that the router accepts connections on port 80")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfiguration(*configFile)
+	if err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			fmt.Fprintf(os.Stderr, "[FAIL] configuration has %d problem(s):\n", len(invalid.Problems))
+			for _, problem := range invalid.Problems {
+				fmt.Fprintf(os.Stderr, "       - %s\n", problem)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "[FAIL] configuration: %v\n", err)
+		}
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "[ OK ] configuration is valid")
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cfg.Effective()); err != nil {
+		fmt.Fprintf(os.Stderr, "[FAIL] failed to print configuration: %v\n", err)
+		return 1
+	}
+
+	if *checkRouter {
+		// Through a proxy only the proxy itself can be dialled
+		target, address := "router", net.JoinHostPort(cfg.Router.IP, "80")
+		if cfg.Router.ProxyURL != "" {
+			target, address = "proxy", proxyAddress(cfg.Router.ProxyURL)
+		}
+		timeout := min(cfg.Router.TimeoutOr(cfg.Router.RequestTimeout), routerDialTimeout)
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[FAIL] %s %s is not reachable: %v\n", target, address, err)
+			return 1
+		}
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "[ OK ] %s %s is reachable (%v), run check to test the login\n", target, address, time.Since(start).Round(time.Millisecond))
+	}
+
+	return 0
+}
+
+// proxyAddress returns the host and port of a validated proxy URL, adding the
+// default port of its scheme
+func proxyAddress(proxyURL string) string {
+	u, _ := url.Parse(proxyURL)
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "1080"
+	switch u.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}